@@ -0,0 +1,74 @@
+package hashive
+
+import "github.com/mkch/hashive/internal/impl"
+
+// ACLEntry requires Capability to access the value mapped by Path and all
+// the values in it. See [WithACL].
+type ACLEntry = impl.ACLEntry
+
+// WithACL stores acl in the database. The values requiring capabilities
+// not provided by [WithCapabilities] are hidden from queries: querying
+// them returns [ErrNotFound], and they are removed from the containers
+// returned by queries, or replaced with nil in arrays to keep the indices.
+// Lower-level functions, such as [Hashive.Dump] and [Hashive.KeysPage], do
+// not hide them.
+// ACLs are not a security boundary, hidden values are still in the file.
+func WithACL(acl ...ACLEntry) WriteOption {
+	return func(o *writeOptions) {
+		o.acl = acl
+	}
+}
+
+// WithCapabilities provides capabilities to access the values hidden by
+// the ACL of the database. See [WithACL].
+func WithCapabilities(capabilities ...string) Option {
+	return func(o *options) {
+		o.capabilities = capabilities
+	}
+}
+
+// ACL returns the ACL stored in the database. See [WithACL].
+func (h *Hashive) ACL() []ACLEntry {
+	return h.acl
+}
+
+// aclValue converts acl to the value stored in the header.
+func aclValue(acl []ACLEntry) any {
+	v := make([]any, len(acl))
+	for i, entry := range acl {
+		path := make([]any, len(entry.Path))
+		for j, key := range entry.Path {
+			path[j] = key
+		}
+		v[i] = map[string]any{impl.ACLPath: path, impl.ACLCapability: entry.Capability}
+	}
+	return v
+}
+
+// ignoreACL reads the values hidden by the ACL, so that rewriting the
+// database, such as by [Compact], keeps them along with the ACL.
+func ignoreACL(o *options) {
+	o.ignoreACL = true
+}
+
+// denied returns the paths of the ACL entries whose capabilities are not provided.
+func (h *Hashive) denied() (paths [][]string) {
+	if h.options.ignoreACL {
+		return nil
+	}
+	return impl.Denied(h.acl, h.options.capabilities)
+}
+
+// checkACL returns [ErrNotFound] if the value mapped by path is hidden by the ACL.
+func (h *Hashive) checkACL(path []string) error {
+	if impl.IsDenied(h.denied(), path) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// hideACL removes the values hidden by the ACL from v mapped by path,
+// which is read recursively.
+func (h *Hashive) hideACL(path []string, v any) any {
+	return impl.HideDenied(h.denied(), path, v)
+}
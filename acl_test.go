@@ -0,0 +1,133 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestACL(t *testing.T) {
+	value := map[string]any{
+		"basic":   map[string]any{"a": 1},
+		"premium": map[string]any{"b": 2},
+		"mixed":   []any{"free", "paid"},
+	}
+	acl := []hashive.ACLEntry{
+		{Path: []string{"premium"}, Capability: "premium"},
+		{Path: []string{"mixed", "1"}, Capability: "premium"},
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithACL(acl...)); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.ACL(), acl) {
+		t.Fatal(h.ACL())
+	}
+	if _, err := h.Query("premium", "b"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err := h.QueryStringAppend(nil, "mixed", "1"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, map[string]any{"basic": map[string]any{"a": int64(1)}, "mixed": []any{"free", nil}}) {
+		t.Fatal(v)
+	}
+
+	h, err = hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithCapabilities("premium"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("premium", "b"); err != nil || v != int64(2) {
+		t.Fatal(v, err)
+	}
+	if v, err := h.Query("mixed"); err != nil || !reflect.DeepEqual(v, []any{"free", "paid"}) {
+		t.Fatal(v, err)
+	}
+}
+
+func TestCompactACL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"free": 1, "premium": 2},
+		hashive.WithACL(hashive.ACLEntry{Path: []string{"premium"}, Capability: "pro"})); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"free": int64(1), "premium": int64(2)}
+	for _, version := range []int{hashive.Version2, hashive.Version1, hashive.Version0} {
+		var migrated bytes.Buffer
+		if err := hashive.Migrate(bytes.NewReader(buf.Bytes()), &migrated, version); err != nil {
+			t.Fatal(version, err)
+		}
+		h, err := hashive.NewBytes(migrated.Bytes(), hashive.WithCapabilities("pro"))
+		if err != nil {
+			t.Fatal(version, err)
+		}
+		if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+			t.Fatal(version, v, err)
+		}
+		if version == hashive.Version0 {
+			continue
+		}
+		if h, err = hashive.NewBytes(migrated.Bytes()); err != nil {
+			t.Fatal(version, err)
+		}
+		if _, err := h.Query("premium"); err != hashive.ErrNotFound {
+			t.Fatal(version, err)
+		}
+	}
+}
+
+func TestACLKeyQueries(t *testing.T) {
+	h, err := hashive.NewMemory(map[string]any{
+		"r": map[string]any{
+			"10": "a",
+			"20": "secret",
+			"30": map[string]any{"x": 1, "y": 2},
+		},
+	}, hashive.WithIntKeys(), hashive.WithACL(
+		hashive.ACLEntry{Path: []string{"r", "20"}, Capability: "admin"},
+		hashive.ACLEntry{Path: []string{"r", "30", "y"}, Capability: "admin"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Query("r", "20"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if key, v, err := h.QueryFloorInt(25, "r"); err != nil || key != 10 || v != "a" {
+		t.Fatal(key, v, err)
+	}
+	if key, v, err := h.QueryFloorInt(35, "r"); err != nil || key != 30 || !reflect.DeepEqual(v, map[string]any{"x": int64(1)}) {
+		t.Fatal(key, v, err)
+	}
+	want := []hashive.IntEntry{{Key: 10, Value: "a"}, {Key: 30, Value: map[string]any{"x": int64(1)}}}
+	if entries, err := h.QueryRangeInt(0, 100, "r"); err != nil || !reflect.DeepEqual(entries, want) {
+		t.Fatal(entries, err)
+	}
+	if key, v, err := h.QueryLongestPrefix("20x", "r"); err != hashive.ErrNotFound {
+		t.Fatal(key, v, err)
+	}
+	if key, v, err := h.QueryLongestPrefix("10x", "r"); err != nil || key != "10" || v != "a" {
+		t.Fatal(key, v, err)
+	}
+
+	h, err = hashive.NewMemory(map[string]any{"p": map[string]any{"": "any", "2": "secret"}},
+		hashive.WithACL(hashive.ACLEntry{Path: []string{"p", "2"}, Capability: "admin"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, v, err := h.QueryLongestPrefix("20x", "p"); err != nil || key != "" || v != "any" {
+		t.Fatal(key, v, err)
+	}
+	if key, v, err := h.QueryFloorInt(5, "p"); err != hashive.ErrNotFound {
+		t.Fatal(key, v, err)
+	}
+}
@@ -0,0 +1,179 @@
+package hashive
+
+import (
+	"iter"
+	"math"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Aggregate is the statistics of numbers computed by [Hashive.QueryAggregate].
+type Aggregate struct {
+	Count    int     // The number of numbers.
+	Sum      float64 // The sum of numbers.
+	Min, Max float64 // The smallest and largest numbers, 0 if Count is 0.
+}
+
+// Mean returns the arithmetic mean of numbers, or NaN if Count is 0.
+func (a *Aggregate) Mean() float64 {
+	if a.Count == 0 {
+		return math.NaN()
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// add adds n to a.
+func (a *Aggregate) add(n float64) {
+	if a.Count == 0 {
+		a.Min, a.Max = n, n
+	} else {
+		a.Min, a.Max = min(a.Min, n), max(a.Max, n)
+	}
+	a.Count++
+	a.Sum += n
+}
+
+// QueryAggregate computes the count, sum, min and max of the numbers in the
+// array mapped by the path, or of the field of the objects in it if field is
+// not empty, such as QueryAggregate("price", "items"). Elements are read one
+// by one without reading the entire array, and only the field of objects is
+// read. Values which are not numbers, including missing fields, are not
+// counted. Integers are converted to float64.
+// [ErrNotFound] will be returned if the path does not map to an array.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryAggregate(field string, path ...string) (agg Aggregate, err error) {
+	ary, err := h.queryArrayValue(path)
+	if err != nil {
+		return
+	}
+	for i := range ary.Len() {
+		var v any
+		if v, err = ary.Index(i, false); err != nil {
+			return
+		}
+		valuePath := append(path[:len(path):len(path)], strconv.Itoa(i))
+		if field != "" {
+			obj, ok := v.(*impl.Object)
+			if !ok {
+				continue
+			}
+			valuePath = append(valuePath, field)
+			if v, err = obj.Index(field, false); err == ErrNotFound {
+				err = nil
+				continue
+			} else if err != nil {
+				return
+			}
+		}
+		if !isNumber(v) || h.checkACL(valuePath) != nil {
+			continue
+		}
+		if v, err = h.result(valuePath, v); err != nil {
+			return
+		}
+		switch n := v.(type) {
+		case int64:
+			agg.add(float64(n))
+		case uint64:
+			agg.add(float64(n))
+		case float64:
+			agg.add(n)
+		}
+	}
+	return
+}
+
+// GroupBy returns an iterator over the distinct values of the field of the
+// objects in the array mapped by the path, and the indices of the objects
+// having each of them in ascending order, such as GroupBy("country",
+// "users"). Groups are iterated in the order of their first objects.
+// Elements are read one by one without reading the entire array, and only
+// the field of objects is read. Only null, bool, number and string fields
+// are grouped, and the elements which are not objects, missing the field
+// or hidden by [WithACL] are not in any group.
+// The array is read when the iteration starts, and the first error stops
+// it, which is returned by err after the iteration. [ErrNotFound] is
+// returned if the path does not map to an array.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) GroupBy(field string, path ...string) (seq iter.Seq2[any, []int], err func() error) {
+	var errIter error
+	seq = func(yield func(any, []int) bool) {
+		var values []any
+		var indices [][]int
+		if values, indices, errIter = h.groupBy(field, path); errIter != nil {
+			return
+		}
+		for i, v := range values {
+			if !yield(v, indices[i]) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return errIter }
+}
+
+// groupBy returns the groups of [Hashive.GroupBy] in the order of their
+// first objects.
+func (h *Hashive) groupBy(field string, path []string) (values []any, indices [][]int, err error) {
+	ary, err := h.queryArrayValue(path)
+	if err != nil {
+		return
+	}
+	groups := make(map[any]int) // value -> index in values
+	for i := range ary.Len() {
+		var v any
+		if v, err = ary.Index(i, false); err != nil {
+			return
+		}
+		obj, ok := v.(*impl.Object)
+		if !ok {
+			continue
+		}
+		valuePath := append(path[:len(path):len(path)], strconv.Itoa(i), field)
+		if v, err = obj.Index(field, false); err == ErrNotFound {
+			err = nil
+			continue
+		} else if err != nil {
+			return
+		}
+		if !isGroupValue(v) || h.checkACL(valuePath) != nil {
+			continue
+		}
+		if v, err = h.result(valuePath, v); err != nil {
+			return
+		}
+		if !isGroupValue(v) {
+			continue
+		}
+		group, ok := groups[v]
+		if !ok {
+			group = len(values)
+			groups[v] = group
+			values = append(values, v)
+			indices = append(indices, nil)
+		}
+		indices[group] = append(indices[group], i)
+	}
+	return
+}
+
+// isGroupValue returns whether v is a value grouped by [Hashive.GroupBy].
+func isGroupValue(v any) bool {
+	switch v.(type) {
+	case nil, bool, string:
+		return true
+	}
+	return isNumber(v)
+}
+
+// isNumber returns whether v is a number read from a database.
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int64, uint64, float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package hashive_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryAggregate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"numbers": []any{3, uint(5), -1.5, "x", nil, []any{100}},
+		"items": []any{
+			map[string]any{"price": 10, "name": "a"},
+			map[string]any{"price": 2.5},
+			map[string]any{"name": "no price"},
+			map[string]any{"price": "free"},
+			1000,
+		},
+		"empty": []any{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agg, err := h.QueryAggregate("", "numbers"); err != nil || agg != (hashive.Aggregate{Count: 3, Sum: 6.5, Min: -1.5, Max: 5}) {
+		t.Fatal(agg, err)
+	}
+	if agg, err := h.QueryAggregate("price", "items"); err != nil || agg != (hashive.Aggregate{Count: 2, Sum: 12.5, Min: 2.5, Max: 10}) || agg.Mean() != 6.25 {
+		t.Fatal(agg, err)
+	}
+	if agg, err := h.QueryAggregate("", "empty"); err != nil || agg != (hashive.Aggregate{}) || !math.IsNaN(agg.Mean()) {
+		t.Fatal(agg, err)
+	}
+	if _, err := h.QueryAggregate("", "items", "0"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	h, err := hashive.NewMemory(map[string]any{
+		"users": []any{
+			map[string]any{"name": "a", "country": "fr"},
+			map[string]any{"name": "b", "country": "us"},
+			map[string]any{"name": "c"},
+			map[string]any{"name": "d", "country": "fr"},
+			map[string]any{"name": "e", "country": []any{"fr"}},
+			map[string]any{"name": "f", "country": nil},
+			"g",
+			map[string]any{"name": "h", "country": 1},
+			map[string]any{"name": "i", "country": "us"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, errFn := h.GroupBy("country", "users")
+	var values []any
+	var indices [][]int
+	for v, i := range seq {
+		values = append(values, v)
+		indices = append(indices, i)
+	}
+	if err := errFn(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []any{"fr", "us", nil, int64(1)}) {
+		t.Fatal(values)
+	}
+	if !reflect.DeepEqual(indices, [][]int{{0, 3}, {1, 8}, {5}, {7}}) {
+		t.Fatal(indices)
+	}
+
+	// Break.
+	for v := range seq {
+		if v != "fr" {
+			t.Fatal(v)
+		}
+		break
+	}
+
+	seq, errFn = h.GroupBy("country", "users", "0")
+	for range seq {
+		t.Fatal("iterated")
+	}
+	if err := errFn(); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+package hashive
+
+import (
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// QueryFind queries the first element of the array mapped by the path for
+// which match returns true, and returns its index and value. Elements are
+// read one by one and passed to match as [Hashive.Query] returns them, and
+// the elements after the matching one are not read, so finding an element
+// near the start of a large array is much faster than querying the array.
+// [ErrNotFound] will be returned if the path does not map to an array or
+// no element matches.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryFind(match func(v any) bool, path ...string) (index int, v any, err error) {
+	ary, err := h.queryArrayValue(path)
+	if err != nil {
+		return
+	}
+	index, _, err = ary.Find(func(i int, elem any) (ok bool, err error) {
+		if v, err = h.element(path, i, elem); err != nil {
+			return
+		}
+		return match(v), nil
+	})
+	if err != nil {
+		return -1, nil, err
+	}
+	return
+}
+
+// element converts the ith element of the array mapped by the path, which
+// is read recursively, to the value returned by queries.
+func (h *Hashive) element(path []string, i int, v any) (any, error) {
+	elemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+	if h.checkACL(elemPath) != nil {
+		v = nil // hidden as in the array
+	} else {
+		v = h.hideACL(elemPath, v)
+	}
+	return h.result(elemPath, v)
+}
+
+// QueryProject queries the array of objects mapped by the path, and returns
+// an object of only the fields of every element, such as a column of wide
+// records. Other fields are skipped without being read. Fields missing from
+// an element are missing from its object, and elements which are not
+// objects are returned as nil.
+// [ErrNotFound] will be returned if the path does not map to an array.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryProject(fields []string, path ...string) (values []any, err error) {
+	ary, err := h.queryArrayValue(path)
+	if err != nil {
+		return
+	}
+	values = make([]any, ary.Len())
+	for i := range values {
+		var elem any
+		if elem, err = ary.Index(i, false); err != nil {
+			return nil, err
+		}
+		obj, ok := elem.(*impl.Object)
+		elemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+		if !ok || h.checkACL(elemPath) != nil {
+			continue
+		}
+		projected := make(map[string]any, len(fields))
+		for _, field := range fields {
+			fieldPath := append(elemPath[:len(elemPath):len(elemPath)], field)
+			if h.checkACL(fieldPath) != nil {
+				continue
+			}
+			var v any
+			if v, err = obj.Index(field, true); err == ErrNotFound {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if projected[field], err = h.result(fieldPath, h.hideACL(fieldPath, v)); err != nil {
+				return nil, err
+			}
+		}
+		values[i] = projected
+	}
+	return
+}
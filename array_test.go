@@ -0,0 +1,69 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryFind(t *testing.T) {
+	owners := []any{
+		map[string]any{"name": "Ann", "age": 30},
+		map[string]any{"name": "Joe", "age": 40, "secret": "x"},
+		map[string]any{"name": "Joe", "age": 50},
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"owners": owners, "name": "n"},
+		hashive.WithACL(hashive.ACLEntry{Path: []string{"owners", "1", "secret"}, Capability: "admin"})); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited int
+	i, v, err := h.QueryFind(func(v any) bool {
+		visited++
+		return v.(map[string]any)["name"] == "Joe"
+	}, "owners")
+	if err != nil || i != 1 || visited != 2 || !reflect.DeepEqual(v, map[string]any{"name": "Joe", "age": int64(40)}) {
+		t.Fatal(i, v, err, visited)
+	}
+	if i, v, err := h.QueryFind(func(v any) bool { return false }, "owners"); err != hashive.ErrNotFound || i != -1 || v != nil {
+		t.Fatal(i, v, err)
+	}
+	if _, _, err := h.QueryFind(func(v any) bool { return true }, "name"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryProject(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"rows": []any{
+		map[string]any{"id": 1, "name": "a", "blob": []byte("xxxxxxxx"), "secret": 1},
+		map[string]any{"id": 2, "blob": []byte("yyyyyyyy")},
+		"not an object",
+	}}, hashive.WithACL(hashive.ACLEntry{Path: []string{"rows", "0", "secret"}, Capability: "admin"})); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := h.QueryProject([]string{"id", "name", "secret"}, "rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{
+		map[string]any{"id": int64(1), "name": "a"},
+		map[string]any{"id": int64(2)},
+		nil,
+	}; !reflect.DeepEqual(v, want) {
+		t.Fatal(v)
+	}
+	if _, err := h.QueryProject([]string{"id"}); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
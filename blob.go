@@ -0,0 +1,183 @@
+package hashive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Blob is a binary value stored in a blob file instead of the database,
+// see [WithBlobWriter]. It is returned by queries in place of the value if
+// the blob file is not provided by [WithBlobReader].
+type Blob = impl.Blob
+
+// ErrNoBlobs is returned when reading blobs of a database opened without
+// [WithBlobReader].
+var ErrNoBlobs = errors.New("no blob file")
+
+// WithBlobWriter writes binary values longer than threshold bytes to blobs,
+// the companion blob file of the database, and stores their offsets and
+// sizes in the database instead, which keeps the database small and
+// cacheable. Offsets are relative to the first byte written to blobs.
+// The blobs are read by [Hashive.QueryBlobReader], or by queries of values
+// of any type with [WithBlobReader].
+// Blob references are a type of [Version2], so the database is written in
+// that format.
+func WithBlobWriter(blobs io.Writer, threshold int) WriteOption {
+	return func(o *writeOptions) {
+		o.blobs = &blobWriter{w: blobs, threshold: threshold}
+	}
+}
+
+// blobWriter writes blobs, see [WithBlobWriter].
+type blobWriter struct {
+	w         io.Writer
+	threshold int
+	offset    uint64 // the offset of the next blob
+}
+
+// extract writes every binary value in v longer than the threshold as a
+// blob, and returns v with them replaced by [Blob].
+func (bw *blobWriter) extract(v any) (_ any, err error) {
+	switch value := v.(type) {
+	case []byte:
+		if len(value) <= bw.threshold {
+			break
+		}
+		if _, err = bw.w.Write(value); err != nil {
+			return
+		}
+		blob := Blob{Offset: bw.offset, Size: uint64(len(value))}
+		bw.offset += blob.Size
+		return blob, nil
+	case []any:
+		ary := make([]any, len(value))
+		for i, elem := range value {
+			if ary[i], err = bw.extract(elem); err != nil {
+				return
+			}
+		}
+		return ary, nil
+	case map[string]any:
+		obj := make(map[string]any, len(value))
+		for key, elem := range value {
+			if obj[key], err = bw.extract(elem); err != nil {
+				return
+			}
+		}
+		return obj, nil
+	case []Interval:
+		intervals := slices.Clone(value)
+		for i := range intervals {
+			if intervals[i].Value, err = bw.extract(intervals[i].Value); err != nil {
+				return
+			}
+		}
+		return intervals, nil
+	}
+	return v, nil
+}
+
+// WithBlobReader reads the blobs of the database from blobs, the companion
+// blob file written by [WithBlobWriter], so that blobs are returned by
+// queries as []byte instead of [Blob].
+func WithBlobReader(blobs io.ReaderAt) Option {
+	return func(o *options) {
+		o.blobs = blobs
+	}
+}
+
+// blobReader returns the reader of the bytes of blob.
+func (h *Hashive) blobReader(blob Blob) (*io.SectionReader, error) {
+	if h.options.blobs == nil {
+		return nil, ErrNoBlobs
+	}
+	if blob.Offset > math.MaxInt64 || blob.Size > math.MaxInt64-blob.Offset {
+		return nil, fmt.Errorf("invalid blob %v", blob)
+	}
+	return io.NewSectionReader(h.options.blobs, int64(blob.Offset), int64(blob.Size)), nil
+}
+
+// readBlob reads the bytes of blob.
+func (h *Hashive) readBlob(blob Blob) (p []byte, err error) {
+	r, err := h.blobReader(blob)
+	if err != nil {
+		return
+	}
+	if blob.Size > maxBlobPrealloc {
+		return io.ReadAll(r)
+	}
+	p = make([]byte, blob.Size)
+	if _, err = io.ReadFull(r, p); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// maxBlobPrealloc is the max size of blobs allocated in advance, so that
+// corrupted sizes fail without allocating them.
+const maxBlobPrealloc = 1 << 20
+
+// isBlob returns whether v is a [Blob].
+func isBlob(v any) bool {
+	_, ok := v.(Blob)
+	return ok
+}
+
+// readBlobs replaces every [Blob] in v, which is read recursively, with its bytes.
+func (h *Hashive) readBlobs(v any) (_ any, err error) {
+	switch value := v.(type) {
+	case Blob:
+		return h.readBlob(value)
+	case []any:
+		for i, elem := range value {
+			if value[i], err = h.readBlobs(elem); err != nil {
+				return
+			}
+		}
+	case map[string]any:
+		for key, elem := range value {
+			if value[key], err = h.readBlobs(elem); err != nil {
+				return
+			}
+		}
+	case []Interval:
+		for i := range value {
+			if value[i].Value, err = h.readBlobs(value[i].Value); err != nil {
+				return
+			}
+		}
+	}
+	return v, nil
+}
+
+// QueryBlobReader returns the reader of the bytes of a binary value mapped by
+// the path, which streams large values stored in the blob file without
+// reading them into memory. See [WithBlobWriter].
+// [ErrNotFound] will be returned if the path does not map to any value or
+// the value is not a binary value, and [ErrNoBlobs] will be returned if the
+// value is a blob but the database is opened without [WithBlobReader].
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryBlobReader(path ...string) (io.ReadCloser, error) {
+	v, err := h.query(path, false)
+	if err != nil {
+		return nil, err
+	}
+	switch value := v.(type) {
+	case []byte:
+		return io.NopCloser(bytes.NewReader(value)), nil
+	case Blob:
+		r, err := h.blobReader(value)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	}
+	return nil, ErrNotFound
+}
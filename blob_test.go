@@ -0,0 +1,80 @@
+package hashive_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestBlob(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789"), 100)
+	value := map[string]any{
+		"small":     []byte("small"),
+		"large":     large,
+		"list":      []any{large[:50], "text"},
+		"intervals": []hashive.Interval{{Start: 1, End: 2, Value: large[:20]}},
+	}
+	var db, blobs bytes.Buffer
+	if err := hashive.Write(&db, value, hashive.WithBlobWriter(&blobs, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if blobs.Len() != len(large)+50+20 || db.Len() > 200 {
+		t.Fatal(blobs.Len(), db.Len())
+	}
+
+	// Without the blob file.
+	h, err := hashive.New(bytes.NewReader(db.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("large"); err != nil {
+		t.Fatal(err)
+	} else if blob, ok := v.(hashive.Blob); !ok || blob.Size != uint64(len(large)) {
+		t.Fatal(v)
+	}
+	if _, err := h.QueryBlobReader("large"); err != hashive.ErrNoBlobs {
+		t.Fatal(err)
+	}
+	if hist, err := h.Histogram(); err != nil || hist.Kinds[hashive.KindBinary] != 3 {
+		t.Fatal(hist, err)
+	}
+
+	h, err = hashive.New(bytes.NewReader(db.Bytes()), -1, hashive.WithBlobReader(bytes.NewReader(blobs.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil {
+		t.Fatal(err)
+	} else if want := map[string]any{
+		"small":     []byte("small"),
+		"large":     large,
+		"list":      []any{large[:50], "text"},
+		"intervals": []hashive.Interval{{Start: 1, End: 2, Value: large[:20]}},
+	}; !reflect.DeepEqual(v, want) {
+		t.Fatal(v)
+	}
+	for _, test := range []struct {
+		path []string
+		want []byte
+	}{
+		{[]string{"large"}, large},
+		{[]string{"small"}, []byte("small")},
+		{[]string{"list", "0"}, large[:50]},
+	} {
+		r, err := h.QueryBlobReader(test.path...)
+		if err != nil {
+			t.Fatal(test.path, err)
+		}
+		p, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || !bytes.Equal(p, test.want) {
+			t.Fatal(test.path, err)
+		}
+	}
+	if _, err := h.QueryBlobReader("list", "1"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,199 @@
+package hashive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// bundleSignature is the file signature of a bundle.
+const bundleSignature = "hashivb\x00"
+
+// bundleTrailerSize is the size of the trailer of a bundle,
+// which is the offset of the directory.
+const bundleTrailerSize = 8
+
+// A bundle is a single file containing many Hashive databases(documents).
+// The layout of a bundle is:
+//
+//	signature | document... | directory | trailer
+//
+// The directory is a Hashive database mapping the name of every document to
+// an array of its offset and size in the bundle. The trailer is the offset of
+// the directory, stored as a little-endian uint64.
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.n += int64(n)
+	return
+}
+
+// BundleWriter writes a bundle of Hashive databases.
+type BundleWriter struct {
+	w   countingWriter
+	dir map[string]any
+	err error
+}
+
+// NewBundleWriter returns a BundleWriter writing to w.
+// [BundleWriter.Close] must be called to finish the bundle.
+func NewBundleWriter(w io.Writer) *BundleWriter {
+	bw := &BundleWriter{w: countingWriter{w: w}, dir: make(map[string]any)}
+	_, bw.err = io.WriteString(&bw.w, bundleSignature)
+	return bw
+}
+
+// Add writes value as a document named name with [Write].
+// It is an error to add two documents with the same name.
+func (bw *BundleWriter) Add(name string, value any) (err error) {
+	if bw.err != nil {
+		return bw.err
+	}
+	if _, exists := bw.dir[name]; exists {
+		return fmt.Errorf("duplicated document %q", name)
+	}
+	offset := bw.w.n
+	if err = Write(&bw.w, value); err != nil {
+		bw.err = err
+		return
+	}
+	bw.dir[name] = []any{uint64(offset), uint64(bw.w.n - offset)}
+	return
+}
+
+// Close writes the directory of the bundle.
+// It does not close the underlying writer.
+func (bw *BundleWriter) Close() (err error) {
+	if bw.err != nil {
+		return bw.err
+	}
+	dirOffset := bw.w.n
+	if err = Write(&bw.w, bw.dir); err != nil {
+		bw.err = err
+		return
+	}
+	var trailer [bundleTrailerSize]byte
+	binary.LittleEndian.PutUint64(trailer[:], uint64(dirOffset))
+	if _, err = bw.w.Write(trailer[:]); err != nil {
+		bw.err = err
+		return
+	}
+	bw.err = errors.New("bundle writer closed")
+	return
+}
+
+// Bundle is a bundle of Hashive databases.
+type Bundle struct {
+	r   io.ReaderAt
+	dir map[string]any
+	end int64 // the end of the documents, which is the offset of the directory
+}
+
+// NewBundle creates a Bundle from r. Argument size is the size of the bundle.
+func NewBundle(r io.ReaderAt, size int64) (b *Bundle, err error) {
+	if size < int64(len(bundleSignature))+bundleTrailerSize {
+		return nil, fmt.Errorf("invalid bundle size %v", size)
+	}
+	signature := make([]byte, len(bundleSignature))
+	if err = readFullAt(r, signature, 0); err != nil {
+		return
+	}
+	if sig := string(signature); sig != bundleSignature {
+		return nil, fmt.Errorf("invalid signature %v", sig)
+	}
+	var trailer [bundleTrailerSize]byte
+	if err = readFullAt(r, trailer[:], size-bundleTrailerSize); err != nil {
+		return
+	}
+	dirOffset := int64(binary.LittleEndian.Uint64(trailer[:]))
+	if dirOffset < int64(len(bundleSignature)) || dirOffset > size-bundleTrailerSize {
+		return nil, fmt.Errorf("invalid directory offset %v", dirOffset)
+	}
+	dirDB, err := NewSection(r, dirOffset, size-bundleTrailerSize-dirOffset, -1)
+	if err != nil {
+		return
+	}
+	dir, err := dirDB.Query()
+	if err != nil {
+		return
+	}
+	dirMap, ok := dir.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid directory type %T", dir)
+	}
+	return &Bundle{r: r, dir: dirMap, end: dirOffset}, nil
+}
+
+// readFullAt reads len(p) bytes from r at off into p.
+// Unlike [io.ReaderAt], reading the last bytes of r is not an [io.EOF].
+func readFullAt(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// OpenBundle opens the bundle denoted by filename.
+// The returned close function can be used to close the bundle file after use.
+func OpenBundle(filename string) (b *Bundle, close func() error, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+	if b, err = NewBundle(f, info.Size()); err != nil {
+		f.Close()
+		return
+	}
+	close = f.Close
+	return
+}
+
+// Names returns the sorted names of all the documents in b.
+func (b *Bundle) Names() []string {
+	var names = make([]string, 0, len(b.dir))
+	for name := range b.dir {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open opens the document named name in b.
+// [ErrNotFound] will be returned if there is no such document.
+//
+// See [New] for the meaning of readBufferSize and opts.
+func (b *Bundle) Open(name string, readBufferSize int, opts ...Option) (h *Hashive, err error) {
+	entry, ok := b.dir[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	location, ok := entry.([]any)
+	if !ok || len(location) != 2 {
+		return nil, fmt.Errorf("invalid directory entry %v", entry)
+	}
+	offset, ok1 := location[0].(uint64)
+	size, ok2 := location[1].(uint64)
+	if !ok1 || !ok2 || offset < uint64(len(bundleSignature)) ||
+		offset > uint64(b.end) || size > uint64(b.end)-offset {
+		return nil, fmt.Errorf("invalid directory entry %v", entry)
+	}
+	return NewSection(b.r, int64(offset), int64(size), readBufferSize, opts...)
+}
@@ -0,0 +1,140 @@
+package hashive_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestBundle(t *testing.T) {
+	docs := map[string]any{
+		"countries": map[string]any{"CN": "China", "US": "United States"},
+		"primes":    []any{int64(2), int64(3), int64(5)},
+		"empty":     map[string]any{},
+	}
+
+	var buf bytes.Buffer
+	bw := hashive.NewBundleWriter(&buf)
+	for _, name := range []string{"countries", "primes", "empty"} {
+		if err := bw.Add(name, docs[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bw.Add("primes", nil); err == nil {
+		t.Fatal("duplicated document added")
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := hashive.NewBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := b.Names(); !reflect.DeepEqual(names, []string{"countries", "empty", "primes"}) {
+		t.Fatal(names)
+	}
+	for name, want := range docs {
+		h, err := b.Open(name, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query(); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, want) {
+			t.Fatalf("%v: Query() = %v, want %v", name, v, want)
+		}
+	}
+
+	h, err := b.Open("countries", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("CN"); err != nil {
+		t.Fatal(err)
+	} else if v != "China" {
+		t.Fatal(v)
+	}
+
+	if _, err := b.Open("not exists", -1); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+// eofReaderAt returns io.EOF with the last bytes of r, which is allowed by
+// io.ReaderAt.
+type eofReaderAt struct {
+	r *bytes.Reader
+}
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = r.r.ReadAt(p, off)
+	if err == nil && off+int64(n) == r.r.Size() {
+		err = io.EOF
+	}
+	return
+}
+
+func TestBundleEOF(t *testing.T) {
+	var buf bytes.Buffer
+	bw := hashive.NewBundleWriter(&buf)
+	if err := bw.Add("doc", []any{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := hashive.NewBundle(eofReaderAt{bytes.NewReader(buf.Bytes())}, int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := b.Names(); !reflect.DeepEqual(names, []string{"doc"}) {
+		t.Fatal(names)
+	}
+}
+
+func TestBundleInvalidEntry(t *testing.T) {
+	var doc bytes.Buffer
+	if err := hashive.Write(&doc, []any{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	// A bundle with a document and a directory of entries of the given
+	// offset and size.
+	bundle := func(offset, size uint64) []byte {
+		buf := bytes.NewBufferString("hashivb\x00")
+		buf.Write(doc.Bytes())
+		dirOffset := buf.Len()
+		if err := hashive.Write(buf, map[string]any{
+			"doc": []any{uint64(8), uint64(doc.Len())},
+			"bad": []any{offset, size},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(dirOffset)))
+		return buf.Bytes()
+	}
+	for _, entry := range [][2]uint64{
+		{0, 8},
+		{8, uint64(doc.Len()) + 1},
+		{1000, 1},
+		{8, math.MaxUint64},
+		{math.MaxUint64, 1},
+	} {
+		data := bundle(entry[0], entry[1])
+		b, err := hashive.NewBundle(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Open("doc", -1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Open("bad", -1); err == nil {
+			t.Fatalf("%v: should fail", entry)
+		}
+	}
+}
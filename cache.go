@@ -0,0 +1,153 @@
+package hashive
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// WithResultCache caches the results of [Hashive.Query] keyed by path, up
+// to maxEntries results, each for ttl, so that identical queries repeated
+// within ttl, which are common in request handlers, return the cached
+// results without reading and decoding them again. The least recently used
+// results are evicted first. If ttl <= 0, results don't expire. The cache
+// is shared by the snapshots of the database, see [Hashive.Snapshot], and
+// the size of it is bounded by [WithMemoryBudget].
+//
+// The cached results are shared by the queries returning them, so they
+// must not be modified. It is ignored if maxEntries <= 0.
+func WithResultCache(maxEntries int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.cacheEntries, o.cacheTTL = max(maxEntries, 0), ttl
+	}
+}
+
+// resultCache is the cache of [WithResultCache]. It is safe for concurrent
+// use.
+type resultCache struct {
+	maxEntries int
+	maxSize    int64 // the max total size of results, 0 for unlimited
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *cachedResult
+	lru     list.List                // the most recently used first
+	size    int64                    // the total size of results
+}
+
+// cachedResult is an entry of [resultCache].
+type cachedResult struct {
+	key     string
+	v       any
+	size    int64
+	expires time.Time // zero if never
+}
+
+// newResultCache returns the cache of results with options o, or nil if
+// it is disabled.
+func newResultCache(o *options) *resultCache {
+	if o.cacheEntries <= 0 {
+		return nil
+	}
+	maxSize, bounded := o.available()
+	if bounded && maxSize == 0 {
+		return nil // No memory left for the cache.
+	}
+	return &resultCache{
+		maxEntries: o.cacheEntries,
+		maxSize:    maxSize,
+		ttl:        o.cacheTTL,
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// cacheKey returns the key of path in [resultCache]. Every element of path
+// is prefixed with its length, so that different paths never share a key.
+func cacheKey(path []string) string {
+	var key []byte
+	for _, name := range path {
+		key = binary.AppendUvarint(key, uint64(len(name)))
+		key = append(key, name...)
+	}
+	return string(key)
+}
+
+// get returns the cached result of key.
+func (c *resultCache) get(key string) (v any, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem := c.entries[key]
+	if elem == nil {
+		return
+	}
+	result := elem.Value.(*cachedResult)
+	if !result.expires.IsZero() && time.Now().After(result.expires) {
+		c.remove(elem)
+		return
+	}
+	c.lru.MoveToFront(elem)
+	return result.v, true
+}
+
+// put caches v as the result of key, and evicts the least recently used
+// results to fit the limits. v is not cached if it is larger than the
+// cache.
+func (c *resultCache) put(key string, v any) {
+	size := int64(len(key)) + resultSize(v)
+	if c.maxSize > 0 && size > c.maxSize {
+		return
+	}
+	result := &cachedResult{key: key, v: v, size: size}
+	if c.ttl > 0 {
+		result.expires = time.Now().Add(c.ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem := c.entries[key]; elem != nil {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(result)
+	c.size += size
+	for len(c.entries) > c.maxEntries || c.maxSize > 0 && c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove removes elem from c.
+func (c *resultCache) remove(elem *list.Element) {
+	result := c.lru.Remove(elem).(*cachedResult)
+	delete(c.entries, result.key)
+	c.size -= result.size
+}
+
+// resultSize returns the approximate memory used by the result v.
+func resultSize(v any) int64 {
+	const (
+		interfaceSize = 16
+		sliceSize     = 24
+		mapSize       = 48
+	)
+	switch v := v.(type) {
+	case string:
+		return interfaceSize + int64(len(v))
+	case []byte:
+		return interfaceSize + sliceSize + int64(len(v))
+	case GobValue:
+		return interfaceSize + sliceSize + int64(len(v))
+	case []any:
+		size := interfaceSize + sliceSize + int64(cap(v)-len(v))*interfaceSize
+		for _, elem := range v {
+			size += resultSize(elem)
+		}
+		return size
+	case map[string]any:
+		size := int64(interfaceSize + mapSize)
+		for key, value := range v {
+			size += interfaceSize + int64(len(key)) + resultSize(value)
+		}
+		return size
+	default:
+		return interfaceSize + 8
+	}
+}
@@ -0,0 +1,91 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkch/hashive"
+)
+
+func TestResultCache(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"a": map[string]any{"b": "c"},
+		"d": map[string]any{"e": "f"},
+		"g": map[string]any{"h": "i"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	var h *hashive.Hashive
+	// same reports whether the queries of path return the same map.
+	same := func(h *hashive.Hashive, path ...string) bool {
+		v1, err := h.Query(path...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v2, err := h.Query(path...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v1, v2) {
+			t.Fatal(v1, v2)
+		}
+		return reflect.ValueOf(v1).UnsafePointer() == reflect.ValueOf(v2).UnsafePointer()
+	}
+
+	// Not cached.
+	if h, err = hashive.NewBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if same(h, "a") {
+		t.Fatal("cached")
+	}
+
+	// Cached.
+	if h, err = hashive.NewBytes(data, hashive.WithResultCache(2, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !same(h, "a") || !same(h, "d") {
+		t.Fatal("not cached")
+	}
+	if _, err := h.Query("x"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	a, _ := h.Query("a")
+	if _, err := h.Query("g"); err != nil { // Evicts "d".
+		t.Fatal(err)
+	}
+	if v, _ := h.Query("a"); reflect.ValueOf(v).UnsafePointer() != reflect.ValueOf(a).UnsafePointer() {
+		t.Fatal("evicted")
+	}
+	// Shared by snapshots.
+	snapshot, err := h.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := snapshot.Query("a"); reflect.ValueOf(v).UnsafePointer() != reflect.ValueOf(a).UnsafePointer() {
+		t.Fatal("not shared")
+	}
+
+	// Expired.
+	if h, err = hashive.NewBytes(data, hashive.WithResultCache(2, time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	a, _ = h.Query("a")
+	time.Sleep(10 * time.Millisecond)
+	if v, _ := h.Query("a"); reflect.ValueOf(v).UnsafePointer() == reflect.ValueOf(a).UnsafePointer() {
+		t.Fatal("not expired")
+	}
+
+	// Over the memory budget.
+	if h, err = hashive.NewBytes(data, hashive.WithResultCache(2, time.Hour), hashive.WithMemoryBudget(10)); err != nil {
+		t.Fatal(err)
+	}
+	if same(h, "a") {
+		t.Fatal("cached")
+	}
+}
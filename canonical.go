@@ -0,0 +1,32 @@
+package hashive
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteCanonicalJSON writes the value mapped by the path to w as canonical
+// JSON, which is indented, one array element or object entry per line, with
+// object keys sorted and numbers formatted the same way every time.
+// The outputs of two versions of a database can be compared with standard
+// text diff tools.
+//
+// Values are converted as encoding/json does. Integers are written in full
+// precision, []byte is written as base64 string, and gob encoded values are
+// decoded first if their types are registered with [RegisterGobTypes].
+// An error will be returned if the value contains NaN or infinite numbers.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) WriteCanonicalJSON(w io.Writer, path ...string) (err error) {
+	v, err := h.query(path, true)
+	if err != nil {
+		return
+	}
+	if v, err = h.result(path, v); err != nil {
+		return
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,57 @@
+package hashive_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWriteCanonicalJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"z":   uint64(math.MaxUint64),
+		"a":   []any{1.5, int64(-1<<53 - 1), "<&>", nil},
+		"m":   map[string]any{"y": true, "x": []byte{1}},
+		"nan": math.NaN(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := h.WriteCanonicalJSON(&out, "m"); err != nil {
+		t.Fatal(err)
+	}
+	const want = "{\n\t\"x\": \"AQ==\",\n\t\"y\": true\n}\n"
+	if out.String() != want {
+		t.Fatal(out.String())
+	}
+
+	out.Reset()
+	if err := h.WriteCanonicalJSON(&out, "a"); err != nil {
+		t.Fatal(err)
+	}
+	const wantArray = "[\n\t1.5,\n\t-9007199254740993,\n\t\"<&>\",\n\tnull\n]\n"
+	if out.String() != wantArray {
+		t.Fatal(out.String())
+	}
+
+	out.Reset()
+	if err := h.WriteCanonicalJSON(&out, "z"); err != nil {
+		t.Fatal(err)
+	} else if out.String() != "18446744073709551615\n" {
+		t.Fatal(out.String())
+	}
+
+	if err := h.WriteCanonicalJSON(&out, "nan"); err == nil {
+		t.Fatal("should fail")
+	}
+	if err := h.WriteCanonicalJSON(&out, "none"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
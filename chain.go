@@ -0,0 +1,187 @@
+package hashive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Chained is a layered view of databases created by [Chain].
+type Chained struct {
+	layers []*Hashive // from the base to the topmost overlay
+}
+
+// Chain returns a view of primary with overlays laid over it, so that a
+// small database, such as hotfixes, can override the values of a large base
+// database without rewriting it. Every overlay overrides primary and the
+// overlays before it.
+//
+// Objects are merged key by key across layers, and any other value of a
+// layer, including arrays, replaces the values of the layers below it at
+// the same path and all the values in them. Overlays can't delete values.
+func Chain(primary *Hashive, overlays ...*Hashive) *Chained {
+	return &Chained{layers: append([]*Hashive{primary}, overlays...)}
+}
+
+// Query queries a value mapped by the path, which is looked up in the
+// overlays first and then primary. Objects found in several layers are
+// merged, see [Chain].
+// [ErrNotFound] will be returned if the path does not map to any value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (c *Chained) Query(path ...string) (v any, err error) {
+	found := false
+	for _, h := range slices.Backward(c.layers) {
+		value, err := h.Query(path...)
+		if err == ErrNotFound {
+			if shadowed, err := h.shadows(path); err != nil {
+				return nil, err
+			} else if shadowed {
+				break
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if found {
+			v = mergeObjects(v, value)
+		} else {
+			v, found = value, true
+		}
+		if _, ok := v.(map[string]any); !ok {
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return
+}
+
+// QueryInto is like [Chained.Query], but stores the value into the value
+// pointed to by dst as [Hashive.QueryInto] does. The value is validated
+// against the schema of the topmost layer having it, and gob encoded values
+// are decoded by that layer too.
+func (c *Chained) QueryInto(dst any, path ...string) (err error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("invalid destination %T", dst)
+	}
+	h, err := c.layer(path)
+	if err != nil {
+		return
+	}
+	value, err := c.Query(path...)
+	if err != nil {
+		return
+	}
+	schema := h.schema.lookup(path)
+	if err = schema.validate(path, value); err != nil {
+		return
+	}
+	return h.decodeInto(rv.Elem(), value, path, schema)
+}
+
+// QueryGob queries a gob encoded value mapped by the path in the topmost
+// layer having it, see [Hashive.QueryGob].
+func (c *Chained) QueryGob(v any, path ...string) (err error) {
+	h, err := c.layer(path)
+	if err != nil {
+		return
+	}
+	return h.QueryGob(v, path...)
+}
+
+// Exists reports whether the path maps to any value of any layer.
+// See [Hashive.Exists].
+func (c *Chained) Exists(path ...string) (bool, error) {
+	_, err := c.layer(path)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// layer returns the topmost layer having a value mapped by the path, which
+// is not shadowed by the layers above it, see [Hashive.shadows].
+func (c *Chained) layer(path []string) (*Hashive, error) {
+	for _, h := range slices.Backward(c.layers) {
+		if exists, err := h.Exists(path...); err != nil {
+			return nil, err
+		} else if exists {
+			return h, nil
+		}
+		if shadowed, err := h.shadows(path); err != nil {
+			return nil, err
+		} else if shadowed {
+			break
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Flatten writes the layered view to w as a single database with opts, such
+// as when the overlays are too many to query efficiently. The schema and ACL
+// of primary are kept unless replaced by [WithSchema] and [WithACL] in opts.
+// Gob encoded values are copied as is. Metadata, provenance and the other
+// data of values are not kept, and neither are values hidden by the ACL of
+// any layer.
+func (c *Chained) Flatten(w io.Writer, opts ...WriteOption) (err error) {
+	value, err := c.Query()
+	if err != nil {
+		return
+	}
+	gobTypes := make(map[string]uint64)
+	for _, h := range c.layers {
+		if h.legacy && contains(value, isGob) {
+			return errors.New("can't flatten legacy database with gob encoded values")
+		}
+		maps.Copy(gobTypes, h.gobTypes)
+	}
+	primary := c.layers[0]
+	opts = append([]WriteOption{WithSchema(primary.schema), WithACL(primary.acl...), func(o *writeOptions) {
+		o.gobTypes = gobTypes
+	}}, opts...)
+	return Write(w, value, opts...)
+}
+
+// shadows returns whether h, which has no value mapped by the path, hides
+// the values mapped by it in the layers below h, which is the case if the
+// longest prefix of the path mapping to a value of h does not map to an
+// object.
+func (h *Hashive) shadows(path []string) (bool, error) {
+	for n := len(path) - 1; n >= 0; n-- {
+		container, err := h.container(path[:n])
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		_, ok := container.(*impl.Object)
+		return !ok, nil
+	}
+	return false, nil
+}
+
+// mergeObjects merges lower into upper recursively if both of them are
+// objects, and returns the result. Values of upper take precedence.
+func mergeObjects(upper, lower any) any {
+	upperObj, ok1 := upper.(map[string]any)
+	lowerObj, ok2 := lower.(map[string]any)
+	if !ok1 || !ok2 {
+		return upper
+	}
+	for key, value := range lowerObj {
+		if v, ok := upperObj[key]; ok {
+			upperObj[key] = mergeObjects(v, value)
+		} else {
+			upperObj[key] = value
+		}
+	}
+	return upperObj
+}
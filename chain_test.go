@@ -0,0 +1,152 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+// newHashive writes value and returns the database.
+func newHashive(t *testing.T, value any) *hashive.Hashive {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestChain(t *testing.T) {
+	base := newHashive(t, map[string]any{
+		"users":  map[string]any{"alice": map[string]any{"age": 30, "city": "Paris"}, "bob": 1},
+		"list":   []any{1, 2, 3},
+		"config": map[string]any{"debug": false},
+		"scalar": map[string]any{"x": 1},
+	})
+	hotfix := newHashive(t, map[string]any{
+		"users":  map[string]any{"alice": map[string]any{"age": 31}},
+		"list":   []any{4},
+		"scalar": "replaced",
+	})
+	top := newHashive(t, map[string]any{
+		"config": map[string]any{"debug": true},
+	})
+	c := hashive.Chain(base, hotfix, top)
+
+	for _, test := range []struct {
+		path []string
+		want any
+	}{
+		{[]string{"users", "alice", "age"}, int64(31)},
+		{[]string{"users", "alice", "city"}, "Paris"},
+		{[]string{"users", "alice"}, map[string]any{"age": int64(31), "city": "Paris"}},
+		{[]string{"users", "bob"}, int64(1)},
+		{[]string{"list"}, []any{int64(4)}},
+		{[]string{"list", "0"}, int64(4)},
+		{[]string{"config", "debug"}, true},
+		{[]string{"scalar"}, "replaced"},
+		{nil, map[string]any{
+			"users":  map[string]any{"alice": map[string]any{"age": int64(31), "city": "Paris"}, "bob": int64(1)},
+			"list":   []any{int64(4)},
+			"config": map[string]any{"debug": true},
+			"scalar": "replaced",
+		}},
+	} {
+		if v, err := c.Query(test.path...); err != nil || !reflect.DeepEqual(v, test.want) {
+			t.Fatal(test.path, v, err)
+		}
+	}
+	// Shadowed by values of overlays.
+	for _, path := range [][]string{{"scalar", "x"}, {"missing"}} {
+		if v, err := c.Query(path...); err != hashive.ErrNotFound {
+			t.Fatal(path, v, err)
+		}
+	}
+	if v, err := c.Query("list", "1"); err == nil {
+		t.Fatal(v)
+	}
+	// The base alone.
+	if v, err := hashive.Chain(base).Query("users", "alice", "age"); err != nil || v != int64(30) {
+		t.Fatal(v, err)
+	}
+}
+
+func TestChainFlatten(t *testing.T) {
+	type Point struct{ X, Y int }
+	base := newHashive(t, map[string]any{"a": map[string]any{"x": 1, "y": 2}, "p": Point{1, 2}})
+	overlay := newHashive(t, map[string]any{"a": map[string]any{"y": 3}, "b": "new"})
+	c := hashive.Chain(base, overlay)
+	var buf bytes.Buffer
+	if err := c.Flatten(&buf, hashive.WithSortedKeys()); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := c.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatal(v, err)
+	}
+	if v, err := h.Query("a", "y"); err != nil || v != int64(3) {
+		t.Fatal(v, err)
+	}
+	var p Point
+	if err := h.QueryGob(&p, "p"); err != nil || p != (Point{1, 2}) {
+		t.Fatal(p, err)
+	}
+}
+
+func TestChainQuerier(t *testing.T) {
+	type Point struct{ X, Y int }
+	type User struct {
+		Age  int    `hashive:"age"`
+		City string `hashive:"city"`
+	}
+	base := newHashive(t, map[string]any{
+		"users":  map[string]any{"alice": map[string]any{"age": 30, "city": "Paris"}},
+		"p":      Point{1, 2},
+		"scalar": map[string]any{"x": 1},
+	})
+	overlay := newHashive(t, map[string]any{
+		"users":  map[string]any{"alice": map[string]any{"age": 31}},
+		"q":      Point{3, 4},
+		"scalar": "replaced",
+	})
+	var c hashive.Querier = hashive.Chain(base, overlay)
+	var user User
+	if err := c.QueryInto(&user, "users", "alice"); err != nil || user != (User{31, "Paris"}) {
+		t.Fatal(user, err)
+	}
+	var p Point
+	for path, want := range map[string]Point{"p": {1, 2}, "q": {3, 4}} {
+		if err := c.QueryGob(&p, path); err != nil || p != want {
+			t.Fatal(path, p, err)
+		}
+	}
+	for _, test := range []struct {
+		path []string
+		want bool
+	}{
+		{[]string{"users", "alice", "city"}, true},
+		{[]string{"q"}, true},
+		{[]string{"scalar", "x"}, false},
+		{[]string{"missing"}, false},
+	} {
+		if exists, err := c.Exists(test.path...); err != nil || exists != test.want {
+			t.Fatal(test.path, exists, err)
+		}
+	}
+	if err := c.QueryGob(&p, "scalar", "x"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
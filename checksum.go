@@ -0,0 +1,157 @@
+package hashive
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// headerChecksums is the header key of the block size of checksums,
+// see [WithChecksums].
+const headerChecksums = "checksums"
+
+// crcTable is the table of CRC-32C checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WithChecksums stores the CRC-32C checksum of every blockSize bytes of the
+// database after the header, so that corruption of the storage can be
+// detected by [Hashive.Scrub] before queries read the corrupted data.
+// It is ignored if blockSize <= 0.
+// Databases written with this option can be read by versions without this
+// option, which ignore the checksums.
+func WithChecksums(blockSize int) WriteOption {
+	return func(o *writeOptions) {
+		o.checksums = max(blockSize, 0)
+	}
+}
+
+// writeChecksums appends the checksums of every blockSize bytes of payload
+// to it as a []byte value.
+func writeChecksums(payload *bytes.Buffer, blockSize int) error {
+	data := payload.Bytes()
+	sums := make([]byte, 0, (len(data)+blockSize-1)/blockSize*4)
+	for start := 0; start < len(data); start += blockSize {
+		block := data[start:min(start+blockSize, len(data))]
+		sums = binary.LittleEndian.AppendUint32(sums, crc32.Checksum(block, crcTable))
+	}
+	return impl.WriteBinary(payload, sums)
+}
+
+// checksums locates the checksums of a database, see [WithChecksums].
+type checksums struct {
+	blockSize  int64
+	start, end int64 // the range of data covered, which the checksums follow
+}
+
+// checksumsFromValue returns the checksums of the block size v of the data
+// starting at start, which is followed by the refs, root and side tables of
+// the sizes in header.
+func checksumsFromValue(v any, start int64, header map[string]any) (*checksums, error) {
+	if v == nil {
+		return nil, nil
+	}
+	blockSize, ok := v.(uint64)
+	if !ok || blockSize == 0 || blockSize > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid checksum block size %v", v)
+	}
+	end := start
+	for _, name := range append([]string{headerLength}, sideTables[:]...) {
+		if size, ok := header[name].(uint64); ok {
+			if size > uint64(math.MaxInt64-end) {
+				return nil, fmt.Errorf("invalid size of %v %v", name, size)
+			}
+			end += int64(size)
+		}
+	}
+	return &checksums{int64(blockSize), start, end}, nil
+}
+
+// ErrNoChecksums is returned by [Hashive.Scrub] if the database is written
+// without [WithChecksums].
+var ErrNoChecksums = errors.New("no checksums")
+
+// ChecksumError is returned by [Hashive.Scrub] when a block of the database
+// does not match its checksum.
+type ChecksumError struct {
+	Offset int64 // The offset of the block in the database.
+	Size   int64 // The size of the block.
+}
+
+func (err *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch of block of %v bytes at %v", err.Size, err.Offset)
+}
+
+// Scrub verifies the checksums of the database block by block, reading at
+// most rateLimit bytes per second, or as fast as possible if rateLimit <= 0,
+// so that long-lived services can detect corruption of the storage in the
+// background without starving queries. It returns a [*ChecksumError] at the
+// first corrupted block, ctx.Err() if ctx is done before all the blocks are
+// verified, or [ErrNoChecksums] if the database is written without
+// [WithChecksums].
+//
+// Scrub reads from a snapshot of h, so h can be queried concurrently.
+// Only the databases which can be snapshotted can be scrubbed,
+// see [Hashive.Snapshot].
+func (h *Hashive) Scrub(ctx context.Context, rateLimit int64) (err error) {
+	if h.checksums == nil {
+		return ErrNoChecksums
+	}
+	snapshot, err := h.Snapshot()
+	if err != nil {
+		return
+	}
+	c, r := h.checksums, snapshot.r
+	if _, err = r.Seek(c.end, io.SeekStart); err != nil {
+		return
+	}
+	sums, err := impl.ReadBinary(r)
+	if err != nil {
+		return
+	}
+	if blocks := (c.end - c.start + c.blockSize - 1) / c.blockSize; int64(len(sums)) != blocks*4 {
+		return fmt.Errorf("invalid checksums of %v bytes for %v blocks", len(sums), blocks)
+	}
+	if _, err = r.Seek(c.start, io.SeekStart); err != nil {
+		return
+	}
+	begin := time.Now()
+	block := make([]byte, c.blockSize)
+	for offset := c.start; offset < c.end; offset += c.blockSize {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		p := block[:min(c.blockSize, c.end-offset)]
+		if _, err = io.ReadFull(r, p); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		if crc32.Checksum(p, crcTable) != binary.LittleEndian.Uint32(sums) {
+			return &ChecksumError{offset, int64(len(p))}
+		}
+		sums = sums[4:]
+		if rateLimit > 0 {
+			// The time reading the bytes so far takes at rateLimit.
+			due := time.Duration(float64(offset+int64(len(p))-c.start) / float64(rateLimit) * float64(time.Second))
+			if wait := due - time.Since(begin); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+	}
+	return
+}
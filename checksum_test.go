@@ -0,0 +1,64 @@
+package hashive_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mkch/hashive"
+)
+
+func TestScrub(t *testing.T) {
+	value := map[string]any{"meta": hashive.ValueWithMeta{Value: 1, Meta: map[string]any{"ttl": 10}}}
+	for i := range 100 {
+		value["key"+strconv.Itoa(i)] = "value" + strconv.Itoa(i)
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithChecksums(64), hashive.WithDedup()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	h, err := hashive.New(bytes.NewReader(data), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Scrub(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("key1"); err != nil || v != "value1" {
+		t.Fatal(v, err)
+	}
+
+	// Corrupted.
+	corrupted := bytes.Clone(data)
+	i := bytes.Index(corrupted, []byte("value42"))
+	corrupted[i] = 'V'
+	h, err = hashive.NewBytes(corrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checksumErr *hashive.ChecksumError
+	if err := h.Scrub(context.Background(), 0); !errors.As(err, &checksumErr) ||
+		checksumErr.Offset > int64(i) || checksumErr.Offset+checksumErr.Size <= int64(i) || checksumErr.Size != 64 {
+		t.Fatal(err)
+	}
+
+	// Throttled.
+	h, err = hashive.NewBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Scrub(ctx, 1000); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+
+	// Without checksums.
+	if err := newHashive(t, value).Scrub(context.Background(), 0); err != hashive.ErrNoChecksums {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package hashive
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+)
+
+// Compact reads the database from src and writes it again to dst with opts,
+// so that existing databases can adopt the layout options, such as
+// [WithDedup] and [WithFixedKeys], without being generated from the source
+// data. The schema and ACL of src are kept unless replaced by [WithSchema]
+// and [WithACL] in opts, and so are the metadata and provenance of values.
+// The values hidden by the ACL are kept as well.
+// Gob encoded values are copied as is, without being decoded.
+// Legacy databases storing gob encoded values can't be compacted, because
+// those values can't be decoded independently.
+func Compact(src io.ReadSeeker, dst io.Writer, opts ...WriteOption) (err error) {
+	value, opts, err := compactValue(src, opts)
+	if err != nil {
+		return
+	}
+	return Write(dst, value, opts...)
+}
+
+// compactValue reads the database from src, and returns its value and
+// the options to write it again with, see [Compact].
+func compactValue(src io.ReadSeeker, opts []WriteOption) (value any, _ []WriteOption, err error) {
+	h, err := New(src, -1, ignoreACL)
+	if err != nil {
+		return
+	}
+	if value, err = h.Query(); err != nil {
+		return
+	}
+	if value, err = h.attachSideValues(nil, value); err != nil {
+		return
+	}
+	if h.legacy && contains(value, isGob) {
+		err = errors.New("can't compact legacy database with gob encoded values")
+		return
+	}
+	opts = append([]WriteOption{WithSchema(h.schema), WithACL(h.acl...), func(o *writeOptions) {
+		o.gobTypes = h.gobTypes
+	}}, opts...)
+	return value, opts, nil
+}
+
+// ExportSubtree writes the value mapped by the path and all the values in it
+// to w as a standalone database, such as a regional slice of a worldwide
+// dataset. The schema, ACL, metadata and provenance of the subtree are kept,
+// and gob encoded values are copied as is. Values hidden by the ACL are not
+// exported, see [WithACL].
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) ExportSubtree(w io.Writer, path ...string) (err error) {
+	value, err := h.query(path, true)
+	if err != nil {
+		return
+	}
+	if value, err = h.attachSideValues(path, value); err != nil {
+		return
+	}
+	if h.legacy && contains(value, isGob) {
+		return errors.New("can't export legacy database with gob encoded values")
+	}
+	var acl []ACLEntry
+	for _, entry := range h.acl {
+		if len(entry.Path) <= len(path) && slices.Equal(entry.Path, path[:len(entry.Path)]) {
+			acl = append(acl, ACLEntry{Path: []string{}, Capability: entry.Capability})
+		} else if len(entry.Path) > len(path) && slices.Equal(entry.Path[:len(path)], path) {
+			acl = append(acl, ACLEntry{Path: entry.Path[len(path):], Capability: entry.Capability})
+		}
+	}
+	return Write(w, value, WithSchema(h.schema.lookup(path)), WithACL(acl...), func(o *writeOptions) {
+		o.gobTypes = h.gobTypes
+	})
+}
+
+// CompactFile is like [Compact] but reads from and writes to files
+// with [WriteFile].
+// dst must be different from src, and it will be overwritten if exists.
+func CompactFile(src, dst string, opts ...WriteOption) (err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	value, opts, err := compactValue(f, opts)
+	if err != nil {
+		return
+	}
+	return WriteFile(dst, value, opts...)
+}
+
+// contains returns whether v or any value in it matches, including the
+// values wrapped as side values.
+func contains(v any, match func(v any) bool) bool {
+	if match(v) {
+		return true
+	}
+	switch value := v.(type) {
+	case sideValue:
+		inner, _, _ := value.unwrap()
+		return contains(inner, match)
+	case []any:
+		for _, elem := range value {
+			if contains(elem, match) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, elem := range value {
+			if contains(elem, match) {
+				return true
+			}
+		}
+	case []Interval:
+		for _, interval := range value {
+			if contains(interval.Value, match) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isGob returns whether v is a gob encoded value.
+func isGob(v any) bool {
+	_, ok := v.(GobValue)
+	return ok
+}
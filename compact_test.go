@@ -0,0 +1,99 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestCompact(t *testing.T) {
+	type Point struct{ X, Y int }
+	block := map[string]any{"a": "xxxxxxxxxxxxxxxx", "b": []any{1, 2, 3}}
+	value := map[string]any{
+		"AA": block,
+		"BB": block,
+		"CC": Point{1, 2},
+	}
+	schema := &hashive.Schema{Kind: hashive.KindObject}
+	var src bytes.Buffer
+	if err := hashive.Write(&src, value, hashive.WithSchema(schema)); err != nil {
+		t.Fatal(err)
+	}
+	var dst bytes.Buffer
+	if err := hashive.Compact(bytes.NewReader(src.Bytes()), &dst, hashive.WithDedup(), hashive.WithFixedKeys()); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Len() >= src.Len() {
+		t.Fatal(dst.Len(), src.Len())
+	}
+	h, err := hashive.New(bytes.NewReader(dst.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Schema() == nil || h.Schema().Kind != hashive.KindObject {
+		t.Fatal(h.Schema())
+	}
+	if v, err := h.Query("BB"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, map[string]any{"a": "xxxxxxxxxxxxxxxx", "b": []any{int64(1), int64(2), int64(3)}}) {
+		t.Fatal(v)
+	}
+	var p Point
+	if err := h.QueryGob(&p, "CC"); err != nil {
+		t.Fatal(err)
+	} else if p != (Point{1, 2}) {
+		t.Fatal(p)
+	}
+	{
+		// The definition is checked against the one used to write src.
+		type Point struct{ X, Z string }
+		var p Point
+		if err := h.QueryGob(&p, "CC"); err == nil {
+			t.Fatal(p)
+		}
+	}
+}
+
+func TestExportSubtree(t *testing.T) {
+	type Point struct{ X, Y int }
+	value := map[string]any{
+		"asia":   map[string]any{"cn": Point{1, 2}, "jp": "x"},
+		"europe": map[string]any{"fr": "y"},
+	}
+	schema := &hashive.Schema{
+		Kind:   hashive.KindObject,
+		Values: &hashive.Schema{Kind: hashive.KindObject},
+	}
+	acl := []hashive.ACLEntry{{Path: []string{"asia", "jp"}, Capability: "jp"}}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithSchema(schema), hashive.WithACL(acl...)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithCapabilities("jp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sub bytes.Buffer
+	if err := h.ExportSubtree(&sub, "asia"); err != nil {
+		t.Fatal(err)
+	}
+	h, err = hashive.New(bytes.NewReader(sub.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Schema() == nil || h.Schema().Kind != hashive.KindObject || h.Schema().Values != nil {
+		t.Fatal(h.Schema())
+	}
+	if !reflect.DeepEqual(h.ACL(), []hashive.ACLEntry{{Path: []string{"jp"}, Capability: "jp"}}) {
+		t.Fatal(h.ACL())
+	}
+	var p Point
+	if err := h.QueryGob(&p, "cn"); err != nil || p != (Point{1, 2}) {
+		t.Fatal(p, err)
+	}
+	if _, err := h.Query("europe"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
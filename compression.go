@@ -0,0 +1,69 @@
+package hashive
+
+import "math"
+
+// WithCompression compresses the []byte values for which decide returns
+// true with DEFLATE, unless they are not smaller compressed, so that
+// compressible values, such as text, take less space, while the ones
+// compressed already, such as JPEG images, are not compressed again.
+// If decide is nil, [Compressible] is used. The values split into chunks
+// by [WithChunkedBinary] are not compressed. Compressed values are
+// decompressed into memory when read, and [Hashive.QueryBinaryView]
+// returns copies of them.
+// The database is written in format [Version2], which added compressed
+// values, even if none of them is compressed.
+func WithCompression(decide func(p []byte) bool) WriteOption {
+	if decide == nil {
+		decide = Compressible
+	}
+	return func(o *writeOptions) {
+		o.compress = decide
+	}
+}
+
+// Compression heuristics of [Compressible].
+const (
+	// compressMinSize is the min size of values worth compressing.
+	compressMinSize = 256
+	// compressSampleSize is the size of each of the samples, taken from the
+	// start, middle and end of a value.
+	compressSampleSize = 1024
+	// compressMaxEntropy is the max entropy, in bits per byte, of the
+	// samples of compressible values. Compressed and encrypted data is
+	// close to 8.
+	compressMaxEntropy = 7.0
+)
+
+// Compressible reports whether p is worth compressing, which is the
+// default decision of [WithCompression]. Values shorter than 256 bytes are
+// not, and otherwise the entropy of the bytes of up to three 1 KiB samples
+// of p is estimated, which is high for the data compressed already.
+func Compressible(p []byte) bool {
+	if len(p) < compressMinSize {
+		return false
+	}
+	var counts [256]int
+	n := 0
+	count := func(sample []byte) {
+		for _, b := range sample {
+			counts[b]++
+		}
+		n += len(sample)
+	}
+	if len(p) <= 3*compressSampleSize {
+		count(p)
+	} else {
+		mid := len(p)/2 - compressSampleSize/2
+		count(p[:compressSampleSize])
+		count(p[mid : mid+compressSampleSize])
+		count(p[len(p)-compressSampleSize:])
+	}
+	var entropy float64
+	for _, c := range counts {
+		if c > 0 {
+			f := float64(c) / float64(n)
+			entropy -= f * math.Log2(f)
+		}
+	}
+	return entropy <= compressMaxEntropy
+}
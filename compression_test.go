@@ -0,0 +1,90 @@
+package hashive_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestCompressible(t *testing.T) {
+	random := make([]byte, 10000)
+	for i := range random {
+		random[i] = byte(rand.N(256))
+	}
+	text := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200))
+	if hashive.Compressible(random) || hashive.Compressible(random[:300]) {
+		t.Fatal("random data is compressible")
+	}
+	if !hashive.Compressible(text) || !hashive.Compressible(text[:300]) {
+		t.Fatal("text is not compressible")
+	}
+	if hashive.Compressible(text[:100]) {
+		t.Fatal("short text is compressible")
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	random := make([]byte, 10000)
+	for i := range random {
+		random[i] = byte(rand.N(256))
+	}
+	text := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200))
+	value := map[string]any{"random": random, "text": text}
+	var plain, compressed bytes.Buffer
+	if err := hashive.Write(&plain, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := hashive.Write(&compressed, value, hashive.WithCompression(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Len()-compressed.Len() < len(text)/2 {
+		t.Fatal(plain.Len(), compressed.Len())
+	}
+	h, err := hashive.NewBytes(compressed.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, p := range map[string][]byte{"random": random, "text": text} {
+		if v, err := h.Query(key); err != nil || !bytes.Equal(v.([]byte), p) {
+			t.Fatal(key, err)
+		}
+		if v, err := h.QueryBinaryView(key); err != nil || !bytes.Equal(v, p) {
+			t.Fatal(key, err)
+		}
+		r, size, err := h.QueryReader(key)
+		if err != nil || size != int64(len(p)) {
+			t.Fatal(key, size, err)
+		}
+		if v, err := io.ReadAll(r); err != nil || !bytes.Equal(v, p) {
+			t.Fatal(key, err)
+		}
+		ra, size, err := h.QueryReaderAt(key)
+		if err != nil || size != int64(len(p)) {
+			t.Fatal(key, size, err)
+		}
+		v := make([]byte, 10)
+		if _, err := ra.ReadAt(v, 100); err != nil || !bytes.Equal(v, p[100:110]) {
+			t.Fatal(key, err)
+		}
+	}
+	hist, err := h.Histogram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hist.Kinds[hashive.KindBinary] != 2 {
+		t.Fatal(hist.Kinds)
+	}
+
+	// Decided by the caller.
+	compressed.Reset()
+	if err := hashive.Write(&compressed, value, hashive.WithCompression(func(p []byte) bool { return false })); err != nil {
+		t.Fatal(err)
+	}
+	if compressed.Len() != plain.Len() {
+		t.Fatal(plain.Len(), compressed.Len())
+	}
+}
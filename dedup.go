@@ -0,0 +1,166 @@
+package hashive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// deduper writes identical arrays and objects of a value only once.
+// Subtrees occurring more than once are written in front of the root value,
+// and replaced by refs to them. See [WithDedup].
+type deduper struct {
+	encoder  *impl.Encoder
+	ids      map[string]int // canonical encoding -> id of subtrees
+	uses     []int          // id -> number of occurrences
+	root     *dedupNode
+	refsSize int // the size of referenced values written by write
+
+	// Fields used by write.
+	refs  bytes.Buffer
+	base  uint64      // the position of refs in the database
+	built map[int]any // id -> ref or inline value of shared subtrees
+}
+
+// dedupNode is a subtree of the value written by deduper.
+type dedupNode struct {
+	id       int
+	value    any          // the value itself, for values other than arrays and objects
+	kind     Kind         // KindArray, KindObject or KindAny
+	keys     []string     // sorted keys of an object
+	children []*dedupNode // elements of an array, or values of keys of an object
+}
+
+// newDeduper creates a deduper writing value.
+func newDeduper(value any, encoder *impl.Encoder) (d *deduper, err error) {
+	d = &deduper{encoder: encoder, ids: make(map[string]int)}
+	if d.root, err = d.scan(value); err != nil {
+		return
+	}
+	d.uses = make([]int, len(d.ids))
+	d.count(d.root)
+	return
+}
+
+// scan returns the subtree of v. Identical subtrees have the same id.
+func (d *deduper) scan(v any) (n *dedupNode, err error) {
+	var key []byte
+	switch value := v.(type) {
+	case []any:
+		n = &dedupNode{kind: KindArray, children: make([]*dedupNode, len(value))}
+		key = append(key, 'a')
+		for i, elem := range value {
+			if n.children[i], err = d.scan(elem); err != nil {
+				return nil, impl.EncodeErrorAt(err, strconv.Itoa(i))
+			}
+			key = binary.AppendUvarint(key, uint64(n.children[i].id))
+		}
+	case map[string]any:
+		n = &dedupNode{kind: KindObject, keys: slices.Sorted(maps.Keys(value))}
+		n.children = make([]*dedupNode, len(n.keys))
+		key = append(key, 'o')
+		for i, k := range n.keys {
+			if n.children[i], err = d.scan(value[k]); err != nil {
+				return nil, impl.EncodeErrorAt(err, k)
+			}
+			key = binary.AppendUvarint(key, uint64(len(k)))
+			key = append(key, k...)
+			key = binary.AppendUvarint(key, uint64(n.children[i].id))
+		}
+	default:
+		n = &dedupNode{value: v}
+		buf := bytes.NewBuffer(append(key, 's'))
+		if err = d.encoder.WriteValue(buf, v); err != nil {
+			return
+		}
+		key = buf.Bytes()
+	}
+	id, ok := d.ids[string(key)]
+	if !ok {
+		id = len(d.ids)
+		d.ids[string(key)] = id
+	}
+	n.id = id
+	return
+}
+
+// count counts the occurrences of subtrees of n. The subtrees of a subtree
+// which occurs more than once are counted only once, because they are
+// written only once.
+func (d *deduper) count(n *dedupNode) {
+	if d.uses[n.id]++; d.uses[n.id] > 1 {
+		return
+	}
+	for _, child := range n.children {
+		d.count(child)
+	}
+}
+
+// write writes the referenced values followed by the root value to w,
+// where the referenced values start at position base in the database.
+func (d *deduper) write(w *bytes.Buffer, base uint64) (err error) {
+	d.refs.Reset()
+	d.base = base
+	d.built = make(map[int]any)
+	root, err := d.content(d.root)
+	if err != nil {
+		return
+	}
+	d.refsSize = d.refs.Len()
+	if _, err = d.refs.WriteTo(w); err != nil {
+		return
+	}
+	return d.encoder.WriteValue(w, root)
+}
+
+// value returns the value to write for n, which is a ref if n is shared.
+func (d *deduper) value(n *dedupNode) (v any, err error) {
+	if n.kind == KindAny || d.uses[n.id] < 2 {
+		return d.content(n)
+	}
+	if v, ok := d.built[n.id]; ok {
+		return v, nil
+	}
+	if v, err = d.content(n); err != nil {
+		return
+	}
+	start := d.refs.Len()
+	if err = d.encoder.WriteValue(&d.refs, v); err != nil {
+		return
+	}
+	if d.refs.Len()-start > impl.RefSize {
+		v = impl.Ref(d.base + uint64(start))
+	} else {
+		// Not worth a ref.
+		d.refs.Truncate(start)
+	}
+	d.built[n.id] = v
+	return
+}
+
+// content returns the value of n, whose shared subtrees are replaced by refs.
+func (d *deduper) content(n *dedupNode) (v any, err error) {
+	switch n.kind {
+	case KindArray:
+		ary := make([]any, len(n.children))
+		for i, child := range n.children {
+			if ary[i], err = d.value(child); err != nil {
+				return
+			}
+		}
+		return ary, nil
+	case KindObject:
+		obj := make(map[string]any, len(n.children))
+		for i, child := range n.children {
+			if obj[n.keys[i]], err = d.value(child); err != nil {
+				return
+			}
+		}
+		return obj, nil
+	}
+	return n.value, nil
+}
@@ -0,0 +1,86 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWithDedup(t *testing.T) {
+	block := func() map[string]any {
+		return map[string]any{
+			"timeout": 30,
+			"retries": []any{1, 2, 4, 8},
+			"tls":     map[string]any{"enabled": true, "ciphers": []any{"a", "b"}},
+		}
+	}
+	servers := make(map[string]any)
+	for i := range 20 {
+		servers["server"+strconv.Itoa(i)] = map[string]any{"name": strconv.Itoa(i), "options": block()}
+	}
+	value := map[string]any{
+		"servers": servers,
+		"default": block(),
+		"list":    []any{block(), []any{}, []any{}, "str"},
+	}
+
+	var plain, deduped bytes.Buffer
+	if err := hashive.Write(&plain, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := hashive.Write(&deduped, value, hashive.WithDedup()); err != nil {
+		t.Fatal(err)
+	}
+	if deduped.Len() >= plain.Len()/2 {
+		t.Fatal(deduped.Len(), plain.Len())
+	}
+
+	h, err := hashive.New(bytes.NewReader(deduped.Bytes()), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := hashive.NewBytes(plain.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValue, err := want.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, wantValue) {
+		t.Fatal(v)
+	}
+	if v, err := h.Query("servers", "server3", "options", "tls", "ciphers", "1"); err != nil {
+		t.Fatal(err)
+	} else if v != "b" {
+		t.Fatal(v)
+	}
+	if keys, _, err := h.KeysPage("", 10, "default", "tls"); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 2 {
+		t.Fatal(keys)
+	}
+	var out strings.Builder
+	if err := h.Dump(&out, "servers", "server1"); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(out.String(), `"options" ref size=9`) {
+		t.Fatal(out.String())
+	}
+
+	// Values without duplicates are written as is.
+	var single bytes.Buffer
+	if err := hashive.Write(&single, map[string]any{"a": 1}, hashive.WithDedup()); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := hashive.NewBytes(single.Bytes()); err != nil {
+		t.Fatal(err)
+	} else if v, err := h.Query("a"); err != nil || v != int64(1) {
+		t.Fatal(v, err)
+	}
+}
@@ -0,0 +1,51 @@
+package hashive
+
+// QueryDefault is like [Hashive.Query], but returns def instead of
+// [ErrNotFound] if the path does not map to any value. Null values exist,
+// so they are returned as nil rather than def.
+func (h *Hashive) QueryDefault(def any, path ...string) (v any, err error) {
+	if v, err = h.Query(path...); err == ErrNotFound {
+		return def, nil
+	}
+	return
+}
+
+// queryDefault queries a value mapped by the path into a T, or returns def
+// if the path does not map to any value. See [Hashive.QueryInto].
+func queryDefault[T any](h *Hashive, def T, path []string) (v T, err error) {
+	if err = h.QueryInto(&v, path...); err == ErrNotFound {
+		return def, nil
+	}
+	return
+}
+
+// QueryStringDefault queries a string mapped by the path, or returns def
+// if the path does not map to any value.
+// An error will be returned if the value is not a string.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryStringDefault(def string, path ...string) (string, error) {
+	return queryDefault(h, def, path)
+}
+
+// QueryInt64Default is like [Hashive.QueryStringDefault] but queries a number
+// which can be converted to int64 without overflow.
+func (h *Hashive) QueryInt64Default(def int64, path ...string) (int64, error) {
+	return queryDefault(h, def, path)
+}
+
+// QueryUint64Default is like [Hashive.QueryStringDefault] but queries a number
+// which can be converted to uint64 without overflow.
+func (h *Hashive) QueryUint64Default(def uint64, path ...string) (uint64, error) {
+	return queryDefault(h, def, path)
+}
+
+// QueryFloat64Default is like [Hashive.QueryStringDefault] but queries a number.
+func (h *Hashive) QueryFloat64Default(def float64, path ...string) (float64, error) {
+	return queryDefault(h, def, path)
+}
+
+// QueryBoolDefault is like [Hashive.QueryStringDefault] but queries a bool.
+func (h *Hashive) QueryBoolDefault(def bool, path ...string) (bool, error) {
+	return queryDefault(h, def, path)
+}
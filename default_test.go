@@ -0,0 +1,68 @@
+package hashive_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryDefault(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"name":  "app",
+		"port":  8080,
+		"ratio": 0.5,
+		"debug": true,
+		"null":  nil,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.QueryDefault("def", "name"); err != nil || v != "app" {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryDefault("def", "missing"); err != nil || v != "def" {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryDefault("def", "null"); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryDefault("def", "name", "x"); err != nil || v != "def" {
+		t.Fatal(v, err)
+	}
+
+	if v, err := h.QueryStringDefault("def", "name"); err != nil || v != "app" {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryStringDefault("def", "missing"); err != nil || v != "def" {
+		t.Fatal(v, err)
+	}
+	if _, err := h.QueryStringDefault("def", "port"); err == nil {
+		t.Fatal("expected error")
+	}
+	if v, err := h.QueryInt64Default(1, "port"); err != nil || v != 8080 {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryInt64Default(1, "missing"); err != nil || v != 1 {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryUint64Default(1, "port"); err != nil || v != 8080 {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryFloat64Default(1, "ratio"); err != nil || v != 0.5 {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryFloat64Default(1, "missing"); err != nil || v != 1 {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryBoolDefault(false, "debug"); err != nil || !v {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryBoolDefault(true, "missing"); err != nil || !v {
+		t.Fatal(v, err)
+	}
+}
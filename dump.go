@@ -0,0 +1,48 @@
+package hashive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Dump writes a human-readable tree of the value mapped by the path and all
+// the values in it to w. Each line shows the key or index of a value, its
+// type as it is stored, its encoded size and its offset in the database:
+//
+//	/ object size=42 offset=57
+//	  "name" string size=6 offset=78
+//	  "list" fixedArray size=8 offset=88
+//	    0 int size=2 offset=92
+//
+// Only the type marks and sizes are read, values are not decoded.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Dump(w io.Writer, path ...string) (err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	buffered := bufio.NewWriter(w)
+	// Types of the ancestors of the current value.
+	var types []impl.Type
+	err = impl.Walk(h.r, h.options.maxDepth, func(p []string, t impl.Type, offset, size int64) (err error) {
+		types = append(types[:len(p)], t)
+		buffered.WriteString(strings.Repeat("  ", len(p)))
+		if len(p) == 0 {
+			buffered.WriteString("/")
+		} else if key := p[len(p)-1]; kindOfType(types[len(p)-1]) == KindObject {
+			fmt.Fprintf(buffered, "%q", key)
+		} else {
+			buffered.WriteString(key)
+		}
+		_, err = fmt.Fprintf(buffered, " %v size=%v offset=%v\n", t, size, offset)
+		return
+	})
+	if err != nil {
+		return
+	}
+	return buffered.Flush()
+}
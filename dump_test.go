@@ -0,0 +1,50 @@
+package hashive_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"a b":  "str",
+		"list": []any{1, "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := h.Dump(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatal(out.String())
+	}
+	if !strings.HasPrefix(lines[0], "/ object size=") {
+		t.Fatal(lines[0])
+	}
+	for _, want := range []string{`  "a b" string size=5 offset=`, `  "list" array size=`, "    0 int size=2 offset=", "    1 string size=3 offset="} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatal(out.String(), want)
+		}
+	}
+
+	out.Reset()
+	if err := h.Dump(&out, "list", "1"); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(out.String(), "/ string size=3 offset=") || strings.Count(out.String(), "\n") != 1 {
+		t.Fatal(out.String())
+	}
+	if err := h.Dump(&out, "none"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
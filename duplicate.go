@@ -0,0 +1,132 @@
+package hashive
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DuplicateKeyPolicy decides what to do with the keys appearing more than
+// once in an object, which can only happen in an [OrderedObject] or JSON
+// input, because Go maps have unique keys. See [WithDuplicateKeys].
+type DuplicateKeyPolicy int
+
+const (
+	DuplicateKeepLast   DuplicateKeyPolicy = iota // Keep the last value, as encoding/json does.
+	DuplicateKeepFirst                            // Keep the first value.
+	DuplicateError                                // Fail with a [*DuplicateKeyError].
+	DuplicateCollect                              // Collect all the values into an array.
+	DuplicateMultiValue                           // Collect all the values into a [MultiValue].
+)
+
+// WithDuplicateKeys sets the policy of duplicate keys in [OrderedObject]
+// values and JSON input. The default is [DuplicateKeepLast].
+// Whichever value is kept, the key stays at its first position.
+func WithDuplicateKeys(policy DuplicateKeyPolicy) WriteOption {
+	return func(o *writeOptions) {
+		o.duplicateKeys = policy
+	}
+}
+
+// DuplicateKeyError is returned when an object has duplicate keys
+// and the policy is [DuplicateError].
+type DuplicateKeyError struct {
+	Path []string // The path to the object.
+	Key  string
+}
+
+func (err *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %q at /%v", err.Key, strings.Join(err.Path, "/"))
+}
+
+// dedupKeys returns the entries of obj at path with unique keys according to policy.
+func (obj OrderedObject) dedupKeys(path []string, policy DuplicateKeyPolicy) (OrderedObject, error) {
+	index := make(map[string]int, len(obj))
+	result := make(OrderedObject, 0, len(obj))
+	var collected map[string]bool // keys whose values are collected into arrays
+	for _, entry := range obj {
+		i, ok := index[entry.Key]
+		if !ok {
+			index[entry.Key] = len(result)
+			result = append(result, entry)
+			continue
+		}
+		switch policy {
+		case DuplicateKeepLast:
+			result[i].Value = entry.Value
+		case DuplicateError:
+			return nil, &DuplicateKeyError{slices.Clone(path), entry.Key}
+		case DuplicateCollect, DuplicateMultiValue:
+			if collected == nil {
+				collected = make(map[string]bool)
+			}
+			if !collected[entry.Key] {
+				result[i].Value = []any{result[i].Value}
+				collected[entry.Key] = true
+			}
+			result[i].Value = append(result[i].Value.([]any), entry.Value)
+		}
+	}
+	if policy == DuplicateMultiValue {
+		for i := range result {
+			if collected[result[i].Key] {
+				result[i].Value = MultiValue(result[i].Value.([]any))
+			}
+		}
+	}
+	return result, nil
+}
+
+// isOrderedObject returns whether v is an [OrderedObject].
+func isOrderedObject(v any) bool {
+	_, ok := v.(OrderedObject)
+	return ok
+}
+
+// dedupKeys applies policy to every [OrderedObject] in v at path.
+func dedupKeys(path []string, v any, policy DuplicateKeyPolicy) (_ any, err error) {
+	switch value := v.(type) {
+	case OrderedObject:
+		if value, err = value.dedupKeys(path, policy); err != nil {
+			return
+		}
+		for i := range value {
+			if value[i].Value, err = dedupKeys(append(path, value[i].Key), value[i].Value, policy); err != nil {
+				return
+			}
+		}
+		return value, nil
+	case sideValue:
+		inner, _, _ := value.unwrap()
+		if inner, err = dedupKeys(path, inner, policy); err != nil {
+			return
+		}
+		return value.rewrap(inner), nil
+	case []any:
+		ary := make([]any, len(value))
+		for i, elem := range value {
+			if ary[i], err = dedupKeys(append(path, strconv.Itoa(i)), elem, policy); err != nil {
+				return
+			}
+		}
+		return ary, nil
+	case map[string]any:
+		obj := make(map[string]any, len(value))
+		for key, elem := range value {
+			if obj[key], err = dedupKeys(append(path, key), elem, policy); err != nil {
+				return
+			}
+		}
+		return obj, nil
+	case []Interval:
+		intervals := slices.Clone(value)
+		for i := range intervals {
+			if intervals[i].Value, err = dedupKeys(append(path, strconv.Itoa(i)), intervals[i].Value, policy); err != nil {
+				return
+			}
+		}
+		return intervals, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,66 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWithDuplicateKeys(t *testing.T) {
+	const input = `{"a":1,"b":{"x":[{"k":1,"k":2}]},"a":2,"a":3}`
+	for _, test := range []struct {
+		policy hashive.DuplicateKeyPolicy
+		value  any
+	}{
+		{hashive.DuplicateKeepLast, map[string]any{"a": 3.0, "b": map[string]any{"x": []any{map[string]any{"k": 2.0}}}}},
+		{hashive.DuplicateKeepFirst, map[string]any{"a": 1.0, "b": map[string]any{"x": []any{map[string]any{"k": 1.0}}}}},
+		{hashive.DuplicateCollect, map[string]any{"a": []any{1.0, 2.0, 3.0}, "b": map[string]any{"x": []any{map[string]any{"k": []any{1.0, 2.0}}}}}},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.WriteJSONString(&buf, input, hashive.WithDuplicateKeys(test.policy), hashive.WithKeyOrder()); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, test.value) {
+			t.Fatal(test.policy, v, err)
+		}
+		if keys, err := h.OrderedKeys(); err != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+			t.Fatal(test.policy, keys, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := hashive.WriteJSONString(&buf, input, hashive.WithDuplicateKeys(hashive.DuplicateError))
+	var dupErr *hashive.DuplicateKeyError
+	if !errors.As(err, &dupErr) || !reflect.DeepEqual(dupErr.Path, []string{"b", "x", "0"}) || dupErr.Key != "k" {
+		t.Fatal(err)
+	}
+
+	value := map[string]any{"m": hashive.ValueWithMeta{
+		Value: hashive.OrderedObject{{"id", 1}, {"id", 2}},
+		Meta:  map[string]any{"n": 1},
+	}}
+	err = hashive.Write(&buf, value, hashive.WithDuplicateKeys(hashive.DuplicateError))
+	if !errors.As(err, &dupErr) || !reflect.DeepEqual(dupErr.Path, []string{"m"}) || dupErr.Key != "id" {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err = hashive.Write(&buf, value, hashive.WithDuplicateKeys(hashive.DuplicateCollect)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, meta, err := h.QueryWithMeta("m"); err != nil ||
+		!reflect.DeepEqual(v, map[string]any{"id": []any{int64(1), int64(2)}}) ||
+		!reflect.DeepEqual(meta, map[string]any{"n": int64(1)}) {
+		t.Fatal(v, meta, err)
+	}
+}
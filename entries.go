@@ -0,0 +1,81 @@
+package hashive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// HashedEntry is an entry of the root object written by [WriteEntries],
+// whose key is hashed by [HashKey] with seed 0, and value is encoded by
+// [EncodeValue].
+type HashedEntry = impl.HashedEntry
+
+// hashedEntries is the value written by [WriteEntries].
+type hashedEntries []HashedEntry
+
+// EncodeValue encodes v as the value of a [HashedEntry] with opts, which
+// must be the options passed to [WriteEntries]. The values containing
+// [ValueWithMeta] and the other values stored in side tables can't be
+// encoded, because the side tables are written with the root object.
+func EncodeValue(v any, opts ...WriteOption) (_ []byte, err error) {
+	options := newWriteOptions(opts)
+	if options.transform != nil {
+		v, _ = transform(nil, v, options.transform)
+	}
+	tables := make(map[string]map[string]any)
+	if v, err = options.prepare(nil, v, tables); err != nil {
+		return
+	}
+	if len(tables) > 0 {
+		return nil, errors.New("side values can't be encoded alone")
+	}
+	if err = options.schema.Validate(v); err != nil {
+		return
+	}
+	if options.blobs != nil {
+		if v, err = options.blobs.extract(v); err != nil {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err = options.newEncoder(impl.NewGobEncoder()).WriteValue(&buf, v); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteEntries is like [Write] but writes the object of entries, whose keys
+// are hashed and values are encoded already, so that hashing and encoding
+// can be done in parallel by the producers of entries, such as the shards
+// of a generator. Keys are checked by [WithMaxKeyLength] and [WithUTF8],
+// but never replaced, because the hashes are computed already, so
+// [UTF8Replace] fails as [UTF8Reject] does. A [*DuplicateKeyError] is
+// returned if a key appears more than once.
+// [WithTransform], [WithSchema] and [WithDedup] are applied to the values by
+// [EncodeValue] instead, and [WithFixedKeys] and [WithIntKeys] are ignored.
+func WriteEntries(w io.Writer, entries []HashedEntry, opts ...WriteOption) (err error) {
+	options := newWriteOptions(opts)
+	for _, entry := range entries {
+		if options.maxKeyLength > 0 && len(entry.Key) > options.maxKeyLength {
+			return &EncodeError{
+				Path: []string{entry.Key},
+				Err:  &KeyLengthError{Length: uint64(len(entry.Key)), MaxKeyLength: options.maxKeyLength},
+			}
+		}
+		if options.utf8 != UTF8Keep && !utf8.ValidString(entry.Key) {
+			return &InvalidUTF8Error{[]string{entry.Key}, true}
+		}
+	}
+	opts = append(opts[:len(opts):len(opts)], func(o *writeOptions) {
+		o.transform, o.schema, o.dedup = nil, nil, false
+	})
+	var encodeErr *EncodeError
+	if err = Write(w, hashedEntries(entries), opts...); errors.As(err, &encodeErr) && encodeErr.Err == impl.ErrDuplicateKey {
+		return &DuplicateKeyError{Key: encodeErr.Path[0]}
+	}
+	return
+}
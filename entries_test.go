@@ -0,0 +1,69 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWriteEntries(t *testing.T) {
+	opts := []hashive.WriteOption{hashive.WithHashOrder()}
+	entries := make([]hashive.HashedEntry, 1000)
+	var wg sync.WaitGroup
+	errs := make([]error, len(entries))
+	for i := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := "key" + strconv.Itoa(i)
+			value, err := hashive.EncodeValue(map[string]any{"i": i, "tags": []any{"a"}}, opts...)
+			entries[i], errs[i] = hashive.HashedEntry{Hash: hashive.HashKey(0, key), Key: key, Value: value}, err
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := hashive.WriteEntries(&buf, entries, opts...); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.NewBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 500, 999} {
+		if v, err := h.Query("key"+strconv.Itoa(i), "i"); err != nil || v != int64(i) {
+			t.Fatal(i, v, err)
+		}
+	}
+	if _, err := h.Query("key1000"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+
+	// Duplicate keys.
+	var dupErr *hashive.DuplicateKeyError
+	if err := hashive.WriteEntries(&buf, append(entries, entries[42]), opts...); !errors.As(err, &dupErr) || dupErr.Key != "key42" {
+		t.Fatal(err)
+	}
+
+	// Invalid keys.
+	var keyLengthErr *hashive.KeyLengthError
+	if err := hashive.WriteEntries(&buf, entries, hashive.WithMaxKeyLength(5)); !errors.As(err, &keyLengthErr) {
+		t.Fatal(err)
+	}
+	invalid := []hashive.HashedEntry{{Hash: hashive.HashKey(0, "\xff"), Key: "\xff", Value: entries[0].Value}}
+	var utf8Err *hashive.InvalidUTF8Error
+	if err := hashive.WriteEntries(&buf, invalid, hashive.WithUTF8(hashive.UTF8Replace)); !errors.As(err, &utf8Err) || !utf8Err.Key {
+		t.Fatal(err)
+	}
+
+	// Side values.
+	if _, err := hashive.EncodeValue(hashive.ValueWithMeta{Value: 1, Meta: map[string]any{"m": 1}}); err == nil {
+		t.Fatal("side value encoded")
+	}
+}
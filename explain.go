@@ -0,0 +1,77 @@
+package hashive
+
+import (
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Step is the lookup of a key or index in a container, see [Hashive.Explain].
+type Step struct {
+	Key    string // The key or index looked up.
+	Layout string // The layout of the container, such as "object" and "fixedArray".
+	Offset int64  // The offset of the table of the container.
+	// The number of hash buckets, keys of objects written with
+	// [WithFixedKeys] or [WithIntKeys], or elements of arrays.
+	Size   uint64
+	Bucket int64 // The hash bucket of the key, or -1 if not hashed.
+	Chain  int64 // The number of entries in the bucket, or -1 if not hashed.
+	Seeks  int   // The number of seeks of the lookup.
+	Found  bool  // Whether the key is found.
+	// The offset of the value if found.
+	ValueOffset int64
+}
+
+// Explain performs the query of the path and returns the lookups it does,
+// such as hash buckets, chain lengths, seeks and offsets, so that layout
+// options can be evaluated before deployment. The lookup of the last step
+// is not found if the path does not map to any value.
+// [ErrNotFound] will be returned if the path passes through a value which
+// is not an array or object, or the value is hidden by [WithACL].
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Explain(path ...string) (steps []Step, err error) {
+	if err = h.checkACL(path); err != nil {
+		return
+	}
+	container, err := h.container(nil)
+	if err != nil {
+		return
+	}
+	for i, key := range path {
+		var lookup impl.Lookup
+		switch c := container.(type) {
+		case *impl.Object:
+			lookup, err = c.Explain(key)
+		case *impl.Array:
+			var index int
+			if index, err = parseIndex(key); err != nil {
+				return
+			}
+			lookup, err = c.Explain(index)
+		default:
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return
+		}
+		steps = append(steps, Step{
+			Key:         key,
+			Layout:      lookup.Type.String(),
+			Offset:      lookup.Pos,
+			Size:        lookup.Size,
+			Bucket:      lookup.Bucket,
+			Chain:       lookup.Chain,
+			Seeks:       lookup.Seeks,
+			Found:       lookup.Found,
+			ValueOffset: lookup.ValuePos,
+		})
+		if !lookup.Found {
+			return
+		}
+		if i < len(path)-1 {
+			if container, err = impl.ReadValueDepth(h.r, false, h.options.maxDepth, h.options.maxKeyLength); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
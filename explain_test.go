@@ -0,0 +1,60 @@
+package hashive_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestExplain(t *testing.T) {
+	obj := map[string]any{"list": []any{1, 2, 3}, "var": []any{"a", 1}, "scalar": 1}
+	for i := range 100 {
+		obj["key"+strconv.Itoa(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"obj": obj, "ids": map[string]any{"10": "a", "20": "b"}}, hashive.WithIntKeys()); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := h.Explain("obj", "list", "2")
+	if err != nil || len(steps) != 3 {
+		t.Fatal(steps, err)
+	}
+	for i, want := range []struct {
+		layout string
+		hashed bool
+	}{{"object", true}, {"object", true}, {"fixedArray", false}} {
+		step := steps[i]
+		if step.Layout != want.layout || !step.Found || step.Seeks == 0 || (step.Bucket >= 0) != want.hashed || (step.Chain > 0) != want.hashed {
+			t.Fatal(i, step)
+		}
+	}
+	if steps[1].Size < 100 || steps[2].Size != 3 || steps[2].Offset <= steps[1].Offset || steps[2].ValueOffset <= steps[2].Offset {
+		t.Fatal(steps)
+	}
+
+	if steps, err := h.Explain("obj", "var", "1"); err != nil || len(steps) != 3 || steps[2].Layout != "array" || !steps[2].Found {
+		t.Fatal(steps, err)
+	}
+	if steps, err := h.Explain("ids", "20"); err != nil || len(steps) != 2 || steps[1].Layout != "intKeyObject" || steps[1].Size != 2 || steps[1].Bucket != -1 || !steps[1].Found {
+		t.Fatal(steps, err)
+	}
+	// Missing keys.
+	for _, path := range [][]string{{"obj", "missing"}, {"ids", "30"}, {"obj", "list", "3"}} {
+		if steps, err := h.Explain(path...); err != nil || len(steps) != len(path) || steps[len(steps)-1].Found {
+			t.Fatal(path, steps, err)
+		}
+	}
+	if _, err := h.Explain("obj", "scalar", "x"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if steps, err := h.Explain(); err != nil || len(steps) != 0 {
+		t.Fatal(steps, err)
+	}
+}
@@ -0,0 +1,12 @@
+package hashive
+
+// MemoryBound returns the max memory used by h within the budget of
+// [WithMemoryBudget]: the memory charged and the max size of the result
+// cache.
+func MemoryBound(h *Hashive) int64 {
+	bound := h.options.charged
+	if h.cache != nil {
+		bound += h.cache.maxSize
+	}
+	return bound
+}
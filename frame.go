@@ -0,0 +1,115 @@
+package hashive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A frame is a Hashive document written by [Write] prefixed with its size
+// encoded as a varint(see [binary.AppendUvarint]). Frames are used to send
+// Hashive documents over network connections.
+
+// ErrFrameTooLarge is returned by [ReadFrame] if the size of a frame
+// exceeds the limit.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// AppendFrame appends the frame of value to dst and returns the extended buffer.
+// The value is written with opts, see [Write].
+func AppendFrame(dst []byte, value any, opts ...WriteOption) ([]byte, error) {
+	var doc bytes.Buffer
+	if err := Write(&doc, value, opts...); err != nil {
+		return dst, err
+	}
+	dst = binary.AppendUvarint(dst, uint64(doc.Len()))
+	return append(dst, doc.Bytes()...), nil
+}
+
+// WriteFrame writes the frame of value to w.
+// The value is written with opts, see [Write].
+func WriteFrame(w io.Writer, value any, opts ...WriteOption) (err error) {
+	var doc bytes.Buffer
+	if err = Write(&doc, value, opts...); err != nil {
+		return
+	}
+	return writeFrame(w, &doc)
+}
+
+// writeFrame writes doc as a frame to w.
+func writeFrame(w io.Writer, doc *bytes.Buffer) (err error) {
+	var size [binary.MaxVarintLen64]byte
+	if _, err = w.Write(binary.AppendUvarint(size[:0], uint64(doc.Len()))); err != nil {
+		return
+	}
+	_, err = doc.WriteTo(w)
+	return
+}
+
+// ReadFrame reads a frame from r into memory and returns the document in it.
+// No bytes after the frame are read from r, so the next frame can be read
+// from r again. [ErrFrameTooLarge] is returned if the size of the document
+// exceeds maxSize, and [io.EOF] is returned if r is at EOF.
+// The document is opened with opts, see [New].
+func ReadFrame(r io.Reader, maxSize int64, opts ...Option) (h *Hashive, err error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &oneByteReader{r: r}
+	}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w (incomplete frame size)", ErrTruncated)
+		}
+		return // io.EOF if no more frames.
+	}
+	if size > math.MaxInt64 || int64(size) > maxSize {
+		return nil, fmt.Errorf("%w (%v bytes, limit %v)", ErrFrameTooLarge, size, maxSize)
+	}
+	// Grow the buffer progressively instead of trusting size.
+	var doc bytes.Buffer
+	n, err := io.CopyN(&doc, r, int64(size))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("%w (expected %v bytes, have %v)", ErrTruncated, size, n)
+		}
+		return
+	}
+	return NewBytes(doc.Bytes(), opts...)
+}
+
+// ParseFrame returns the document in the frame at the start of data, which is
+// not copied and must not be modified while h is in use. Argument rest is
+// the bytes after the frame.
+// The document is opened with opts, see [NewBytes].
+func ParseFrame(data []byte, opts ...Option) (h *Hashive, rest []byte, err error) {
+	size, n := binary.Uvarint(data)
+	if n == 0 {
+		return nil, nil, fmt.Errorf("%w (incomplete frame size)", ErrTruncated)
+	} else if n < 0 {
+		return nil, nil, errors.New("invalid frame size")
+	}
+	data = data[n:]
+	if size > uint64(len(data)) {
+		return nil, nil, fmt.Errorf("%w (expected %v bytes, have %v)", ErrTruncated, size, len(data))
+	}
+	if h, err = NewBytes(data[:size:size], opts...); err != nil {
+		return
+	}
+	return h, data[size:], nil
+}
+
+// oneByteReader implements [io.ByteReader] without reading ahead.
+type oneByteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (r *oneByteReader) ReadByte() (b byte, err error) {
+	if _, err = io.ReadFull(r.r, r.buf[:]); err != nil {
+		return
+	}
+	return r.buf[0], nil
+}
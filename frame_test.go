@@ -0,0 +1,89 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestFrame(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer client.Close()
+		for i := range 3 {
+			if err := hashive.WriteFrame(client, map[string]any{"i": i}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := range 3 {
+		h, err := hashive.ReadFrame(server, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query("i"); err != nil {
+			t.Fatal(err)
+		} else if v != int64(i) {
+			t.Fatal(v)
+		}
+	}
+	if _, err := hashive.ReadFrame(server, 1024); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	frame, err := hashive.AppendFrame(nil, []any{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A size prefix cut off after the first byte, and a cut off document.
+	for _, data := range [][]byte{{0x80}, frame[:len(frame)-1]} {
+		if _, err := hashive.ReadFrame(bytes.NewReader(data), 1024); !errors.Is(err, hashive.ErrTruncated) {
+			t.Fatal(len(data), err)
+		}
+		if _, err := hashive.ReadFrame(io.MultiReader(bytes.NewReader(data)), 1024); !errors.Is(err, hashive.ErrTruncated) {
+			t.Fatal(len(data), err)
+		}
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	data, err := hashive.AppendFrame(nil, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = hashive.AppendFrame(data, "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	h, rest, err := hashive.ParseFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if v, err := h.Query(); err != nil || v != "first" {
+		t.Fatal(v, err)
+	}
+	h, rest, err = hashive.ParseFrame(rest)
+	if err != nil {
+		t.Fatal(err)
+	} else if v, err := h.Query(); err != nil || v != "second" {
+		t.Fatal(v, err)
+	} else if len(rest) != 0 {
+		t.Fatal(rest)
+	}
+	if _, _, err := hashive.ParseFrame(data[:len(data)-1]); err != nil {
+		t.Fatal(err) // The first frame is complete.
+	}
+	if _, _, err := hashive.ParseFrame(data[:5]); !errors.Is(err, hashive.ErrTruncated) {
+		t.Fatal(err)
+	}
+
+	if _, err := hashive.ReadFrame(bytes.NewReader(data), 5); !errors.Is(err, hashive.ErrFrameTooLarge) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,371 @@
+/*
+Package fusefs exposes Hashive databases as read-only file systems, so that
+shell tools and programs not written in Go can browse them.
+
+Every object or array of a database is a directory, whose entries are named
+by the keys of the object, or the indices of the array. The other values are
+files: strings, []byte and gob encoded values contain their raw bytes, and
+the others contain their JSON text followed by a newline. See [EscapeKey]
+for the keys which are not valid file names.
+
+[New] returns the database as an [fs.FS], which can be used by Go programs
+directly, and [Mount] mounts an [fs.FS] with FUSE on Linux.
+*/
+package fusefs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkch/hashive"
+)
+
+// FS is the read-only file system of a database. It is safe for concurrent
+// use.
+type FS struct {
+	mu sync.Mutex // guards h
+	h  *hashive.Hashive
+}
+
+// New returns the file system of the database h. h must not be used by
+// others while the file system is in use, because it is not safe for
+// concurrent use, see [hashive.Hashive.Snapshot].
+func New(h *hashive.Hashive) *FS {
+	return &FS{h: h}
+}
+
+// EscapeKey returns the file name of the object key. The keys which are not
+// valid file names are escaped: "%", "/" and NUL are replaced by "%25",
+// "%2F" and "%00", and the empty key, "." and ".." are named "%", "%2E" and
+// "%2E%2E".
+func EscapeKey(key string) string {
+	switch key {
+	case "":
+		return "%"
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+	if !strings.ContainsAny(key, "%/\x00") {
+		return key
+	}
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; c {
+		case '%':
+			b.WriteString("%25")
+		case '/':
+			b.WriteString("%2F")
+		case 0:
+			b.WriteString("%00")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// UnescapeKey returns the object key of the file name returned by
+// [EscapeKey]. An error will be returned if name is not returned by
+// [EscapeKey].
+func UnescapeKey(name string) (key string, err error) {
+	if name == "%" {
+		return "", nil
+	}
+	if key, err = url.PathUnescape(name); err != nil {
+		return
+	}
+	if EscapeKey(key) != name {
+		return "", errors.New("invalid escaped key " + strconv.Quote(name))
+	}
+	return
+}
+
+// splitName splits the name of a file into the path of the value.
+func splitName(name string) (path []string, err error) {
+	if name == "." {
+		return nil, nil
+	}
+	for elem := range strings.SplitSeq(name, "/") {
+		var key string
+		if key, err = UnescapeKey(elem); err != nil {
+			return
+		}
+		path = append(path, key)
+	}
+	return
+}
+
+// stat returns the kind of the value mapped by the path. The path is looked
+// up level by level, so that the elements of arrays are only named by the
+// indices in canonical decimal form.
+func (fsys *FS) stat(path []string) (kind hashive.Kind, err error) {
+	if kind, err = fsys.h.Kind(); err != nil {
+		return
+	}
+	for i, key := range path {
+		switch kind {
+		case hashive.KindArray:
+			if index, err := strconv.Atoi(key); err != nil || index < 0 || strconv.Itoa(index) != key {
+				return kind, fs.ErrNotExist
+			}
+		case hashive.KindObject:
+		default:
+			return kind, fs.ErrNotExist
+		}
+		if kind, err = fsys.h.Kind(path[:i+1]...); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// pathError returns the [fs.PathError] of err.
+func pathError(op, name string, err error) error {
+	if err == hashive.ErrNotFound {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// Open implements [fs.FS]. The files of objects and arrays implement
+// [fs.ReadDirFile], and the others implement [io.ReaderAt] and [io.Seeker].
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, pathError("open", name, fs.ErrInvalid)
+	}
+	path, err := splitName(name)
+	if err != nil {
+		return nil, pathError("open", name, fs.ErrNotExist)
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	kind, err := fsys.stat(path)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	info := &fileInfo{name: baseName(name), kind: kind}
+	if info.IsDir() {
+		return &dir{fsys: fsys, path: path, info: info}, nil
+	}
+	content, err := fsys.content(path, kind)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	info.size = content.Size()
+	return &file{content, info}, nil
+}
+
+// Stat implements [fs.StatFS].
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, pathError("stat", name, errors.Unwrap(err))
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// ReadDir implements [fs.ReadDirFS]. The entries are sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, pathError("readdir", name, errors.Unwrap(err))
+	}
+	defer f.Close()
+	d, ok := f.(*dir)
+	if !ok {
+		return nil, pathError("readdir", name, errors.New("not a directory"))
+	}
+	entries, err := d.ReadDir(-1)
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, err
+}
+
+// baseName returns the last element of the name of a file.
+func baseName(name string) string {
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// content returns the content of the file of the value mapped by the path,
+// whose kind is kind. The bytes of strings and []byte are read when the
+// content is read.
+func (fsys *FS) content(path []string, kind hashive.Kind) (*io.SectionReader, error) {
+	if kind == hashive.KindString || kind == hashive.KindBinary {
+		r, size, err := fsys.h.QueryReaderAt(path...)
+		if err != nil {
+			return nil, err
+		}
+		return io.NewSectionReader(&lockedReaderAt{&fsys.mu, r}, 0, size), nil
+	}
+	v, err := fsys.h.Query(path...)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if g, ok := v.(hashive.GobValue); ok {
+		buf.Write(g)
+	} else if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		// Not valid JSON, but still readable.
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64) + "\n")
+	} else {
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err = encoder.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	return io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), nil
+}
+
+// lockedReaderAt is an [io.ReaderAt] reading r with mu locked.
+type lockedReaderAt struct {
+	mu *sync.Mutex
+	r  io.ReaderAt
+}
+
+func (r *lockedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.ReadAt(p, off)
+}
+
+// fileInfo implements [fs.FileInfo]. Sys returns the [hashive.Kind] of the
+// value.
+type fileInfo struct {
+	name string
+	size int64
+	kind hashive.Kind
+}
+
+func (info *fileInfo) Name() string       { return info.name }
+func (info *fileInfo) Size() int64        { return info.size }
+func (info *fileInfo) ModTime() time.Time { return time.Time{} }
+func (info *fileInfo) Sys() any           { return info.kind }
+
+func (info *fileInfo) IsDir() bool {
+	return info.kind == hashive.KindObject || info.kind == hashive.KindArray
+}
+
+func (info *fileInfo) Mode() fs.FileMode {
+	if info.IsDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// file is the file of a value other than objects and arrays.
+type file struct {
+	*io.SectionReader
+	info *fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+// dir is the file of an object or array.
+type dir struct {
+	fsys *FS
+	path []string
+	info *fileInfo
+	next string // the cursor of the next page of keys
+	done bool   // whether all the keys are read
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+// readDirPage is the number of keys read at a time by [dir.ReadDir].
+const readDirPage = 1024
+
+// ReadDir implements [fs.ReadDirFile]. The entries are in the order of
+// [hashive.Hashive.KeysPage], and the values hidden by [hashive.WithACL]
+// are not listed.
+func (d *dir) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	for !d.done && (n <= 0 || len(entries) < n) {
+		limit := readDirPage
+		if n > 0 {
+			limit = n - len(entries)
+		}
+		if entries, err = d.readDir(entries, limit); err != nil {
+			return
+		}
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if entries == nil {
+		entries = []fs.DirEntry{}
+	}
+	return
+}
+
+// readDir appends the entries of at most limit keys to entries.
+func (d *dir) readDir(entries []fs.DirEntry, limit int) (_ []fs.DirEntry, err error) {
+	d.fsys.mu.Lock()
+	defer d.fsys.mu.Unlock()
+	keys, next, err := d.fsys.h.KeysPage(d.next, limit, d.path...)
+	if err != nil {
+		return entries, err
+	}
+	path := slices.Clip(d.path)
+	for _, key := range keys {
+		kind, err := d.fsys.h.Kind(append(path, key)...)
+		if err == hashive.ErrNotFound {
+			continue // Hidden
+		} else if err != nil {
+			return entries, err
+		}
+		name := EscapeKey(key)
+		entries = append(entries, &dirEntry{d.fsys, d.childName(name), &fileInfo{name: name, kind: kind}})
+	}
+	d.next, d.done = next, next == ""
+	return entries, nil
+}
+
+// childName returns the name of the file of the entry name in d.
+func (d *dir) childName(name string) string {
+	if len(d.path) == 0 {
+		return name
+	}
+	var b strings.Builder
+	for _, key := range d.path {
+		b.WriteString(EscapeKey(key))
+		b.WriteByte('/')
+	}
+	b.WriteString(name)
+	return b.String()
+}
+
+// dirEntry implements [fs.DirEntry]. The size of the value is read by Info.
+type dirEntry struct {
+	fsys *FS
+	name string // the name of the file in fsys
+	info *fileInfo
+}
+
+func (e *dirEntry) Name() string      { return e.info.name }
+func (e *dirEntry) IsDir() bool       { return e.info.IsDir() }
+func (e *dirEntry) Type() fs.FileMode { return e.info.Mode().Type() }
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	if e.info.IsDir() {
+		return e.info, nil
+	}
+	return e.fsys.Stat(e.name)
+}
@@ -0,0 +1,100 @@
+package fusefs_test
+
+import (
+	"io/fs"
+	"math"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/fusefs"
+)
+
+func newTestFS(t *testing.T) *fusefs.FS {
+	t.Helper()
+	h, err := hashive.NewMemory(map[string]any{
+		"s":   "str",
+		"b":   []byte{0, 1, 2},
+		"n":   1,
+		"f":   math.NaN(),
+		"a":   []any{true, nil, map[string]any{"k": "v"}},
+		"a/b": 1.5,
+		"":    "empty",
+		"..":  "dots",
+		"%":   "percent",
+		"o":   map[string]any{"x": map[string]any{}, "y": []any{}},
+		"secret": map[string]any{
+			"x": 1,
+		},
+	}, hashive.WithACL(hashive.ACLEntry{Path: []string{"secret"}, Capability: "admin"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fusefs.New(h)
+}
+
+func TestFS(t *testing.T) {
+	fsys := newTestFS(t)
+	if err := fstest.TestFS(fsys, "s", "b", "n", "f", "a/0", "a/1", "a/2/k", "a%2Fb", "%", "%2E%2E", "%25", "o/x", "o/y"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"s":      "str",
+		"b":      "\x00\x01\x02",
+		"n":      "1\n",
+		"f":      "NaN\n",
+		"a/0":    "true\n",
+		"a/1":    "null\n",
+		"a/2/k":  "v",
+		"a%2Fb":  "1.5\n",
+		"%":      "empty",
+		"%2E%2E": "dots",
+		"%25":    "percent",
+	} {
+		if data, err := fs.ReadFile(fsys, name); err != nil || string(data) != want {
+			t.Fatal(name, string(data), err)
+		}
+	}
+	for _, name := range []string{"secret", "secret/x", "a/3", "a/00", "a/+1", "s/x", "a%2fb", "%2E", "x"} {
+		if _, err := fs.Stat(fsys, name); !errorsIsNotExist(err) {
+			t.Fatal(name, err)
+		}
+	}
+	info, err := fs.Stat(fsys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() || info.Mode() != fs.ModeDir|0555 || info.Sys() != hashive.KindArray {
+		t.Fatal(info.Mode(), info.Sys())
+	}
+}
+
+func errorsIsNotExist(err error) bool {
+	pathErr, ok := err.(*fs.PathError)
+	return ok && pathErr.Err == fs.ErrNotExist
+}
+
+func TestEscapeKey(t *testing.T) {
+	for key, name := range map[string]string{
+		"":      "%",
+		".":     "%2E",
+		"..":    "%2E%2E",
+		"...":   "...",
+		"a/b":   "a%2Fb",
+		"100%":  "100%25",
+		"a\x00": "a%00",
+		"a b":   "a b",
+	} {
+		if got := fusefs.EscapeKey(key); got != name {
+			t.Fatal(key, got)
+		}
+		if got, err := fusefs.UnescapeKey(name); err != nil || got != key {
+			t.Fatal(name, got, err)
+		}
+	}
+	for _, name := range []string{"%2f", "%41", "%%", "%zz", "."} {
+		if _, err := fusefs.UnescapeKey(name); err == nil {
+			t.Fatal(name)
+		}
+	}
+}
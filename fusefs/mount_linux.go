@@ -0,0 +1,632 @@
+package fusefs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// The opcodes of FUSE requests.
+const (
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opOpen        = 14
+	opRead        = 15
+	opStatfs      = 17
+	opRelease     = 18
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opAccess      = 34
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+)
+
+const (
+	fuseMajor = 7
+	fuseMinor = 31
+
+	inHeaderSize  = 40
+	outHeaderSize = 16
+	attrSize      = 88
+	rootID        = 1
+	maxWrite      = 128 << 10
+	// The buffer of requests must be larger than the write requests, which
+	// are never sent to read-only file systems.
+	bufSize = maxWrite + 4096
+	// The seconds the kernel caches names and attributes.
+	cacheValid = 1
+)
+
+// pollHackName is the name of the empty file in the root, which is polled
+// while mounting, see [pollHack].
+const pollHackName = ".hashive-poll-hack"
+
+// Mount mounts fsys read-only at dir with FUSE, and serves the requests of
+// it in a goroutine until unmount is called. Only the modes and sizes of
+// files are used. Mount requires the permission to mount file systems, or
+// the fusermount3 or fusermount program of libfuse.
+//
+// Once mounted, dir must be unmounted by unmount, which fails if dir is in
+// use. If the process exits without unmounting, dir must be unmounted with
+// "fusermount3 -u" or umount.
+func Mount(dir string, fsys fs.FS) (unmount func() error, err error) {
+	fd, unmountDir, err := mountFUSE(dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "mount", Path: dir, Err: err}
+	}
+	s := &server{
+		fsys:    fsys,
+		fd:      fd,
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+		nodes:   map[uint64]*node{rootID: {name: ".", lookups: 1}},
+		ids:     map[string]uint64{".": rootID},
+		nextID:  rootID + 1,
+		handles: make(map[uint64]any),
+		polling: true,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.serve()
+	}()
+	var once sync.Once
+	unmount = func() (err error) {
+		if err = unmountDir(); err != nil {
+			return &fs.PathError{Op: "unmount", Path: dir, Err: err}
+		}
+		once.Do(func() {
+			<-done
+			err = syscall.Close(fd)
+		})
+		return
+	}
+	if err = pollHack(dir); err != nil {
+		unmount()
+		return nil, &fs.PathError{Op: "mount", Path: dir, Err: err}
+	}
+	return unmount, nil
+}
+
+// pollHack polls a file of the file system mounted at dir, so that the
+// kernel learns that the file system does not support poll. Otherwise,
+// opening a file of it in the process serving it deadlocks if GOMAXPROCS
+// is 1, because the os package adds files to epoll in raw system calls,
+// which keep the only P while waiting for the POLL request to be served.
+func pollHack(dir string) error {
+	fd, err := syscall.Open(filepath.Join(dir, pollHackName), syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(epfd)
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN}
+	// Not a raw system call, and the result doesn't matter.
+	syscall.Syscall6(syscall.SYS_EPOLL_CTL, uintptr(epfd), syscall.EPOLL_CTL_ADD, uintptr(fd), uintptr(unsafe.Pointer(&event)), 0, 0)
+	return nil
+}
+
+// mountFUSE mounts dir, and returns the FUSE device of it, and the function
+// to unmount dir. mount(2) is used if permitted, otherwise fusermount.
+func mountFUSE(dir string) (fd int, unmount func() error, err error) {
+	fd, err = syscall.Open("/dev/fuse", syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	data := fmt.Sprintf("fd=%d,rootmode=%o,user_id=%d,group_id=%d", fd, syscall.S_IFDIR, os.Getuid(), os.Getgid())
+	err = syscall.Mount("hashive", dir, "fuse.hashive", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_RDONLY, data)
+	if err == nil {
+		return fd, func() error { return syscall.Unmount(dir, 0) }, nil
+	}
+	syscall.Close(fd)
+	if err != syscall.EPERM {
+		return
+	}
+	return fusermount(dir)
+}
+
+// fusermount mounts dir with the fusermount program, which sends the FUSE
+// device back through a socket.
+func fusermount(dir string) (fd int, unmount func() error, err error) {
+	prog, err := exec.LookPath("fusermount3")
+	if err != nil {
+		if prog, err = exec.LookPath("fusermount"); err != nil {
+			return
+		}
+	}
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	local, remote := os.NewFile(uintptr(fds[0]), "fusermount"), os.NewFile(uintptr(fds[1]), "fusermount")
+	defer local.Close()
+	cmd := exec.Command(prog, "-o", "ro,nosuid,nodev,fsname=hashive,subtype=hashive", "--", dir)
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	cmd.ExtraFiles = []*os.File{remote}
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	remote.Close()
+	if err != nil {
+		return
+	}
+	buf, oob := make([]byte, 1), make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(fds[0], buf, oob, 0)
+	if err != nil {
+		return
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return
+	}
+	if len(msgs) != 1 {
+		return -1, nil, errors.New("no FUSE device received from " + prog)
+	}
+	rights, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return
+	}
+	if len(rights) != 1 {
+		return -1, nil, errors.New("no FUSE device received from " + prog)
+	}
+	syscall.CloseOnExec(rights[0])
+	return rights[0], func() error {
+		return exec.Command(prog, "-u", "--", dir).Run()
+	}, nil
+}
+
+// node is a file looked up by the kernel.
+type node struct {
+	name    string // the name in the file system
+	lookups uint64 // the number of lookups not forgotten
+}
+
+// server serves the FUSE requests of a file system. Requests are served one
+// at a time.
+type server struct {
+	fsys     fs.FS
+	fd       int
+	uid, gid uint32
+
+	nodes      map[uint64]*node
+	ids        map[string]uint64 // the IDs of nodes by name
+	nextID     uint64
+	handles    map[uint64]any // fs.File or *dirHandle
+	nextHandle uint64
+
+	polling    bool   // whether the file of pollHackName exists
+	pollHandle uint64 // the handle of the file of pollHackName
+}
+
+// request is a FUSE request.
+type request struct {
+	opcode uint32
+	unique uint64
+	nodeID uint64
+	in     []byte // the input after the header
+}
+
+// serve serves requests until the file system is unmounted.
+func (s *server) serve() {
+	buf := make([]byte, bufSize)
+	for {
+		n, err := syscall.Read(s.fd, buf)
+		if err == syscall.EINTR || err == syscall.ENOENT || err == syscall.EAGAIN {
+			continue // Interrupted
+		} else if err != nil {
+			// ENODEV after unmounted.
+			break
+		}
+		if n < inHeaderSize {
+			continue
+		}
+		length := min(int(binary.NativeEndian.Uint32(buf)), n)
+		req := request{
+			opcode: binary.NativeEndian.Uint32(buf[4:]),
+			unique: binary.NativeEndian.Uint64(buf[8:]),
+			nodeID: binary.NativeEndian.Uint64(buf[16:]),
+			in:     buf[inHeaderSize:max(length, inHeaderSize)],
+		}
+		if req.opcode == opDestroy {
+			s.reply(req, nil, 0)
+			break
+		}
+		s.handle(req)
+	}
+	for _, h := range s.handles {
+		if f, ok := h.(fs.File); ok {
+			f.Close()
+		}
+	}
+}
+
+// reply replies req with out, or errno if it is not 0.
+func (s *server) reply(req request, out []byte, errno syscall.Errno) {
+	if errno != 0 {
+		out = nil
+	}
+	msg := make([]byte, outHeaderSize, outHeaderSize+len(out))
+	binary.NativeEndian.PutUint32(msg, uint32(outHeaderSize+len(out)))
+	binary.NativeEndian.PutUint32(msg[4:], uint32(-int32(errno)))
+	binary.NativeEndian.PutUint64(msg[8:], req.unique)
+	msg = append(msg, out...)
+	// Fails if the request is interrupted, or the file system is unmounted.
+	syscall.Write(s.fd, msg)
+}
+
+// errno returns the errno of err.
+func errno(err error) syscall.Errno {
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, fs.ErrNotExist):
+		return syscall.ENOENT
+	case errors.Is(err, fs.ErrPermission):
+		return syscall.EACCES
+	case errors.Is(err, fs.ErrInvalid):
+		return syscall.EINVAL
+	default:
+		return syscall.EIO
+	}
+}
+
+// cString returns the NUL terminated string at the start of p.
+func cString(p []byte) string {
+	for i, c := range p {
+		if c == 0 {
+			return string(p[:i])
+		}
+	}
+	return string(p)
+}
+
+// handle handles req.
+func (s *server) handle(req request) {
+	if req.opcode == opInit {
+		s.init(req)
+		return
+	}
+	if req.opcode == opForget || req.opcode == opBatchForget || req.opcode == opInterrupt {
+		s.forget(req)
+		return // No reply
+	}
+	var n *node
+	if req.opcode != opStatfs {
+		if n = s.nodes[req.nodeID]; n == nil {
+			s.reply(req, nil, syscall.ENOENT)
+			return
+		}
+	}
+	var out []byte
+	var err error
+	switch req.opcode {
+	case opLookup:
+		out, err = s.lookup(n, cString(req.in))
+	case opGetattr:
+		out, err = s.getattr(n)
+	case opOpen:
+		out, err = s.open(n, req.in)
+	case opRead:
+		out, err = s.read(req.in)
+	case opRelease, opReleasedir:
+		s.release(req.in)
+	case opFlush:
+	case opStatfs:
+		out = s.statfs()
+	case opOpendir:
+		out, err = s.opendir(n)
+	case opReaddir:
+		out, err = s.readdir(req.in)
+	case opAccess:
+		if len(req.in) >= 4 && binary.NativeEndian.Uint32(req.in)&2 != 0 { // W_OK
+			err = syscall.EROFS
+		}
+	default:
+		err = syscall.ENOSYS
+	}
+	if err != nil {
+		s.reply(req, nil, errno(err))
+		return
+	}
+	s.reply(req, out, 0)
+}
+
+// init handles the INIT request.
+func (s *server) init(req request) {
+	if len(req.in) < 16 {
+		s.reply(req, nil, syscall.EIO)
+		return
+	}
+	major, minor := binary.NativeEndian.Uint32(req.in), binary.NativeEndian.Uint32(req.in[4:])
+	maxReadahead := binary.NativeEndian.Uint32(req.in[8:])
+	out := make([]byte, 64)
+	binary.NativeEndian.PutUint32(out, fuseMajor)
+	if major == fuseMajor {
+		// The kernel sends INIT again with our major if it is newer.
+		binary.NativeEndian.PutUint32(out[4:], min(minor, fuseMinor))
+		binary.NativeEndian.PutUint32(out[8:], maxReadahead)
+		binary.NativeEndian.PutUint32(out[20:], maxWrite)
+	} else if major < fuseMajor {
+		s.reply(req, nil, syscall.EPROTO)
+		return
+	}
+	s.reply(req, out, 0)
+}
+
+// forget handles the FORGET, BATCH_FORGET and INTERRUPT requests, which
+// are not replied.
+func (s *server) forget(req request) {
+	switch req.opcode {
+	case opForget:
+		if len(req.in) >= 8 {
+			s.forgetNode(req.nodeID, binary.NativeEndian.Uint64(req.in))
+		}
+	case opBatchForget:
+		if len(req.in) < 8 {
+			return
+		}
+		count, entries := binary.NativeEndian.Uint32(req.in), req.in[8:]
+		for i := 0; i < int(count) && len(entries) >= 16*(i+1); i++ {
+			entry := entries[16*i:]
+			s.forgetNode(binary.NativeEndian.Uint64(entry), binary.NativeEndian.Uint64(entry[8:]))
+		}
+	}
+}
+
+// forgetNode forgets lookups of the node id.
+func (s *server) forgetNode(id, lookups uint64) {
+	n := s.nodes[id]
+	if n == nil || id == rootID {
+		return
+	}
+	n.lookups -= min(lookups, n.lookups)
+	if n.lookups == 0 {
+		delete(s.nodes, id)
+		delete(s.ids, n.name)
+	}
+}
+
+// inode returns the inode number of the file name, which is stable across
+// lookups.
+func inode(name string) uint64 {
+	if name == "." {
+		return rootID
+	}
+	h := fnv.New64a()
+	io.WriteString(h, name)
+	return max(h.Sum64(), rootID+1)
+}
+
+// appendAttr appends the fuse_attr of the file name to p.
+func (s *server) appendAttr(p []byte, name string, info fs.FileInfo) []byte {
+	size := uint64(max(info.Size(), 0))
+	var mtime int64
+	if t := info.ModTime(); !t.IsZero() {
+		mtime = t.Unix()
+	}
+	mode := uint32(info.Mode().Perm())
+	nlink := uint32(1)
+	if info.IsDir() {
+		mode |= syscall.S_IFDIR
+		nlink = 2
+	} else {
+		mode |= syscall.S_IFREG
+	}
+	p = binary.NativeEndian.AppendUint64(p, inode(name))
+	p = binary.NativeEndian.AppendUint64(p, size)
+	p = binary.NativeEndian.AppendUint64(p, (size+511)/512)
+	for range 3 { // atime, mtime and ctime
+		p = binary.NativeEndian.AppendUint64(p, uint64(mtime))
+	}
+	p = append(p, make([]byte, 12)...) // nanoseconds
+	p = binary.NativeEndian.AppendUint32(p, mode)
+	p = binary.NativeEndian.AppendUint32(p, nlink)
+	p = binary.NativeEndian.AppendUint32(p, s.uid)
+	p = binary.NativeEndian.AppendUint32(p, s.gid)
+	p = binary.NativeEndian.AppendUint32(p, 0)    // rdev
+	p = binary.NativeEndian.AppendUint32(p, 4096) // blksize
+	return binary.NativeEndian.AppendUint32(p, 0) // flags
+}
+
+// stat returns the file info of the file name.
+func (s *server) stat(name string) (fs.FileInfo, error) {
+	if s.polling && name == pollHackName {
+		return &fileInfo{name: pollHackName}, nil
+	}
+	return fs.Stat(s.fsys, name)
+}
+
+// lookup handles the LOOKUP request of the entry name in the directory n.
+func (s *server) lookup(n *node, name string) (out []byte, err error) {
+	if name == "" || name == "." || name == ".." {
+		return nil, syscall.ENOENT
+	}
+	child := name
+	if n.name != "." {
+		child = n.name + "/" + name
+	}
+	if !fs.ValidPath(child) {
+		return nil, syscall.ENOENT
+	}
+	info, err := s.stat(child)
+	if err != nil {
+		return
+	}
+	id := s.ids[child]
+	if id == 0 {
+		id = s.nextID
+		s.nextID++
+		s.nodes[id] = &node{name: child}
+		s.ids[child] = id
+	}
+	s.nodes[id].lookups++
+	out = make([]byte, 0, 40+attrSize)
+	out = binary.NativeEndian.AppendUint64(out, id)
+	out = binary.NativeEndian.AppendUint64(out, 0) // generation
+	out = binary.NativeEndian.AppendUint64(out, cacheValid)
+	out = binary.NativeEndian.AppendUint64(out, cacheValid)
+	out = append(out, make([]byte, 8)...) // nanoseconds
+	return s.appendAttr(out, child, info), nil
+}
+
+// getattr handles the GETATTR request of n.
+func (s *server) getattr(n *node) (out []byte, err error) {
+	info, err := s.stat(n.name)
+	if err != nil {
+		return
+	}
+	out = make([]byte, 0, 16+attrSize)
+	out = binary.NativeEndian.AppendUint64(out, cacheValid)
+	out = append(out, make([]byte, 8)...) // nanoseconds and padding
+	return s.appendAttr(out, n.name, info), nil
+}
+
+// addHandle returns the fuse_open_out of the new handle of h.
+func (s *server) addHandle(h any) []byte {
+	s.nextHandle++
+	s.handles[s.nextHandle] = h
+	out := binary.NativeEndian.AppendUint64(nil, s.nextHandle)
+	out = binary.NativeEndian.AppendUint32(out, 1<<1) // FOPEN_KEEP_CACHE
+	return binary.NativeEndian.AppendUint32(out, 0)
+}
+
+// open handles the OPEN request of n.
+func (s *server) open(n *node, in []byte) (out []byte, err error) {
+	if len(in) < 4 {
+		return nil, syscall.EIO
+	}
+	if binary.NativeEndian.Uint32(in)&syscall.O_ACCMODE != syscall.O_RDONLY {
+		return nil, syscall.EROFS
+	}
+	if s.polling && n.name == pollHackName {
+		out = s.addHandle(&file{io.NewSectionReader(strings.NewReader(""), 0, 0), &fileInfo{name: pollHackName}})
+		s.pollHandle = s.nextHandle
+		return
+	}
+	f, err := s.fsys.Open(n.name)
+	if err != nil {
+		return
+	}
+	return s.addHandle(f), nil
+}
+
+// read handles the READ request.
+func (s *server) read(in []byte) (out []byte, err error) {
+	if len(in) < 20 {
+		return nil, syscall.EIO
+	}
+	fh, off, size := binary.NativeEndian.Uint64(in), binary.NativeEndian.Uint64(in[8:]), binary.NativeEndian.Uint32(in[16:])
+	f, ok := s.handles[fh].(fs.File)
+	if !ok || off > 1<<63-1 {
+		return nil, syscall.EBADF
+	}
+	out = make([]byte, min(size, maxWrite))
+	var n int
+	switch f := f.(type) {
+	case io.ReaderAt:
+		n, err = f.ReadAt(out, int64(off))
+	case io.ReadSeeker:
+		if _, err = f.Seek(int64(off), io.SeekStart); err == nil {
+			n, err = io.ReadFull(f, out)
+		}
+	default:
+		return nil, syscall.ENOSYS
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return out[:n], err
+}
+
+// release handles the RELEASE and RELEASEDIR requests.
+func (s *server) release(in []byte) {
+	if len(in) < 8 {
+		return
+	}
+	fh := binary.NativeEndian.Uint64(in)
+	if f, ok := s.handles[fh].(fs.File); ok {
+		f.Close()
+	}
+	delete(s.handles, fh)
+	if s.polling && fh == s.pollHandle {
+		s.polling = false
+	}
+}
+
+// statfs handles the STATFS request.
+func (s *server) statfs() []byte {
+	out := make([]byte, 80)
+	binary.NativeEndian.PutUint32(out[40:], 4096) // bsize
+	binary.NativeEndian.PutUint32(out[44:], 255)  // namelen
+	binary.NativeEndian.PutUint32(out[48:], 4096) // frsize
+	return out
+}
+
+// opendir handles the OPENDIR request of n. The entries are read when the
+// directory is opened.
+func (s *server) opendir(n *node) (out []byte, err error) {
+	entries, err := fs.ReadDir(s.fsys, n.name)
+	if err != nil {
+		return
+	}
+	return s.addHandle(&dirHandle{n.name, entries}), nil
+}
+
+// dirHandle is the handle of an opened directory.
+type dirHandle struct {
+	name    string
+	entries []fs.DirEntry
+}
+
+// readdir handles the READDIR request. The offset of an entry is its index
+// plus one.
+func (s *server) readdir(in []byte) (out []byte, err error) {
+	if len(in) < 20 {
+		return nil, syscall.EIO
+	}
+	fh, off, size := binary.NativeEndian.Uint64(in), binary.NativeEndian.Uint64(in[8:]), binary.NativeEndian.Uint32(in[16:])
+	d, ok := s.handles[fh].(*dirHandle)
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	out = make([]byte, 0, size)
+	for i := off; i < uint64(len(d.entries)); i++ {
+		entry := d.entries[i]
+		name := entry.Name()
+		direntSize := (24 + len(name) + 7) &^ 7
+		if len(out)+direntSize > int(size) {
+			break
+		}
+		typ := uint32(syscall.DT_REG)
+		if entry.IsDir() {
+			typ = syscall.DT_DIR
+		}
+		out = binary.NativeEndian.AppendUint64(out, inode(path.Join(d.name, name)))
+		out = binary.NativeEndian.AppendUint64(out, i+1)
+		out = binary.NativeEndian.AppendUint32(out, uint32(len(name)))
+		out = binary.NativeEndian.AppendUint32(out, typ)
+		out = append(out, name...)
+		out = append(out, make([]byte, direntSize-24-len(name))...)
+	}
+	return out, nil
+}
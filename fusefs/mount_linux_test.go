@@ -0,0 +1,61 @@
+package fusefs_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mkch/hashive/fusefs"
+)
+
+func TestMount(t *testing.T) {
+	dir := t.TempDir()
+	unmount, err := fusefs.Mount(dir, newTestFS(t))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer func() {
+		if err := unmount(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"%", "%25", "%2E%2E", "a", "a%2Fb", "b", "f", "n", "o", "s"}; !slices.Equal(names, want) {
+		t.Fatal(names)
+	}
+	for name, want := range map[string]string{
+		"s":     "str",
+		"n":     "1\n",
+		"a/2/k": "v",
+		"%25":   "percent",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Fatal(name, string(data), err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0555 {
+		t.Fatal(info.Mode())
+	}
+	if info, err = os.Stat(filepath.Join(dir, "b")); err != nil || info.Size() != 3 || info.Mode() != 0444 {
+		t.Fatal(info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "s"), nil, 0644); err == nil {
+		t.Fatal("written")
+	}
+}
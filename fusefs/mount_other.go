@@ -0,0 +1,14 @@
+//go:build !linux
+
+package fusefs
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// Mount mounts fsys read-only at dir with FUSE, which is only supported on
+// Linux.
+func Mount(dir string, fsys fs.FS) (unmount func() error, err error) {
+	return nil, &fs.PathError{Op: "mount", Path: dir, Err: errors.ErrUnsupported}
+}
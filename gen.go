@@ -0,0 +1,340 @@
+package hashive
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxGenFields is the maximum number of keys of an object to be generated as
+// an accessor with a method for every key. Objects with more keys are accessed
+// with a Get(key) method.
+const maxGenFields = 64
+
+// genKind is the kind of a value inspected by [Generate].
+type genKind int
+
+const (
+	genAny genKind = iota // null or values of mixed types
+	genString
+	genInt
+	genUint
+	genFloat
+	genBool
+	genBinary
+	genGob
+	genArray
+	genStruct // object with a method for every key
+	genMap    // object accessed by Get(key)
+)
+
+// genShape is the inferred shape of a value.
+type genShape struct {
+	kind   genKind
+	elem   *genShape            // element of genArray and genMap
+	fields map[string]*genShape // fields of genStruct
+}
+
+// inspectShape infers the shape of v.
+func inspectShape(v any) *genShape {
+	switch value := v.(type) {
+	case string:
+		return &genShape{kind: genString}
+	case int64:
+		return &genShape{kind: genInt}
+	case uint64:
+		return &genShape{kind: genUint}
+	case float64:
+		return &genShape{kind: genFloat}
+	case bool:
+		return &genShape{kind: genBool}
+	case []byte:
+		return &genShape{kind: genBinary}
+	case GobValue:
+		return &genShape{kind: genGob}
+	case []any:
+		var elem *genShape
+		for _, e := range value {
+			elem = mergeShape(elem, inspectShape(e))
+		}
+		if elem == nil {
+			elem = &genShape{kind: genAny}
+		}
+		return &genShape{kind: genArray, elem: elem}
+	case map[string]any:
+		var isStruct = len(value) <= maxGenFields
+		for k := range value {
+			if !isGenIdent(k) {
+				isStruct = false
+				break
+			}
+		}
+		if isStruct {
+			fields := make(map[string]*genShape, len(value))
+			for k, e := range value {
+				fields[k] = inspectShape(e)
+			}
+			return &genShape{kind: genStruct, fields: fields}
+		}
+		var elem *genShape
+		for _, e := range value {
+			elem = mergeShape(elem, inspectShape(e))
+		}
+		if elem == nil {
+			elem = &genShape{kind: genAny}
+		}
+		return &genShape{kind: genMap, elem: elem}
+	default:
+		return &genShape{kind: genAny}
+	}
+}
+
+// mergeShape merges two shapes into one that fits both.
+// Nil shape merges into the other one.
+func mergeShape(s1, s2 *genShape) *genShape {
+	if s1 == nil {
+		return s2
+	} else if s2 == nil {
+		return s1
+	}
+	if s1.kind == genStruct && s2.kind == genStruct {
+		fields := maps.Clone(s1.fields)
+		for k, f := range s2.fields {
+			fields[k] = mergeShape(fields[k], f)
+		}
+		if len(fields) <= maxGenFields {
+			return &genShape{kind: genStruct, fields: fields}
+		}
+		var elem *genShape
+		for _, f := range fields {
+			elem = mergeShape(elem, f)
+		}
+		return &genShape{kind: genMap, elem: elem}
+	}
+	if (s1.kind == genStruct || s1.kind == genMap) && (s2.kind == genStruct || s2.kind == genMap) {
+		return &genShape{kind: genMap, elem: mergeShape(shapeElem(s1), shapeElem(s2))}
+	}
+	if s1.kind != s2.kind {
+		return &genShape{kind: genAny}
+	}
+	if s1.kind == genArray || s1.kind == genMap {
+		return &genShape{kind: s1.kind, elem: mergeShape(s1.elem, s2.elem)}
+	}
+	return s1
+}
+
+// shapeElem returns the merged shape of the values of an object shape.
+func shapeElem(s *genShape) *genShape {
+	if s.kind == genMap {
+		return s.elem
+	}
+	var elem *genShape
+	for _, f := range s.fields {
+		elem = mergeShape(elem, f)
+	}
+	if elem == nil {
+		elem = &genShape{kind: genAny}
+	}
+	return elem
+}
+
+// isGenIdent returns whether key can be converted to a Go identifier.
+func isGenIdent(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// exportedName converts key to an exported Go identifier.
+func exportedName(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	// Letters without case, such as CJK ones, are not exported.
+	if !token.IsExported(name) {
+		name = "X" + name
+	}
+	return name
+}
+
+type generator struct {
+	decls []*bytes.Buffer // type declarations and methods
+	types map[string]bool
+}
+
+// typeName returns an unused type name based on name.
+func (g *generator) typeName(name string) string {
+	unique := name
+	for i := 2; g.types[unique]; i++ {
+		unique = fmt.Sprintf("%v%v", name, i)
+	}
+	g.types[unique] = true
+	return unique
+}
+
+// scalarType returns the Go type of scalar kinds.
+func scalarType(kind genKind) string {
+	switch kind {
+	case genString:
+		return "string"
+	case genInt:
+		return "int64"
+	case genUint:
+		return "uint64"
+	case genFloat:
+		return "float64"
+	case genBool:
+		return "bool"
+	case genBinary:
+		return "[]byte"
+	default:
+		return "any"
+	}
+}
+
+// genAccessor generates the accessor type of a container shape and returns its name.
+func (g *generator) genAccessor(name string, shape *genShape) string {
+	typeName := g.typeName(name)
+	var buf bytes.Buffer
+	g.decls = append(g.decls, &buf) // before the accessors of children
+	fmt.Fprintf(&buf, "// %v is the accessor of a hashive value.\n", typeName)
+	fmt.Fprintf(&buf, "type %v struct {\n\th    *hashive.Hashive\n\tpath []string\n}\n\n", typeName)
+
+	var valueType string
+	switch shape.kind {
+	case genArray:
+		valueType = "[]any"
+	default:
+		valueType = "map[string]any"
+	}
+	fmt.Fprintf(&buf, "// Value queries the entire value.\n")
+	fmt.Fprintf(&buf, "func (v %v) Value() (%v, error) {\n\treturn query[%v](v.h, v.path)\n}\n\n", typeName, valueType, valueType)
+
+	switch shape.kind {
+	case genArray:
+		g.genMethod(&buf, typeName, "At", "i int", "strconv.Itoa(i)", name+"Elem", shape.elem)
+	case genMap:
+		g.genMethod(&buf, typeName, "Get", "key string", "key", name+"Value", shape.elem)
+	case genStruct:
+		keys := slices.Sorted(maps.Keys(shape.fields))
+		methods := map[string]bool{"Value": true}
+		for _, key := range keys {
+			method := exportedName(key)
+			for methods[method] {
+				method += "_"
+			}
+			methods[method] = true
+			g.genMethod(&buf, typeName, method, "", fmt.Sprintf("%q", key), name+method, shape.fields[key])
+		}
+	}
+	return typeName
+}
+
+// genMethod generates a method of typeName to access the value of shape.
+func (g *generator) genMethod(buf *bytes.Buffer, typeName, method, param, pathElem, childName string, shape *genShape) {
+	switch shape.kind {
+	case genArray, genStruct, genMap:
+		childType := g.genAccessor(childName, shape)
+		fmt.Fprintf(buf, "func (v %v) %v(%v) %v {\n\treturn %v{v.h, appendPath(v.path, %v)}\n}\n\n",
+			typeName, method, param, childType, childType, pathElem)
+	case genGob:
+		if param != "" {
+			param += ", "
+		}
+		fmt.Fprintf(buf, "func (v %v) %v(%vdst any) error {\n\treturn v.h.QueryGob(dst, appendPath(v.path, %v)...)\n}\n\n",
+			typeName, method, param, pathElem)
+	default:
+		t := scalarType(shape.kind)
+		fmt.Fprintf(buf, "func (v %v) %v(%v) (%v, error) {\n\treturn query[%v](v.h, appendPath(v.path, %v))\n}\n\n",
+			typeName, method, param, t, t, pathElem)
+	}
+}
+
+const genHelpers = `
+func appendPath(path []string, elem string) []string {
+	return append(path[:len(path):len(path)], elem)
+}
+
+func query[T any](h *hashive.Hashive, path []string) (v T, err error) {
+	value, err := h.Query(path...)
+	if err != nil || value == nil { // null is the zero value of T.
+		return
+	}
+	v, ok := value.(T)
+	if !ok {
+		err = fmt.Errorf("unexpected type %T of %v", value, path)
+	}
+	return
+}
+`
+
+// Generate inspects the entire content of h and writes Go source code of
+// package pkg to w. The generated code contains typed accessors of the
+// content, which eliminates string paths and type assertions in applications.
+// The root accessor is named typeName and created by New{typeName}(h).
+//
+// For example, the value of h.Query("Owners", "0", "Name") can be accessed
+// with the generated code as:
+//
+//	NewDB(h).Owners().At(0).Name()
+//
+// Objects with too many keys, or keys not suitable as Go identifiers,
+// are accessed by a Get(key) method.
+func Generate(w io.Writer, h *Hashive, pkg, typeName string) (err error) {
+	if !token.IsIdentifier(pkg) || !token.IsIdentifier(typeName) {
+		return fmt.Errorf("invalid package name %q or type name %q", pkg, typeName)
+	}
+	value, err := h.Query()
+	if err != nil {
+		return
+	}
+	shape := inspectShape(value)
+	if shape.kind != genArray && shape.kind != genStruct && shape.kind != genMap {
+		return fmt.Errorf("unsupported root type %T", value)
+	}
+
+	var buf bytes.Buffer
+	g := &generator{types: map[string]bool{}}
+	fmt.Fprintf(&buf, "// Code generated by hashive.Generate. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %v\n\n", pkg)
+	fmt.Fprintf(&buf, "import (\n\t\"fmt\"\n\t\"strconv\"\n\n\t\"github.com/mkch/hashive\"\n)\n\n")
+	fmt.Fprintf(&buf, "var _ = strconv.Itoa\n\n")
+	fmt.Fprintf(&buf, "// New%v returns the root accessor of h.\n", typeName)
+	fmt.Fprintf(&buf, "func New%v(h *hashive.Hashive) %v {\n\treturn %v{h: h}\n}\n\n", typeName, typeName, typeName)
+	g.genAccessor(typeName, shape)
+	for _, decl := range g.decls {
+		buf.Write(decl.Bytes())
+	}
+	buf.WriteString(genHelpers)
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return
+	}
+	_, err = w.Write(src)
+	return
+}
@@ -0,0 +1,149 @@
+package hashive_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestGenerate(t *testing.T) {
+	var db bytes.Buffer
+	err := hashive.Write(&db, map[string]any{
+		"Key1": 123,
+		"Owners": []any{
+			map[string]any{"Name": "John", "Age": 28},
+			map[string]any{"Name": "Joe", "Age": 29, "Addr": "abc street"},
+		},
+		"Description": Description{"description here"},
+		"codes":       map[string]any{"1-a": "x", "2-b": "y"},
+		"名字":          "n",
+		"ñame":        "m",
+		"value":       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(db.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var src bytes.Buffer
+	if err := hashive.Generate(&src, h, "data", "DB"); err != nil {
+		t.Fatal(err)
+	}
+	code := src.String()
+	for _, want := range []string{
+		"package data",
+		"func NewDB(h *hashive.Hashive) DB",
+		"func (v DB) Key1() (int64, error)",
+		"func (v DB) Owners() DBOwners",
+		"func (v DBOwners) At(i int) DBOwnersElem",
+		"func (v DBOwnersElem) Name() (string, error)",
+		"func (v DBOwnersElem) Addr() (string, error)",
+		"func (v DB) Description(dst any) error",
+		"func (v DB) Codes() DBCodes",
+		"func (v DBCodes) Get(key string) (string, error)",
+		"func (v DB) X名字() (string, error)",
+		"func (v DB) Ñame() (string, error)",
+		"func (v DB) Value_() (bool, error)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("%q not generated:\n%v", want, code)
+		}
+	}
+
+	// The generated code compiles, and all the accessors are exported.
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("importing from source needs the go command")
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "data.go", code, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("data", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("%v:\n%v", err, code)
+	}
+	for _, decl := range file.Decls {
+		if f, ok := decl.(*ast.FuncDecl); ok && f.Recv != nil && !f.Name.IsExported() {
+			t.Errorf("unexported method %v", f.Name)
+		}
+	}
+}
+
+func TestGenerateNull(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the generated code is slow")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("running the generated code needs the go command")
+	}
+	db := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(db, map[string]any{"Name": "x", "Note": nil}); err != nil {
+		t.Fatal(err)
+	}
+	h, closeFile, err := hashive.Open(db, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFile()
+	var src bytes.Buffer
+	if err := hashive.Generate(&src, h, "main", "DB"); err != nil {
+		t.Fatal(err)
+	}
+	if code := src.String(); !strings.Contains(code, "func (v DB) Note() (any, error)") {
+		t.Fatalf("Note not generated:\n%v", code)
+	}
+
+	// The package is built in the module to use its go.sum.
+	dir, err := os.MkdirTemp(".", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const main = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mkch/hashive"
+)
+
+func main() {
+	h, closeFile, err := hashive.Open(os.Args[1], -1)
+	if err != nil {
+		panic(err)
+	}
+	defer closeFile()
+	fmt.Println(NewDB(h).Note())
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "db.go"), src.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "run", ".", db)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if string(out) != "<nil> <nil>\n" {
+		t.Fatalf("%s", out)
+	}
+}
@@ -0,0 +1,344 @@
+package hashive
+
+import (
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// gobRegistry is the registry of types registered by [RegisterGobTypes].
+var gobRegistry struct {
+	sync.RWMutex
+	types map[string]reflect.Type // type name -> type
+}
+
+// RegisterGobTypes registers the types of values with [gob.Register], so
+// they can be stored in interface values, and records them in the registry
+// of gob types of Hashive.
+//
+// Values of registered types are written as gob encoded interface values,
+// which can be decoded without knowing their types in advance.
+// See [WithGobDecoding].
+//
+// [Write] stores a fingerprint of the definition of every gob encoded type
+// in the database. When a database is opened, the fingerprints of the
+// registered types are compared with the stored ones to report values
+// encoded from a different definition of the type clearly.
+// See [Hashive.CheckGobTypes] and [GobTypeError].
+func RegisterGobTypes(values ...any) {
+	gobRegistry.Lock()
+	defer gobRegistry.Unlock()
+	if gobRegistry.types == nil {
+		gobRegistry.types = make(map[string]reflect.Type)
+	}
+	for _, v := range values {
+		gob.Register(v)
+		t := reflect.TypeOf(v)
+		gobRegistry.types[gobTypeName(t)] = t
+	}
+}
+
+// registeredGobType returns the type registered with name.
+func registeredGobType(name string) (t reflect.Type, ok bool) {
+	gobRegistry.RLock()
+	defer gobRegistry.RUnlock()
+	t, ok = gobRegistry.types[name]
+	return
+}
+
+// GobTypeError is returned when a gob encoded value is decoded into
+// a type whose definition is different from the one used to encode it.
+type GobTypeError struct {
+	Type string // The name of the type.
+}
+
+func (err *GobTypeError) Error() string {
+	return fmt.Sprintf("definition of gob type %v mismatches the one used to write the database", err.Type)
+}
+
+// gobTypeName returns the name of t used in the fingerprints.
+func gobTypeName(t reflect.Type) string {
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+
+// gobFingerprint returns the fingerprint of the definition of t.
+// Only the aspects of t affecting the compatibility of gob encoding
+// are counted, such as the names and types of exported struct fields.
+func gobFingerprint(t reflect.Type) uint64 {
+	var b strings.Builder
+	describeGobType(&b, t, make(map[reflect.Type]bool))
+	h := fnv.New64a()
+	io.WriteString(h, b.String())
+	return h.Sum64()
+}
+
+var (
+	gobEncoderType      = reflect.TypeFor[gob.GobEncoder]()
+	binaryMarshalerType = reflect.TypeFor[encoding.BinaryMarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// describeGobType writes the description of t as gob sees it to b.
+func describeGobType(b *strings.Builder, t reflect.Type, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem() // gob flattens pointers.
+	}
+	if t.Implements(gobEncoderType) || t.Implements(binaryMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(gobEncoderType) || reflect.PointerTo(t).Implements(binaryMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		// Opaque to gob.
+		b.WriteString("opaque:")
+		b.WriteString(gobTypeName(t))
+		return
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b.WriteString("bool")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString("int")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.WriteString("uint")
+	case reflect.Float32, reflect.Float64:
+		b.WriteString("float")
+	case reflect.Complex64, reflect.Complex128:
+		b.WriteString("complex")
+	case reflect.String:
+		b.WriteString("string")
+	case reflect.Interface:
+		b.WriteString("interface")
+	case reflect.Array:
+		b.WriteString("[" + strconv.Itoa(t.Len()) + "]")
+		describeGobType(b, t.Elem(), visiting)
+	case reflect.Slice:
+		b.WriteString("[]")
+		describeGobType(b, t.Elem(), visiting)
+	case reflect.Map:
+		b.WriteString("map[")
+		describeGobType(b, t.Key(), visiting)
+		b.WriteString("]")
+		describeGobType(b, t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			// Recursive types.
+			b.WriteString(gobTypeName(t))
+			return
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		// gob matches fields by name, the order does not matter.
+		fields := make(map[string]reflect.Type)
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() && f.Type.Kind() != reflect.Chan && f.Type.Kind() != reflect.Func {
+				fields[f.Name] = f.Type
+			}
+		}
+		b.WriteString("struct{")
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			b.WriteString(name)
+			b.WriteString(" ")
+			describeGobType(b, fields[name], visiting)
+			b.WriteString(";")
+		}
+		b.WriteString("}")
+	default:
+		b.WriteString(t.Kind().String())
+	}
+}
+
+// gobTypeRecorder records the fingerprints of gob encoded types.
+// It is safe for concurrent use.
+type gobTypeRecorder struct {
+	mu    sync.Mutex
+	types map[reflect.Type]bool
+	value map[string]any // type name -> fingerprint, stored in the header
+}
+
+// wrap returns a GobEncoder records the types of values encoded by encoder.
+func (r *gobTypeRecorder) wrap(encoder impl.GobEncoder) impl.GobEncoder {
+	r.types = make(map[reflect.Type]bool)
+	r.value = make(map[string]any)
+	return func(v any) (GobValue, error) {
+		t := reflect.TypeOf(v)
+		r.mu.Lock()
+		if !r.types[t] {
+			r.types[t] = true
+			elem := t
+			for elem.Kind() == reflect.Pointer {
+				elem = elem.Elem() // Checked without pointers, see checkGobType.
+			}
+			r.value[gobTypeName(elem)] = gobFingerprint(elem)
+		}
+		r.mu.Unlock()
+		if _, registered := registeredGobType(gobTypeName(t)); registered {
+			return impl.EncodeGobInterface(v)
+		}
+		return encoder(v)
+	}
+}
+
+// gobTypesFromValue converts the value stored by [gobTypeRecorder] back.
+func gobTypesFromValue(v any) (types map[string]uint64, err error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid gob types %v", v)
+	}
+	types = make(map[string]uint64, len(m))
+	for name, fp := range m {
+		if types[name], ok = fp.(uint64); !ok {
+			return nil, fmt.Errorf("invalid gob type fingerprint %v", fp)
+		}
+	}
+	return
+}
+
+// checkGobType checks the definition of t against the stored fingerprint.
+func (h *Hashive) checkGobType(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name := gobTypeName(t)
+	if fp, ok := h.gobTypes[name]; ok && fp != gobFingerprint(t) {
+		return &GobTypeError{Type: name}
+	}
+	return nil
+}
+
+// CheckGobTypes checks the types registered with [RegisterGobTypes] against
+// the definitions used to write h. A [*GobTypeError] is returned if any of
+// them mismatches.
+func (h *Hashive) CheckGobTypes() error {
+	for _, name := range slices.Sorted(maps.Keys(h.gobTypes)) {
+		if t, ok := registeredGobType(name); ok {
+			if err := h.checkGobType(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeGob decodes gob into v, checking the type of v against the
+// stored fingerprints.
+func (h *Hashive) decodeGob(g GobValue, v any) (err error) {
+	if err = h.checkGobType(reflect.TypeOf(v)); err != nil {
+		return
+	}
+	if err = h.gobDecoder(g, v); err != nil {
+		// Values of registered types can be decoded into interfaces.
+		if errType := h.CheckGobTypes(); errType != nil {
+			err = fmt.Errorf("%w: %w", err, errType)
+		}
+	}
+	return
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	byteSliceType     = reflect.TypeFor[[]byte]()
+)
+
+// expandGob replaces the gob encoded values of registered types in v,
+// which is returned by [impl.ReadValue] recursively, with their content.
+// See [WithGobDecoding].
+func expandGob(v any) any {
+	switch value := v.(type) {
+	case GobValue:
+		if decoded, err := value.DecodeInterface(); err == nil {
+			return plainValue(reflect.ValueOf(decoded))
+		}
+	case []any:
+		for i, elem := range value {
+			value[i] = expandGob(elem)
+		}
+	case map[string]any:
+		for k, elem := range value {
+			value[k] = expandGob(elem)
+		}
+	case []Interval:
+		for i := range value {
+			value[i].Value = expandGob(value[i].Value)
+		}
+	}
+	return v
+}
+
+// plainValue converts v to the types returned by [Hashive.Query].
+// Values can't be converted, such as those marshal themselves, are returned as is.
+func plainValue(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return plainValue(v.Elem())
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice {
+				return v.Convert(byteSliceType).Interface()
+			}
+			p := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(p), v)
+			return p
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		ary := make([]any, v.Len())
+		for i := range ary {
+			ary[i] = plainValue(v.Index(i))
+		}
+		return ary
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		obj := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			obj[iter.Key().String()] = plainValue(iter.Value())
+		}
+		return obj
+	case reflect.Struct:
+		obj := make(map[string]any)
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() {
+				obj[f.Name] = plainValue(v.Field(i))
+			}
+		}
+		return obj
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,193 @@
+package hashive_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+type gobRect struct{ W, H int }
+
+func (r gobRect) Area() int { return r.W * r.H }
+
+func TestGobTypes(t *testing.T) {
+	hashive.RegisterGobTypes(gobRect{})
+
+	var buf bytes.Buffer
+	{
+		type Point struct{ X, Y int }
+		err := hashive.Write(&buf, map[string]any{
+			"p1": Point{1, 2},
+			"p2": Point{3, 4},
+			"r":  gobRect{2, 3},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.CheckGobTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		// Values are decoded in any order.
+		type Point struct{ X, Y int32 }
+		var p Point
+		if err := h.QueryGob(&p, "p2"); err != nil {
+			t.Fatal(err)
+		} else if p != (Point{3, 4}) {
+			t.Fatal(p)
+		}
+		if err := h.QueryGob(&p, "p1"); err != nil {
+			t.Fatal(err)
+		} else if p != (Point{1, 2}) {
+			t.Fatal(p)
+		}
+	}
+	{
+		type Point struct{ X, Z string }
+		var p Point
+		var typeErr *hashive.GobTypeError
+		if err := h.QueryGob(&p, "p1"); !errors.As(err, &typeErr) {
+			t.Fatal(err)
+		} else if typeErr.Type != "github.com/mkch/hashive_test.Point" {
+			t.Fatal(typeErr.Type)
+		}
+	}
+
+	var r gobRect
+	if err := h.QueryGob(&r, "r"); err != nil {
+		t.Fatal(err)
+	} else if r.Area() != 6 {
+		t.Fatal(r)
+	}
+}
+
+type gobUser struct {
+	Name  string
+	Age   uint8
+	Tags  []string
+	Rect  *gobRect
+	Extra map[string]float32
+	note  string
+}
+
+func TestWithGobDecoding(t *testing.T) {
+	hashive.RegisterGobTypes(gobUser{}, gobRect{})
+	type Unregistered struct{ A int }
+
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"user":  gobUser{Name: "mkch", Age: 18, Tags: []string{"a"}, Rect: &gobRect{1, 2}, Extra: map[string]float32{"x": 0.5}, note: "n"},
+		"other": Unregistered{1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithGobDecoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := h.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := v.(map[string]any)
+	want := map[string]any{
+		"Name":  "mkch",
+		"Age":   uint64(18),
+		"Tags":  []any{"a"},
+		"Rect":  map[string]any{"W": int64(1), "H": int64(2)},
+		"Extra": map[string]any{"x": float64(0.5)},
+	}
+	if !reflect.DeepEqual(root["user"], want) {
+		t.Fatal(root["user"])
+	}
+	if _, err := json.Marshal(root); err != nil {
+		t.Fatal(err)
+	}
+	// Unregistered types are not decoded.
+	if _, ok := root["other"].(map[string]any); ok {
+		t.Fatal(root["other"])
+	}
+	var other Unregistered
+	if err := h.QueryGob(&other, "other"); err != nil {
+		t.Fatal(err)
+	} else if other.A != 1 {
+		t.Fatal(other)
+	}
+
+	// Registered values can still be decoded into their types.
+	var user gobUser
+	if err := h.QueryGob(&user, "user"); err != nil {
+		t.Fatal(err)
+	} else if user.Name != "mkch" || user.Rect.H != 2 {
+		t.Fatal(user)
+	}
+	var shape any
+	if err := h.QueryGob(&shape, "user"); err != nil {
+		t.Fatal(err)
+	} else if shape.(gobUser).Age != 18 {
+		t.Fatal(shape)
+	}
+}
+
+func TestGobValueDecode(t *testing.T) {
+	type Point struct{ X, Y int }
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, []any{Point{1, 2}, Point{3, 4}, gobRect{5, 6}}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := h.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ary := v.([]any)
+	var p Point
+	if err := ary[1].(hashive.GobValue).Decode(&p); err != nil {
+		t.Fatal(err)
+	} else if p != (Point{3, 4}) {
+		t.Fatal(p)
+	}
+	var r gobRect
+	if err := ary[2].(hashive.GobValue).Decode(&r); err != nil {
+		t.Fatal(err)
+	} else if r != (gobRect{5, 6}) {
+		t.Fatal(r)
+	}
+}
+
+func TestGobTypesPointer(t *testing.T) {
+	var buf bytes.Buffer
+	{
+		type PtrPoint struct{ X, Y int }
+		if err := hashive.Write(&buf, map[string]any{"p": &PtrPoint{1, 2}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type PtrPoint struct{ X, Z string }
+	var p PtrPoint
+	var typeErr *hashive.GobTypeError
+	if err := h.QueryGob(&p, "p"); !errors.As(err, &typeErr) {
+		t.Fatal(err)
+	} else if typeErr.Type != "github.com/mkch/hashive_test.PtrPoint" {
+		t.Fatal(typeErr.Type)
+	}
+}
@@ -0,0 +1,93 @@
+package hashive
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"slices"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// errStopIter stops walking when the loop body of an iterator breaks.
+var errStopIter = errors.New("stop iteration")
+
+// GobValues returns an iterator over the path and raw bytes of every gob
+// encoded value in the database in depth-first order, so that migration
+// tools can re-encode them without knowing their types in advance.
+// Values are not decoded, even with [WithGobDecoding].
+// The iteration stops at the first error, which is returned by err after
+// the iteration. h must not be queried in the loop body.
+func (h *Hashive) GobValues() (seq iter.Seq2[[]string, GobValue], err func() error) {
+	var errIter error
+	seq = func(yield func([]string, GobValue) bool) {
+		errIter = h.seekValue(nil)
+		if errIter != nil {
+			return
+		}
+		errIter = impl.Walk(h.r, h.options.maxDepth, func(path []string, t impl.Type, offset, size int64) (err error) {
+			if t != impl.TypeGob && t != impl.TypeRef {
+				return
+			}
+			if _, err = h.r.Seek(offset, io.SeekStart); err != nil {
+				return
+			}
+			switch t {
+			case impl.TypeGob:
+				var gob GobValue
+				if gob, err = impl.ReadGob(h.r); err != nil {
+					return
+				}
+				if !yield(slices.Clone(path), gob) {
+					return errStopIter
+				}
+			case impl.TypeRef:
+				// Referenced values are not walked, see [WithDedup].
+				var v any
+				if v, err = impl.ReadValueDepth(h.r, true, h.options.maxDepth, h.options.maxKeyLength); err != nil {
+					return
+				}
+				if !yieldGobValues(path, v, yield) {
+					return errStopIter
+				}
+			}
+			return
+		})
+		if errIter == errStopIter {
+			errIter = nil
+		}
+	}
+	return seq, func() error { return errIter }
+}
+
+// yieldGobValues yields the gob encoded values in v returned by [Hashive.Query]
+// at path. It returns false if yield returns false.
+func yieldGobValues(path []string, v any, yield func([]string, GobValue) bool) bool {
+	childPath := func(elem string) []string {
+		return append(path[:len(path):len(path)], elem)
+	}
+	switch value := v.(type) {
+	case GobValue:
+		return yield(slices.Clone(path), value)
+	case []any:
+		for i, elem := range value {
+			if !yieldGobValues(childPath(strconv.Itoa(i)), elem, yield) {
+				return false
+			}
+		}
+	case map[string]any:
+		for key, elem := range value {
+			if !yieldGobValues(childPath(key), elem, yield) {
+				return false
+			}
+		}
+	case []Interval:
+		for i, interval := range value {
+			if !yieldGobValues(childPath(strconv.Itoa(i)), interval.Value, yield) {
+				return false
+			}
+		}
+	}
+	return true
+}
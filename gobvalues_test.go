@@ -0,0 +1,57 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestGobValues(t *testing.T) {
+	type Point struct{ X, Y int }
+	shared := []any{Point{5, 6}, "not gob"}
+	value := map[string]any{
+		"p":    Point{1, 2},
+		"list": []any{1, Point{3, 4}},
+		"a":    shared,
+		"b":    shared,
+	}
+	for _, opts := range [][]hashive.WriteOption{nil, {hashive.WithDedup()}} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]Point)
+		seq, errf := h.GobValues()
+		for path, gob := range seq {
+			var p Point
+			if err := gob.Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			got[strings.Join(path, "/")] = p
+		}
+		if err := errf(); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]Point{"p": {1, 2}, "list/1": {3, 4}, "a/0": {5, 6}, "b/0": {5, 6}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatal(got)
+		}
+
+		var paths [][]string
+		for path := range seq {
+			paths = append(paths, path)
+			break
+		}
+		if err := errf(); err != nil || len(paths) != 1 || !slices.Contains([]string{"p", "list/1", "a/0", "b/0"}, strings.Join(paths[0], "/")) {
+			t.Fatal(paths, err)
+		}
+	}
+}
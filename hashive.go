@@ -2,19 +2,47 @@ package hashive
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/mkch/hashive/internal/impl"
 )
 
-const fileSignature = "hashive\x00"
+// Header keys.
+const (
+	headerSchema = "schema"
+	headerLength = "length" // the size of the root value, including refs
+	headerRefs   = "refs"   // the size of referenced values before the root value, see [WithDedup]
+	headerACL    = "acl"    // see [WithACL]
+	headerMeta   = "meta"   // the size of the side table of metadata, see [ValueWithMeta]
+	headerOrder  = "order"  // the size of the side table of key order, see [OrderedObject]
+	headerMulti  = "multi"  // the size of the side table of multi-values, see [MultiValue]
+	// the size of the side table of provenance, see [ValueWithProvenance]
+	headerProvenance = "provenance"
+	// type name -> fingerprint of gob encoded types, see [RegisterGobTypes].
+	headerGobTypes = "gobTypes"
+	// the fingerprint of the database checked by index files, see [WithIndexFile]
+	headerFingerprint = "fingerprint"
+)
+
+// EncodeError is returned by [Write] and its variants when a value can't be
+// encoded, such as a value encoding/gob can't encode or an invalid [Interval].
+// Its Path is the path to the value.
+type EncodeError = impl.EncodeError
+
+// writerPool is the pool of buffered writers used by [Write].
+var writerPool = sync.Pool{
+	New: func() any { return bufio.NewWriter(nil) },
+}
 
 // Write encodes value into Hashive format recursively and writes it to w.
 //   - All singed integers are stored as int64.
@@ -23,23 +51,216 @@ const fileSignature = "hashive\x00"
 //   - bool, string and []byte are stored as is.
 //   - []any is stored as array.
 //   - map[string]any is stored as associated object.
+//   - nil is stored as null, while nil []any and nil map[string]any are
+//     stored as empty array and object.
 //   - All the others types are stored as gob encoded binary data.
-func Write(w io.Writer, value any) (err error) {
-	buffered := bufio.NewWriter(w)
+//
+// The database is written in format [Version1], which stores a header before
+// the root value even if no option needs it, or in format [Version2] if an
+// option or the value needs a layout added after version 1, such as
+// [WithBlobWriter]. Readers of older versions, including the releases of this
+// package before the header was added, refuse such databases by the
+// signature; use [Migrate] to write databases for them.
+//
+// The options are applied in order.
+func Write(w io.Writer, value any, opts ...WriteOption) (err error) {
+	signature, headerData, payload, err := encode(value, newWriteOptions(opts), false)
+	if err != nil {
+		return
+	}
+
+	buffered := writerPool.Get().(*bufio.Writer)
+	buffered.Reset(w)
 	defer func() {
 		errFlush := buffered.Flush()
 		if err == nil {
 			err = errFlush
 		}
+		buffered.Reset(nil) // Do not retain w.
+		writerPool.Put(buffered)
 	}()
 
 	// Write magic number
-	if _, err = buffered.WriteString(fileSignature); err != nil {
+	if _, err = buffered.WriteString(signature); err != nil {
+		return
+	}
+
+	if _, err = headerData.WriteTo(buffered); err != nil {
+		return
+	}
+	_, err = payload.WriteTo(buffered)
+	return
+}
+
+// encode encodes the header and root value of a database, and returns the
+// file signature of its format version.
+// If parallel is true, the values in the root value are encoded concurrently.
+func encode(value any, options *writeOptions, parallel bool) (signature string, headerData, payload *bytes.Buffer, err error) {
+	if options.transform != nil {
+		value, _ = transform(nil, value, options.transform)
+	}
+	tables := make(map[string]map[string]any)
+	if value, err = options.prepare(nil, value, tables); err != nil {
+		return
+	}
+	if err = options.schema.Validate(value); err != nil {
+		return
+	}
+	if options.maxVersion < 0 || options.maxVersion > CurrentVersion {
+		err = fmt.Errorf("unsupported version %v", options.maxVersion)
+		return
+	}
+	if options.legacy {
+		return encodeVersion0(value, options, tables)
+	}
+	version := options.version()
+	if version < Version2 && contains(value, isBlob) {
+		version = Version2 // Blobs copied from another database.
+	}
+	if options.maxVersion > 0 && version > options.maxVersion {
+		err = fmt.Errorf("the database needs version %v", version)
+		return
+	}
+	signature = impl.FileSignatureHeader
+	if version == Version2 {
+		signature = impl.FileSignatureVersion2
+	}
+	if options.blobs != nil {
+		if value, err = options.blobs.extract(value); err != nil {
+			return
+		}
+	}
+	header := make(map[string]any)
+	if options.schema != nil {
+		header[headerSchema] = options.schema.value()
+	}
+	if len(options.acl) > 0 {
+		header[headerACL] = aclValue(options.acl)
+	}
+
+	// The root value is encoded before the header to store its length.
+	var gobTypes gobTypeRecorder
+	gobEncoder := gobTypes.wrap(impl.NewGobEncoder())
+	encoder := options.newEncoder(gobEncoder)
+	if seq, ok := value.(*pairSeq); ok {
+		if value, err = seq.encode(encoder, options, tables); err != nil {
+			return
+		}
+	}
+	payload = new(bytes.Buffer)
+	var dedup *deduper
+	if options.dedup {
+		if dedup, err = newDeduper(value, encoder); err != nil {
+			return
+		}
+		// Refs are written with fixed size, so the size of payload
+		// does not depend on where it starts.
+		if err = dedup.write(payload, 0); err != nil {
+			return
+		}
+		if dedup.refsSize > 0 {
+			header[headerRefs] = uint64(dedup.refsSize)
+		}
+	} else {
+		if parallel {
+			if value, err = encodeChildren(encoder, value); err != nil {
+				return
+			}
+		}
+		switch value := value.(type) {
+		case *elemSeq:
+			err = value.write(payload, encoder, options, tables)
+		case hashedEntries:
+			err = encoder.WriteEntries(payload, value)
+		default:
+			err = encoder.WriteValue(payload, value)
+		}
+		if err != nil {
+			return
+		}
+	}
+	header[headerLength] = uint64(payload.Len())
+	tableData := new(bytes.Buffer)
+	if err = writeSideTables(tableData, tables, encoder, header); err != nil {
+		return
+	}
+	for name, fp := range options.gobTypes {
+		gobTypes.value[name] = fp
+	}
+	if len(gobTypes.value) > 0 {
+		header[headerGobTypes] = gobTypes.value
+	}
+	if options.checksums > 0 {
+		header[headerChecksums] = uint64(options.checksums)
+	}
+	headerData = new(bytes.Buffer)
+	headerEncoder := &impl.Encoder{Gob: gobEncoder, SortedKeys: options.sortedKeys, HashOrder: options.hashOrder, PrimeTable: options.primeTable, Pow2Buckets: options.pow2Buckets}
+	if options.indexFile {
+		if header[headerFingerprint], err = contentFingerprint(headerEncoder, header, payload, tableData); err != nil {
+			return
+		}
+	}
+	if err = headerEncoder.WriteObject(headerData, header); err != nil {
 		return
 	}
+	if dedup != nil && dedup.refsSize > 0 {
+		// Write again with the real positions of referenced values.
+		payload.Reset()
+		if err = dedup.write(payload, uint64(len(signature)+headerData.Len())); err != nil {
+			return
+		}
+	}
+	// Side tables are stored after the root value.
+	if _, err = tableData.WriteTo(payload); err != nil {
+		return
+	}
+	if options.checksums > 0 {
+		err = writeChecksums(payload, options.checksums)
+	}
+	return
+}
 
-	gobEncoder := impl.NewGobEncoder()
-	return impl.WriteValue(buffered, value, gobEncoder)
+// newEncoder returns the encoder of values with the options o.
+func (o *writeOptions) newEncoder(gobEncoder impl.GobEncoder) *impl.Encoder {
+	return &impl.Encoder{
+		Gob:            gobEncoder,
+		FrontCoding:    o.frontCoding,
+		FixedKeys:      o.fixedKeys,
+		IntKeys:        o.intKeys,
+		SortedKeys:     o.sortedKeys,
+		HashOrder:      o.hashOrder,
+		ChunkSize:      o.chunkSize,
+		ChunkThreshold: o.chunkOver,
+		ArrayChunkSize: o.arrayChunkSize,
+		PrimeTable:     o.primeTable,
+		Pow2Buckets:    o.pow2Buckets,
+		MaxBuckets:     o.maxBuckets,
+		Compress:       o.compress,
+	}
+}
+
+// prepare checks the value to write at path, and extracts the side values
+// of it into tables. It returns the value to encode.
+func (o *writeOptions) prepare(path []string, value any, tables map[string]map[string]any) (_ any, err error) {
+	if o.utf8 != UTF8Keep {
+		if value, err = checkUTF8(path, value, o.utf8); err != nil {
+			return
+		}
+	}
+	if o.maxKeyLength > 0 {
+		if err = checkKeyLength(path, value, o.maxKeyLength); err != nil {
+			return
+		}
+	}
+	if o.duplicateKeys != DuplicateKeepLast && contains(value, isOrderedObject) {
+		if value, err = dedupKeys(path, value, o.duplicateKeys); err != nil {
+			return
+		}
+	}
+	if contains(value, isSideValue) {
+		value = extractSideValues(path, value, tables)
+	}
+	return value, nil
 }
 
 func writeFile(filename string, callback func(f *os.File) error) (err error) {
@@ -56,46 +277,81 @@ func writeFile(filename string, callback func(f *os.File) error) (err error) {
 	return callback(f)
 }
 
-// WriteFile is like [Write] but writes value to a file.
+// WriteFile is like [Write] but writes value to a file with [WriteAt].
 // The file will be overwritten if exists.
-func WriteFile(filename string, value any) (err error) {
-	return writeFile(filename, func(f *os.File) error {
-		return Write(f, value)
+// See [WithIndexFile] for writing the index file with it.
+func WriteFile(filename string, value any, opts ...WriteOption) (err error) {
+	// The index file of the old database must not be used with the new one.
+	if err = removeIndexFile(filename); err != nil {
+		return
+	}
+	options := newWriteOptions(opts)
+	var data []byte
+	var info os.FileInfo
+	err = writeFile(filename, func(f *os.File) (err error) {
+		if data, err = writeAt(f, 0, value, options); err != nil {
+			return
+		}
+		info, err = f.Stat()
+		return
 	})
+	if err != nil || !options.indexFile {
+		return
+	}
+	return writeIndexFile(filename, data, info)
 }
 
 // WriteJSON decodes the next JSON-encoded value from jsonInput,
 // and then writes the decoded value with [Write].
-func WriteJSON(w io.Writer, jsonInput io.Reader) (err error) {
-	var v any
-	if err = json.NewDecoder(jsonInput).Decode(&v); err != nil {
+func WriteJSON(w io.Writer, jsonInput io.Reader, opts ...WriteOption) (err error) {
+	v, err := readJSON(jsonInput, newWriteOptions(opts))
+	if err != nil {
 		return
 	}
-	return Write(w, v)
+	return Write(w, v, opts...)
+}
+
+// readJSON decodes the next JSON-encoded value from jsonInput to write with options.
+func readJSON(jsonInput io.Reader, options *writeOptions) (v any, err error) {
+	if options.jsonSource != "" || options.keyOrder || options.duplicateKeys != DuplicateKeepLast {
+		return decodeJSON(jsonInput, options)
+	}
+	err = json.NewDecoder(jsonInput).Decode(&v)
+	return
 }
 
-// WriteFileJSON is like [WriteJSON] but writes the decoded value to a file.
+// WriteFileJSON is like [WriteJSON] but writes the decoded value to a file
+// with [WriteFile].
 // The file will be overwritten if exists.
-func WriteFileJSON(filename string, jsonInput io.Reader) (err error) {
-	return writeFile(filename, func(f *os.File) error {
-		return WriteJSON(f, jsonInput)
-	})
+func WriteFileJSON(filename string, jsonInput io.Reader, opts ...WriteOption) (err error) {
+	v, err := readJSON(jsonInput, newWriteOptions(opts))
+	if err != nil {
+		return
+	}
+	return WriteFile(filename, v, opts...)
 }
 
 // WriteJSONString the next JSON-encoded value from jsonString,
 // and then writes the decoded value with [Write].
-func WriteJSONString(w io.Writer, jsonString string) (err error) {
-	return WriteJSON(w, strings.NewReader(jsonString))
+func WriteJSONString(w io.Writer, jsonString string, opts ...WriteOption) (err error) {
+	return WriteJSON(w, strings.NewReader(jsonString), opts...)
 }
 
-// WriteFileJSONString is like [WriteJSONString] but writes the decoded value to a file.
+// WriteFileJSONString is like [WriteJSONString] but writes the decoded value to a file
+// with [WriteFile].
 // The file will be overwritten if exists.
-func WriteFileJSONString(filename string, jsonString string) (err error) {
-	return writeFile(filename, func(f *os.File) error {
-		return WriteJSONString(f, jsonString)
-	})
+func WriteFileJSONString(filename string, jsonString string, opts ...WriteOption) (err error) {
+	return WriteFileJSON(filename, strings.NewReader(jsonString), opts...)
 }
 
+// GobValue is a gob encoded value, which is returned by [Hashive.Query] for
+// the values of types other than those listed in [Write].
+//
+// Call its Decode(v any) error method to decode it into the value pointed to
+// by v, the same as [Hashive.QueryGob] does, except the type of v is not
+// checked against the definition used to write it(see [RegisterGobTypes]).
+type GobValue = impl.GobValue
+
 // ErrNotFound is returned by [Hashive.Query] and [Hashive.QueryGob]
 // when no matching value is found.
 var ErrNotFound = impl.ErrNotFound
@@ -103,81 +359,277 @@ var ErrNotFound = impl.ErrNotFound
 // Hashive is the Hashive instance.
 type Hashive struct {
 	r          impl.ByteReadSeeker
+	rootPos    int64 // the position of the root value
 	ary        *impl.Array
 	obj        *impl.Object
-	gobDecoder func(gob impl.GobValue, v any) error
+	schema     *Schema
+	gobDecoder func(gob GobValue, v any) error
+	gobTypes   map[string]uint64 // fingerprints of gob types, see [RegisterGobTypes]
+	legacy     bool              // written without header, see [impl.FileSignature]
+	acl        []ACLEntry
+	tables     map[string]*impl.Object // side tables, see [sideTables]
+	options    *options
+	snapshot   func() (*Hashive, error) // see [Hashive.Snapshot]
+	checksums  *checksums               // see [Hashive.Scrub]
+	// the fingerprint in the header, or 0 if none, see [WithIndexFile]
+	fingerprint uint64
+	mapped      []byte       // the memory mapped by [OpenMmap] or [OpenShared]
+	cache       *resultCache // see [WithResultCache]
 }
 
 const defaultBufferSize = 1024
 
 // Open opens the Hashive database denoted by filename.
 // The returned close function can be used to close the database file after use.
-// See [New] for more details.
-func Open(filename string, readBufferSize int) (h *Hashive, close func() error, err error) {
+// See [New] for more details, and [WithInMemory] to read small files into memory.
+// The index file written by [WithIndexFile] is used if it exists.
+func Open(filename string, readBufferSize int, opts ...Option) (h *Hashive, close func() error, err error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return
 	}
-	close = f.Close
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+	opts, unmapIndex := withIndexFile(filename, info, opts)
+	defer func() {
+		if err != nil {
+			unmapIndex()
+		}
+	}()
+	close = func() error {
+		return errors.Join(f.Close(), unmapIndex())
+	}
+	if threshold := newOptions(opts).inMemoryThreshold(); threshold > 0 {
+		if info.Size() <= threshold {
+			data := make([]byte, info.Size())
+			_, err = io.ReadFull(f, data)
+			if errClose := f.Close(); err == nil {
+				err = errClose
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			close = unmapIndex
+			h, err = NewBytes(data, append(opts[:len(opts):len(opts)], chargeBudget(int64(len(data))))...)
+			return
+		}
+	}
 
-	h, err = New(f, readBufferSize)
+	h, err = New(f, readBufferSize, opts...)
 	return
 }
 
 // New creates a Hashive instance from r.
 //
 // If readBufferSize < 0, a reasonable default will be used.
-func New(r io.ReadSeeker, readBufferSize int) (h *Hashive, err error) {
-	if readBufferSize < 0 {
-		readBufferSize = defaultBufferSize
+// See [WithMemoryBudget] for bounding it.
+// The options are applied in order.
+func New(r io.ReadSeeker, readBufferSize int, opts ...Option) (h *Hashive, err error) {
+	reader, bufferSize, err := newOptions(opts).newReader(r, readBufferSize)
+	if err != nil {
+		return
+	}
+	if h, err = newHashive(reader, append(opts[:len(opts):len(opts)], chargeBudget(int64(bufferSize)))); err != nil {
+		return
+	}
+	if ra, ok := r.(io.ReaderAt); ok {
+		h.snapshot = func() (*Hashive, error) {
+			mr, err := NewMultiReader(ra)
+			if err != nil {
+				return nil, err
+			}
+			return New(mr, readBufferSize, opts...)
+		}
+	}
+	return
+}
+
+// NewBytes creates a Hashive instance from data, which is a Hashive database
+// in memory, such as a memory mapped file. data must not be modified while
+// the returned Hashive is in use.
+func NewBytes(data []byte, opts ...Option) (h *Hashive, err error) {
+	if h, err = newHashive(impl.NewSliceReader(data), opts); err != nil {
+		return
 	}
-	reader, err := impl.NewBufByteReadSeeker(r, readBufferSize)
+	h.snapshot = func() (*Hashive, error) {
+		return NewBytes(data, opts...)
+	}
+	return
+}
+
+// NewMemory creates a Hashive instance of value written with opts into
+// memory, see [Write], so that the code querying a database can be tested
+// without files. The queries of it behave the same as the ones of the
+// database files of value. To open it with options, use [Write] and
+// [NewBytes] instead.
+func NewMemory(value any, opts ...WriteOption) (h *Hashive, err error) {
+	var buf bytes.Buffer
+	if err = Write(&buf, value, opts...); err != nil {
+		return
+	}
+	return NewBytes(buf.Bytes())
+}
+
+// newHashive creates a Hashive instance reading from reader.
+func newHashive(reader impl.ByteReadSeeker, opts []Option) (h *Hashive, err error) {
+	version, header, err := impl.ReadHeader(reader)
 	if err != nil {
 		return
 	}
-	signature := make([]byte, len(fileSignature))
-	if _, err = io.ReadFull(reader, signature); err != nil {
+	gobDecoder := impl.NewGobDecoder()
+	if version == Version0 {
+		gobDecoder = impl.NewStreamGobDecoder()
+	}
+	schema, err := schemaFromValue(header[headerSchema])
+	if err != nil {
 		return
 	}
-	if sig := string(signature); sig != fileSignature {
-		err = fmt.Errorf("invalid signature %v", sig)
+	gobTypes, err := gobTypesFromValue(header[headerGobTypes])
+	if err != nil {
+		return
+	}
+	acl, err := impl.ACLFromValue(header[headerACL])
+	if err != nil {
+		return
+	}
+	rootPos, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	checksums, err := checksumsFromValue(header[headerChecksums], rootPos, header)
+	if err != nil {
 		return
 	}
 
-	var ary *impl.Array
-	var obj *impl.Object
-	obj, err = impl.ReadObject(reader)
-	var typeErr *impl.TypeError
-	if errors.As(err, &typeErr) {
-		if _, err = reader.Seek(int64(len(fileSignature)), io.SeekStart); err != nil {
+	if length, ok := header[headerLength]; ok {
+		if err = checkLength(reader, rootPos, length); err != nil {
 			return
 		}
-		ary, err = impl.ReadArray(reader)
-		if !errors.As(err, &typeErr) {
+	}
+	var tables map[string]*impl.Object
+	if length, ok := header[headerLength].(uint64); ok {
+		if tables, err = readSideTables(reader, rootPos+int64(length), header, newOptions(opts).maxDepth); err != nil {
+			return
+		}
+		if _, err = reader.Seek(rootPos, io.SeekStart); err != nil {
+			return
+		}
+	}
+	if refs, ok := header[headerRefs]; ok {
+		// Referenced values are stored before the root value.
+		n, ok := refs.(uint64)
+		if length, _ := header[headerLength].(uint64); !ok || n > length {
+			err = fmt.Errorf("invalid refs %v", refs)
+			return
+		}
+		rootPos += int64(n)
+		if _, err = reader.Seek(rootPos, io.SeekStart); err != nil {
+			return
+		}
+	}
+
+	fingerprint, _ := header[headerFingerprint].(uint64)
+
+	// The root can be a value of any type.
+	options := newOptions(opts)
+	ary, obj, err := impl.ReadContainer(reader, options.maxDepth, options.maxKeyLength)
+	if err != nil {
+		return
+	}
+	switch {
+	case options.index != nil && obj != nil && options.index.use(fingerprint, obj):
+		// The index file is ignored if it doesn't match the database.
+	case options.directory && obj != nil:
+		n, bounded := options.available()
+		if bounded && n == 0 {
+			break
+		}
+		var loaded bool
+		if loaded, err = obj.LoadDirectory(n); err != nil {
 			return
+		} else if loaded {
+			starts, entries, _ := obj.Directory()
+			options.charged += int64(len(starts) + len(entries))
 		}
 	}
 
 	return &Hashive{
-		r:          reader,
-		ary:        ary,
-		obj:        obj,
-		gobDecoder: impl.NewGobDecoder(),
+		r:           reader,
+		rootPos:     rootPos,
+		ary:         ary,
+		obj:         obj,
+		schema:      schema,
+		gobDecoder:  gobDecoder,
+		gobTypes:    gobTypes,
+		legacy:      header == nil,
+		acl:         acl,
+		tables:      tables,
+		options:     options,
+		checksums:   checksums,
+		fingerprint: fingerprint,
+		cache:       newResultCache(options),
 	}, nil
 }
 
+// ErrTruncated is returned when opening a database which is shorter than
+// the length recorded in its header, such as a partially copied file.
+var ErrTruncated = errors.New("truncated database")
+
+// ErrPartiallyWritten is returned when opening a database whose writing by
+// [WriteAt] or [WriteFile] was interrupted, such as by a crash.
+var ErrPartiallyWritten = impl.ErrPartiallyWritten
+
+// checkLength checks whether the size of reader is large enough to hold
+// the root value at rootPos of length. On success, reader is positioned
+// at rootPos.
+func checkLength(reader impl.ByteReadSeeker, rootPos int64, length any) (err error) {
+	n, ok := length.(uint64)
+	if !ok || n > uint64(math.MaxInt64-rootPos) {
+		return fmt.Errorf("invalid length %v", length)
+	}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if expected := rootPos + int64(n); size < expected {
+		return fmt.Errorf("%w (expected %v bytes, have %v)", ErrTruncated, expected, size)
+	}
+	_, err = reader.Seek(rootPos, io.SeekStart)
+	return
+}
+
+// Schema returns the schema stored in the database, or nil if there is none.
+// See [WithSchema].
+func (h *Hashive) Schema() *Schema {
+	return h.schema
+}
+
+// NewSection creates a Hashive instance from the n bytes of r starting at offset off.
+// It is used to query a database embedded in a larger file, such as an archive,
+// in place.
+//
+// See [New] for the meaning of readBufferSize and opts.
+func NewSection(r io.ReaderAt, off int64, n int64, readBufferSize int, opts ...Option) (h *Hashive, err error) {
+	return New(io.NewSectionReader(r, off, n), readBufferSize, opts...)
+}
+
 // QueryGob queries a gob encoded value mapped by the path.
 // [ErrNotFound] will be returned if the path does not map to any value
 // or the type of the value is not a gob encoded value.
+// A [*GobTypeError] will be returned if the definition of the type of v is
+// different from the one used to write the value.
 //
 // For the meaning of argument path, see [Hashive.Query].
 func (h *Hashive) QueryGob(v any, path ...string) (err error) {
-	value, err := h.Query(path...)
+	value, err := h.query(path, true)
 	if err != nil {
 		return
 	}
-	if gob, ok := value.(impl.GobValue); ok {
-		err = h.gobDecoder(gob, v)
+	if gob, ok := value.(GobValue); ok {
+		err = h.decodeGob(gob, v)
 	} else {
 		err = ErrNotFound
 	}
@@ -195,57 +647,409 @@ func (h *Hashive) QueryGob(v any, path ...string) (err error) {
 //
 //	h["key1"]["key2"][1]["key3"]
 //
-// Empty path maps to the entire value(a map[string]any or []any).
+// Empty path maps to the entire value, which is the root value of any type
+// written by [Write]. If the root value is not an array or object, non-empty
+// paths do not map to any value.
+//
+// Null, empty arrays and empty objects are returned as nil, empty []any and
+// empty map[string]any respectively, so they remain distinguishable.
+// See also [Hashive.IsNull] and [Hashive.IsEmptyObject].
 func (h *Hashive) Query(path ...string) (v any, err error) {
-	if len(path) == 0 {
-		if _, err = h.r.Seek(int64(len(fileSignature)), io.SeekStart); err != nil {
+	var key string
+	if h.cache != nil {
+		key = cacheKey(path)
+		if v, ok := h.cache.get(key); ok {
+			return v, nil
+		}
+	}
+	if h.options.stringBytes {
+		v, err = h.queryBytes(path)
+	} else {
+		v, err = h.query(path, true)
+	}
+	if err != nil {
+		return
+	}
+	if v, err = h.result(path, v); err == nil && h.cache != nil {
+		h.cache.put(key, v)
+	}
+	return
+}
+
+// QueryWithBuffer is like [Hashive.Query], but reads with a buffer of
+// bufSize bytes, or the default size if bufSize < 0, and restores the buffer
+// after, so that an occasional export of a large subtree can use a large
+// buffer without inflating the memory used by point lookups all the time.
+// bufSize is bounded by [WithMemoryBudget]. It is the same as
+// [Hashive.Query] if h is read without a buffer, such as created by
+// [NewBytes], or bufSize is 0.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryWithBuffer(bufSize int, path ...string) (v any, err error) {
+	restore, err := impl.SetBufferSize(h.r, h.options.bufferSize(bufSize))
+	if err != nil {
+		return
+	}
+	defer func() {
+		if errRestore := restore(); err == nil {
+			err = errRestore
+		}
+	}()
+	return h.Query(path...)
+}
+
+// queryBytes is like [Hashive.query] with recursive true, but strings are
+// read as []byte. See [WithStringBytes].
+func (h *Hashive) queryBytes(path []string) (v any, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	// Every key or index of the path is in a container.
+	if v, err = impl.ReadValueBytes(h.r, len(path), h.options.maxDepth, h.options.maxKeyLength); err != nil {
+		return
+	}
+	return h.hideACL(path, v), nil
+}
+
+// result converts v mapped by the path, which is read recursively,
+// to the value returned by queries. See [WithBlobReader], [WithGobDecoding]
+// and [WithReadTransform].
+func (h *Hashive) result(path []string, v any) (_ any, err error) {
+	if h.options.blobs != nil && contains(v, isBlob) {
+		if v, err = h.readBlobs(v); err != nil {
+			return
+		}
+	}
+	if h.options.decodeGob {
+		v = expandGob(v)
+	}
+	if h.options.utf8 != UTF8Keep {
+		if v, err = checkUTF8(slices.Clone(path), v, h.options.utf8); err != nil {
 			return
 		}
-		return impl.ReadValue(h.r, true)
 	}
-	if h.obj != nil {
-		return queryObject(path, h.obj)
+	if h.options.transform == nil {
+		return v, nil
+	}
+	return readTransform(slices.Clone(path), v, h.options.transform)
+}
+
+// query queries a value mapped by the path.
+// If recursive is false, arrays and objects are returned as [impl.Array] and [impl.Object].
+func (h *Hashive) query(path []string, recursive bool) (v any, err error) {
+	if err = h.checkACL(path); err != nil {
+		return
+	}
+	if len(path) == 0 {
+		if _, err = h.r.Seek(h.rootPos, io.SeekStart); err != nil {
+			return
+		}
+		v, err = impl.ReadValueDepth(h.r, recursive, h.options.maxDepth, h.options.maxKeyLength)
+	} else if h.obj != nil {
+		v, err = queryObject(path, h.obj, recursive)
 	} else if h.ary != nil {
-		return queryArray(path, h.ary)
+		v, err = queryArray(path, h.ary, recursive)
+	} else {
+		err = ErrNotFound
 	}
-	return nil, ErrNotFound
+	if err == nil && recursive {
+		v = h.hideACL(path, v)
+	}
+	return
 }
 
-func queryObject(path []string, obj *impl.Object) (v any, err error) {
-	value, err := obj.Index(path[0], len(path) == 1)
+func queryObject(path []string, obj *impl.Object, recursive bool) (v any, err error) {
+	value, err := obj.Index(path[0], recursive && len(path) == 1)
 	if err != nil {
 		return
 	}
 	if len(path) == 1 {
 		return value, err
 	} else if obj, ok := value.(*impl.Object); ok {
-		return queryObject(path[1:], obj)
+		return queryObject(path[1:], obj, recursive)
 	} else if ary, ok := value.(*impl.Array); ok {
-		return queryArray(path[1:], ary)
+		return queryArray(path[1:], ary, recursive)
 	}
 	return nil, ErrNotFound
 }
 
-func queryArray(path []string, ary *impl.Array) (v any, err error) {
-	index, err := strconv.ParseUint(path[0], 0, 64)
+func queryArray(path []string, ary *impl.Array, recursive bool) (v any, err error) {
+	index, err := parseIndex(path[0])
 	if err != nil {
 		return
 	}
-	if index > math.MaxInt {
-		err = fmt.Errorf("invalid index %v", index)
-		return
-	}
 
-	value, err := ary.Index(int(index), len(path) == 1)
+	value, err := ary.Index(index, recursive && len(path) == 1)
 	if err != nil {
 		return
 	}
 	if len(path) == 1 {
 		return value, err
 	} else if obj, ok := value.(*impl.Object); ok {
-		return queryObject(path[1:], obj)
+		return queryObject(path[1:], obj, recursive)
 	} else if ary, ok := value.(*impl.Array); ok {
-		return queryArray(path[1:], ary)
+		return queryArray(path[1:], ary, recursive)
 	}
 	return nil, ErrNotFound
 }
+
+// parseIndex parses an array index in path.
+func parseIndex(s string) (index int, err error) {
+	n, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return
+	}
+	if n > math.MaxInt {
+		err = fmt.Errorf("invalid index %v", n)
+		return
+	}
+	return int(n), nil
+}
+
+// container returns the [*impl.Object] or [*impl.Array] mapped by the path,
+// or nil if the value is not a container.
+func (h *Hashive) container(path []string) (container any, err error) {
+	if len(path) > 0 {
+		return h.query(path, false)
+	} else if h.obj != nil {
+		return h.obj, nil
+	} else if h.ary != nil {
+		return h.ary, nil
+	}
+	return nil, nil
+}
+
+// HashKey returns the hash of key mixed with seed. The hash of seed 0 can be
+// passed to [Hashive.QueryHashed] to look up key without hashing it again.
+func HashKey(seed uint64, key string) uint64 {
+	return impl.HashKey(seed, key)
+}
+
+// QueryHashed is like [Hashive.Query], but the last key of the path is looked
+// up with its precomputed hash, which must be HashKey(0, key). The hash of a
+// key can be reused across lookups against any databases.
+// [ErrNotFound] will be returned if the path does not map to a value of an object.
+func (h *Hashive) QueryHashed(hash uint64, path ...string) (v any, err error) {
+	if len(path) == 0 {
+		return nil, ErrNotFound
+	}
+	if err = h.checkACL(path); err != nil {
+		return
+	}
+	container, err := h.container(path[:len(path)-1])
+	if err != nil {
+		return
+	}
+	obj, ok := container.(*impl.Object)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if v, err = obj.IndexHash(hash, path[len(path)-1], true); err != nil {
+		return
+	}
+	return h.result(path, v)
+}
+
+// seekValue positions the underlying reader at the start of the value mapped by the path.
+func (h *Hashive) seekValue(path []string) (err error) {
+	if err = h.checkACL(path); err != nil {
+		return
+	}
+	if len(path) == 0 {
+		_, err = h.r.Seek(h.rootPos, io.SeekStart)
+		return
+	}
+	container, err := h.container(path[:len(path)-1])
+	if err != nil {
+		return
+	}
+	switch c := container.(type) {
+	case *impl.Object:
+		return c.Seek(path[len(path)-1])
+	case *impl.Array:
+		var index int
+		if index, err = parseIndex(path[len(path)-1]); err != nil {
+			return
+		}
+		return c.Seek(index)
+	}
+	return ErrNotFound
+}
+
+// QueryStringAppend queries a string mapped by the path and appends it to dst.
+// Unlike [Hashive.Query], it does not allocate if dst has enough capacity and
+// the value is directly in the root, so it is suitable for hot lookup paths.
+// An error will be returned if the value is not a string.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryStringAppend(dst []byte, path ...string) ([]byte, error) {
+	if err := h.seekValue(path); err != nil {
+		return dst, err
+	}
+	return impl.AppendString(dst, h.r)
+}
+
+// QueryBinaryAppend is like [Hashive.QueryStringAppend] but queries a []byte value.
+func (h *Hashive) QueryBinaryAppend(dst []byte, path ...string) ([]byte, error) {
+	if err := h.seekValue(path); err != nil {
+		return dst, err
+	}
+	return impl.AppendBinary(dst, h.r)
+}
+
+// QueryBinaryView queries a []byte value mapped by the path.
+// If h is created by [NewBytes] or [OpenMmap], the returned slice is a view of
+// the underlying memory without copying, which is only valid until the memory
+// is released(the close function of [OpenMmap] is called), and must not be
+// modified. Otherwise, it returns a copy as [Hashive.Query] does.
+// An error will be returned if the value is not a []byte.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryBinaryView(path ...string) (p []byte, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	return impl.ReadBinaryView(h.r)
+}
+
+// QueryReader returns the reader of the bytes of a string or []byte value
+// mapped by the path and their size, which streams the bytes from the
+// underlying reader instead of reading them into memory as [Hashive.Query]
+// does. Blobs are read from the blob file, see [WithBlobReader], and values
+// written with [WithCompression] are decompressed into memory.
+// The reader can be read between other queries of h, but not concurrently.
+// An error will be returned if the value is not a string or []byte.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryReader(path ...string) (r io.Reader, size int64, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	sr, size, err := h.bytesReader()
+	if err != nil {
+		return nil, 0, err
+	}
+	return sr, size, nil
+}
+
+// bytesReader returns the reader of the bytes of the string or []byte value
+// at the current position of h.r and their size.
+func (h *Hashive) bytesReader() (r *io.SectionReader, size int64, err error) {
+	length, blob, inflated, err := impl.ReadBytesHeader(h.r)
+	if err != nil {
+		return
+	}
+	if inflated != nil {
+		return io.NewSectionReader(bytes.NewReader(inflated), 0, int64(len(inflated))), int64(len(inflated)), nil
+	}
+	if blob != nil {
+		var sr *io.SectionReader
+		if sr, err = h.blobReader(*blob); err != nil {
+			return
+		}
+		return sr, sr.Size(), nil
+	}
+	pos, err := h.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if length > uint64(math.MaxInt64-pos) {
+		return nil, 0, fmt.Errorf("invalid length %v", length)
+	}
+	return io.NewSectionReader(seekReaderAt{h.r}, pos, int64(length)), int64(length), nil
+}
+
+// QueryReaderAt is like [Hashive.QueryReader], but returns an [io.ReaderAt]
+// reading any range of the bytes. Only the chunks in the range are read for
+// values written with [WithChunkedBinary].
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryReaderAt(path ...string) (r io.ReaderAt, size int64, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	start, err := h.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	chunked, err := impl.ReadChunked(h.r)
+	if err == nil {
+		return chunked, chunked.Len(), nil
+	} else if _, ok := err.(*impl.TypeError); !ok {
+		return
+	}
+	if _, err = h.r.Seek(start, io.SeekStart); err != nil {
+		return
+	}
+	sr, size, err := h.bytesReader()
+	if err != nil {
+		return nil, 0, err
+	}
+	return sr, size, nil
+}
+
+// seekReaderAt implements [io.ReaderAt] by seeking r, so that it can be read
+// between other reads of r, but not concurrently.
+type seekReaderAt struct {
+	r impl.ByteReadSeeker
+}
+
+func (r seekReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if _, err = r.r.Seek(off, io.SeekStart); err != nil {
+		return
+	}
+	return io.ReadFull(r.r, p)
+}
+
+// queryObjectValue queries an object mapped by the path without reading its content.
+// [ErrNotFound] will be returned if the path does not map to an object.
+func (h *Hashive) queryObjectValue(path []string) (obj *impl.Object, err error) {
+	v, err := h.query(path, false)
+	if err != nil {
+		return
+	}
+	obj, ok := v.(*impl.Object)
+	if !ok {
+		err = ErrNotFound
+	}
+	return
+}
+
+// queryArrayValue queries an array mapped by the path without reading its content.
+// [ErrNotFound] will be returned if the path does not map to an array.
+func (h *Hashive) queryArrayValue(path []string) (ary *impl.Array, err error) {
+	v, err := h.query(path, false)
+	if err != nil {
+		return
+	}
+	ary, ok := v.(*impl.Array)
+	if !ok {
+		err = ErrNotFound
+	}
+	return
+}
+
+// QueryStringMap queries an object of string values mapped by the path.
+// It is much faster and allocates much less than [Hashive.Query] for
+// this kind of values, because values are not boxed in interfaces.
+// [ErrNotFound] will be returned if the path does not map to an object,
+// and an error will be returned if any value is not a string.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryStringMap(path ...string) (m map[string]string, err error) {
+	obj, err := h.queryObjectValue(path)
+	if err != nil {
+		return
+	}
+	return obj.StringMap()
+}
+
+// QueryInt64Map is like [Hashive.QueryStringMap] but queries an object of
+// integer values. Unsigned integers are accepted if they fit in int64.
+func (h *Hashive) QueryInt64Map(path ...string) (m map[string]int64, err error) {
+	obj, err := h.queryObjectValue(path)
+	if err != nil {
+		return
+	}
+	return obj.Int64Map()
+}
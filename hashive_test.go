@@ -2,9 +2,14 @@ package hashive_test
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"testing"
 
 	"github.com/mkch/hashive"
@@ -101,3 +106,1032 @@ func TestWriteJSONString(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileJSONString(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFileJSONString(filename, `{"a":[1,"b"]}`, hashive.WithIndexFile()); err != nil {
+		t.Fatal(err)
+	}
+	// Written with WriteFile.
+	if _, err := os.Stat(filename + ".idx"); err != nil {
+		t.Fatal(err)
+	}
+	h, closeFile, err := hashive.Open(filename, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFile()
+	if v, err := h.Query("a", "1"); err != nil || v != "b" {
+		t.Fatal(v, err)
+	}
+
+	if err := hashive.WriteFileJSONString(filename, `{"a"`); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func TestNewSection(t *testing.T) {
+	var db bytes.Buffer
+	if err := hashive.Write(&db, map[string]any{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	prefix := []byte("some leading data")
+	file := append(append(prefix, db.Bytes()...), "trailing data"...)
+
+	h, err := hashive.NewSection(bytes.NewReader(file), int64(len(prefix)), int64(db.Len()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("k"); err != nil {
+		t.Fatal(err)
+	} else if v != "v" {
+		t.Fatal(v)
+	}
+	if v, err := h.Query(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, map[string]any{"k": "v"}) {
+		t.Fatal(v)
+	}
+}
+
+func TestQueryStringMap(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"names": map[string]any{"a": "A", "b": "B", "c": ""},
+		"ages":  map[string]any{"a": 1, "b": -2, "c": uint64(3)},
+		"mixed": map[string]any{"a": "A", "b": 1},
+		"empty": map[string]any{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names, err := h.QueryStringMap("names"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(names, map[string]string{"a": "A", "b": "B", "c": ""}) {
+		t.Fatal(names)
+	}
+	if ages, err := h.QueryInt64Map("ages"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ages, map[string]int64{"a": 1, "b": -2, "c": 3}) {
+		t.Fatal(ages)
+	}
+	if empty, err := h.QueryStringMap("empty"); err != nil {
+		t.Fatal(err)
+	} else if len(empty) != 0 {
+		t.Fatal(empty)
+	}
+	if _, err := h.QueryStringMap("mixed"); err == nil {
+		t.Fatal("should fail")
+	}
+	if _, err := h.QueryInt64Map("names"); err == nil {
+		t.Fatal("should fail")
+	}
+	if _, err := h.QueryStringMap("names", "a"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+
+	var names map[string]string
+	if err := h.QueryInto(&names, "names"); err != nil {
+		t.Fatal(err)
+	} else if names["b"] != "B" {
+		t.Fatal(names)
+	}
+	// Following queries are not affected.
+	if v, err := h.Query("ages", "b"); err != nil {
+		t.Fatal(err)
+	} else if v != int64(-2) {
+		t.Fatal(v)
+	}
+}
+
+func TestQueryStringAppend(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"name":   "mkch",
+		"data":   []byte{1, 2, 3},
+		"nested": map[string]any{"list": []any{"a", "bc"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := []byte("name=")
+	if p, err = h.QueryStringAppend(p, "name"); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "name=mkch" {
+		t.Fatal(string(p))
+	}
+	if p, err = h.QueryStringAppend(p[:0], "nested", "list", "1"); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "bc" {
+		t.Fatal(string(p))
+	}
+	if p, err = h.QueryBinaryAppend(p[:0], "data"); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, []byte{1, 2, 3}) {
+		t.Fatal(p)
+	}
+	if _, err = h.QueryStringAppend(nil, "data"); err == nil {
+		t.Fatal("should fail")
+	}
+	if _, err = h.QueryStringAppend(nil, "none"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err = h.QueryStringAppend(nil, "name", "x"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+
+	p = make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		if p, err = h.QueryStringAppend(p[:0], "name"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatal(allocs)
+	}
+}
+
+func TestScalarRoot(t *testing.T) {
+	type Point struct{ X, Y int }
+	for _, root := range []any{"str", int64(-1), uint64(1), 1.5, true, nil, []byte{1, 2}, Point{1, 2}} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, root); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(root, err)
+		}
+		if p, ok := root.(Point); ok {
+			var v Point
+			if err := h.QueryGob(&v); err != nil {
+				t.Fatal(err)
+			} else if v != p {
+				t.Fatal(v)
+			}
+		} else if v, err := h.Query(); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, root) {
+			t.Fatal(v)
+		}
+		if _, err := h.Query("a"); err != hashive.ErrNotFound {
+			t.Fatal(root, err)
+		}
+		if _, err := h.Query("0"); err != hashive.ErrNotFound {
+			t.Fatal(root, err)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"a": "abc", "b": []any{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if _, err := hashive.New(bytes.NewReader(data), -1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := hashive.New(bytes.NewReader(data[:len(data)-1]), -1)
+	if !errors.Is(err, hashive.ErrTruncated) {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("truncated database (expected %v bytes, have %v)", len(data), len(data)-1)
+	if err.Error() != want {
+		t.Fatal(err)
+	}
+	if _, err := hashive.NewBytes(data[:len(data)-5]); !errors.Is(err, hashive.ErrTruncated) {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryHashed(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"name":   "mkch",
+		"nested": map[string]any{"name": "abc"},
+		"list":   []any{1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := hashive.HashKey(0, "name")
+	if v, err := h.QueryHashed(hash, "name"); err != nil {
+		t.Fatal(err)
+	} else if v != "mkch" {
+		t.Fatal(v)
+	}
+	if v, err := h.QueryHashed(hash, "nested", "name"); err != nil {
+		t.Fatal(err)
+	} else if v != "abc" {
+		t.Fatal(v)
+	}
+	if _, err := h.QueryHashed(hashive.HashKey(0, "0"), "list", "0"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if hashive.HashKey(0, "a") == hashive.HashKey(1, "a") {
+		t.Fatal("seed not mixed")
+	}
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	var v any = "leaf"
+	for range 10 {
+		v = []any{v}
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithMaxDepth(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Query(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithMaxDepth(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var depthErr *hashive.DepthError
+	if _, err := h.Query(); !errors.As(err, &depthErr) {
+		t.Fatal(err)
+	}
+	// The leaf is in an array at depth 10.
+	if _, err := h.Query("0", "0", "0", "0", "0", "0", "0", "0", "0", "0"); !errors.As(err, &depthErr) {
+		t.Fatal(err)
+	}
+}
+
+func TestWithFrontCoding(t *testing.T) {
+	vendors := make(map[string]any)
+	want := make(map[string]string)
+	for i := range 1000 {
+		key, vendor := fmt.Sprintf("00:1A:%02X:%02X", i/256, i%256), fmt.Sprint("vendor", i%10)
+		vendors[key], want[key] = vendor, vendor
+	}
+	value := map[string]any{"vendors": vendors}
+	var plain, prefixed bytes.Buffer
+	if err := hashive.Write(&plain, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := hashive.Write(&prefixed, value, hashive.WithFrontCoding()); err != nil {
+		t.Fatal(err)
+	}
+	if prefixed.Len() >= plain.Len() {
+		t.Fatal(prefixed.Len(), plain.Len())
+	}
+	h, err := hashive.New(bytes.NewReader(prefixed.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("vendors", "00:1A:01:02"); err != nil {
+		t.Fatal(err)
+	} else if v != "vendor8" {
+		t.Fatal(v)
+	}
+	if _, err := h.Query("vendors", "00:1A:01:0"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if m, err := h.QueryStringMap("vendors"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, want) {
+		t.Fatal(m)
+	}
+}
+
+func TestWithSortedKeys(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value[fmt.Sprint("key", i)] = map[string]any{"a": i, "b": []any{"x", "y"}}
+	}
+	for _, opts := range [][]hashive.WriteOption{
+		{hashive.WithSortedKeys()},
+		{hashive.WithSortedKeys(), hashive.WithDedup(), hashive.WithSchema(&hashive.Schema{Kind: hashive.KindObject})},
+		{hashive.WithHashOrder()},
+	} {
+		var first bytes.Buffer
+		if err := hashive.Write(&first, value, opts...); err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			var buf bytes.Buffer
+			if err := hashive.Write(&buf, value, opts...); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), first.Bytes()) {
+				t.Fatal("different bytes")
+			}
+		}
+		h, err := hashive.New(bytes.NewReader(first.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 1100 {
+			v, err := h.Query(fmt.Sprint("key", i), "a")
+			if i >= 1000 {
+				if err != hashive.ErrNotFound {
+					t.Fatal(i, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			} else if v != int64(i) {
+				t.Fatal(v)
+			}
+		}
+		if v, err := h.Query(); err != nil {
+			t.Fatal(err)
+		} else if len(v.(map[string]any)) != len(value) {
+			t.Fatal(len(v.(map[string]any)))
+		}
+	}
+}
+
+func TestWithFixedKeys(t *testing.T) {
+	countries := map[string]any{"CN": "China", "DE": "Germany", "US": "United States"}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"countries": countries}, hashive.WithFixedKeys()); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("countries", "DE"); err != nil {
+		t.Fatal(err)
+	} else if v != "Germany" {
+		t.Fatal(v)
+	}
+	if _, err := h.Query("countries", "FR"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("countries"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, countries) {
+		t.Fatal(v)
+	}
+	// Keys are listed in order.
+	if keys, next, err := h.KeysPage("", 2, "countries"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{"CN", "DE"}) || next == "" {
+		t.Fatal(keys, next)
+	}
+}
+
+func TestWithChunkedArrays(t *testing.T) {
+	rows := func(yield func(any) bool) {
+		for i := range 100 {
+			if !yield(map[string]any{"id": i, "tags": []any{"a", "b"}}) {
+				return
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := hashive.WriteArraySeq(&buf, rows, hashive.WithChunkedArrays(16)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	h, err := hashive.New(bytes.NewReader(data), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{99, 0, 16, 57} {
+		if v, err := h.Query(strconv.Itoa(i), "id"); err != nil || v != int64(i) {
+			t.Fatal(i, v, err)
+		}
+	}
+	if _, err := h.Query("100"); err == nil {
+		t.Fatal("out of range")
+	}
+	if v, err := h.Query(); err != nil || len(v.([]any)) != 100 {
+		t.Fatal(v, err)
+	}
+	types, err := hashive.TypesUsed(bytes.NewReader(data))
+	if err != nil || !slices.ContainsFunc(types, func(u hashive.TypeUsage) bool { return u.Type == "chunkedArray" && u.Count == 1 }) {
+		t.Fatal(types, err)
+	}
+}
+
+func TestWithPrimeTable(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value["key"+strconv.Itoa(i)] = map[string]any{"i": i, "s": strconv.Itoa(i)}
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithPrimeTable()); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 500, 999} {
+		if v, err := h.Query("key"+strconv.Itoa(i), "i"); err != nil || v != int64(i) {
+			t.Fatal(i, v, err)
+		}
+	}
+	if _, err := h.Query("key1000"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestWithPow2Buckets(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value["key"+strconv.Itoa(i)] = map[string]any{"i": i}
+	}
+	for _, opts := range [][]hashive.WriteOption{
+		{hashive.WithPow2Buckets()},
+		{hashive.WithPow2Buckets(), hashive.WithHashOrder()},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range []int{0, 500, 999} {
+			if v, err := h.Query("key"+strconv.Itoa(i), "i"); err != nil || v != int64(i) {
+				t.Fatal(i, v, err)
+			}
+		}
+		if _, err := h.Query("key1000"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWithMaxBuckets(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value["key"+strconv.Itoa(i)] = map[string]any{"i": i}
+	}
+	for _, test := range []struct {
+		opts    []hashive.WriteOption
+		buckets int
+	}{
+		{[]hashive.WriteOption{hashive.WithMaxBuckets(1)}, 1},
+		{[]hashive.WriteOption{hashive.WithMaxBuckets(10), hashive.WithSortedKeys()}, 10},
+		{[]hashive.WriteOption{hashive.WithMaxBuckets(3), hashive.WithHashOrder()}, 3},
+		{[]hashive.WriteOption{hashive.WithMaxBuckets(3), hashive.WithFrontCoding()}, 3},
+		{[]hashive.WriteOption{hashive.WithMaxBuckets(3), hashive.WithPow2Buckets()}, 2},
+		{[]hashive.WriteOption{hashive.WithMaxBuckets(1), hashive.WithPow2Buckets()}, 1},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, test.opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats, err := h.BucketStats(); err != nil || stats.Buckets != test.buckets || stats.Entries != 1000 {
+			t.Fatal(test.buckets, stats, err)
+		}
+		for _, i := range []int{0, 500, 999} {
+			if v, err := h.Query("key"+strconv.Itoa(i), "i"); err != nil || v != int64(i) {
+				t.Fatal(i, v, err)
+			}
+		}
+		if _, err := h.Query("key1000"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+		if stats, err := h.BucketStats("key0"); err != nil || stats.Buckets > test.buckets {
+			t.Fatal(stats, err)
+		}
+	}
+}
+
+func TestNewMemory(t *testing.T) {
+	h, err := hashive.NewMemory(map[string]any{
+		"a": []any{1, "x"},
+		"m": hashive.ValueWithMeta{Value: true, Meta: map[string]any{"m": 1}},
+	}, hashive.WithSortedKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("a", "1"); err != nil || v != "x" {
+		t.Fatal(v, err)
+	}
+	if v, meta, err := h.QueryWithMeta("m"); err != nil || v != true || meta["m"] != int64(1) {
+		t.Fatal(v, meta, err)
+	}
+	if _, err := h.Query("b"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err := hashive.NewMemory(func() {}); err == nil {
+		t.Fatal("func written")
+	}
+}
+
+func TestWithInMemory(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, map[string]any{"name": "mkch"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, threshold := range []int64{1024, 8} {
+		h, close, err := hashive.Open(filename, -1, hashive.WithInMemory(threshold))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := close(); err != nil {
+			t.Fatal(err)
+		}
+		// The file is closed on opening if it is read into memory.
+		v, err := h.Query("name")
+		if threshold == 8 {
+			if err == nil {
+				t.Fatal(v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		} else if v != "mkch" {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestWithMemoryBudget(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, map[string]any{"name": "mkch", "list": []any{1, "2", 3.0}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, budget := range []int64{1, 100, 1 << 20} {
+		h, close, err := hashive.Open(filename, -1, hashive.WithMemoryBudget(budget))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query("list", "1"); err != nil {
+			t.Fatal(err)
+		} else if v != "2" {
+			t.Fatal(v)
+		}
+		close()
+	}
+	// The file is larger than the budget, so it is not read into memory.
+	h, close, err := hashive.Open(filename, -1, hashive.WithInMemory(1024), hashive.WithMemoryBudget(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	close()
+	if v, err := h.Query("name"); err == nil {
+		t.Fatal(v)
+	}
+}
+
+func TestMemoryBudgetTotal(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value[fmt.Sprint("key", i)] = i
+	}
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, value); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, budget := range []int64{8, 64, 4096, info.Size(), info.Size() + 4096, 1 << 20} {
+		for _, inMemory := range []int64{0, 1 << 20} {
+			h, close, err := hashive.Open(filename, 1024,
+				hashive.WithInMemory(inMemory), hashive.WithRootDirectory(), hashive.WithResultCache(100, 0),
+				hashive.WithAdaptiveBuffer(16, 8192), hashive.WithMemoryBudget(budget))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bound := hashive.MemoryBound(h); bound > budget {
+				t.Fatalf("budget %v, in memory %v: %v bytes", budget, inMemory, bound)
+			}
+			if v, err := h.Query("key999"); err != nil || v != int64(999) {
+				t.Fatal(v, err)
+			}
+			close()
+		}
+	}
+}
+
+// readCounter counts the reads of the underlying reader.
+type readCounter struct {
+	io.ReadSeeker
+	reads int
+}
+
+func (r *readCounter) Read(p []byte) (int, error) {
+	r.reads++
+	return r.ReadSeeker.Read(p)
+}
+
+func TestWithAdaptiveBuffer(t *testing.T) {
+	// Elements of the same size are read in a row.
+	value := make([]any, 1000)
+	for i := range value {
+		value[i] = fmt.Sprint("value", 1000+i)
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"list": value}); err != nil {
+		t.Fatal(err)
+	}
+	var reads [2]int
+	for i, opts := range [][]hashive.Option{nil, {hashive.WithAdaptiveBuffer(16, 64*1024)}} {
+		r := &readCounter{ReadSeeker: bytes.NewReader(buf.Bytes())}
+		h, err := hashive.New(r, 64, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.reads = 0
+		if v, err := h.Query("list"); err != nil || !reflect.DeepEqual(v, value) {
+			t.Fatal(v, err)
+		}
+		reads[i] = r.reads
+		if v, err := h.Query("list", "500"); err != nil || v != "value1500" {
+			t.Fatal(v, err)
+		}
+	}
+	if reads[1] >= reads[0]/4 {
+		t.Fatal(reads)
+	}
+}
+
+func TestQueryWithBuffer(t *testing.T) {
+	value := make([]any, 1000)
+	for i := range value {
+		value[i] = fmt.Sprint("value", 1000+i)
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"list": value}); err != nil {
+		t.Fatal(err)
+	}
+	r := &readCounter{ReadSeeker: bytes.NewReader(buf.Bytes())}
+	h, err := hashive.New(r, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reads []int
+	for _, bufSize := range []int{64 * 1024, 0, -1, 16} {
+		r.reads = 0
+		if v, err := h.QueryWithBuffer(bufSize, "list"); err != nil || !reflect.DeepEqual(v, value) {
+			t.Fatal(v, err)
+		}
+		reads = append(reads, r.reads)
+	}
+	// The buffer is restored.
+	r.reads = 0
+	if v, err := h.Query("list"); err != nil || !reflect.DeepEqual(v, value) {
+		t.Fatal(v, err)
+	}
+	if reads[0] >= reads[2] || reads[2] >= reads[1] || reads[1] != reads[3] || r.reads != reads[1] {
+		t.Fatal(reads, r.reads)
+	}
+}
+
+func TestWithTransform(t *testing.T) {
+	value := map[string]any{
+		"users": []any{
+			map[string]any{"name": "a", "ssn": "123"},
+			map[string]any{"name": "b", "ssn": "456"},
+			"invalid",
+		},
+		"size": 1.5,
+	}
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, value, hashive.WithTransform(func(path []string, v any) (any, bool) {
+		if len(path) > 0 && path[len(path)-1] == "ssn" {
+			return nil, false
+		}
+		if _, ok := v.(string); ok && len(path) == 2 {
+			return nil, false
+		}
+		if f, ok := v.(float64); ok {
+			return int(f * 10), true
+		}
+		return v, true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"users": []any{map[string]any{"name": "a"}, map[string]any{"name": "b"}},
+		"size":  int64(15),
+	}
+	if v, err := h.Query(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, want) {
+		t.Fatal(v)
+	}
+	// The input is not modified.
+	if _, ok := value["users"].([]any)[0].(map[string]any)["ssn"]; !ok {
+		t.Fatal(value)
+	}
+}
+
+func TestWithReadTransform(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"user":   map[string]any{"name": "a", "secret": "olleh"},
+		"prices": map[string]any{"10": 100, "20": 200},
+		"bad":    -1,
+	}, hashive.WithIntKeys()); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	errBad := errors.New("bad")
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithReadTransform(func(path []string, v any) (any, error) {
+		paths = append(paths, fmt.Sprint(path))
+		if len(path) > 0 && path[len(path)-1] == "secret" {
+			s := []rune(v.(string))
+			slices.Reverse(s)
+			return string(s), nil
+		}
+		if n, ok := v.(int64); ok {
+			if n < 0 {
+				return nil, errBad
+			}
+			return float64(n) / 100, nil
+		}
+		return v, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("user"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, map[string]any{"name": "a", "secret": "hello"}) {
+		t.Fatal(v)
+	}
+	slices.Sort(paths)
+	if !reflect.DeepEqual(paths, []string{"[user name]", "[user secret]", "[user]"}) {
+		t.Fatal(paths)
+	}
+	if key, v, err := h.QueryFloorInt(15, "prices"); err != nil {
+		t.Fatal(err)
+	} else if key != 10 || v != 1.0 {
+		t.Fatal(key, v)
+	}
+	if _, err := h.Query("bad"); err != errBad {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	value := map[string]any{
+		"ok": 1,
+		"a":  []any{1, map[string]any{"b": func() {}}},
+	}
+	for _, test := range []struct {
+		name  string
+		value any
+		opts  []hashive.WriteOption
+		path  []string
+	}{
+		{"default", value, nil, []string{"a", "1", "b"}},
+		{"dedup", value, []hashive.WriteOption{hashive.WithDedup()}, []string{"a", "1", "b"}},
+		{"fixedKeys", map[string]any{"k": []any{make(chan int)}}, []hashive.WriteOption{hashive.WithFixedKeys()}, []string{"k", "0"}},
+		{"intKeys", map[string]any{"-1": make(chan int)}, []hashive.WriteOption{hashive.WithIntKeys()}, []string{"-1"}},
+		{"intervals", []any{[]hashive.Interval{{Start: 0, End: 1, Value: func() {}}}}, nil, []string{"0", "0"}},
+		{"invalidInterval", map[string]any{"i": []hashive.Interval{{Start: 1, End: 0}}}, nil, []string{"i"}},
+		{"root", func() {}, nil, nil},
+	} {
+		for _, writeAt := range []bool{false, true} {
+			var err error
+			if writeAt {
+				var f *os.File
+				if f, err = os.Create(filepath.Join(t.TempDir(), "test.hashive")); err != nil {
+					t.Fatal(err)
+				}
+				_, err = hashive.WriteAt(f, 0, test.value, test.opts...)
+				f.Close()
+			} else {
+				err = hashive.Write(&bytes.Buffer{}, test.value, test.opts...)
+			}
+			var encodeErr *hashive.EncodeError
+			if !errors.As(err, &encodeErr) || !slices.Equal(encodeErr.Path, test.path) {
+				t.Fatal(test.name, writeAt, err)
+			}
+		}
+	}
+}
+
+func TestQueryReader(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	var buf, blobs bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"binary": large,
+		"string": "text",
+		"int":    1,
+		"blob":   []any{large[:3000]},
+	}, hashive.WithBlobWriter(&blobs, 2000)); err != nil {
+		t.Fatal(err)
+	}
+	// Small buffer to read through it.
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), 16, hashive.WithBlobReader(bytes.NewReader(blobs.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, size, err := h.QueryReader("binary")
+	if err != nil || size != int64(len(large)) {
+		t.Fatal(size, err)
+	}
+	// Interleaved with other queries.
+	p := make([]byte, 100)
+	if _, err := io.ReadFull(r, p); err != nil || !bytes.Equal(p, large[:100]) {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("string"); err != nil || v != "text" {
+		t.Fatal(v, err)
+	}
+	if rest, err := io.ReadAll(r); err != nil || !bytes.Equal(rest, large[100:]) {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path []string
+		want []byte
+	}{
+		{[]string{"string"}, []byte("text")},
+		{[]string{"blob", "0"}, large[:3000]},
+	} {
+		r, size, err := h.QueryReader(test.path...)
+		if err != nil || size != int64(len(test.want)) {
+			t.Fatal(test.path, size, err)
+		}
+		if p, err := io.ReadAll(r); err != nil || !bytes.Equal(p, test.want) {
+			t.Fatal(test.path, err)
+		}
+	}
+	if _, _, err := h.QueryReader("int"); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, _, err := h.QueryReader("missing"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryReaderAt(t *testing.T) {
+	large := make([]byte, 10000)
+	for i := range large {
+		large[i] = byte(i)
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"chunked": large,
+		"small":   large[:100],
+	}, hashive.WithChunkedBinary(1000, 256)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("chunked"); err != nil || !bytes.Equal(v.([]byte), large) {
+		t.Fatal(err)
+	}
+	if v, err := h.QueryBinaryAppend(nil, "chunked"); err != nil || !bytes.Equal(v, large) {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path []string
+		want []byte
+	}{
+		{[]string{"chunked"}, large},
+		{[]string{"small"}, large[:100]},
+	} {
+		r, size, err := h.QueryReaderAt(test.path...)
+		if err != nil || size != int64(len(test.want)) {
+			t.Fatal(test.path, size, err)
+		}
+		p := make([]byte, 50)
+		if _, err := r.ReadAt(p, 30); err != nil || !bytes.Equal(p, test.want[30:80]) {
+			t.Fatal(test.path, err)
+		}
+		if v, err := h.Query("small"); err != nil || !bytes.Equal(v.([]byte), large[:100]) {
+			t.Fatal(err)
+		}
+		if _, err := r.ReadAt(p, size-50); err != nil || !bytes.Equal(p, test.want[size-50:]) {
+			t.Fatal(test.path, err)
+		}
+		reader, _, err := h.QueryReader(test.path...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if all, err := io.ReadAll(reader); err != nil || !bytes.Equal(all, test.want) {
+			t.Fatal(test.path, err)
+		}
+	}
+	if _, _, err := h.QueryReaderAt(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestWithStringBytes(t *testing.T) {
+	var buf bytes.Buffer
+	value := map[string]any{"s": "abc", "list": []any{"x", 1, []byte("y")}, "obj": map[string]any{"k": "v"}}
+	if err := hashive.Write(&buf, value, hashive.WithDedup()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	want := map[string]any{"s": []byte("abc"), "list": []any{[]byte("x"), int64(1), []byte("y")}, "obj": map[string]any{"k": []byte("v")}}
+
+	h, err := hashive.New(bytes.NewReader(data), -1, hashive.WithStringBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatal(v, err)
+	}
+	if v, err := h.Query("list", "0"); err != nil || !reflect.DeepEqual(v, []byte("x")) {
+		t.Fatal(v, err)
+	}
+	if _, err := h.Query("s", "x"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	// Other queries are not affected.
+	var s string
+	if err := h.QueryInto(&s, "s"); err != nil || s != "abc" {
+		t.Fatal(s, err)
+	}
+
+	view := bytes.Clone(data)
+	hb, err := hashive.NewBytes(view, hashive.WithStringBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := hb.Query("obj", "k")
+	if err != nil || !reflect.DeepEqual(v, []byte("v")) {
+		t.Fatal(v, err)
+	}
+	// A view of the underlying memory.
+	clear(view)
+	if p := v.([]byte); p[0] != 0 {
+		t.Fatal(p)
+	}
+
+	// The depth limit counts the containers of the path.
+	hd, err := hashive.New(bytes.NewReader(data), -1, hashive.WithStringBytes(), hashive.WithMaxDepth(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := hd.Query("s"); err != nil || !reflect.DeepEqual(v, []byte("abc")) {
+		t.Fatal(v, err)
+	}
+	var depthErr *hashive.DepthError
+	if _, err := hd.Query("obj"); !errors.As(err, &depthErr) {
+		t.Fatal(err)
+	}
+}
+
+func TestWithRootDirectory(t *testing.T) {
+	value := map[string]any{"nested": map[string]any{"a": 1}}
+	for i := range 1000 {
+		value[fmt.Sprint("key", i)] = i
+	}
+	for _, writeOpts := range [][]hashive.WriteOption{nil, {hashive.WithHashOrder()}, {hashive.WithFrontCoding()}, {hashive.WithDedup()}} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, writeOpts...); err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range [][]hashive.Option{{hashive.WithRootDirectory()}, {hashive.WithRootDirectory(), hashive.WithMemoryBudget(100)}} {
+			h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range 1000 {
+				if v, err := h.Query(fmt.Sprint("key", i)); err != nil || v != int64(i) {
+					t.Fatal(i, v, err)
+				}
+			}
+			if v, err := h.Query("nested", "a"); err != nil || v != int64(1) {
+				t.Fatal(v, err)
+			}
+			if _, err := h.Query("missing"); err != hashive.ErrNotFound {
+				t.Fatal(err)
+			}
+		}
+	}
+}
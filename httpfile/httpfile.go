@@ -0,0 +1,147 @@
+/*
+Package httpfile reads files over HTTP with range requests, so that a
+Hashive database hosted by a static web server can be queried without being
+downloaded entirely, such as by a web app built with GOOS=js GOARCH=wasm,
+where requests are sent with the Fetch API:
+
+	f, err := httpfile.Open("db.hashive", nil)
+	if err != nil {
+		return err
+	}
+	h, err := hashive.NewSection(f, 0, f.Size(), 64<<10)
+
+Every read of the database not in its read buffer is a request, so a large
+buffer, or [hashive.WithRootDirectory], saves round trips. The web server
+must support range requests, and must not compress the file.
+*/
+package httpfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// File is a file read over HTTP with range requests. It implements
+// [io.ReaderAt], and is safe for concurrent use.
+type File struct {
+	url    string
+	client *http.Client
+	size   int64
+	// The validator of the content, sent in If-Range, so that the file
+	// replaced on the server is not read partially.
+	validator string
+}
+
+// ErrChanged is returned by [File.ReadAt] if the file is changed on the
+// server after it is opened.
+var ErrChanged = errors.New("file changed on server")
+
+// Open opens the file at url, whose size is read by a HEAD request.
+// Requests are sent by client, or [http.DefaultClient] if it is nil.
+func Open(url string, client *http.Client) (f *File, err error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Head(url)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open %v: %v", url, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("open %v: unknown size", url)
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return nil, fmt.Errorf("open %v: compressed by %v", url, encoding)
+	}
+	f = &File{url: url, client: client, size: resp.ContentLength}
+	// Weak ETags can't be used in If-Range.
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		f.validator = etag
+	} else {
+		f.validator = resp.Header.Get("Last-Modified")
+	}
+	return
+}
+
+// Size returns the size of f.
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// ReadAt implements [io.ReaderAt]. Each call sends a range request.
+// [ErrChanged] will be returned if the file is changed on the server, which
+// is detected only if the server sends ETag or Last-Modified.
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("read %v: negative offset %v", f.url, off)
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	want := len(p)
+	if int64(want) > f.size-off {
+		want = int(f.size - off)
+	}
+	if want == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, f.url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-"+strconv.FormatInt(off+int64(want)-1, 10))
+	if f.validator != "" {
+		req.Header.Set("If-Range", f.validator)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if f.validator != "" {
+			return 0, fmt.Errorf("read %v: %w", f.url, ErrChanged)
+		}
+		return 0, fmt.Errorf("read %v: range requests not supported", f.url)
+	default:
+		return 0, fmt.Errorf("read %v: %v", f.url, resp.Status)
+	}
+	if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); ok && start != off {
+		return 0, fmt.Errorf("read %v: invalid content range %q", f.url, resp.Header.Get("Content-Range"))
+	}
+	if n, err = io.ReadFull(resp.Body, p[:want]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	if want < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+// contentRangeStart returns the start of the Content-Range header value s,
+// if it is readable. The header is not exposed to browsers for cross-origin
+// requests unless the server allows it.
+func contentRangeStart(s string) (start int64, ok bool) {
+	s, ok = strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return
+	}
+	s, _, ok = strings.Cut(s, "-")
+	if !ok {
+		return
+	}
+	start, err := strconv.ParseInt(s, 10, 64)
+	return start, err == nil
+}
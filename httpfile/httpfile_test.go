@@ -0,0 +1,77 @@
+package httpfile_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/httpfile"
+)
+
+func TestFile(t *testing.T) {
+	var buf bytes.Buffer
+	value := map[string]any{"a": "b", "c": []any{1, 2, 3}, "d": bytes.Repeat([]byte{1}, 10000)}
+	if err := hashive.Write(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+	var content atomic.Value
+	content.Store(buf.Bytes())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := content.Load().([]byte)
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(len(data))))
+		http.ServeContent(w, r, "db", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	f, err := httpfile.Open(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size() != int64(buf.Len()) {
+		t.Fatal(f.Size())
+	}
+	h, err := hashive.NewSection(f, 0, f.Size(), 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("c", "2"); err != nil || v != int64(3) {
+		t.Fatal(v, err)
+	}
+	if v, err := h.Query("d"); err != nil || len(v.([]byte)) != 10000 {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 10)
+	if n, err := f.ReadAt(p, f.Size()-4); n != 4 || err != io.EOF || !bytes.Equal(p[:4], buf.Bytes()[buf.Len()-4:]) {
+		t.Fatal(n, err)
+	}
+	if _, err := f.ReadAt(p, f.Size()); err != io.EOF {
+		t.Fatal(err)
+	}
+
+	content.Store(append(buf.Bytes(), 0))
+	if _, err := f.ReadAt(p, 0); !errors.Is(err, httpfile.ErrChanged) {
+		t.Fatal(err)
+	}
+}
+
+func TestFileNoRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+	f, err := httpfile.Open(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ReadAt(make([]byte, 2), 1); err == nil {
+		t.Fatal("no error")
+	}
+}
@@ -0,0 +1,164 @@
+package hashive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// indexFileSuffix is appended to the name of a database file to name its
+// index file, see [WithIndexFile].
+const indexFileSuffix = ".idx"
+
+// indexFileSignature is the signature of index files.
+const indexFileSignature = "hashidx\x02"
+
+// indexFileHeaderSize is the size of the header of index files: the
+// signature, the size, modification time and fingerprint of the database,
+// and the size of the starts of the directory.
+const indexFileHeaderSize = len(indexFileSignature) + 8 + 8 + 8 + 8
+
+// WithIndexFile makes [WriteFile] also write the directory of the root
+// object, see [WithRootDirectory], to an index file named by appending
+// ".idx" to the name of the database file. [Open], [OpenMmap] and
+// [OpenShared] map the index file read-only if it exists, and use it as the
+// directory of the root object instead of loading one, so that many short
+// lived processes querying the same database don't build the directory
+// again and share the memory of it. The index file is only a cache: it is
+// ignored if it is invalid, or the size, modification time or fingerprint
+// of the database differs from the ones it records. The fingerprint is a
+// hash of the database stored in its header when it is written, so that
+// checking it reads no more than the header.
+// Negative lookups are answered by the index file alone, because it
+// records the hashes of all the keys.
+//
+// No index file is written if the directory can't be loaded, such as when
+// the root is not an object. It is ignored by the writers other than
+// [WriteFile], which removes the index file of the file it overwrites
+// with or without this option.
+func WithIndexFile() WriteOption {
+	return func(o *writeOptions) {
+		o.indexFile = true
+	}
+}
+
+// rootIndex is the directory of the root object read from an index file,
+// see [WithIndexFile].
+type rootIndex struct {
+	fingerprint     uint64 // the fingerprint in the header of the database
+	starts, entries []byte
+}
+
+// contentFingerprint returns the fingerprint of the database stored in its
+// header for index files: the 64-bit FNV-1a hash of the header without the
+// fingerprint, the root value and the side tables, so that index files are
+// checked without reading the database beyond the header.
+func contentFingerprint(encoder *impl.Encoder, header map[string]any, payload, tableData *bytes.Buffer) (uint64, error) {
+	h := fnv.New64a()
+	if err := encoder.WriteObject(h, header); err != nil {
+		return 0, err
+	}
+	h.Write(payload.Bytes())
+	h.Write(tableData.Bytes())
+	return h.Sum64(), nil
+}
+
+// use sets the directory of the root object obj of the database whose
+// header stores fingerprint to index, and reports whether index matches
+// the database.
+func (index *rootIndex) use(fingerprint uint64, obj *impl.Object) bool {
+	return fingerprint != 0 && fingerprint == index.fingerprint &&
+		obj.SetDirectory(index.starts, index.entries) == nil
+}
+
+// removeIndexFile removes the index file of the database file filename
+// if it exists.
+func removeIndexFile(filename string) error {
+	if err := os.Remove(filename + indexFileSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// writeIndexFile writes the index file of the database data written to
+// filename, whose file info is info. The index file is written to a
+// temporary file first, and renamed to replace the old one, so that it is
+// never read partially written.
+func writeIndexFile(filename string, data []byte, info fs.FileInfo) (err error) {
+	h, err := NewBytes(data)
+	if err != nil || h.obj == nil || h.fingerprint == 0 {
+		return
+	}
+	if loaded, err := h.obj.LoadDirectory(0); err != nil || !loaded {
+		return err
+	}
+	starts, entries, _ := h.obj.Directory()
+	index := make([]byte, 0, indexFileHeaderSize+len(starts)+len(entries))
+	index = append(index, indexFileSignature...)
+	index = binary.LittleEndian.AppendUint64(index, uint64(info.Size()))
+	index = binary.LittleEndian.AppendUint64(index, uint64(info.ModTime().UnixNano()))
+	index = binary.LittleEndian.AppendUint64(index, h.fingerprint)
+	index = binary.LittleEndian.AppendUint64(index, uint64(len(starts)))
+	index = append(index, starts...)
+	index = append(index, entries...)
+
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+indexFileSuffix+".*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(index); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), filename+indexFileSuffix)
+}
+
+// openIndexFile maps the index file of the database file filename, whose
+// file info is info, into memory. If the index file can't be mapped, or is
+// invalid or stale, index is nil, because it is only a cache. unmap unmaps
+// the index file.
+func openIndexFile(filename string, info fs.FileInfo) (index *rootIndex, unmap func() error) {
+	noop := func() error { return nil }
+	data, _, unmapData, err := mapFile(filename + indexFileSuffix)
+	if err != nil {
+		return nil, noop
+	}
+	if len(data) < indexFileHeaderSize || string(data[:len(indexFileSignature)]) != indexFileSignature {
+		unmapData()
+		return nil, noop
+	}
+	header := data[len(indexFileSignature):]
+	size, modTime := binary.LittleEndian.Uint64(header), binary.LittleEndian.Uint64(header[8:])
+	data = data[indexFileHeaderSize:]
+	n := binary.LittleEndian.Uint64(header[24:])
+	if size != uint64(info.Size()) || modTime != uint64(info.ModTime().UnixNano()) || n > uint64(len(data)) {
+		unmapData()
+		return nil, noop
+	}
+	return &rootIndex{binary.LittleEndian.Uint64(header[16:]), data[:n:n], data[n:]}, unmapData
+}
+
+// withIndexFile returns opts with the index file of the database file
+// filename, whose file info is info, if there is one. See [WithIndexFile].
+// unmap unmaps the index file.
+func withIndexFile(filename string, info fs.FileInfo, opts []Option) (_ []Option, unmap func() error) {
+	index, unmap := openIndexFile(filename, info)
+	if index == nil {
+		return opts, unmap
+	}
+	return append(opts[:len(opts):len(opts)], func(o *options) { o.index = index }), unmap
+}
@@ -0,0 +1,153 @@
+package hashive_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestIndexFile(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value[fmt.Sprint(i)] = i
+	}
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, value, hashive.WithIndexFile()); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.ReadFile(filename + ".idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opens := map[string]func(string) (*hashive.Hashive, func() error, error){
+		"Open": func(name string) (*hashive.Hashive, func() error, error) { return hashive.Open(name, -1) },
+		"InMemory": func(name string) (*hashive.Hashive, func() error, error) {
+			return hashive.Open(name, -1, hashive.WithInMemory(1<<20))
+		},
+		"OpenMmap":   func(name string) (*hashive.Hashive, func() error, error) { return hashive.OpenMmap(name) },
+		"OpenShared": func(name string) (*hashive.Hashive, func() error, error) { return hashive.OpenShared(name) },
+	}
+	query := func(open func(string) (*hashive.Hashive, func() error, error), key string) (any, error) {
+		h, closeFile, err := open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer closeFile()
+		return h.Query(key)
+	}
+	for name, open := range opens {
+		for _, i := range []int{0, 500, 999} {
+			if v, err := query(open, fmt.Sprint(i)); err != nil || v != int64(i) {
+				t.Fatal(name, i, v, err)
+			}
+		}
+		if _, err := query(open, "1000"); err != hashive.ErrNotFound {
+			t.Fatal(name, err)
+		}
+	}
+
+	// The index file is used: the keys whose hashes are cleared are not found.
+	cleared := append([]byte(nil), index[:len(index)-1000*16]...)
+	cleared = append(cleared, make([]byte, 1000*16)...)
+	if err := os.WriteFile(filename+".idx", cleared, 0666); err != nil {
+		t.Fatal(err)
+	}
+	for name, open := range opens {
+		if _, err := query(open, "500"); err != hashive.ErrNotFound {
+			t.Fatal(name, err)
+		}
+	}
+
+	// Invalid index files are ignored.
+	for _, invalid := range [][]byte{[]byte("invalid"), index[:len(index)-1], index[:40]} {
+		if err := os.WriteFile(filename+".idx", invalid, 0666); err != nil {
+			t.Fatal(err)
+		}
+		for name, open := range opens {
+			if v, err := query(open, "500"); err != nil || v != int64(500) {
+				t.Fatal(name, len(invalid), v, err)
+			}
+		}
+	}
+
+	// Stale index files are ignored, even if the database has the same
+	// size and modification time.
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Values of other sizes move the entries, but not the end.
+	value["500"], value["999"] = 5, 1<<24
+	if err := hashive.WriteFile(filename, value, hashive.WithIndexFile()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename+".idx", index, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if newInfo, err := os.Stat(filename); err != nil || newInfo.Size() != info.Size() {
+		t.Fatal(newInfo.Size(), info.Size(), err)
+	}
+	for name, open := range opens {
+		for _, key := range []string{"500", "999"} {
+			if v, err := query(open, key); err != nil || v != int64(value[key].(int)) {
+				t.Fatal(name, key, v, err)
+			}
+		}
+	}
+
+	// WriteFile removes the index file.
+	value["1000"] = 1000
+	if err := hashive.WriteFile(filename, value); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename + ".idx"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	for name, open := range opens {
+		if v, err := query(open, "1000"); err != nil || v != int64(1000) {
+			t.Fatal(name, v, err)
+		}
+	}
+
+	// CompactFile and MigrateFile remove the index file.
+	src := filepath.Join(t.TempDir(), "src.hashive")
+	if err := hashive.WriteFile(src, value); err != nil {
+		t.Fatal(err)
+	}
+	for name, write := range map[string]func() error{
+		"CompactFile":  func() error { return hashive.CompactFile(src, filename) },
+		"MigrateFile1": func() error { return hashive.MigrateFile(src, filename, hashive.Version1) },
+		"MigrateFile0": func() error { return hashive.MigrateFile(src, filename, hashive.Version0) },
+	} {
+		if err := hashive.WriteFile(filename, map[string]any{"a": 1}, hashive.WithIndexFile()); err != nil {
+			t.Fatal(name, err)
+		}
+		if err := write(); err != nil {
+			t.Fatal(name, err)
+		}
+		if _, err := os.Stat(filename + ".idx"); !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(name, err)
+		}
+		for openName, open := range opens {
+			if v, err := query(open, "1000"); err != nil || v != int64(1000) {
+				t.Fatal(name, openName, v, err)
+			}
+		}
+	}
+
+	// Not an object.
+	if err := hashive.WriteFile(filename, []any{1}, hashive.WithIndexFile()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename + ".idx"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package impl
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// ACLEntry requires Capability to access the value mapped by Path and all
+// the values in it.
+type ACLEntry struct {
+	Path       []string
+	Capability string
+}
+
+// Keys of ACL entries stored in the header.
+const (
+	ACLPath       = "path"
+	ACLCapability = "capability"
+)
+
+// ACLFromValue converts the ACL stored in the header back.
+func ACLFromValue(v any) (acl []ACLEntry, err error) {
+	if v == nil {
+		return nil, nil
+	}
+	entries, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid ACL %v", v)
+	}
+	for _, e := range entries {
+		m, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid ACL entry %v", e)
+		}
+		var entry ACLEntry
+		path, ok := m[ACLPath].([]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid ACL path %v", m[ACLPath])
+		}
+		for _, key := range path {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid ACL path %v", path)
+			}
+			entry.Path = append(entry.Path, s)
+		}
+		if entry.Capability, ok = m[ACLCapability].(string); !ok {
+			return nil, fmt.Errorf("invalid ACL capability %v", m[ACLCapability])
+		}
+		acl = append(acl, entry)
+	}
+	return
+}
+
+// Denied returns the paths of the entries of acl whose capabilities are not
+// provided.
+func Denied(acl []ACLEntry, capabilities []string) (paths [][]string) {
+	for _, entry := range acl {
+		if !slices.Contains(capabilities, entry.Capability) {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return
+}
+
+// IsDenied returns whether the value mapped by path is in any of the
+// denied paths.
+func IsDenied(denied [][]string, path []string) bool {
+	for _, d := range denied {
+		if len(d) <= len(path) && slices.Equal(d, path[:len(d)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// HideDenied removes the values of the denied paths from v mapped by path,
+// which is read recursively, or replaces them with nil in arrays to keep
+// the indices.
+func HideDenied(denied [][]string, path []string, v any) any {
+	for _, d := range denied {
+		if len(d) > len(path) && slices.Equal(d[:len(path)], path) {
+			v = hideValue(v, d[len(path):])
+		}
+	}
+	return v
+}
+
+// hideValue removes the value mapped by path from v.
+func hideValue(v any, path []string) any {
+	switch value := v.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			delete(value, path[0])
+		} else if elem, ok := value[path[0]]; ok {
+			value[path[0]] = hideValue(elem, path[1:])
+		}
+	case []any:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(value) {
+			break
+		}
+		if len(path) == 1 {
+			value[i] = nil
+		} else {
+			value[i] = hideValue(value[i], path[1:])
+		}
+	}
+	return v
+}
@@ -0,0 +1,34 @@
+package impl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHideDenied(t *testing.T) {
+	acl, err := ACLFromValue([]any{
+		map[string]any{ACLPath: []any{"a", "1"}, ACLCapability: "admin"},
+		map[string]any{ACLPath: []any{"b"}, ACLCapability: "user"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied := Denied(acl, []string{"user"})
+	if !reflect.DeepEqual(denied, [][]string{{"a", "1"}}) {
+		t.Fatal(denied)
+	}
+	if !IsDenied(denied, []string{"a", "1", "x"}) || IsDenied(denied, []string{"a"}) {
+		t.Fatal("wrong denied paths")
+	}
+	v := map[string]any{"a": []any{1, 2, 3}, "b": true}
+	want := map[string]any{"a": []any{1, nil, 3}, "b": true}
+	if v := HideDenied(denied, nil, v); !reflect.DeepEqual(v, want) {
+		t.Fatal(v)
+	}
+	for _, invalid := range []any{"acl", []any{1}, []any{map[string]any{ACLPath: []any{1}, ACLCapability: "x"}}} {
+		if _, err := ACLFromValue(invalid); err == nil || !strings.Contains(err.Error(), "invalid ACL") {
+			t.Fatal(invalid, err)
+		}
+	}
+}
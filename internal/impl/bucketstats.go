@@ -0,0 +1,177 @@
+package impl
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// BucketStats is the statistics of the hash table of an [Object].
+// Every entry of a [typeFixedKeyObject] or [typeIntKeyObject] is a bucket
+// of itself.
+type BucketStats struct {
+	Buckets      int     // The number of buckets.
+	EmptyBuckets int     // The number of buckets without entries.
+	Entries      int     // The number of entries.
+	LoadFactor   float64 // The number of entries per bucket.
+	MaxChain     int     // The number of entries of the longest chain.
+	AvgChain     float64 // The average number of entries of non-empty chains.
+	TableSize    int64   // The size of the offset table, including fixed size keys, in bytes.
+	ChainSize    int64   // The total size of the chains, including the values, in bytes.
+	MaxChainSize int64   // The size of the largest chain in bytes.
+}
+
+// BucketStats scans the offset table and the heads of chains of obj, and
+// returns the statistics of the buckets. Values are skipped by their sizes.
+func (obj *Object) BucketStats() (stats BucketStats, err error) {
+	if obj.bucketCount > math.MaxInt32 {
+		err = fmt.Errorf("invalid bucket count %v", obj.bucketCount)
+		return
+	}
+	if obj.keySize > 0 {
+		err = obj.fixedKeyBucketStats(&stats)
+	} else {
+		err = obj.hashBucketStats(&stats)
+	}
+	if err != nil {
+		return
+	}
+	if stats.Buckets > 0 {
+		stats.LoadFactor = float64(stats.Entries) / float64(stats.Buckets)
+	}
+	if used := stats.Buckets - stats.EmptyBuckets; used > 0 {
+		stats.AvgChain = float64(stats.Entries) / float64(used)
+	}
+	return
+}
+
+// fixedKeyBucketStats is [Object.BucketStats] of a [typeFixedKeyObject] or
+// [typeIntKeyObject].
+func (obj *Object) fixedKeyBucketStats(stats *BucketStats) (err error) {
+	n := obj.bucketCount
+	stats.Buckets, stats.Entries = int(n), int(n)
+	stats.MaxChain = min(int(n), 1)
+	stats.TableSize = int64(n)*int64(obj.keySize) + int64(n+1)*int64(obj.offsetSize)
+	var prev int64
+	for i := range n + 1 {
+		var pos int64
+		if pos, err = obj.fixedOffset(i); err != nil {
+			return
+		}
+		if i > 0 {
+			if pos < prev {
+				return fmt.Errorf("invalid offset of value %v", i)
+			}
+			stats.ChainSize += pos - prev
+			stats.MaxChainSize = max(stats.MaxChainSize, pos-prev)
+		}
+		prev = pos
+	}
+	return
+}
+
+// hashBucketStats is [Object.BucketStats] of a hash table.
+func (obj *Object) hashBucketStats(stats *BucketStats) (err error) {
+	stats.Buckets = int(obj.bucketCount)
+	stats.TableSize = int64(obj.bucketCount) * int64(obj.offsetSize)
+	// The chains are stored in the order of buckets, so the size of a chain
+	// is the distance to the next one.
+	var last int64 = -1 // the position of the last chain
+	addChain := func(end int64) error {
+		if last < 0 {
+			return nil
+		}
+		if end < last {
+			return fmt.Errorf("invalid chain at %v", end)
+		}
+		stats.ChainSize += end - last
+		stats.MaxChainSize = max(stats.MaxChainSize, end-last)
+		return nil
+	}
+	var listLen uint64
+	for i := range obj.bucketCount {
+		if _, err = obj.r.Seek(obj.pos+int64(i)*int64(obj.offsetSize), io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(obj.r, obj.offsetSize); err != nil {
+			return
+		}
+		if offset > math.MaxInt64-uint64(obj.pos) {
+			return fmt.Errorf("invalid offset %v", offset)
+		}
+		if offset == 0 {
+			stats.EmptyBuckets++
+			continue // Not exists
+		}
+		pos := obj.pos + int64(offset)
+		if err = addChain(pos); err != nil {
+			return
+		}
+		if _, err = obj.r.Seek(pos, io.SeekStart); err != nil {
+			return
+		}
+		if listLen, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if listLen == 0 || listLen > math.MaxInt32-uint64(stats.Entries) {
+			return fmt.Errorf("invalid chain length %v", listLen)
+		}
+		stats.Entries += int(listLen)
+		stats.MaxChain = max(stats.MaxChain, int(listLen))
+		last = pos
+	}
+	if last < 0 {
+		return
+	}
+	// The last chain ends the object.
+	if _, err = obj.r.Seek(last, io.SeekStart); err != nil {
+		return
+	}
+	if _, err = readUintValue(obj.r); err != nil {
+		return
+	}
+	obj.keyBuf = obj.keyBuf[:0]
+	for range listLen {
+		if _, err = obj.readKey(); err != nil {
+			return
+		}
+		var valueSize uint64
+		if valueSize, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if valueSize > math.MaxInt64 {
+			return fmt.Errorf("invalid value size %v", valueSize)
+		}
+		if _, err = obj.r.Seek(int64(valueSize), io.SeekCurrent); err != nil {
+			return
+		}
+	}
+	end, err := obj.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	return addChain(end)
+}
+
+// OffsetTable returns the size of the offsets of obj and the number of them.
+func (obj *Object) OffsetTable() (offsetSize byte, count int64) {
+	if obj.keySize > 0 {
+		return obj.offsetSize, int64(obj.bucketCount) + 1
+	}
+	return obj.offsetSize, int64(obj.bucketCount)
+}
+
+// OffsetTable returns the size of the offsets of array and the number of
+// them, which are the offsets of chunks of a [typeChunkedArray]. A
+// [typeFixedArray] has no offsets.
+func (array *Array) OffsetTable() (offsetSize byte, count int64) {
+	switch {
+	case array.stride != 0:
+		return 0, 0
+	case array.chunkSize != 0:
+		return array.offsetSize, (array.data - array.pos) / int64(max(array.offsetSize, 1))
+	default:
+		return array.offsetSize, int64(array.length)
+	}
+}
@@ -0,0 +1,57 @@
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBucketStats(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 1000 {
+		obj[fmt.Sprintf("%04d", i)] = i
+	}
+	for _, encoder := range []*Encoder{{}, {SortedKeys: true}, {HashOrder: true}, {FrontCoding: true}, {FixedKeys: true}, {Pow2Buckets: true}} {
+		var buf bytes.Buffer
+		if err := encoder.WriteObject(&buf, obj); err != nil {
+			t.Fatal(err)
+		}
+		o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := o.BucketStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Entries != len(obj) || stats.Buckets != int(o.bucketCount) || stats.Buckets < stats.Entries && o.keySize == 0 {
+			t.Fatal(stats)
+		}
+		if stats.MaxChain < 1 || stats.AvgChain < 1 || stats.AvgChain > float64(stats.MaxChain) {
+			t.Fatal(stats)
+		}
+		if stats.LoadFactor != float64(stats.Entries)/float64(stats.Buckets) {
+			t.Fatal(stats)
+		}
+		if stats.EmptyBuckets+stats.Entries < stats.Buckets || stats.MaxChainSize > stats.ChainSize {
+			t.Fatal(stats)
+		}
+		// The table and the chains end the object.
+		if end := o.pos + stats.TableSize + stats.ChainSize; end != int64(buf.Len()) {
+			t.Fatal(end, buf.Len(), stats)
+		}
+	}
+
+	// Empty.
+	var buf bytes.Buffer
+	if err := (&Encoder{}).WriteObject(&buf, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := o.BucketStats(); err != nil || stats.Entries != 0 || stats.EmptyBuckets != stats.Buckets || stats.ChainSize != 0 {
+		t.Fatal(stats, err)
+	}
+}
@@ -0,0 +1,155 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A [typeChunked] is a []byte split into chunks of the same size except the
+// last one, so that any range of it can be read without reading the bytes
+// before the range. See [Encoder.ChunkSize].
+//
+// The layout is: type mark, length, chunk size, the offsets of chunks
+// relative to the end of the offset table, and then the chunks in order.
+// Length and chunk size are variable-length encoded, and offsets are stored
+// with the size in the type mark.
+
+// writeChunked writes p to w as a [typeChunked] of chunkSize.
+func writeChunked(w ByteWriter, p []byte, chunkSize int) (err error) {
+	offsetSize := fixedUintSize(uint64(len(p)))
+	if err = writeTypeMarker(w, typeChunked, offsetSize); err != nil {
+		return
+	}
+	if err = writeUintValue(w, uint64(len(p))); err != nil {
+		return
+	}
+	if err = writeUintValue(w, uint64(chunkSize)); err != nil {
+		return
+	}
+	for offset := 0; offset < len(p); offset += chunkSize {
+		if err = writeFixedUint(w, uint64(offset), offsetSize); err != nil {
+			return
+		}
+	}
+	return write(w, p)
+}
+
+// Chunked is a descriptor of a [typeChunked] read from a stream.
+// It implements [io.ReaderAt].
+type Chunked struct {
+	r          ByteReadSeeker
+	length     int64
+	chunkSize  int64
+	offsetSize byte
+	table      int64 // the position of the offset table
+	data       int64 // the position of the end of the offset table
+}
+
+// readChunkedValue reads the descriptor of a [typeChunked] from r after the
+// type mark. On success, r is positioned at the first chunk.
+func readChunkedValue(r ByteReadSeeker, offsetSize byte) (c *Chunked, err error) {
+	length, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	chunkSize, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt64 || chunkSize > math.MaxInt64 || chunkSize == 0 && length > 0 {
+		return nil, fmt.Errorf("invalid chunked binary of length %v and chunk size %v", length, chunkSize)
+	}
+	table, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	var count uint64
+	if length > 0 {
+		count = (length-1)/chunkSize + 1
+	}
+	if count > uint64(math.MaxInt64-table)/uint64(max(offsetSize, 1)) {
+		return nil, fmt.Errorf("invalid chunk count %v", count)
+	}
+	c = &Chunked{
+		r:          r,
+		length:     int64(length),
+		chunkSize:  int64(chunkSize),
+		offsetSize: offsetSize,
+		table:      table,
+		data:       table + int64(count)*int64(offsetSize),
+	}
+	_, err = r.Seek(c.data, io.SeekStart)
+	return
+}
+
+// Len returns the length of the value.
+func (c *Chunked) Len() int64 {
+	return c.length
+}
+
+// ReadAt implements [io.ReaderAt]. It moves the position of the underlying
+// reader.
+func (c *Chunked) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	for n < len(p) && off+int64(n) < c.length {
+		pos := off + int64(n)
+		i := pos / c.chunkSize
+		if _, err = c.r.Seek(c.table+i*int64(c.offsetSize), io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(c.r, c.offsetSize); err != nil {
+			return
+		}
+		inChunk := pos % c.chunkSize
+		if offset > uint64(math.MaxInt64-c.data-inChunk) {
+			return n, fmt.Errorf("invalid chunk offset %v", offset)
+		}
+		if _, err = c.r.Seek(c.data+int64(offset)+inChunk, io.SeekStart); err != nil {
+			return
+		}
+		m := min(int64(len(p)-n), c.chunkSize-inChunk, c.length-pos)
+		if _, err = io.ReadFull(c.r, p[n:n+int(m)]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		n += int(m)
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+// Value reads the entire value.
+func (c *Chunked) Value() (p []byte, err error) {
+	if _, err = c.r.Seek(c.data, io.SeekStart); err != nil {
+		return
+	}
+	return readBytes(c.r, int(c.length))
+}
+
+// skip skips the value. r is positioned at the end of the value.
+func (c *Chunked) skip() (err error) {
+	_, err = c.r.Seek(c.data+c.length, io.SeekStart)
+	return
+}
+
+// ReadChunked reads the descriptor of a [typeChunked] from r.
+// A [*TypeError] is returned if the value is of another type.
+func ReadChunked(r ByteReadSeeker) (c *Chunked, err error) {
+	mt, t, err := readTypeMarker(r)
+	if err != nil {
+		return
+	}
+	if t != typeChunked {
+		return nil, &TypeError{t}
+	}
+	return readChunkedValue(r, mt.OffsetSize())
+}
@@ -0,0 +1,71 @@
+package impl
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestChunked(t *testing.T) {
+	p := make([]byte, 1000)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	e := &Encoder{ChunkSize: 64, ChunkThreshold: 100}
+	var buf bytes.Buffer
+	if err := e.WriteValue(&buf, []any{p, p[:100], "end"}); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	v, err := ReadValue(r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []any{p, p[:100], "end"}) {
+		t.Fatal(v)
+	}
+
+	// Find the chunked value.
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	ary, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ary.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadChunked(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != int64(len(p)) {
+		t.Fatal(c.Len())
+	}
+	for _, test := range []struct{ off, n int }{{0, 10}, {60, 10}, {64, 64}, {100, 500}, {990, 10}, {0, 1000}} {
+		got := make([]byte, test.n)
+		if n, err := c.ReadAt(got, int64(test.off)); err != nil || n != test.n || !bytes.Equal(got, p[test.off:test.off+test.n]) {
+			t.Fatal(test, n, err)
+		}
+	}
+	got := make([]byte, 20)
+	if n, err := c.ReadAt(got, 990); err != io.EOF || n != 10 || !bytes.Equal(got[:n], p[990:]) {
+		t.Fatal(n, err)
+	}
+
+	// Read as binary.
+	if err := ary.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ReadBinary(r); err != nil || !bytes.Equal(v, p) {
+		t.Fatal(err)
+	}
+	if err := ary.Seek(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadChunked(r); err == nil {
+		t.Fatal("expected error")
+	}
+}
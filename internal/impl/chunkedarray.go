@@ -0,0 +1,139 @@
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A [typeChunkedArray] is a []any split into chunks of the same number of
+// elements except the last one, so that it can be written a chunk at a time
+// without knowing the length until the end, and any element can be read
+// by seeking the chunk of it. See [Encoder.ArrayChunkSize].
+//
+// The layout is: type mark, length, chunk size, the offsets of chunks
+// relative to the end of the offset table, and then the chunks in order,
+// each of which is an array written as [WriteArray] does. Length and chunk
+// size are variable-length encoded, and offsets are stored with the size in
+// the type mark.
+
+// chunkedArrayWriter collects the chunks of a [typeChunkedArray].
+type chunkedArrayWriter struct {
+	chunks  *bytes.Buffer
+	offsets []int // the offsets of chunks in chunks
+}
+
+func newChunkedArrayWriter() *chunkedArrayWriter {
+	return &chunkedArrayWriter{chunks: getBuffer()}
+}
+
+// release returns the buffer of cw to the pool.
+func (cw *chunkedArrayWriter) release() {
+	putBuffer(cw.chunks)
+}
+
+// add adds the chunk of the encoded elements in data, whose offsets in data
+// are offsets. offsets is modified.
+func (cw *chunkedArrayWriter) add(e *Encoder, offsets []int, data *bytes.Buffer) error {
+	cw.offsets = append(cw.offsets, cw.chunks.Len())
+	return e.writeArrayData(cw.chunks, offsets, data)
+}
+
+// writeTo writes the [typeChunkedArray] of length elements in chunks of
+// chunkSize to w.
+func (cw *chunkedArrayWriter) writeTo(w io.Writer, length, chunkSize int) (err error) {
+	offsetSize := fixedUintSize(uint64(cw.offsets[len(cw.offsets)-1]))
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err = writeTypeMarker(buf, typeChunkedArray, offsetSize); err != nil {
+		return
+	}
+	if err = writeUintValue(buf, uint64(length)); err != nil {
+		return
+	}
+	if err = writeUintValue(buf, uint64(chunkSize)); err != nil {
+		return
+	}
+	for _, offset := range cw.offsets {
+		if err = writeFixedUint(buf, uint64(offset), offsetSize); err != nil {
+			return
+		}
+	}
+	return copyBuffers(w, buf, cw.chunks)
+}
+
+// readChunkedArrayValue reads an Array of [typeChunkedArray] from r after
+// the type mark.
+func readChunkedArrayValue(r ByteReadSeeker, offsetSize byte) (array *Array, err error) {
+	length, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	chunkSize, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt || chunkSize > math.MaxInt || chunkSize == 0 && length > 0 {
+		return nil, fmt.Errorf("failed to read array: invalid chunked array of length %v and chunk size %v", length, chunkSize)
+	}
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	var count uint64
+	if length > 0 {
+		count = (length-1)/chunkSize + 1
+	}
+	if count > uint64(math.MaxInt64-pos)/uint64(max(offsetSize, 1)) {
+		return nil, fmt.Errorf("failed to read array: invalid chunk count %v", count)
+	}
+	array = &Array{
+		r:          r,
+		pos:        pos,
+		length:     int(length),
+		offsetSize: offsetSize,
+		chunkSize:  int(chunkSize),
+		data:       pos + int64(count)*int64(offsetSize),
+		limit:      depthLimit{depth: 1, max: DefaultMaxDepth},
+	}
+	return
+}
+
+// seekChunkElem seeks to the start of the ith element of array of
+// [typeChunkedArray].
+func (array *Array) seekChunkElem(i int) (err error) {
+	n := i / array.chunkSize
+	if array.chunk == nil || array.chunk.index != n {
+		if _, err = array.r.Seek(array.pos+int64(n)*int64(array.offsetSize), io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(array.r, array.offsetSize); err != nil {
+			return
+		}
+		if offset > uint64(math.MaxInt64-array.data) {
+			return fmt.Errorf("invalid chunk offset %v", offset)
+		}
+		if _, err = array.r.Seek(array.data+int64(offset), io.SeekStart); err != nil {
+			return
+		}
+		var chunk *Array
+		if chunk, err = ReadArray(array.r); err != nil {
+			return
+		}
+		if want := min(array.chunkSize, array.length-n*array.chunkSize); chunk.length != want || chunk.chunkSize != 0 {
+			return fmt.Errorf("invalid chunk of length %v", chunk.length)
+		}
+		array.chunk = &arrayChunk{*chunk, n}
+	}
+	// The reader of array may be replaced, such as by [Array.Explain].
+	array.chunk.r = array.r
+	return array.chunk.seekElem(i % array.chunkSize)
+}
+
+// arrayChunk is the last chunk of a [typeChunkedArray] read.
+type arrayChunk struct {
+	Array
+	index int // the index of the chunk
+}
@@ -0,0 +1,111 @@
+package impl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestChunkedArray(t *testing.T) {
+	ary := make([]any, 0, 250)
+	for i := range 250 {
+		if i%3 == 0 {
+			ary = append(ary, "elem"+strconv.Itoa(i))
+		} else {
+			ary = append(ary, int64(i))
+		}
+	}
+	e := &Encoder{Gob: NewGobEncoder(), ArrayChunkSize: 100}
+	var buf bytes.Buffer
+	if err := e.WriteValue(&buf, []any{ary, ary[:100], []any{}}); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	v, err := ReadValue(r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []any{ary, ary[:100], []any{}}) {
+		t.Fatal(v)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	// Only the arrays longer than a chunk are chunked.
+	var types []Type
+	err = Walk(r, DefaultMaxDepth, func(path []string, t Type, offset, size int64) error {
+		if len(path) == 1 {
+			types = append(types, t)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(types, []Type{TypeChunkedArray, TypeArray, TypeArray}) {
+		t.Fatal(types)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	outer, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	array, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if array.Len() != len(ary) {
+		t.Fatal(array.Len())
+	}
+	for _, i := range []int{249, 0, 99, 100, 101, 200, 150} {
+		if v, err := array.Index(i, true); err != nil || v != ary[i] {
+			t.Fatal(i, v, err)
+		}
+	}
+	var boundsErr *BoundsError
+	if _, err := array.Index(250, true); !errors.As(err, &boundsErr) {
+		t.Fatal(err)
+	}
+	lookup, err := array.Explain(150)
+	if err != nil || !lookup.Found || lookup.Type != TypeChunkedArray {
+		t.Fatal(lookup, err)
+	}
+
+	// Skipped.
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := SkipValue(r); err != nil || r.Len() != 0 {
+		t.Fatal(r.Len(), err)
+	}
+
+	// Streamed.
+	buf.Reset()
+	if err := e.AppendFrom(&buf, slices.Values(ary)); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ReadValue(bytes.NewReader(buf.Bytes()), true); err != nil || !reflect.DeepEqual(v, ary) {
+		t.Fatal(v, err)
+	}
+
+	// Legacy.
+	buf.Reset()
+	e.Legacy = true
+	if err := e.WriteArray(&buf, ary); err != nil {
+		t.Fatal(err)
+	}
+	if tm := typeMarker(buf.Bytes()[0]); tm.Type() != typeArray {
+		t.Fatal(tm.Type())
+	}
+}
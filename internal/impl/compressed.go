@@ -0,0 +1,98 @@
+package impl
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// A [typeCompressed] is a []byte compressed with DEFLATE, see
+// [Encoder.Compress].
+//
+// The layout is: type mark, the length of the value, the length of the
+// compressed data, and then the compressed data. Lengths are variable-length
+// encoded, and the size in the type mark is unused.
+
+var flateWriterPool = sync.Pool{
+	New: func() any {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	},
+}
+
+// writeCompressed writes p to w as a [typeCompressed] if it is smaller than
+// a [typeBinary] of p. Otherwise, p is written as a [typeBinary].
+func writeCompressed(w ByteWriter, p []byte) (err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	fw := flateWriterPool.Get().(*flate.Writer)
+	defer flateWriterPool.Put(fw)
+	fw.Reset(buf)
+	if _, err = fw.Write(p); err != nil {
+		return
+	}
+	if err = fw.Close(); err != nil {
+		return
+	}
+	// The extended type byte and the length of compressed data are the
+	// overhead.
+	if buf.Len()+1+len(appendUintValue(nil, uint64(buf.Len()))) >= len(p) {
+		return WriteBinary(w, p)
+	}
+	if err = writeTypeMarker(w, typeCompressed, 0); err != nil {
+		return
+	}
+	if err = writeUintValue(w, uint64(len(p))); err != nil {
+		return
+	}
+	return writeBinaryValue(w, buf.Bytes())
+}
+
+// readCompressedLength reads the lengths of a [typeCompressed] from r after
+// the type mark. On success, r is positioned at the compressed data.
+func readCompressedLength(r ByteReadSeeker) (length, compressed uint64, err error) {
+	if length, err = readUintValue(r); err != nil {
+		return
+	}
+	if compressed, err = readUintValue(r); err != nil {
+		return
+	}
+	if length > math.MaxInt || compressed > math.MaxInt64 {
+		err = fmt.Errorf("invalid compressed binary of length %v and compressed length %v", length, compressed)
+	}
+	return
+}
+
+// readCompressedValue reads and decompresses a [typeCompressed] from r after
+// the type mark. On success, r is positioned at the end of the value.
+func readCompressedValue(r ByteReadSeeker) (p []byte, err error) {
+	length, compressed, err := readCompressedLength(r)
+	if err != nil {
+		return
+	}
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	fr := flate.NewReader(io.LimitReader(r, int64(compressed)))
+	defer fr.Close()
+	// One more byte is read to detect longer data.
+	var buf bytes.Buffer
+	buf.Grow(int(min(length+1, maxPrealloc)))
+	if _, err = io.Copy(&buf, io.LimitReader(fr, int64(length)+1)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to decompress binary: %w", err)
+	}
+	if uint64(buf.Len()) != length {
+		return nil, fmt.Errorf("invalid compressed binary of length %v, decompressed %v bytes", length, buf.Len())
+	}
+	if _, err = r.Seek(start+int64(compressed), io.SeekStart); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
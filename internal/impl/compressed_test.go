@@ -0,0 +1,84 @@
+package impl
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompressed(t *testing.T) {
+	text := []byte(strings.Repeat("compressible text ", 100))
+	short := []byte("short")
+	e := &Encoder{Compress: func(p []byte) bool { return true }}
+	var buf bytes.Buffer
+	if err := e.WriteValue(&buf, []any{text, short, []byte{}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > len(text)/2 {
+		t.Fatal(buf.Len())
+	}
+	r := bytes.NewReader(buf.Bytes())
+	if v, err := ReadValue(r, true); err != nil || !reflect.DeepEqual(v, []any{text, short, []byte{}}) {
+		t.Fatal(v, err)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	// Values not smaller compressed are stored as is.
+	var types []Type
+	if err := Walk(r, DefaultMaxDepth, func(path []string, t Type, offset, size int64) error {
+		if len(path) == 1 {
+			types = append(types, t)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(types, []Type{TypeCompressed, TypeBinary, TypeBinary}) {
+		t.Fatal(types)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	ary, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, read := range []func(r ByteReadSeeker) ([]byte, error){
+		ReadBinary,
+		ReadBinaryView,
+		func(r ByteReadSeeker) ([]byte, error) { return AppendBinary([]byte("x"), r) },
+	} {
+		if err := ary.Seek(0); err != nil {
+			t.Fatal(err)
+		}
+		p, err := read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bytes.TrimPrefix(p, []byte("x")), text) {
+			t.Fatal(len(p))
+		}
+	}
+	if err := ary.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	if length, _, inflated, err := ReadBytesHeader(r); err != nil || length != uint64(len(text)) || !bytes.Equal(inflated, text) {
+		t.Fatal(length, err)
+	}
+
+	// Corrupted.
+	data := bytes.Clone(buf.Bytes())
+	if err := ary.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	data[pos+2]++ // The length of the value.
+	if _, err := ReadValue(bytes.NewReader(data), true); err == nil {
+		t.Fatal("corruption not detected")
+	}
+}
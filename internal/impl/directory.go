@@ -0,0 +1,183 @@
+package impl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// directory is the in-memory directory of an [Object], which records the
+// hash and position of every entry, so that lookups seek the entries whose
+// hashes match directly instead of reading the offset table and scanning
+// the chains. See [Object.LoadDirectory].
+//
+// The numbers are stored in little-endian byte slices, so that a directory
+// can be used in place from a file mapped into memory, see
+// [Object.SetDirectory].
+type directory struct {
+	// The uint32 index of the first entry of every bucket in entries,
+	// followed by the number of entries.
+	starts []byte
+	// The uint64 hash and int64 position of the key of every entry.
+	entries []byte
+}
+
+// directoryEntrySize is the memory used by an entry of a [directory].
+const directoryEntrySize = 8 + 8
+
+// start returns the index of the first entry of bucket i.
+func (dir *directory) start(i uint64) uint32 {
+	return binary.LittleEndian.Uint32(dir.starts[i*4:])
+}
+
+// len returns the number of entries.
+func (dir *directory) len() uint32 {
+	return uint32(len(dir.entries) / directoryEntrySize)
+}
+
+// entry returns the hash and position of the key of entry j.
+func (dir *directory) entry(j uint32) (hash uint64, pos int64) {
+	e := dir.entries[int(j)*directoryEntrySize:]
+	return binary.LittleEndian.Uint64(e), int64(binary.LittleEndian.Uint64(e[8:]))
+}
+
+// LoadDirectory reads the positions and hashes of all the entries of obj
+// into memory, which are used by the lookups of obj afterwards, so that
+// most lookups read the underlying reader once. If the directory takes
+// more than maxSize bytes, or obj is written with [Encoder.FrontCoding] or
+// as a packed object, nothing is loaded and loaded is false.
+// If maxSize <= 0, the size is not limited.
+func (obj *Object) LoadDirectory(maxSize int64) (loaded bool, err error) {
+	if obj.keySize > 0 || obj.prefixed || obj.bucketCount >= math.MaxUint32 {
+		return
+	}
+	size := int64(obj.bucketCount+1) * 4
+	if maxSize > 0 && size > maxSize {
+		return
+	}
+	dir := &directory{starts: make([]byte, 0, size)}
+	for i := range obj.bucketCount {
+		dir.starts = binary.LittleEndian.AppendUint32(dir.starts, dir.len())
+		if _, err = obj.r.Seek(obj.pos+int64(i)*int64(obj.offsetSize), io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(obj.r, obj.offsetSize); err != nil {
+			return
+		}
+		if offset > math.MaxInt64-uint64(obj.pos) {
+			err = fmt.Errorf("invalid offset %v", offset)
+			return
+		}
+		if offset == 0 {
+			continue // Not exists
+		}
+		if _, err = obj.r.Seek(obj.pos+int64(offset), io.SeekStart); err != nil {
+			return
+		}
+		var listLen uint64
+		if listLen, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if size += int64(min(listLen, math.MaxInt32)) * directoryEntrySize; maxSize > 0 && size > maxSize {
+			return false, nil
+		}
+		if uint64(dir.len())+listLen >= math.MaxUint32 {
+			return false, nil
+		}
+		for range listLen {
+			var pos int64
+			if pos, err = obj.r.Seek(0, io.SeekCurrent); err != nil {
+				return
+			}
+			if obj.hashed {
+				pos += 8
+			}
+			var key []byte
+			if key, err = obj.readKey(); err != nil {
+				return
+			}
+			dir.entries = binary.LittleEndian.AppendUint64(dir.entries, stringHash(string(key)))
+			dir.entries = binary.LittleEndian.AppendUint64(dir.entries, uint64(pos))
+			var valueSize uint64
+			if valueSize, err = readUintValue(obj.r); err != nil {
+				return
+			}
+			if valueSize > math.MaxInt64 {
+				err = fmt.Errorf("invalid value size %v", valueSize)
+				return
+			}
+			if _, err = obj.r.Seek(int64(valueSize), io.SeekCurrent); err != nil {
+				return
+			}
+		}
+	}
+	dir.starts = binary.LittleEndian.AppendUint32(dir.starts, dir.len())
+	obj.dir = dir
+	return true, nil
+}
+
+// Directory returns the directory loaded by [Object.LoadDirectory] or set by
+// [Object.SetDirectory], which can be stored and set to the objects read
+// from the same data later. ok is false if obj has no directory.
+func (obj *Object) Directory() (starts, entries []byte, ok bool) {
+	if obj.dir == nil {
+		return
+	}
+	return obj.dir.starts, obj.dir.entries, true
+}
+
+// ErrInvalidDirectory is returned by [Object.SetDirectory] if the
+// directory does not fit the object.
+var ErrInvalidDirectory = errors.New("invalid directory")
+
+// SetDirectory sets the directory of obj to the one returned by
+// [Object.Directory] of the same object, which is used in place, so starts
+// and entries must not be modified afterwards. Only the sizes are checked,
+// and the rest is checked by the lookups using it.
+func (obj *Object) SetDirectory(starts, entries []byte) (err error) {
+	if obj.keySize > 0 || obj.prefixed || obj.bucketCount >= math.MaxUint32 ||
+		uint64(len(starts)) != (obj.bucketCount+1)*4 ||
+		len(entries)%directoryEntrySize != 0 || uint64(len(entries)/directoryEntrySize) >= math.MaxUint32 {
+		return ErrInvalidDirectory
+	}
+	dir := &directory{starts: starts, entries: entries}
+	if dir.start(obj.bucketCount) != dir.len() {
+		return ErrInvalidDirectory
+	}
+	obj.dir = dir
+	return
+}
+
+// seekDirectory is [Object.SeekHash] using the directory of obj.
+func (obj *Object) seekDirectory(hash uint64, key string) (err error) {
+	i := obj.bucket(hash)
+	start, end := obj.dir.start(i), obj.dir.start(i+1)
+	if start > end || end > obj.dir.len() {
+		return fmt.Errorf("invalid directory of bucket %v", i)
+	}
+	for j := start; j < end; j++ {
+		h, pos := obj.dir.entry(j)
+		if h != hash {
+			continue
+		}
+		if pos < 0 {
+			return fmt.Errorf("invalid directory entry %v", j)
+		}
+		if _, err = obj.r.Seek(pos, io.SeekStart); err != nil {
+			return
+		}
+		var found bool
+		if found, err = obj.matchKey(key); err != nil {
+			return
+		} else if !found {
+			continue
+		}
+		// Read value size
+		_, err = readUintValue(obj.r)
+		return
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,128 @@
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDirectory(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 3000 {
+		obj[fmt.Sprint(i)] = i
+	}
+	obj[""] = "empty"
+	for _, encoder := range []*Encoder{{}, {SortedKeys: true}, {HashOrder: true}} {
+		var buf bytes.Buffer
+		if err := encoder.WriteObject(&buf, obj); err != nil {
+			t.Fatal(err)
+		}
+		o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded, err := o.LoadDirectory(1000); err != nil || loaded {
+			t.Fatal(loaded, err)
+		}
+		if loaded, err := o.LoadDirectory(0); err != nil || !loaded {
+			t.Fatal(loaded, err)
+		}
+		counter := &seekCounter{ByteReadSeeker: o.r}
+		o.r = counter
+		for key, value := range obj {
+			counter.seeks = 0
+			if v, err := o.Index(key, true); err != nil {
+				t.Fatal(key, err)
+			} else if !reflect.DeepEqual(v, int64OrString(value)) {
+				t.Fatal(key, v)
+			} else if counter.seeks != 1 {
+				t.Fatal(key, counter.seeks)
+			}
+		}
+		for i := range 3000 {
+			key := fmt.Sprint(i + 3000)
+			if _, err := o.Index(key, false); err != ErrNotFound {
+				t.Fatal(key, err)
+			}
+		}
+		if all, err := o.Value(); err != nil || len(all) != len(obj) {
+			t.Fatal(len(all), err)
+		}
+	}
+
+	// Front-coded.
+	var buf bytes.Buffer
+	if err := (&Encoder{FrontCoding: true}).WriteObject(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+	o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := o.LoadDirectory(0); err != nil || loaded {
+		t.Fatal(loaded, err)
+	}
+}
+
+func TestSetDirectory(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 1000 {
+		obj[fmt.Sprint(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := (&Encoder{}).WriteObject(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+	o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := o.Directory(); ok {
+		t.Fatal("directory without loading")
+	}
+	if loaded, err := o.LoadDirectory(0); err != nil || !loaded {
+		t.Fatal(loaded, err)
+	}
+	starts, entries, ok := o.Directory()
+	if !ok {
+		t.Fatal("no directory")
+	}
+
+	o, err = ReadObject(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.SetDirectory(starts[4:], entries); err != ErrInvalidDirectory {
+		t.Fatal(err)
+	}
+	if err := o.SetDirectory(starts, entries[directoryEntrySize:]); err != ErrInvalidDirectory {
+		t.Fatal(err)
+	}
+	if err := o.SetDirectory(starts, entries); err != nil {
+		t.Fatal(err)
+	}
+	counter := &seekCounter{ByteReadSeeker: o.r}
+	o.r = counter
+	for _, i := range []int{0, 500, 999} {
+		counter.seeks = 0
+		if v, err := o.Index(fmt.Sprint(i), true); err != nil || v != int64(i) || counter.seeks != 1 {
+			t.Fatal(i, v, counter.seeks, err)
+		}
+	}
+	if _, err := o.Index("1000", false); err != ErrNotFound {
+		t.Fatal(err)
+	}
+
+	// Corrupted.
+	corrupted := bytes.Clone(starts)
+	for i := 0; i < len(corrupted)-4; i++ {
+		corrupted[i] = 0xff
+	}
+	if err := o.SetDirectory(corrupted, entries); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Index("0", false); err == nil || err == ErrNotFound {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+package impl
+
+import (
+	"io"
+	"math"
+)
+
+// Lookup describes the lookup of a key in an [Object] or an index in an
+// [Array], see [Object.Explain] and [Array.Explain].
+type Lookup struct {
+	Type Type  // The type of the container.
+	Pos  int64 // The position of the table of the container.
+	// The number of buckets of hash tables, keys of objects of fixed keys,
+	// or elements of arrays.
+	Size   uint64
+	Bucket int64 // The bucket of the key in hash tables, or -1.
+	Chain  int64 // The number of entries in the bucket, or -1.
+	Seeks  int   // The number of seeks moving the underlying reader.
+	Found  bool  // Whether the key is found.
+	// The position of the value if found.
+	ValuePos int64
+}
+
+// seekCounter counts the seeks of r moving its position.
+type seekCounter struct {
+	ByteReadSeeker
+	seeks int
+}
+
+func (c *seekCounter) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekCurrent || offset != 0 {
+		c.seeks++
+	}
+	return c.ByteReadSeeker.Seek(offset, whence)
+}
+
+// typ returns the type of obj.
+func (obj *Object) typ() typ {
+	switch {
+	case obj.intKeys:
+		return typeIntKeyObject
+	case obj.keySize > 0:
+		return typeFixedKeyObject
+	case obj.prefixed:
+		return typePrefixObject
+	case obj.hashed:
+		return typeHashedObject
+	case obj.sorted:
+		return typeSortedObject
+	}
+	return typeObject
+}
+
+// Explain looks up key in obj as [Object.Seek] does, and describes the lookup.
+// On success, the underlying reader is positioned at the value if found.
+func (obj *Object) Explain(key string) (lookup Lookup, err error) {
+	lookup = Lookup{Type: obj.typ(), Pos: obj.pos, Size: obj.bucketCount, Bucket: -1, Chain: -1}
+	if obj.keySize == 0 && obj.bucketCount > 0 {
+		bucket := obj.bucket(stringHash(key))
+		lookup.Bucket = int64(bucket)
+		if _, err = obj.r.Seek(obj.pos+int64(bucket)*int64(obj.offsetSize), io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(obj.r, obj.offsetSize); err != nil {
+			return
+		}
+		lookup.Chain = 0
+		if offset != 0 && offset <= math.MaxInt64-uint64(obj.pos) {
+			if _, err = obj.r.Seek(obj.pos+int64(offset), io.SeekStart); err != nil {
+				return
+			}
+			var chain uint64
+			if chain, err = readUintValue(obj.r); err != nil {
+				return
+			}
+			lookup.Chain = int64(min(chain, math.MaxInt64))
+		}
+	}
+	r := obj.r
+	counter := &seekCounter{ByteReadSeeker: r}
+	obj.r = counter
+	err = obj.Seek(key)
+	obj.r = r
+	lookup.Seeks = counter.seeks
+	return lookup.found(r, err)
+}
+
+// Explain seeks the ith element of array as [Array.Seek] does, and
+// describes the lookup. On success, the underlying reader is positioned at
+// the element if found.
+func (array *Array) Explain(i int) (lookup Lookup, err error) {
+	lookup = Lookup{Type: typeArray, Pos: array.pos, Size: uint64(array.length), Bucket: -1, Chain: -1}
+	if array.chunkSize != 0 {
+		lookup.Type = typeChunkedArray
+	} else if array.stride != 0 {
+		lookup.Type = typeFixedArray
+	}
+	r := array.r
+	counter := &seekCounter{ByteReadSeeker: r}
+	array.r = counter
+	err = array.Seek(i)
+	array.r = r
+	lookup.Seeks = counter.seeks
+	if _, ok := err.(*BoundsError); ok {
+		err = ErrNotFound
+	}
+	return lookup.found(r, err)
+}
+
+// found records the position of r in lookup if err is nil, and returns
+// lookup and err, which is nil if the lookup finds nothing.
+func (lookup Lookup) found(r io.Seeker, err error) (Lookup, error) {
+	if err == ErrNotFound {
+		return lookup, nil
+	} else if err != nil {
+		return lookup, err
+	}
+	lookup.Found = true
+	lookup.ValuePos, err = r.Seek(0, io.SeekCurrent)
+	return lookup, err
+}
@@ -0,0 +1,415 @@
+package impl
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// A [typeFixedKeyObject] is an object whose keys are all of the same size.
+// The layout is: type mark, number of entries, key size, sorted keys packed
+// without lengths, offsets of values and the end of the object relative to
+// the first key, and then the values in the order of keys.
+// Every entry is a bucket of itself, so the cursor of an entry is the index
+// of the entry as bucket.
+//
+// A [typeIntKeyObject] is an object whose keys are all integers. It has the
+// same layout as [typeFixedKeyObject], with keys stored as 8-byte big-endian
+// integers whose sign bits are flipped, so they are sorted numerically.
+
+// fixedKeySize returns the size of every key of obj, or false if the keys
+// are not of the same size, or obj is empty or has only an empty key.
+func fixedKeySize(obj map[string]any) (size int, ok bool) {
+	size = -1
+	for key := range obj {
+		if size == -1 {
+			size = len(key)
+		} else if len(key) != size {
+			return 0, false
+		}
+	}
+	return size, size > 0
+}
+
+// writeFixedKeyObject writes obj whose keys are all of keySize as a [typeFixedKeyObject].
+func (e *Encoder) writeFixedKeyObject(w io.Writer, obj map[string]any, keySize int) (err error) {
+	keys := slices.Sorted(maps.Keys(obj))
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = obj[key]
+	}
+	return e.writePackedKeys(w, typeFixedKeyObject, keySize, keys, values)
+}
+
+// intKeySize is the size of keys of [typeIntKeyObject].
+const intKeySize = 8
+
+// appendIntKey appends the key of n in a [typeIntKeyObject] to dst.
+func appendIntKey(dst []byte, n int64) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(n)^(1<<63))
+}
+
+// decodeIntKey decodes a key of a [typeIntKeyObject].
+func decodeIntKey(p []byte) int64 {
+	return int64(binary.BigEndian.Uint64(p) ^ (1 << 63))
+}
+
+// parseIntKey parses key as an integer in canonical decimal form.
+func parseIntKey(key string) (n int64, ok bool) {
+	n, err := strconv.ParseInt(key, 10, 64)
+	return n, err == nil && strconv.FormatInt(n, 10) == key
+}
+
+// writeIntKeyObject writes obj as a [typeIntKeyObject] if all the keys of obj
+// are integers in canonical decimal form. ok is false if obj is not written.
+func (e *Encoder) writeIntKeyObject(w io.Writer, obj map[string]any) (ok bool, err error) {
+	if len(obj) == 0 {
+		return
+	}
+	type entry struct {
+		n     int64
+		value any
+	}
+	entries := make([]entry, 0, len(obj))
+	for key, value := range obj {
+		n, ok := parseIntKey(key)
+		if !ok {
+			return false, nil
+		}
+		entries = append(entries, entry{n, value})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Compare(a.n, b.n)
+	})
+	keys := make([]string, len(entries))
+	values := make([]any, len(entries))
+	var buf []byte
+	for i, entry := range entries {
+		buf = appendIntKey(buf[:0], entry.n)
+		keys[i], values[i] = string(buf), entry.value
+	}
+	return true, e.writePackedKeys(w, typeIntKeyObject, intKeySize, keys, values)
+}
+
+// writePackedKeys writes an object of type t with sorted keys of keySize and their values.
+func (e *Encoder) writePackedKeys(w io.Writer, t typ, keySize int, keys []string, values []any) (err error) {
+	data := getBuffer()
+	defer putBuffer(data)
+	offsets := make([]int, len(keys)+1)
+	for i, value := range values {
+		offsets[i] = data.Len()
+		if err = e.WriteValue(data, value); err != nil {
+			key := keys[i]
+			if t == typeIntKeyObject {
+				key = strconv.FormatInt(decodeIntKey([]byte(key)), 10)
+			}
+			return EncodeErrorAt(err, key)
+		}
+	}
+	offsets[len(keys)] = data.Len()
+
+	keysSize := len(keys) * keySize
+	// offsetSize must be large enough to hold the end of object.
+	offsetSize := minOffsetSize(func(size byte) uint64 {
+		return uint64(keysSize) + uint64(len(offsets))*uint64(size) + uint64(data.Len())
+	})
+	delta := keysSize + len(offsets)*int(offsetSize)
+
+	header := getBuffer()
+	defer putBuffer(header)
+	if err = writeTypeMarker(header, t, offsetSize); err != nil {
+		return
+	}
+	if err = writeUintValue(header, uint64(len(keys))); err != nil {
+		return
+	}
+	if err = writeUintValue(header, uint64(keySize)); err != nil {
+		return
+	}
+	for _, key := range keys {
+		if err = writeString(header, key); err != nil {
+			return
+		}
+	}
+	for _, offset := range offsets {
+		if err = writeFixedUint(header, uint64(offset+delta), offsetSize); err != nil {
+			return
+		}
+	}
+	return copyBuffers(w, header, data)
+}
+
+// readFixedKeyObjectValue reads the rest of the header of a [typeFixedKeyObject]
+// into obj, whose bucketCount has been read.
+func readFixedKeyObjectValue(r ByteReadSeeker, obj *Object) (err error) {
+	keySize, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	if keySize > math.MaxInt32 || obj.bucketCount > math.MaxInt64/(keySize+8) {
+		return fmt.Errorf("invalid key size %v of %v keys", keySize, obj.bucketCount)
+	}
+	if obj.intKeys && keySize != intKeySize {
+		return fmt.Errorf("invalid integer key size %v", keySize)
+	}
+	obj.keySize = int(keySize)
+	return
+}
+
+// fixedKeyString returns key read by [Object.readFixedKey] as string.
+func (obj *Object) fixedKeyString(key []byte) string {
+	if obj.intKeys {
+		return strconv.FormatInt(decodeIntKey(key), 10)
+	}
+	return string(key)
+}
+
+// readFixedKey reads the i-th key of obj into obj.keyBuf.
+func (obj *Object) readFixedKey(i uint64) (key []byte, err error) {
+	if !obj.intKeys {
+		if err = obj.limit.checkKey(uint64(obj.keySize)); err != nil {
+			return
+		}
+	}
+	if _, err = obj.r.Seek(obj.pos+int64(i)*int64(obj.keySize), io.SeekStart); err != nil {
+		return
+	}
+	obj.keyBuf = slices.Grow(obj.keyBuf[:0], obj.keySize)[:obj.keySize]
+	if _, err = io.ReadFull(obj.r, obj.keyBuf); err != nil {
+		return
+	}
+	return obj.keyBuf, nil
+}
+
+// fixedOffset returns the position of the i-th value of obj,
+// or the end of obj if i is the number of entries.
+func (obj *Object) fixedOffset(i uint64) (pos int64, err error) {
+	offsetPos := obj.pos + int64(obj.bucketCount)*int64(obj.keySize) + int64(i)*int64(obj.offsetSize)
+	if _, err = obj.r.Seek(offsetPos, io.SeekStart); err != nil {
+		return
+	}
+	offset, err := readFixedUint(obj.r, obj.offsetSize)
+	if err != nil {
+		return
+	}
+	if offset > math.MaxInt64-uint64(obj.pos) {
+		err = fmt.Errorf("invalid offset %v", offset)
+		return
+	}
+	return obj.pos + int64(offset), nil
+}
+
+// seekFixedKey is [Object.SeekHash] of a [typeFixedKeyObject] or [typeIntKeyObject].
+func (obj *Object) seekFixedKey(key string) (err error) {
+	if obj.intKeys {
+		n, ok := parseIntKey(key)
+		if !ok {
+			return ErrNotFound
+		}
+		var buf [intKeySize]byte
+		key = string(appendIntKey(buf[:0], n))
+	}
+	if len(key) != obj.keySize {
+		return ErrNotFound
+	}
+	i, found, err := obj.searchFixedKey(key)
+	if err != nil {
+		return
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return obj.seekFixedValue(i)
+}
+
+// searchFixedKey binary searches key in obj, and returns the index of the
+// smallest key >= key, and whether the key at the index equals key.
+func (obj *Object) searchFixedKey(key string) (i uint64, found bool, err error) {
+	lo, hi := uint64(0), obj.bucketCount
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		var k []byte
+		if k, err = obj.readFixedKey(mid); err != nil {
+			return
+		}
+		switch {
+		case string(k) == key:
+			return mid, true, nil
+		case string(k) < key:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return lo, false, nil
+}
+
+// seekFixedValue positions the underlying reader at the start of the i-th value of obj.
+func (obj *Object) seekFixedValue(i uint64) (err error) {
+	pos, err := obj.fixedOffset(i)
+	if err != nil {
+		return
+	}
+	_, err = obj.r.Seek(pos, io.SeekStart)
+	return
+}
+
+// forEachFixedKey is [Object.forEachFrom] of a [typeFixedKeyObject].
+func (obj *Object) forEachFixedKey(from Cursor, fn func(cursor Cursor, key string) error) (err error) {
+	start := from.Bucket
+	if from.Entry > 0 {
+		start++
+	}
+	for i := start; i < obj.bucketCount; i++ {
+		var key []byte
+		if key, err = obj.readFixedKey(i); err != nil {
+			return
+		}
+		keyString := obj.fixedKeyString(key)
+		if err = obj.seekFixedValue(i); err != nil {
+			return
+		}
+		if err = fn(Cursor{Bucket: i}, keyString); err != nil {
+			return
+		}
+	}
+	return obj.skip()
+}
+
+// skipFixedKey is [Object.skip] of a [typeFixedKeyObject].
+func (obj *Object) skipFixedKey() (err error) {
+	end, err := obj.fixedOffset(obj.bucketCount)
+	if err != nil {
+		return
+	}
+	_, err = obj.r.Seek(end, io.SeekStart)
+	return
+}
+
+// IntRange returns the integer keys of obj in [lo, hi] and their values in
+// the order of keys. See [Array.Index] for the meaning of recursive.
+// Objects other than [typeIntKeyObject] are scanned entirely.
+func (obj *Object) IntRange(lo, hi int64, recursive bool) (keys []int64, values []any, err error) {
+	if lo > hi {
+		return
+	}
+	if !obj.intKeys {
+		if keys, err = obj.scanIntKeys(lo, hi); err != nil {
+			return
+		}
+		values = make([]any, len(keys))
+		for i, key := range keys {
+			if values[i], err = obj.Index(strconv.FormatInt(key, 10), recursive); err != nil {
+				return
+			}
+		}
+		return
+	}
+	var buf [intKeySize]byte
+	start, _, err := obj.searchFixedKey(string(appendIntKey(buf[:0], lo)))
+	if err != nil {
+		return
+	}
+	for i := start; i < obj.bucketCount; i++ {
+		var k []byte
+		if k, err = obj.readFixedKey(i); err != nil {
+			return
+		}
+		key := decodeIntKey(k)
+		if key > hi {
+			break
+		}
+		if err = obj.seekFixedValue(i); err != nil {
+			return
+		}
+		var v any
+		if v, err = readValue(obj.r, recursive, obj.limit); err != nil {
+			return
+		}
+		keys, values = append(keys, key), append(values, v)
+	}
+	return
+}
+
+// FloorInt returns the largest integer key of obj <= x and its value.
+// The returned error is [ErrNotFound] if there is no such key.
+// See [Array.Index] for the meaning of recursive.
+// Objects other than [typeIntKeyObject] are scanned entirely.
+func (obj *Object) FloorInt(x int64, recursive bool) (key int64, v any, err error) {
+	if !obj.intKeys {
+		var keys []int64
+		if keys, err = obj.scanIntKeys(math.MinInt64, x); err != nil {
+			return
+		}
+		if len(keys) == 0 {
+			err = ErrNotFound
+			return
+		}
+		key = keys[len(keys)-1]
+		v, err = obj.Index(strconv.FormatInt(key, 10), recursive)
+		return
+	}
+	var buf [intKeySize]byte
+	i, found, err := obj.searchFixedKey(string(appendIntKey(buf[:0], x)))
+	if err != nil {
+		return
+	}
+	if !found {
+		if i == 0 {
+			err = ErrNotFound
+			return
+		}
+		i-- // The largest key < x.
+	}
+	k, err := obj.readFixedKey(i)
+	if err != nil {
+		return
+	}
+	key = decodeIntKey(k)
+	if err = obj.seekFixedValue(i); err != nil {
+		return
+	}
+	v, err = readValue(obj.r, recursive, obj.limit)
+	return
+}
+
+// scanIntKeys returns the sorted integer keys of obj in [lo, hi] by
+// scanning all the keys. Keys not in canonical decimal form are ignored.
+func (obj *Object) scanIntKeys(lo, hi int64) (keys []int64, err error) {
+	err = obj.forEach(func(key string) error {
+		if n, ok := parseIntKey(key); ok && n >= lo && n <= hi {
+			keys = append(keys, n)
+		}
+		return nil
+	})
+	slices.Sort(keys)
+	return
+}
+
+// LongestPrefix returns the longest key of obj which is a prefix of s and its
+// value. The returned error is [ErrNotFound] if there is no such key.
+// See [Array.Index] for the meaning of recursive.
+// Objects of [typeFixedKeyObject] are looked up once, and the others are
+// looked up once for every prefix of s from the longest.
+func (obj *Object) LongestPrefix(s string, recursive bool) (key string, v any, err error) {
+	longest, shortest := len(s), 0
+	if obj.keySize > 0 && !obj.intKeys {
+		// All the keys are of keySize.
+		longest, shortest = obj.keySize, obj.keySize
+	}
+	for n := min(longest, len(s)); n >= shortest; n-- {
+		key = s[:n]
+		if err = obj.Seek(key); err == nil {
+			v, err = readValue(obj.r, recursive, obj.limit)
+			return
+		} else if err != ErrNotFound {
+			return
+		}
+	}
+	return "", nil, ErrNotFound
+}
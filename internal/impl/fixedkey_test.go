@@ -0,0 +1,213 @@
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestFixedKeyObject(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 500 {
+		obj[fmt.Sprintf("%04X", i*3)] = []any{i, fmt.Sprint(i)}
+	}
+	encoder := &Encoder{FixedKeys: true}
+	var buf bytes.Buffer
+	if err := encoder.WriteValue(&buf, []any{obj, map[string]any{"a": 1, "bc": 2}, map[string]any{"": 1}, "end"}); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	ary, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ary.Index(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := v.(*Object)
+	if o.keySize != 4 {
+		t.Fatal(o.keySize)
+	}
+	for i := range 1500 {
+		key := fmt.Sprintf("%04X", i)
+		v, err := o.Index(key, true)
+		if i%3 != 0 {
+			if err != ErrNotFound {
+				t.Fatal(key, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(key, err)
+		} else if !reflect.DeepEqual(v, []any{int64(i / 3), fmt.Sprint(i / 3)}) {
+			t.Fatal(key, v)
+		}
+	}
+	for _, key := range []string{"", "000", "00000", "FFFF"} {
+		if _, err := o.Index(key, false); err != ErrNotFound {
+			t.Fatal(key, err)
+		}
+	}
+	if all, err := o.Value(); err != nil {
+		t.Fatal(err)
+	} else if len(all) != len(obj) {
+		t.Fatal(len(all))
+	}
+	keys, next, more, err := o.Keys(Cursor{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{"0000", "0003"}) || !more {
+		t.Fatal(keys, more)
+	}
+	if keys, _, _, err = o.Keys(next, 1); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{"0006"}) {
+		t.Fatal(keys)
+	}
+
+	// Objects of different key sizes and empty key are stored in hash tables.
+	for i, want := range []any{map[string]any{"a": int64(1), "bc": int64(2)}, map[string]any{"": int64(1)}, "end"} {
+		if v, err := ary.Index(i+1, true); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, want) {
+			t.Fatal(v)
+		}
+	}
+	r.Seek(0, io.SeekStart)
+	if err := SkipValue(r); err != nil {
+		t.Fatal(err)
+	} else if r.Len() != 0 {
+		t.Fatal(r.Len())
+	}
+}
+
+func TestIntKeyObject(t *testing.T) {
+	obj := make(map[string]any)
+	for i := -50; i < 50; i++ {
+		obj[strconv.Itoa(i*10)] = i
+	}
+	obj["9223372036854775807"] = "max"
+	obj["-9223372036854775808"] = "min"
+	encoder := &Encoder{IntKeys: true, FixedKeys: true}
+	var buf bytes.Buffer
+	if err := encoder.WriteValue(&buf, []any{obj, map[string]any{"1": 1, "01": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	ary, err := ReadArray(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, intKeys := range []bool{true, false} {
+		v, err := ary.Index(i, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o := v.(*Object); o.intKeys != intKeys {
+			t.Fatal(o.intKeys)
+		}
+	}
+	v, _ := ary.Index(0, false)
+	o := v.(*Object)
+	for key, value := range obj {
+		if v, err := o.Index(key, true); err != nil {
+			t.Fatal(key, err)
+		} else if !reflect.DeepEqual(v, int64OrString(value)) {
+			t.Fatal(key, v)
+		}
+	}
+	for _, key := range []string{"5", "010", "-0", "+10", "a", ""} {
+		if _, err := o.Index(key, false); err != ErrNotFound {
+			t.Fatal(key, err)
+		}
+	}
+	if all, err := o.Value(); err != nil {
+		t.Fatal(err)
+	} else if len(all) != len(obj) {
+		t.Fatal(len(all))
+	}
+
+	keys, values, err := o.IntRange(-15, 20, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []int64{-10, 0, 10, 20}) || !reflect.DeepEqual(values, []any{int64(-1), int64(0), int64(1), int64(2)}) {
+		t.Fatal(keys, values)
+	}
+	for _, test := range []struct {
+		x    int64
+		want int64
+	}{{25, 20}, {20, 20}, {-491, -500}, {-501, math.MinInt64}, {math.MaxInt64, math.MaxInt64}} {
+		if key, _, err := o.FloorInt(test.x, false); err != nil {
+			t.Fatal(err)
+		} else if key != test.want {
+			t.Fatal(test.x, key)
+		}
+	}
+
+	// Objects without integer keys are scanned.
+	v, _ = ary.Index(1, false)
+	o = v.(*Object)
+	if keys, values, err := o.IntRange(0, 10, true); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []int64{1}) || !reflect.DeepEqual(values, []any{int64(1)}) {
+		t.Fatal(keys, values)
+	}
+	if _, _, err := o.FloorInt(0, true); err != ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	vendors := map[string]any{"AC319D": "a", "AC31": "b", "00": "c"}
+	fixed := map[string]any{"AC319D": "a", "AC319E": "b"}
+	encoder := &Encoder{FixedKeys: true}
+	var buf bytes.Buffer
+	if err := encoder.WriteValue(&buf, []any{vendors, fixed, map[string]any{"": "any"}}); err != nil {
+		t.Fatal(err)
+	}
+	ary, err := ReadArray(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := make([]*Object, ary.Len())
+	for i := range objects {
+		v, err := ary.Index(i, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects[i] = v.(*Object)
+	}
+	for _, test := range []struct {
+		obj       int
+		s         string
+		key       string
+		v         any
+		wantFound bool
+	}{
+		{0, "AC319D1234", "AC319D", "a", true},
+		{0, "AC319E1234", "AC31", "b", true},
+		{0, "AC3", "", nil, false},
+		{0, "00", "00", "c", true},
+		{1, "AC319E1234", "AC319E", "b", true},
+		{1, "AC319F1234", "", nil, false},
+		{1, "AC319", "", nil, false},
+		{2, "x", "", "any", true},
+	} {
+		key, v, err := objects[test.obj].LongestPrefix(test.s, true)
+		if !test.wantFound {
+			if err != ErrNotFound {
+				t.Fatal(test.s, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(test.s, err)
+		} else if key != test.key || v != test.v {
+			t.Fatal(test.s, key, v)
+		}
+	}
+}
@@ -1,19 +1,14 @@
+//go:build !(tinygo || hashive_nogob)
+
 package impl
 
 import (
 	"bytes"
 	"encoding/gob"
 	"io"
+	"reflect"
 )
 
-type writerWrapper struct {
-	io.Writer
-}
-
-func (w *writerWrapper) Write(p []byte) (n int, err error) {
-	return w.Writer.Write(p)
-}
-
 type byteReaderWrapper struct {
 	io.Reader
 }
@@ -34,25 +29,67 @@ func (r *byteReaderWrapper) ReadByte() (b byte, err error) {
 	return
 }
 
-type GobEncoder func(v any) GobValue
-type GobDecoder func(gob GobValue, v any) error
-
+// NewGobEncoder returns a GobEncoder encoding every value with a new
+// [gob.Encoder], so that every value carries its own type information
+// and can be decoded independently in any order.
 func NewGobEncoder() GobEncoder {
-	var gobWriterWrapper writerWrapper
-	var gobEncoder = gob.NewEncoder(&gobWriterWrapper)
-
-	return func(v any) GobValue {
+	return func(v any) (GobValue, error) {
 		var buf bytes.Buffer
-		gobWriterWrapper.Writer = &buf
-		err := gobEncoder.Encode(v)
-		if err != nil {
-			panic(err)
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
 		}
-		return GobValue(buf.Bytes())
+		return GobValue(buf.Bytes()), nil
 	}
 }
 
+// NewGobDecoder returns a GobDecoder decoding values with [GobValue.Decode].
 func NewGobDecoder() GobDecoder {
+	return GobValue.Decode
+}
+
+// EncodeGobInterface encodes v as an interface value, which carries the name
+// of its type registered with [gob.Register].
+func EncodeGobInterface(v any) (GobValue, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return GobValue(buf.Bytes()), nil
+}
+
+// DecodeInterface decodes g encoded by [EncodeGobInterface] and returns
+// the value of its registered type.
+func (g GobValue) DecodeInterface() (v any, err error) {
+	err = gob.NewDecoder(bytes.NewReader(g)).Decode(&v)
+	return
+}
+
+// Decode decodes g into the value pointed to by v.
+// Values encoded by [NewGobEncoder] are decoded with [gob.Decoder.Decode],
+// and values encoded by [EncodeGobInterface] are stored into v if the
+// registered type is assignable to the type v points to.
+func (g GobValue) Decode(v any) (err error) {
+	if err = gob.NewDecoder(bytes.NewReader(g)).Decode(v); err == nil {
+		return
+	}
+	iface, errIface := g.DecodeInterface()
+	if errIface != nil {
+		return
+	}
+	if dst := reflect.ValueOf(v); dst.Kind() == reflect.Pointer && !dst.IsNil() {
+		if src := reflect.ValueOf(iface); src.IsValid() && src.Type().AssignableTo(dst.Elem().Type()) {
+			dst.Elem().Set(src)
+			return nil
+		}
+	}
+	return
+}
+
+// NewStreamGobDecoder returns a GobDecoder decoding values with a shared
+// [gob.Decoder]. Legacy databases encode all the values with a shared
+// [gob.Encoder], whose type information is only sent with the first value
+// of that type, so values must be decoded in the order they are written.
+func NewStreamGobDecoder() GobDecoder {
 	var gobReaderWrapper byteReaderWrapper
 	var gobDecoder = gob.NewDecoder(&gobReaderWrapper)
 
@@ -0,0 +1,42 @@
+//go:build tinygo || hashive_nogob
+
+package impl
+
+import "errors"
+
+// ErrGobUnsupported is returned by the functions encoding and decoding gob
+// values if encoding/gob is left out by the tinygo or hashive_nogob build
+// tag. Gob encoded values are still read as [GobValue].
+var ErrGobUnsupported = errors.New("gob not supported by this build")
+
+// NewGobEncoder returns a GobEncoder returning [ErrGobUnsupported].
+func NewGobEncoder() GobEncoder {
+	return func(v any) (GobValue, error) {
+		return nil, ErrGobUnsupported
+	}
+}
+
+// NewGobDecoder returns a GobDecoder returning [ErrGobUnsupported].
+func NewGobDecoder() GobDecoder {
+	return GobValue.Decode
+}
+
+// EncodeGobInterface returns [ErrGobUnsupported].
+func EncodeGobInterface(v any) (GobValue, error) {
+	return nil, ErrGobUnsupported
+}
+
+// DecodeInterface returns [ErrGobUnsupported].
+func (g GobValue) DecodeInterface() (v any, err error) {
+	return nil, ErrGobUnsupported
+}
+
+// Decode returns [ErrGobUnsupported].
+func (g GobValue) Decode(v any) (err error) {
+	return ErrGobUnsupported
+}
+
+// NewStreamGobDecoder returns a GobDecoder returning [ErrGobUnsupported].
+func NewStreamGobDecoder() GobDecoder {
+	return GobValue.Decode
+}
@@ -3,12 +3,18 @@ package impl
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"hash/fnv"
 	"io"
+	"iter"
 	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 // typeMarker is a byte that precedes every typed Hashive value.
@@ -21,6 +27,7 @@ func (t typeMarker) Type() typ {
 
 // OffsetSize returns the offset size of t.
 // Offset size is only exists in [typeArray] and [typeObject].
+// For [typeFixedArray], it is the size of length and stride.
 func (t typeMarker) OffsetSize() byte {
 	return byte(t >> 4)
 }
@@ -29,21 +36,164 @@ func newTypeMarker(t typ, size byte) typeMarker {
 	return typeMarker(t) | typeMarker(size<<4)
 }
 
+// writeTypeMarker writes the type mark of t with size to w.
+// Extended types are written as [typeExt] followed by the type.
+func writeTypeMarker(w io.ByteWriter, t typ, size byte) (err error) {
+	if t <= typeExt {
+		return writeByte(w, byte(newTypeMarker(t, size)))
+	}
+	if err = writeByte(w, byte(newTypeMarker(typeExt, size))); err != nil {
+		return
+	}
+	return writeByte(w, byte(t))
+}
+
+// writeHook, if not nil, is called before every write of encoders, and
+// the write fails with the error it returns. It is a test hook injecting
+// write failures, which makes sure that no write error is ignored.
+var writeHook func() error
+
+// writeByte writes b to w.
+func writeByte(w io.ByteWriter, b byte) (err error) {
+	if writeHook != nil {
+		if err = writeHook(); err != nil {
+			return
+		}
+	}
+	return w.WriteByte(b)
+}
+
+// write writes p to w.
+func write(w io.Writer, p []byte) (err error) {
+	if writeHook != nil {
+		if err = writeHook(); err != nil {
+			return
+		}
+	}
+	_, err = w.Write(p)
+	return
+}
+
+// writeString writes s to w.
+func writeString(w io.Writer, s string) (err error) {
+	if writeHook != nil {
+		if err = writeHook(); err != nil {
+			return
+		}
+	}
+	_, err = io.WriteString(w, s)
+	return
+}
+
+// copyBuffers writes the content of bufs to w in order.
+func copyBuffers(w io.Writer, bufs ...*bytes.Buffer) (err error) {
+	for _, buf := range bufs {
+		if writeHook != nil {
+			if err = writeHook(); err != nil {
+				return
+			}
+		}
+		if _, err = buf.WriteTo(w); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// readTypeMarker reads a type mark from r, and returns it with the type,
+// which is read from the next byte for [typeExt].
+func readTypeMarker(r io.ByteReader) (mt typeMarker, t typ, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	mt = typeMarker(b)
+	if t = mt.Type(); t != typeExt {
+		return
+	}
+	if b, err = r.ReadByte(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	if t = typ(b); t <= typeExt {
+		err = fmt.Errorf("invalid extended type %v", t)
+	}
+	return
+}
+
 type typ byte
 
 const (
-	typeNull   typ = iota // JSON null or go nil
-	typeInt               // All signed integers
-	typeUint              // All unsigned integers
-	typeBool              // bool
-	typeString            // string
-	typeFloat             // float64
-	typeBinary            // []byte
-	typeGob               // gob encoded go values
-	typeArray             // []any
-	typeObject            // map[string]any
+	typeNull           typ = iota // JSON null or go nil
+	typeInt                       // All signed integers
+	typeUint                      // All unsigned integers
+	typeBool                      // bool
+	typeString                    // string
+	typeFloat                     // float64
+	typeBinary                    // []byte
+	typeGob                       // gob encoded go values
+	typeArray                     // []any
+	typeObject                    // map[string]any
+	typeFixedArray                // []any whose elements are all of the same encoded size
+	typeRef                       // reference to a value stored elsewhere, see [Ref]
+	typePrefixObject              // map[string]any whose chains are sorted and keys are front-coded
+	typeFixedKeyObject            // map[string]any whose keys are all of the same size, see [Encoder.FixedKeys]
+
+	typeExt typ = 0x0F // extended type, the type is stored in the byte after the type mark
+)
+
+// Extended types, which are stored in the byte after a type mark of [typeExt].
+const (
+	typeIntKeyObject typ = typeExt + 1 + iota // map[string]any whose keys are all integers, see [Encoder.IntKeys]
+	typeIntervals                             // []Interval, see [Interval]
+	typeSortedObject                          // map[string]any whose chains are sorted, see [Encoder.SortedKeys]
+	typeHashedObject                          // map[string]any whose chains are sorted by hash, see [Encoder.HashOrder]
+	typeBlob                                  // []byte stored in a blob file, see [Blob]
+	typeChunked                               // []byte split into chunks, see [Encoder.ChunkSize]
+	typeChunkedArray                          // []any split into chunks, see [Encoder.ArrayChunkSize]
+	typeCompressed                            // []byte compressed with DEFLATE, see [Encoder.Compress]
 )
 
+var typeNames = [...]string{
+	typeNull:           "null",
+	typeInt:            "int",
+	typeUint:           "uint",
+	typeBool:           "bool",
+	typeString:         "string",
+	typeFloat:          "float",
+	typeBinary:         "binary",
+	typeGob:            "gob",
+	typeArray:          "array",
+	typeObject:         "object",
+	typeFixedArray:     "fixedArray",
+	typeRef:            "ref",
+	typePrefixObject:   "prefixObject",
+	typeFixedKeyObject: "fixedKeyObject",
+	typeExt:            "ext",
+	typeIntKeyObject:   "intKeyObject",
+	typeIntervals:      "intervals",
+	typeSortedObject:   "sortedObject",
+	typeHashedObject:   "hashedObject",
+	typeBlob:           "blob",
+	typeChunked:        "chunked",
+	typeChunkedArray:   "chunkedArray",
+	typeCompressed:     "compressed",
+}
+
+// Known reports whether t is a type known to this version.
+func (t typ) Known() bool {
+	return int(t) < len(typeNames) && typeNames[t] != ""
+}
+
+func (t typ) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return "typ(" + strconv.Itoa(int(t)) + ")"
+}
+
 // ByteWriter is the interface that groups the io.Writer and io.ByteWriter.
 type ByteWriter interface {
 	io.Writer
@@ -61,6 +211,8 @@ type bufByteReadSeeker struct {
 	buf       *bufio.Reader
 	bufOffset int64 // the seek offset of r when r is wrapped into buf
 	bufRead   int   // the number of bytes read from buf since wrapping
+	// the range of buffer size, see [NewAdaptiveBufByteReadSeeker]
+	minSize, maxSize int
 }
 
 // NewBufByteReadSeeker wraps r in a [bufio.Reader] and implements [io.ByteReader].
@@ -73,14 +225,82 @@ func NewBufByteReadSeeker(r io.ReadSeeker, bufferSize int) (brs ByteReadSeeker,
 	if err != nil {
 		return
 	}
+	buf := bufio.NewReaderSize(r, bufferSize)
 	return &bufByteReadSeeker{
 		r:         r,
-		buf:       bufio.NewReaderSize(r, bufferSize),
+		buf:       buf,
 		bufOffset: offset,
+		minSize:   buf.Size(),
+		maxSize:   buf.Size(),
+	}, nil
+}
+
+// NewAdaptiveBufByteReadSeeker is like [NewBufByteReadSeeker], but the
+// size of the buffer adapts to how r is read, between minSize and maxSize.
+// Reading more than a buffer without seeking, such as scanning values,
+// doubles the size, and seeking after reading little of the buffer, such as
+// looking up keys, halves the size. Seeking forward within the buffer skips
+// the buffered data instead of discarding the buffer.
+func NewAdaptiveBufByteReadSeeker(r io.ReadSeeker, bufferSize, minSize, maxSize int) (brs ByteReadSeeker, err error) {
+	if brs, err = NewBufByteReadSeeker(r, min(max(bufferSize, minSize), maxSize)); err != nil {
+		return
+	}
+	if br, ok := brs.(*bufByteReadSeeker); ok {
+		br.minSize = min(br.minSize, minSize)
+		br.maxSize = max(br.maxSize, maxSize)
+	}
+	return
+}
+
+// SetBufferSize changes the size of the buffer of r created by
+// [NewBufByteReadSeeker] or [NewAdaptiveBufByteReadSeeker] to size, which
+// does not adapt until restore is called to change it back.
+// If r has no buffer or size <= 0, r is not changed.
+func SetBufferSize(r ByteReadSeeker, size int) (restore func() error, err error) {
+	br, ok := r.(*bufByteReadSeeker)
+	if !ok || size <= 0 {
+		return func() error { return nil }, nil
+	}
+	set := func(size, minSize, maxSize int) (err error) {
+		// Drop the buffered data.
+		current := br.bufOffset + int64(br.bufRead)
+		if _, err = br.r.Seek(current, io.SeekStart); err != nil {
+			return
+		}
+		br.bufOffset, br.bufRead = current, 0
+		br.resize(size)
+		br.minSize, br.maxSize = minSize, maxSize
+		return
+	}
+	oldSize, oldMin, oldMax := br.buf.Size(), br.minSize, br.maxSize
+	if err = set(size, size, size); err != nil {
+		return
+	}
+	return func() error {
+		return set(oldSize, oldMin, oldMax)
 	}, nil
 }
 
+// resize wraps r.r into a buffer of size at the current position.
+func (r *bufByteReadSeeker) resize(size int) {
+	r.bufOffset += int64(r.bufRead)
+	r.bufRead = 0
+	if size == r.buf.Size() {
+		r.buf.Reset(r.r)
+	} else {
+		r.buf = bufio.NewReaderSize(r.r, size)
+	}
+}
+
+// grow doubles the buffer if it has been read through without seeking.
+func (r *bufByteReadSeeker) grow() {
+	if size := r.buf.Size(); size < r.maxSize && r.bufRead >= size && r.buf.Buffered() == 0 {
+		r.resize(min(size*2, r.maxSize))
+	}
+}
+
 func (r *bufByteReadSeeker) Read(p []byte) (n int, err error) {
+	r.grow()
 	n, err = r.buf.Read(p)
 	r.bufRead += n
 	return
@@ -96,20 +316,31 @@ func (r *bufByteReadSeeker) Seek(offset int64, whence int) (n int64, err error)
 		whence = io.SeekStart
 		offset += current
 	}
+	if whence == io.SeekStart && offset == current {
+		return current, nil
+	}
+	if r.minSize < r.maxSize && whence == io.SeekStart && offset > current && offset-current <= int64(r.buf.Buffered()) {
+		// Adaptive buffers skip the buffered data instead of discarding them.
+		n, _ := r.buf.Discard(int(offset - current))
+		r.bufRead += n
+		return offset, nil
+	}
 	n, err = r.r.Seek(offset, whence)
 	if err == nil {
-		advanced := n - current
-		if advanced == 0 {
-			return
+		// The underlying reader is moved, the buffered data is invalid anyway.
+		size := r.buf.Size()
+		if r.bufRead > 0 && r.bufRead < size/8 {
+			// Most of the buffer is read in vain.
+			size = max(size/2, r.minSize)
 		}
-		r.buf.Reset(r.r)
-		r.bufOffset = n
-		r.bufRead = 0
+		r.bufOffset, r.bufRead = n, 0
+		r.resize(size)
 	}
 	return
 }
 
 func (r *bufByteReadSeeker) ReadByte() (b byte, err error) {
+	r.grow()
 	b, err = r.buf.ReadByte()
 	if err == nil {
 		r.bufRead++
@@ -119,6 +350,18 @@ func (r *bufByteReadSeeker) ReadByte() (b byte, err error) {
 
 var littleEndian = binary.LittleEndian
 
+// minOffsetSize returns the minimal size of the offsets of a table, which can
+// store the largest offset maxOffset(size) of the table of offsets of size.
+// maxOffset must not decrease as size increases.
+func minOffsetSize(maxOffset func(size byte) uint64) (size byte) {
+	for size = 1; size < 8; size++ {
+		if fixedUintSize(maxOffset(size)) <= size {
+			break
+		}
+	}
+	return
+}
+
 // fixedUintSize returns the minimum byte size to store n.
 func fixedUintSize(n uint64) byte {
 	if n > 0xFF_FF_FF_FF_FF_FF_FF {
@@ -168,73 +411,72 @@ func writeFixedUint(w io.Writer, n uint64, size byte) (err error) {
 		err = fmt.Errorf("invalid size %v", size)
 		return
 	}
-	_, err = w.Write(buf[:size])
-	return
+	return write(w, buf[:size])
 }
 
 // readFixedUint reads a byte sequence from r and convert it to a unsigned integer.
 func readFixedUint(r ByteReadSeeker, size byte) (n uint64, err error) {
-	var buf [8]byte // size of uint64
-	if _, err = io.ReadFull(r, buf[:size]); err != nil {
-		return
-	}
-	switch size {
-	case 1:
-		n = uint64(buf[0])
-	case 2:
-		n = uint64(littleEndian.Uint16(buf[:]))
-	case 3, 4:
-		n = uint64(littleEndian.Uint32(buf[:]))
-	case 5, 6, 7, 8:
-		n = littleEndian.Uint64(buf[:])
-	default:
+	if size < 1 || size > 8 { // size of uint64
 		err = fmt.Errorf("invalid size %v", size)
 		return
 	}
+	// Read byte by byte, a buffer passed to r.Read escapes to heap.
+	for i := range size {
+		var b byte
+		if b, err = r.ReadByte(); err != nil {
+			if err == io.EOF && i > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		n |= uint64(b) << (8 * i)
+	}
 	return
 }
 
+// maxUintValueSize is the max size of an encoded unsigned integer.
+const maxUintValueSize = 9
+
+// appendUintValue appends the variable-length encoding of n to dst.
+// Integers less than 128 are stored as one byte, others are stored as
+// the negative number of bytes followed by the little-endian bytes of n.
+func appendUintValue(dst []byte, n uint64) []byte {
+	if n <= math.MaxInt8 {
+		return append(dst, byte(n))
+	}
+	size := (bits.Len64(n) + 7) / 8
+	dst = append(dst, -byte(size))
+	for range size {
+		dst = append(dst, byte(n))
+		n >>= 8
+	}
+	return dst
+}
+
 // writeUintValue write n to w without the type mark.
 // Argument n is encoded with a variable-length encoding.
 func writeUintValue(w io.Writer, n uint64) (err error) {
-	var buf = make([]byte, 9)
-	if n <= math.MaxInt8 {
-		buf[0] = byte(n)
-		buf = buf[:1]
-	} else if n <= 0xFF {
-		buf[0] = ^byte(1) + 1 // -1
-		buf[1] = byte(n)
-		buf = buf[:2]
-	} else if n <= 0xFF_FF {
-		buf[0] = ^byte(2) + 1 // -2
-		littleEndian.PutUint16(buf[1:], uint16(n))
-		buf = buf[:3]
-	} else if n <= 0xFF_FF_FF {
-		buf[0] = ^byte(3) + 1 // -3
-		littleEndian.PutUint32(buf[1:], uint32(n))
-		buf = buf[:4]
-	} else if n <= 0xFF_FF_FF_FF {
-		buf[0] = ^byte(4) + 1 // -4
-		littleEndian.PutUint32(buf[1:], uint32(n))
-		buf = buf[:5]
-	} else if n <= 0xFF_FF_FF_FF_FF {
-		buf[0] = ^byte(5) + 1 // -5
-		littleEndian.PutUint64(buf[1:], n)
-		buf = buf[:6]
-	} else if n <= 0xFF_FF_FF_FF_FF_FF {
-		buf[0] = ^byte(6) + 1 // -6
-		littleEndian.PutUint64(buf[1:], n)
-		buf = buf[:7]
-	} else if n <= 0xFF_FF_FF_FF_FF_FF_FF {
-		buf[0] = ^byte(7) + 1 // -7
-		littleEndian.PutUint64(buf[1:], n)
-		buf = buf[:8]
-	} else {
-		buf[0] = ^byte(8) + 1 // -8
-		littleEndian.PutUint64(buf[1:], n)
-		buf = buf[:9]
+	switch w := w.(type) {
+	case *bytes.Buffer:
+		var buf [maxUintValueSize]byte // Does not escape.
+		err = write(w, appendUintValue(buf[:0], n))
+	case io.ByteWriter:
+		if n <= math.MaxInt8 {
+			return writeByte(w, byte(n))
+		}
+		size := (bits.Len64(n) + 7) / 8
+		if err = writeByte(w, -byte(size)); err != nil {
+			return
+		}
+		for range size {
+			if err = writeByte(w, byte(n)); err != nil {
+				return
+			}
+			n >>= 8
+		}
+	default:
+		err = write(w, appendUintValue(make([]byte, 0, maxUintValueSize), n))
 	}
-	_, err = w.Write(buf)
 	return
 }
 
@@ -247,15 +489,17 @@ func readUintValue(r ByteReadSeeker) (n uint64, err error) {
 	}
 	if b0 <= math.MaxInt8 {
 		return uint64(b0), nil
-	} else {
-		length := ^(b0 - 1) // length = -b0
-		return readFixedUint(r, length)
 	}
+	n, err = readFixedUint(r, -b0) // size = -b0
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
 }
 
 // WriteUint writes n with a variable-length encoding.
 func WriteUint(w ByteWriter, n uint64) (err error) {
-	if err = w.WriteByte(byte(typeUint)); err != nil {
+	if err = writeByte(w, byte(typeUint)); err != nil {
 		return
 	}
 	err = writeUintValue(w, n)
@@ -282,7 +526,7 @@ func WriteBool(w ByteWriter, b bool) (err error) {
 	if b {
 		n = 1
 	}
-	if err = w.WriteByte(byte(typeBool)); err != nil {
+	if err = writeByte(w, byte(typeBool)); err != nil {
 		return
 	}
 	err = writeUintValue(w, n)
@@ -322,7 +566,7 @@ func ReadBool(r ByteReadSeeker) (b bool, err error) {
 
 // WriteInt writes a signed integer to w.
 func WriteInt(w ByteWriter, n int64) (err error) {
-	if err = w.WriteByte(byte(typeInt)); err != nil {
+	if err = writeByte(w, byte(typeInt)); err != nil {
 		return
 	}
 	err = writeUintValue(w, int2Uint(n))
@@ -349,7 +593,7 @@ func int2Uint(n int64) uint64 {
 func uint2Int(u uint64) int64 {
 	// See int2Uint.
 	if u&1 == 1 {
-		return int64(^(u >> 1) | 0x80000000)
+		return int64(^(u >> 1))
 	} else {
 		return int64(u >> 1)
 	}
@@ -396,7 +640,7 @@ func WriteFloat(w ByteWriter, f float64) (err error) {
 	// Floating-point numbers are always sent as a representation of a float64 value.
 	// That value is converted to a uint64 using math.Float64bits.
 	// The uint64 is then byte-reversed and sent as a regular unsigned integer.
-	if err = w.WriteByte(byte(typeFloat)); err != nil {
+	if err = writeByte(w, byte(typeFloat)); err != nil {
 		return
 	}
 	u := reverseBytes(math.Float64bits(f))
@@ -430,11 +674,11 @@ func ReadFloat(r ByteReadSeeker) (f float64, err error) {
 // writeBinary writes a byte sequence([]byte) to w with type t.
 // The argument t should be [typeString], [typeBinary] or [typeGob].
 func writeBinary(w ByteWriter, t typ, p []byte) (err error) {
-	if err = w.WriteByte(byte(t)); err != nil {
+	if err = writeByte(w, byte(t)); err != nil {
 		return
 	}
 	if err = writeUintValue(w, uint64(len(p))); err == nil {
-		_, err = w.Write(p)
+		err = write(w, p)
 	}
 	return
 }
@@ -442,11 +686,41 @@ func writeBinary(w ByteWriter, t typ, p []byte) (err error) {
 // writeBinaryValue writes p to w without a type mark.
 func writeBinaryValue(w io.Writer, p []byte) (err error) {
 	if err = writeUintValue(w, uint64(len(p))); err == nil {
-		_, err = w.Write(p)
+		err = write(w, p)
+	}
+	return
+}
+
+// writeStringValue writes s to w without a type mark.
+// It is the same as writeBinaryValue(w, []byte(s)) without converting s.
+func writeStringValue(w io.Writer, s string) (err error) {
+	if err = writeUintValue(w, uint64(len(s))); err == nil {
+		err = writeString(w, s)
 	}
 	return
 }
 
+// maxPrealloc is the max number of bytes or elements allocated in advance
+// according to lengths read from streams, so that corrupted lengths fail
+// with io.ErrUnexpectedEOF instead of exhausting memory.
+const maxPrealloc = 1 << 20
+
+// readBytes reads exactly n bytes from r. Allocation grows with the data
+// read if n is larger than [maxPrealloc].
+func readBytes(r io.Reader, n int) (p []byte, err error) {
+	if n <= maxPrealloc {
+		p = make([]byte, n)
+		_, err = io.ReadFull(r, p)
+		return
+	}
+	var buf bytes.Buffer
+	buf.Grow(maxPrealloc)
+	if _, err = io.CopyN(&buf, r, int64(n)); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
 // readBinaryValue reads a byte sequence form r after the type mark.
 func readBinaryValue(r ByteReadSeeker) (p []byte, err error) {
 	length, err := readUintValue(r)
@@ -457,22 +731,131 @@ func readBinaryValue(r ByteReadSeeker) (p []byte, err error) {
 		err = fmt.Errorf("failed to read binary: invalid length %v", length)
 		return
 	}
-	p = make([]byte, length)
-	_, err = io.ReadFull(r, p)
+	return readBytes(r, int(length))
+}
+
+// readBinaryLength reads the type mark and length of a [typeString],
+// [typeBinary] or [typeGob] from r, which is then positioned at the bytes.
+// A [typeChunked] is read as a [typeBinary]. A [typeCompressed] is read
+// and decompressed as inflated, which is not nil, and r is positioned at
+// the end of it.
+func readBinaryLength(r ByteReadSeeker, t typ) (length uint64, inflated []byte, err error) {
+	mt, destT, err := readTypeMarker(r)
+	if err != nil {
+		return
+	}
+	if destT == typeCompressed && t == typeBinary {
+		if inflated, err = readCompressedValue(r); err != nil {
+			return
+		}
+		return uint64(len(inflated)), inflated, nil
+	}
+	if destT == typeChunked && t == typeBinary {
+		var c *Chunked
+		if c, err = readChunkedValue(r, mt.OffsetSize()); err != nil {
+			return
+		}
+		// Chunks are stored in order after the offset table.
+		return uint64(c.length), nil, nil
+	}
+	if destT != t {
+		return 0, nil, &TypeError{destT}
+	}
+	length, err = readUintValue(r)
+	return
+}
+
+// appendBinary reads a [typeString], [typeBinary] or [typeGob] from r
+// and appends it to dst.
+func appendBinary(dst []byte, r ByteReadSeeker, t typ) (p []byte, err error) {
+	length, inflated, err := readBinaryLength(r, t)
+	if err != nil {
+		if _, ok := err.(*TypeError); ok {
+			err = fmt.Errorf("failed to read binary: %w", err)
+		}
+		return
+	}
+	if inflated != nil {
+		return append(dst, inflated...), nil
+	}
+	if length > uint64(math.MaxInt-len(dst)) {
+		err = fmt.Errorf("failed to read binary: invalid length %v", length)
+		return
+	}
+	if length > maxPrealloc {
+		var data []byte
+		if data, err = readBytes(r, int(length)); err != nil {
+			return dst, err
+		}
+		return append(dst, data...), nil
+	}
+	p = slices.Grow(dst, int(length))
+	if _, err = io.ReadFull(r, p[len(dst):len(dst)+int(length)]); err != nil {
+		return dst, err
+	}
+	return p[:len(dst)+int(length)], nil
+}
+
+// ReadBytesHeader reads the header of a string, binary, chunked, compressed
+// or [Blob] value from r. For blobs, the blob is returned. For compressed
+// values, the decompressed bytes are returned as inflated. Otherwise, the
+// length of the value is returned, and r is positioned at the bytes of the
+// value.
+func ReadBytesHeader(r ByteReadSeeker) (length uint64, blob *Blob, inflated []byte, err error) {
+	mt, t, err := readTypeMarker(r)
+	if err != nil {
+		return
+	}
+	switch t {
+	case typeString, typeBinary:
+		length, err = readUintValue(r)
+	case typeChunked:
+		var c *Chunked
+		if c, err = readChunkedValue(r, mt.OffsetSize()); err == nil {
+			length = uint64(c.length)
+		}
+	case typeBlob:
+		var b Blob
+		if b, err = readBlobValue(r); err == nil {
+			blob = &b
+		}
+	case typeCompressed:
+		if inflated, err = readCompressedValue(r); err == nil {
+			length = uint64(len(inflated))
+		}
+	default:
+		err = &TypeError{t}
+	}
 	return
 }
 
+// AppendString reads a string from r and appends it to dst.
+func AppendString(dst []byte, r ByteReadSeeker) ([]byte, error) {
+	return appendBinary(dst, r, typeString)
+}
+
+// AppendBinary reads a byte sequence from r and appends it to dst.
+func AppendBinary(dst []byte, r ByteReadSeeker) ([]byte, error) {
+	return appendBinary(dst, r, typeBinary)
+}
+
 // readBinary reads a [typeString], [typeBinary] or [typeGob] from r.
 func readBinary(r ByteReadSeeker, t typ) (p []byte, err error) {
-	tb, err := r.ReadByte()
+	length, inflated, err := readBinaryLength(r, t)
 	if err != nil {
+		if typeErr, ok := err.(*TypeError); ok {
+			err = fmt.Errorf("failed to read binary: invalid type %v", typeErr.t)
+		}
 		return
 	}
-	if destT := typeMarker(tb).Type(); destT != t {
-		err = fmt.Errorf("failed to read binary: invalid type %v", destT)
+	if inflated != nil {
+		return inflated, nil
+	}
+	if length > math.MaxInt {
+		err = fmt.Errorf("failed to read binary: invalid length %v", length)
 		return
 	}
-	return readBinaryValue(r)
+	return readBytes(r, int(length))
 }
 
 // WriteBinary writes a byte sequence to w.
@@ -487,32 +870,98 @@ func ReadBinary(r ByteReadSeeker) (p []byte, err error) {
 
 // readStringValue reads a [typeString] from r after the type mark.
 func readStringValue(r ByteReadSeeker) (s string, err error) {
-	p, err := readBinaryValue(r)
+	length, err := readUintValue(r)
 	if err != nil {
 		return
 	}
-	s = string(p)
+	if length > math.MaxInt {
+		err = fmt.Errorf("failed to read string: invalid length %v", length)
+		return
+	}
+	if length > maxPrealloc {
+		var data []byte
+		data, err = readBytes(r, int(length))
+		return string(data), err
+	}
+	// Read into a pooled buffer, string(p) copies anyway.
+	p := getBytes(int(length))
+	defer putBytes(p)
+	if _, err = io.ReadFull(r, *p); err != nil {
+		return
+	}
+	s = string(*p)
 	return
 }
 
+// readStringBytes reads a [typeString] from r after the type mark as []byte,
+// which is a view of the underlying slice if r is a [*SliceReader].
+func readStringBytes(r ByteReadSeeker) (p []byte, err error) {
+	length, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt {
+		err = fmt.Errorf("failed to read string: invalid length %v", length)
+		return
+	}
+	if sr, ok := r.(*SliceReader); ok {
+		return sr.View(int(length))
+	}
+	return readBytes(r, int(length))
+}
+
 // ReadString reads a string from r.
 func ReadString(r ByteReadSeeker) (s string, err error) {
-	p, err := readBinary(r, typeString)
+	tb, err := r.ReadByte()
 	if err != nil {
 		return
 	}
-	s = string(p)
-	return
+	if t := typeMarker(tb).Type(); t != typeString {
+		err = fmt.Errorf("failed to read binary: invalid type %v", t)
+		return
+	}
+	return readStringValue(r)
 }
 
 // WriteString writes a string to w.
 func WriteString(w ByteWriter, s string) (err error) {
-	return writeBinary(w, typeString, []byte(s))
+	if err = writeByte(w, byte(typeString)); err != nil {
+		return
+	}
+	return writeStringValue(w, s)
 }
 
 // WriteGob writes the gob encoding of v to w.
+// An [*EncodeError] is returned if v can't be gob encoded.
 func WriteGob(w ByteWriter, v any, encode GobEncoder) (err error) {
-	return writeBinary(w, typeGob, encode(v))
+	p, err := encode(v)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	return writeBinary(w, typeGob, p)
+}
+
+// EncodeError is returned when a value can't be encoded.
+type EncodeError struct {
+	Path []string // The path to the value, see [EncodeError.Error].
+	Err  error
+}
+
+func (err *EncodeError) Error() string {
+	return fmt.Sprintf("failed to encode value at /%v: %v", strings.Join(err.Path, "/"), err.Err)
+}
+
+func (err *EncodeError) Unwrap() error {
+	return err.Err
+}
+
+// EncodeErrorAt returns err with key prepended to its path if err is an
+// [*EncodeError] of a value in a container, or err itself otherwise.
+func EncodeErrorAt(err error, key string) error {
+	if encodeErr, ok := err.(*EncodeError); ok {
+		encodeErr.Path = slices.Insert(encodeErr.Path, 0, key)
+	}
+	return err
 }
 
 // readGobValue reads a GobValue from r.
@@ -526,9 +975,12 @@ func readGobValue(r ByteReadSeeker) (gob GobValue, err error) {
 }
 
 // GobValue is the gob encoded value.
-// Use [GobGobDecoder] to decode the value.
+// Use [GobValue.Decode] to decode the value.
 type GobValue []byte
 
+type GobEncoder func(v any) (GobValue, error)
+type GobDecoder func(gob GobValue, v any) error
+
 // ReadGob reads gob encoded value from r.
 func ReadGob(r ByteReadSeeker) (gob GobValue, err error) {
 	p, err := readBinary(r, typeGob)
@@ -539,10 +991,85 @@ func ReadGob(r ByteReadSeeker) (gob GobValue, err error) {
 	return
 }
 
+// Raw is an encoded value, which is written as is by [Encoder.WriteValue].
+// It is used to encode values in advance, such as concurrently.
+type Raw []byte
+
+// Ref is the position of a value in the underlying reader. It is written by
+// [WriteValue] in place of the value it references, which must be written
+// before the ref, and is read as the referenced value.
+// It is used to store identical values only once.
+type Ref uint64
+
+// RefSize is the encoded size of a [Ref].
+const RefSize = 1 + 8 // type mark and position
+
+// WriteRef writes ref to w.
+// Refs are written with fixed size, so the size of a value does not depend
+// on the positions it references.
+func WriteRef(w ByteWriter, ref Ref) (err error) {
+	if err = writeByte(w, byte(newTypeMarker(typeRef, 8))); err != nil {
+		return
+	}
+	return writeFixedUint(w, uint64(ref), 8)
+}
+
+// Blob is a binary value of Size bytes stored at Offset of a blob file
+// instead of the database, so that large values don't bloat the database.
+type Blob struct {
+	Offset, Size uint64
+}
+
+// WriteBlob writes blob to w.
+func WriteBlob(w ByteWriter, blob Blob) (err error) {
+	if err = writeTypeMarker(w, typeBlob, 0); err != nil {
+		return
+	}
+	if err = writeUintValue(w, blob.Offset); err != nil {
+		return
+	}
+	return writeUintValue(w, blob.Size)
+}
+
+// readBlobValue reads a [Blob] from r after the type mark.
+func readBlobValue(r ByteReadSeeker) (blob Blob, err error) {
+	if blob.Offset, err = readUintValue(r); err != nil {
+		return
+	}
+	blob.Size, err = readUintValue(r)
+	return
+}
+
+// readRefValue reads the value referenced by a ref of size after the type mark.
+// The value must be in front of the ref, so refs can't form cycles.
+// On success, r is positioned at the end of the ref.
+func readRefValue(r ByteReadSeeker, size byte, recursive bool, parent depthLimit) (v any, err error) {
+	pos, err := readFixedUint(r, size)
+	if err != nil {
+		return
+	}
+	end, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if start := end - 1 - int64(size); pos >= uint64(start) {
+		err = fmt.Errorf("invalid ref %v at %v", pos, start)
+		return
+	}
+	if _, err = r.Seek(int64(pos), io.SeekStart); err != nil {
+		return
+	}
+	if v, err = readValue(r, recursive, parent); err != nil {
+		return
+	}
+	_, err = r.Seek(end, io.SeekStart)
+	return
+}
+
 // WriteNull writes a null.
 // JSON null and go nil are encoded as null.
 func WriteNull(w ByteWriter) (err error) {
-	return w.WriteByte(byte(typeNull))
+	return writeByte(w, byte(typeNull))
 }
 
 // WriteValue writes v to w.
@@ -554,13 +1081,72 @@ func WriteNull(w ByteWriter) (err error) {
 //   - map[string]any is stored as associated object.
 //   - All the others types are stored as gob encoded binary data.
 func WriteValue(w ByteWriter, v any, gobEncoder GobEncoder) (err error) {
+	return (&Encoder{Gob: gobEncoder}).WriteValue(w, v)
+}
+
+// Encoder writes values with options.
+type Encoder struct {
+	Gob GobEncoder // The encoder of gob encoded values.
+	// FrontCoding sorts the chains of objects by key and stores every key
+	// as the length of the prefix shared with the previous key followed by
+	// the rest of the key.
+	FrontCoding bool
+	// FixedKeys writes objects whose keys are all of the same size with
+	// keys sorted and packed, which are binary searched instead of hashed.
+	FixedKeys bool
+	// IntKeys writes objects whose keys are all integers in canonical decimal
+	// form with keys stored as sorted integers, which can be queried by range.
+	// It takes precedence over FixedKeys.
+	IntKeys bool
+	// SortedKeys sorts the chains of objects by key, so that lookups stop
+	// at the first greater key, and objects are written the same regardless
+	// of the order of map iteration.
+	SortedKeys bool
+	// HashOrder sorts the chains of objects by the hashes of keys, which are
+	// stored before the keys, so that lookups compare hashes instead of
+	// keys and stop at the first greater hash. It takes precedence over
+	// SortedKeys.
+	HashOrder bool
+	// Legacy writes arrays in the layout of the original format, which
+	// stores the offsets of all the elements.
+	Legacy bool
+	// ChunkSize, if positive, splits []byte values longer than
+	// ChunkThreshold into chunks of ChunkSize bytes, so that any range of
+	// them can be read without reading the bytes before it.
+	ChunkSize      int
+	ChunkThreshold int
+	// PrimeTable chooses the bucket counts of objects from a precomputed
+	// table of primes, each about 5/4 of the previous one, instead of
+	// searching for the prime nearest to the number of keys of every object.
+	PrimeTable bool
+	// Pow2Buckets makes the bucket counts of objects powers of two, whose
+	// buckets are chosen by multiply-shift hashing instead of modulo, so
+	// that lookups take no 64-bit division. It takes precedence over
+	// PrimeTable.
+	Pow2Buckets bool
+	// MaxBuckets, if positive, limits the bucket counts of objects to
+	// MaxBuckets, rounded down to a power of two with Pow2Buckets, so that
+	// the chains are as long as the worst cases of hashing, for testing.
+	MaxBuckets int
+	// ArrayChunkSize, if positive, splits arrays of more elements into
+	// chunks of ArrayChunkSize elements, so that arrays of unknown length
+	// are written a chunk at a time. See [Encoder.AppendFrom].
+	ArrayChunkSize int
+	// Compress, if not nil, is called with every []byte value not split
+	// into chunks, and the values for which it returns true are compressed
+	// with DEFLATE, unless they are not smaller compressed.
+	Compress func(p []byte) bool
+}
+
+// WriteValue writes v to w. See [WriteValue] for how v is stored.
+func (e *Encoder) WriteValue(w ByteWriter, v any) (err error) {
 	switch value := v.(type) {
 	case nil:
 		return WriteNull(w)
 	case int8:
-		return WriteUint(w, uint64(value))
-	case uint8:
 		return WriteInt(w, int64(value))
+	case uint8:
+		return WriteUint(w, uint64(value))
 	case int16:
 		return WriteInt(w, int64(value))
 	case uint16:
@@ -586,68 +1172,257 @@ func WriteValue(w ByteWriter, v any, gobEncoder GobEncoder) (err error) {
 	case float64:
 		return WriteFloat(w, value)
 	case []byte:
+		if e.ChunkSize > 0 && len(value) > e.ChunkThreshold {
+			return writeChunked(w, value, e.ChunkSize)
+		}
+		if e.Compress != nil && e.Compress(value) {
+			return writeCompressed(w, value)
+		}
 		return WriteBinary(w, value)
 	case []any:
-		return WriteArray(w, value, gobEncoder)
+		return e.WriteArray(w, value)
 	case map[string]any:
-		return WriteObject(w, value, gobEncoder)
+		return e.WriteObject(w, value)
+	case Ref:
+		return WriteRef(w, value)
+	case Blob:
+		return WriteBlob(w, value)
+	case []Interval:
+		return e.WriteIntervals(w, value)
+	case Raw:
+		return write(w, value)
+	case GobValue:
+		// Already encoded, such as read from another database.
+		return writeBinary(w, typeGob, value)
 	default:
-		return WriteGob(w, v, gobEncoder)
+		return WriteGob(w, v, e.Gob)
 	}
 }
 
 // WriteArray writes an array to w.
+// If all the elements have the same encoded size, the array is written as
+// [typeFixedArray] and only the size of the elements(stride) is stored
+// instead of the offset table.
 func WriteArray(w io.Writer, array []any, gobEncoder GobEncoder) (err error) {
-	var offsets = make([]int, len(array))
-	var data bytes.Buffer
-	for i, elem := range array {
-		offsets[i] = data.Len()
-		WriteValue(&data, elem, gobEncoder)
+	return (&Encoder{Gob: gobEncoder}).WriteArray(w, array)
+}
+
+// WriteArray writes an array to w. See [WriteArray] for the layout.
+func (e *Encoder) WriteArray(w io.Writer, array []any) (err error) {
+	return e.appendFrom(w, slices.Values(array), len(array))
+}
+
+// AppendFrom writes the array of the elements yielded by seq to w, whose
+// length is not known until seq ends, such as the rows of a database
+// cursor. The elements are encoded as they are yielded, and the offset
+// table is written after seq ends. See [WriteArray] for the layout.
+func (e *Encoder) AppendFrom(w io.Writer, seq iter.Seq[any]) (err error) {
+	return e.appendFrom(w, seq, 0)
+}
+
+// appendFrom is [Encoder.AppendFrom] with the hint of the length of the array.
+func (e *Encoder) appendFrom(w io.Writer, seq iter.Seq[any], sizeHint int) (err error) {
+	chunkSize := e.ArrayChunkSize
+	if e.Legacy {
+		chunkSize = 0
+	} else if chunkSize > 0 {
+		sizeHint = min(sizeHint, chunkSize)
+	}
+	var offsets = make([]int, 0, sizeHint)
+	data := getBuffer()
+	defer putBuffer(data)
+	var chunks *chunkedArrayWriter
+	length := 0
+	for elem := range seq {
+		if chunkSize > 0 && len(offsets) == chunkSize {
+			// The array is longer than a chunk, write the full chunk.
+			if chunks == nil {
+				chunks = newChunkedArrayWriter()
+				defer chunks.release()
+			}
+			if err = chunks.add(e, offsets, data); err != nil {
+				return
+			}
+			offsets = offsets[:0]
+			data.Reset()
+		}
+		offsets = append(offsets, data.Len())
+		if err = e.WriteValue(data, elem); err != nil {
+			return EncodeErrorAt(err, strconv.Itoa(length))
+		}
+		length++
 	}
-
+	if chunks == nil {
+		return e.writeArrayData(w, offsets, data)
+	}
+	if err = chunks.add(e, offsets, data); err != nil {
+		return
+	}
+	return chunks.writeTo(w, length, chunkSize)
+}
+
+// writeArrayData writes the array of the encoded elements in data to w,
+// whose offsets in data are offsets. offsets is modified.
+func (e *Encoder) writeArrayData(w io.Writer, offsets []int, data *bytes.Buffer) (err error) {
+	if stride, ok := fixedStride(offsets, data.Len()); ok && !e.Legacy {
+		return writeFixedArray(w, len(offsets), stride, data)
+	}
+
 	var maxOffset = 0
 	if len(offsets) > 0 {
 		maxOffset = offsets[len(offsets)-1]
 	}
-	offsetSize := fixedUintSize(uint64(maxOffset))
 	// offsetSize must be large enough to hold the max offset plus the size of offset section.
-	for offsetSize < fixedUintSize(uint64(maxOffset+len(array)*int(offsetSize))) {
-		offsetSize *= 2
-		if offsetSize > 8 {
-			err = fmt.Errorf("invalid offset size %v", offsetSize)
-			return
-		}
-	}
+	offsetSize := minOffsetSize(func(size byte) uint64 {
+		return uint64(maxOffset) + uint64(len(offsets))*uint64(size)
+	})
 
 	// Fix offsets
-	delta := len(array) * int(offsetSize)
+	delta := len(offsets) * int(offsetSize)
 	for i := range offsets {
 		offsets[i] += delta
 	}
 
-	var buf bytes.Buffer
-	buf.WriteByte(byte(newTypeMarker(typeArray, offsetSize)))
-	writeFixedUint(&buf, uint64(len(array)), offsetSize)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err = writeTypeMarker(buf, typeArray, offsetSize); err != nil {
+		return
+	}
+	if err = writeFixedUint(buf, uint64(len(offsets)), offsetSize); err != nil {
+		return
+	}
 	for _, offset := range offsets {
-		writeFixedUint(&buf, uint64(offset), offsetSize)
+		if err = writeFixedUint(buf, uint64(offset), offsetSize); err != nil {
+			return
+		}
 	}
-	io.Copy(&buf, &data)
+	return copyBuffers(w, buf, data)
+}
 
-	_, err = io.Copy(w, &buf)
-	return
+// fixedStride returns the size of every element if all the elements
+// have the same encoded size. Argument offsets is the offset of each
+// element and dataLen is the total size of elements.
+func fixedStride(offsets []int, dataLen int) (stride int, ok bool) {
+	if len(offsets) == 0 {
+		return
+	}
+	stride = dataLen - offsets[len(offsets)-1]
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i]-offsets[i-1] != stride {
+			return 0, false
+		}
+	}
+	return stride, true
+}
+
+// writeFixedArray writes a [typeFixedArray] to w.
+// The layout is: type mark, length, stride and then the elements.
+// Length and stride are stored with the same size in the type mark.
+func writeFixedArray(w io.Writer, length, stride int, data *bytes.Buffer) (err error) {
+	size := fixedUintSize(uint64(max(length, stride)))
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err = writeTypeMarker(buf, typeFixedArray, size); err != nil {
+		return
+	}
+	if err = writeFixedUint(buf, uint64(length), size); err != nil {
+		return
+	}
+	if err = writeFixedUint(buf, uint64(stride), size); err != nil {
+		return
+	}
+	return copyBuffers(w, buf, data)
+}
+
+// DefaultMaxDepth is the default max nesting depth of arrays and objects.
+const DefaultMaxDepth = 10000
+
+// DepthError is returned when the nesting depth of arrays and objects
+// exceeds the limit.
+type DepthError struct {
+	MaxDepth int
+}
+
+func (err *DepthError) Error() string {
+	return fmt.Sprintf("max depth %v of nested arrays and objects exceeded", err.MaxDepth)
+}
+
+// KeyLengthError is returned when the length of a key of an object
+// exceeds the limit.
+type KeyLengthError struct {
+	Length       uint64
+	MaxKeyLength int
+}
+
+func (err *KeyLengthError) Error() string {
+	return fmt.Sprintf("key length %v exceeds max key length %v", err.Length, err.MaxKeyLength)
+}
+
+// depthLimit tracks the nesting depth of arrays and objects, and how the
+// values in them are read.
+type depthLimit struct {
+	depth, max int  // the depth of a container and the max depth allowed
+	maxKey     int  // the max length of keys, or 0 if unlimited
+	bytes      bool // whether strings are read as []byte, see [ReadValueBytes]
+}
+
+// rootLimit returns the depth limit of the parent of a root value, whose
+// objects have keys of at most maxKeyLength if it is positive.
+func rootLimit(maxDepth, maxKeyLength int) depthLimit {
+	return depthLimit{depth: 0, max: maxDepth, maxKey: max(maxKeyLength, 0)}
+}
+
+// checkKey returns a [*KeyLengthError] if length exceeds the limit of keys.
+func (l depthLimit) checkKey(length uint64) error {
+	if l.maxKey > 0 && length > uint64(l.maxKey) {
+		return &KeyLengthError{length, l.maxKey}
+	}
+	return nil
+}
+
+// child returns the depth limit of a child container.
+func (l depthLimit) child() (depthLimit, error) {
+	if l.depth >= l.max {
+		return l, &DepthError{l.max}
+	}
+	l.depth++
+	return l, nil
 }
 
 // ReadValue reads a value from r.
 // See [WriteValue] for the the type of v.
 // If recursive is false, arrays and maps are returned as [Array] and [Object],
 // otherwise they are returned as []any and map[string]any.
+// The nesting depth is limited by [DefaultMaxDepth].
 func ReadValue(r ByteReadSeeker, recursive bool) (v any, err error) {
-	tb, err := r.ReadByte()
+	return readValue(r, recursive, rootLimit(DefaultMaxDepth, 0))
+}
+
+// ReadValueDepth is like [ReadValue], but a [*DepthError] is returned if the
+// nesting depth of arrays and objects exceeds maxDepth, and a
+// [*KeyLengthError] is returned if a key read from objects is longer than
+// maxKeyLength, unless maxKeyLength is 0.
+func ReadValueDepth(r ByteReadSeeker, recursive bool, maxDepth, maxKeyLength int) (v any, err error) {
+	return readValue(r, recursive, rootLimit(maxDepth, maxKeyLength))
+}
+
+// ReadValueBytes is like [ReadValueDepth] with recursive true, but strings
+// are read as []byte, which are views of the underlying slice if r is a
+// [*SliceReader]. The value is in a container at depth, or is the root
+// value if depth is 0.
+func ReadValueBytes(r ByteReadSeeker, depth, maxDepth, maxKeyLength int) (v any, err error) {
+	limit := rootLimit(maxDepth, maxKeyLength)
+	limit.depth, limit.bytes = depth, true
+	return readValue(r, true, limit)
+}
+
+// readValue reads a value in a container of parent limit from r.
+func readValue(r ByteReadSeeker, recursive bool, parent depthLimit) (v any, err error) {
+	mt, t, err := readTypeMarker(r)
 	if err != nil {
 		return
 	}
-	mt := typeMarker(tb)
-	switch t := mt.Type(); t {
+	switch t {
 	case typeNull:
 		// NOP
 	case typeInt:
@@ -669,6 +1444,14 @@ func ReadValue(r ByteReadSeeker, recursive bool) (v any, err error) {
 		}
 		v = b
 	case typeString:
+		if parent.bytes {
+			var p []byte
+			if p, err = readStringBytes(r); err != nil {
+				return
+			}
+			v = p
+			break
+		}
 		var s string
 		if s, err = readStringValue(r); err != nil {
 			return
@@ -692,9 +1475,12 @@ func ReadValue(r ByteReadSeeker, recursive bool) (v any, err error) {
 			return
 		}
 		v = g
-	case typeArray:
+	case typeArray, typeFixedArray, typeChunkedArray:
 		var array *Array
-		if array, err = readArrayValue(r, mt.OffsetSize()); err != nil {
+		if array, err = readArrayOfType(r, t, mt.OffsetSize()); err != nil {
+			return
+		}
+		if array.limit, err = parent.child(); err != nil {
 			return
 		}
 		if !recursive {
@@ -706,9 +1492,12 @@ func ReadValue(r ByteReadSeeker, recursive bool) (v any, err error) {
 			return
 		}
 		v = value
-	case typeObject:
+	case typeObject, typePrefixObject, typeFixedKeyObject, typeIntKeyObject, typeSortedObject, typeHashedObject:
 		var obj *Object
-		if obj, err = readObjectValue(r, mt.OffsetSize()); err != nil {
+		if obj, err = readObjectValue(r, t, mt.OffsetSize()); err != nil {
+			return
+		}
+		if obj.limit, err = parent.child(); err != nil {
 			return
 		}
 		if !recursive {
@@ -720,12 +1509,117 @@ func ReadValue(r ByteReadSeeker, recursive bool) (v any, err error) {
 			return
 		}
 		v = value
+	case typeRef:
+		v, err = readRefValue(r, mt.OffsetSize(), recursive, parent)
+	case typeIntervals:
+		var intervals *Intervals
+		if intervals, err = readIntervalsValue(r, mt.OffsetSize()); err != nil {
+			return
+		}
+		if intervals.limit, err = parent.child(); err != nil {
+			return
+		}
+		if !recursive {
+			v = intervals
+			break
+		}
+		var value []Interval
+		if value, err = intervals.Value(); err != nil {
+			return
+		}
+		v = value
+	case typeBlob:
+		v, err = readBlobValue(r)
+	case typeChunked:
+		var c *Chunked
+		if c, err = readChunkedValue(r, mt.OffsetSize()); err != nil {
+			return
+		}
+		v, err = c.Value()
+	case typeCompressed:
+		v, err = readCompressedValue(r)
 	default:
 		err = fmt.Errorf("failed to read value: invalid type %v", t)
 	}
 	return
 }
 
+// SkipValue skips the value at the current position of r without decoding it.
+// On success, r is positioned at the end of the value.
+// Containers are skipped by their offset tables, except that the last
+// element of an array of variable-size elements is skipped recursively.
+// The nesting depth is limited by [DefaultMaxDepth].
+func SkipValue(r ByteReadSeeker) (err error) {
+	return skipValue(r, rootLimit(DefaultMaxDepth, 0))
+}
+
+// skipValue skips a value in a container of parent limit.
+func skipValue(r ByteReadSeeker, parent depthLimit) (err error) {
+	mt, t, err := readTypeMarker(r)
+	if err != nil {
+		return
+	}
+	switch t {
+	case typeNull:
+		// NOP
+	case typeInt, typeUint, typeBool, typeFloat:
+		_, err = readUintValue(r)
+	case typeString, typeBinary, typeGob:
+		var length uint64
+		if length, err = readUintValue(r); err != nil {
+			return
+		}
+		if length > math.MaxInt64 {
+			err = fmt.Errorf("failed to skip value: invalid length %v", length)
+			return
+		}
+		_, err = r.Seek(int64(length), io.SeekCurrent)
+	case typeArray, typeFixedArray, typeChunkedArray:
+		var array *Array
+		if array, err = readArrayOfType(r, t, mt.OffsetSize()); err != nil {
+			return
+		}
+		if array.limit, err = parent.child(); err != nil {
+			return
+		}
+		err = array.skip()
+	case typeObject, typePrefixObject, typeFixedKeyObject, typeIntKeyObject, typeSortedObject, typeHashedObject:
+		var obj *Object
+		if obj, err = readObjectValue(r, t, mt.OffsetSize()); err != nil {
+			return
+		}
+		if obj.limit, err = parent.child(); err != nil {
+			return
+		}
+		err = obj.skip()
+	case typeRef:
+		_, err = r.Seek(int64(mt.OffsetSize()), io.SeekCurrent)
+	case typeIntervals:
+		var intervals *Intervals
+		if intervals, err = readIntervalsValue(r, mt.OffsetSize()); err != nil {
+			return
+		}
+		err = intervals.skip()
+	case typeBlob:
+		_, err = readBlobValue(r)
+	case typeChunked:
+		var c *Chunked
+		if c, err = readChunkedValue(r, mt.OffsetSize()); err != nil {
+			return
+		}
+		err = c.skip()
+	case typeCompressed:
+		var compressed uint64
+		if _, compressed, err = readCompressedLength(r); err != nil {
+			return
+		}
+		_, err = r.Seek(int64(compressed), io.SeekCurrent)
+	default:
+		err = fmt.Errorf("failed to skip value: %w", &TypeError{t})
+	}
+	return
+}
+
 // BoundsError is returned when an out-of-bounds error occurs in [Array.Index].
 type BoundsError struct {
 	Length, Index int
@@ -741,6 +1635,13 @@ type Array struct {
 	pos        int64
 	length     int
 	offsetSize byte
+	stride     int64 // the size of every element of a [typeFixedArray], 0 otherwise.
+	// the number of elements of every chunk of a [typeChunkedArray],
+	// 0 otherwise.
+	chunkSize int
+	data      int64       // the position of the chunks of a [typeChunkedArray]
+	chunk     *arrayChunk // the last chunk read of a [typeChunkedArray]
+	limit     depthLimit
 }
 
 // Len returns the length of array.
@@ -752,10 +1653,30 @@ func (array *Array) Len() int {
 // If recursive is false, arrays and maps are returned as [Array] and [Object],
 // otherwise they are returned as []any and map[string]any.
 func (array *Array) Index(i int, recursive bool) (v any, err error) {
+	if err = array.Seek(i); err != nil {
+		return
+	}
+	return readValue(array.r, recursive, array.limit)
+}
+
+// Seek positions the underlying reader at the start of the ith element of array.
+func (array *Array) Seek(i int) (err error) {
 	if i < 0 || i+1 > array.length {
 		err = &BoundsError{Length: array.length, Index: i}
 		return
 	}
+	return array.seekElem(i)
+}
+
+// seekElem seeks to the start of the ith element of array.
+func (array *Array) seekElem(i int) (err error) {
+	if array.chunkSize != 0 {
+		return array.seekChunkElem(i)
+	}
+	if array.stride != 0 {
+		_, err = array.r.Seek(array.pos+array.stride*int64(i), io.SeekStart)
+		return
+	}
 	offsetPos := int64(array.offsetSize) * int64(i)
 	_, err = array.r.Seek(array.pos+offsetPos, io.SeekStart)
 	if err != nil {
@@ -765,41 +1686,67 @@ func (array *Array) Index(i int, recursive bool) (v any, err error) {
 	if err != nil {
 		return
 	}
-	if offset > math.MaxInt64 {
+	if offset > math.MaxInt64-uint64(array.pos) {
 		err = fmt.Errorf("invalid offset %v", offset)
 		return
 	}
 	_, err = array.r.Seek(array.pos+int64(offset), io.SeekStart)
-	if err != nil {
+	return
+}
+
+// skip seeks to the end of array.
+func (array *Array) skip() (err error) {
+	if array.stride != 0 {
+		_, err = array.r.Seek(array.pos+array.stride*int64(array.length), io.SeekStart)
+		return
+	}
+	if array.length == 0 {
+		_, err = array.r.Seek(max(array.pos, array.data), io.SeekStart)
+		return
+	}
+	// Elements are stored in order, the last one ends the array.
+	if err = array.seekElem(array.length - 1); err != nil {
 		return
 	}
-	return ReadValue(array.r, recursive)
+	return skipValue(array.r, array.limit)
 }
 
 // Value reads and returns the content of array.
 func (array *Array) Value() (v []any, err error) {
-	v = make([]any, 0, array.length)
+	v = make([]any, 0, min(array.length, maxPrealloc))
 	for i := range array.length {
-		offsetPos := int64(array.offsetSize) * int64(i)
-		if _, err = array.r.Seek(array.pos+offsetPos, io.SeekStart); err != nil {
+		if err = array.seekElem(i); err != nil {
 			return
 		}
-		var offset uint64
-		offset, err = readFixedUint(array.r, array.offsetSize)
+		var elem any
+		elem, err = readValue(array.r, true, array.limit)
 		if err != nil {
 			return
 		}
-		if _, err = array.r.Seek(array.pos+int64(offset), io.SeekStart); err != nil {
+		v = append(v, elem)
+	}
+	return
+}
+
+// Find reads the elements of array in order until match returns true, and
+// returns the index and value of the matching element. Elements after it
+// are not read. [ErrNotFound] is returned if no element matches.
+func (array *Array) Find(match func(i int, v any) (bool, error)) (index int, v any, err error) {
+	for i := range array.length {
+		if err = array.seekElem(i); err != nil {
 			return
 		}
-		var elem any
-		elem, err = ReadValue(array.r, true)
-		if err != nil {
+		if v, err = readValue(array.r, true, array.limit); err != nil {
 			return
 		}
-		v = append(v, elem)
+		var ok bool
+		if ok, err = match(i, v); err != nil {
+			return
+		} else if ok {
+			return i, v, nil
+		}
 	}
-	return
+	return -1, nil, ErrNotFound
 }
 
 // readArrayValue reads an Array form r after the type mark.
@@ -822,6 +1769,40 @@ func readArrayValue(r ByteReadSeeker, offsetSize byte) (array *Array, err error)
 		pos:        pos,
 		length:     int(length),
 		offsetSize: offsetSize,
+		limit:      depthLimit{depth: 1, max: DefaultMaxDepth},
+	}
+	return
+}
+
+// readFixedArrayValue reads an Array of [typeFixedArray] form r after the type mark.
+func readFixedArrayValue(r ByteReadSeeker, size byte) (array *Array, err error) {
+	length, err := readFixedUint(r, size)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt {
+		err = fmt.Errorf("failed to read array: invalid length %v", length)
+		return
+	}
+	stride, err := readFixedUint(r, size)
+	if err != nil {
+		return
+	}
+	if stride == 0 || stride > math.MaxInt64/(length+1) {
+		err = fmt.Errorf("failed to read array: invalid stride %v", stride)
+		return
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	array = &Array{
+		r:      r,
+		pos:    pos,
+		length: int(length),
+		stride: int64(stride),
+		limit:  depthLimit{depth: 1, max: DefaultMaxDepth},
 	}
 	return
 }
@@ -835,63 +1816,244 @@ func (err *TypeError) Error() string {
 	return fmt.Sprintf("invalid type %v", err.t)
 }
 
+// Type returns the type encountered.
+func (err *TypeError) Type() Type {
+	return err.t
+}
+
 // ReadArray reads an Array from r.
 func ReadArray(r ByteReadSeeker) (array *Array, err error) {
-	tb, err := r.ReadByte()
+	tm, t, err := readTypeMarker(r)
+	if err != nil {
+		return
+	}
+	return readArrayOfType(r, t, tm.OffsetSize())
+}
+
+// readArrayOfType reads an Array of type t from r after the type mark.
+func readArrayOfType(r ByteReadSeeker, t typ, offsetSize byte) (array *Array, err error) {
+	switch t {
+	case typeArray:
+		return readArrayValue(r, offsetSize)
+	case typeFixedArray:
+		return readFixedArrayValue(r, offsetSize)
+	case typeChunkedArray:
+		return readChunkedArrayValue(r, offsetSize)
+	default:
+		err = fmt.Errorf("failed to read array: %w", &TypeError{t})
+		return
+	}
+}
+
+// ReadContainer reads an [Array] or an [Object] from r.
+// If the value is neither an array nor an object, both array and obj are nil,
+// and the value is not read.
+// The nesting depth of the values in the container is limited by maxDepth,
+// and so is the length of keys by maxKeyLength, see [ReadValueDepth].
+func ReadContainer(r ByteReadSeeker, maxDepth, maxKeyLength int) (array *Array, obj *Object, err error) {
+	tm, t, err := readTypeMarker(r)
 	if err != nil {
 		return
 	}
-	tm := typeMarker(tb)
-	if t := tm.Type(); t != typeArray {
-		err = fmt.Errorf("failed to read array: invalid type %w", &TypeError{t})
+	switch t {
+	case typeArray, typeFixedArray, typeChunkedArray:
+		array, err = readArrayOfType(r, t, tm.OffsetSize())
+	case typeObject, typePrefixObject, typeFixedKeyObject, typeIntKeyObject, typeSortedObject, typeHashedObject:
+		obj, err = readObjectValue(r, t, tm.OffsetSize())
+	default:
+		return // Not a container.
+	}
+	if err != nil {
 		return
 	}
-	return readArrayValue(r, tm.OffsetSize())
+	limit, err := rootLimit(maxDepth, maxKeyLength).child()
+	if err != nil {
+		return nil, nil, err
+	}
+	if array != nil {
+		array.limit = limit
+	} else if obj != nil {
+		obj.limit = limit
+	}
+	return
 }
 
+// stringHash returns the hash of the key of an object.
 func stringHash(s string) uint64 {
-	h := fnv.New64a()
-	io.WriteString(h, s)
-	return h.Sum64()
+	if hash := keyHash.Load(); hash != nil {
+		return (*hash)(s)
+	}
+	return HashKey(0, s)
+}
+
+// keyHash, if set, replaces the hash of the keys of objects, see [SetKeyHash].
+var keyHash atomic.Pointer[func(key string) uint64]
+
+// SetKeyHash replaces the hash of the keys of objects with hash until
+// restore is called, so that the handling of hash collisions can be tested.
+// [HashKey] is not affected.
+func SetKeyHash(hash func(key string) uint64) (restore func()) {
+	var p *func(key string) uint64
+	if hash != nil {
+		p = &hash
+	}
+	old := keyHash.Swap(p)
+	return func() { keyHash.Store(old) }
+}
+
+// HashKey returns the hash of key mixed with seed.
+// Keys of objects are hashed with seed 0.
+func HashKey(seed uint64, key string) uint64 {
+	// Inlined FNV-1a, same as hash/fnv.New64a without allocations.
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	var h uint64 = offset64 ^ seed
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
 }
 
 type bucketKV struct {
+	H uint64 // the hash of K
 	K string
 	V any
 }
 
+// HashedEntry is an entry of an object with the hash of its key and its
+// encoded value, see [Encoder.WriteEntries].
+type HashedEntry struct {
+	Hash  uint64 // The hash of Key, which must be HashKey(0, Key).
+	Key   string
+	Value []byte // The encoded value.
+}
+
+// ErrDuplicateKey is returned by [Encoder.WriteEntries] if a key appears
+// more than once.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// fibonacciMultiplier is 2^64 divided by the golden ratio, which mixes
+// the hashes of keys in multiply-shift hashing. See [Encoder.Pow2Buckets].
+const fibonacciMultiplier = 0x9E3779B97F4A7C15
+
+// pow2BucketsMark marks the bucket count of a hash table of 2^k buckets,
+// which is stored as a byte of pow2BucketsMark|k instead of a varuint.
+// See [Encoder.Pow2Buckets].
+const pow2BucketsMark = 0x80
+
+// bucketIndex returns the bucket of hash in a hash table of bucketCount
+// buckets. If shift is positive, the bucket count is 2^(64-shift) and
+// the bucket is chosen by multiply-shift hashing, otherwise by modulo.
+func bucketIndex(hash, bucketCount uint64, shift byte) uint64 {
+	if shift > 0 {
+		return hash * fibonacciMultiplier >> shift
+	}
+	return hash % bucketCount
+}
+
 // genBuckets is the Separate Chaining hash table algorithm.
-func genBuckets(obj map[string]any, bucketCount int) (buckets [][]bucketKV, avgOverflow int) {
-	buckets = make([][]bucketKV, bucketCount)
-	for k, v := range obj {
-		hash := stringHash(k)
-		i := hash % uint64(bucketCount)
-		buckets[i] = append(buckets[i], bucketKV{k, v})
+// See [bucketIndex] for bucketCount and shift.
+// Entries are placed into a single slice by counting sort, which is sliced
+// into buckets, instead of being appended to every bucket, and the order of
+// entries in every bucket is kept.
+func genBuckets(entries []bucketKV, bucketCount int, shift byte) (buckets [][]bucketKV, avgOverflow int) {
+	// starts[i+1] is the number of entries of bucket i, and then the end of
+	// bucket i in sorted after the prefix sum.
+	starts := make([]int, bucketCount+1)
+	for _, entry := range entries {
+		starts[bucketIndex(entry.H, uint64(bucketCount), shift)+1]++
 	}
 	var sumOverflow int
 	var numOverflow int
-	for _, b := range buckets {
-		if overflow := len(b); overflow > 1 {
+	for i := range bucketCount {
+		if overflow := starts[i+1]; overflow > 1 {
 			numOverflow++
 			sumOverflow += overflow
 		}
+		starts[i+1] += starts[i]
 	}
 	if numOverflow > 0 {
 		avgOverflow = sumOverflow / numOverflow
 	}
+	// Placed backwards, so starts[i+1] becomes the start of bucket i.
+	sorted := make([]bucketKV, len(entries))
+	for _, entry := range slices.Backward(entries) {
+		i := bucketIndex(entry.H, uint64(bucketCount), shift)
+		starts[i+1]--
+		sorted[starts[i+1]] = entry
+	}
+	buckets = make([][]bucketKV, bucketCount)
+	for i := range buckets {
+		start, end := starts[i+1], len(sorted)
+		if i+1 < bucketCount {
+			end = starts[i+2]
+		}
+		if start < end {
+			buckets[i] = sorted[start:end:end]
+		}
+	}
 	return
 }
 
 // WriteObject writes a map[string]any to w.
 func WriteObject(w io.Writer, obj map[string]any, gobEncoder GobEncoder) (err error) {
-	bucketCount := nearestPrime(len(obj) * 4 / 3)
-	buckets, avgOverflow := genBuckets(obj, bucketCount)
+	return (&Encoder{Gob: gobEncoder}).WriteObject(w, obj)
+}
+
+// WriteObject writes a map[string]any to w.
+func (e *Encoder) WriteObject(w io.Writer, obj map[string]any) (err error) {
+	if e.IntKeys {
+		if ok, err := e.writeIntKeyObject(w, obj); ok || err != nil {
+			return err
+		}
+	}
+	if e.FixedKeys {
+		if keySize, ok := fixedKeySize(obj); ok {
+			return e.writeFixedKeyObject(w, obj, keySize)
+		}
+	}
+	entries := make([]bucketKV, 0, len(obj))
+	for k, v := range obj {
+		entries = append(entries, bucketKV{K: k, V: v})
+	}
+	// Hashing dominates large objects, such as the ones of millions of keys.
+	parallelize(len(entries), func(lo, hi int) {
+		for i := range entries[lo:hi] {
+			entries[lo+i].H = stringHash(entries[lo+i].K)
+		}
+	})
+	return e.writeHashTable(w, entries)
+}
+
+// WriteEntries writes the object of entries to w, whose keys are hashed and
+// values are encoded already, such as by producers in parallel.
+// [Encoder.FixedKeys] and [Encoder.IntKeys] are ignored.
+// An [*EncodeError] of [ErrDuplicateKey] is returned if a key appears more
+// than once.
+func (e *Encoder) WriteEntries(w io.Writer, entries []HashedEntry) (err error) {
+	kvs := make([]bucketKV, len(entries))
+	for i, entry := range entries {
+		kvs[i] = bucketKV{entry.Hash, entry.Key, Raw(entry.Value)}
+	}
+	return e.writeHashTable(w, kvs)
+}
+
+// writeHashTable writes the object of entries to w as a hash table.
+func (e *Encoder) writeHashTable(w io.Writer, entries []bucketKV) (err error) {
+	bucketCount, shift := e.bucketCount(len(entries) * 4 / 3)
+	buckets, avgOverflow := genBuckets(entries, bucketCount, shift)
 	if avgOverflow > 5 {
-		bucketCount = nearestPrime(max(bucketCount*4/3, bucketCount+1))
-		buckets, _ = genBuckets(obj, bucketCount)
+		bucketCount, shift = e.bucketCount(max(bucketCount*4/3, bucketCount+1))
+		buckets, _ = genBuckets(entries, bucketCount, shift)
 	}
 
-	var bucketData bytes.Buffer
+	bucketData := getBuffer()
+	defer putBuffer(bucketData)
+	valueData := getBuffer()
+	defer putBuffer(valueData)
 	var offsets = make([]int, bucketCount)
 	for i, list := range buckets {
 		if listLen := len(list); listLen == 0 {
@@ -900,15 +2062,53 @@ func WriteObject(w io.Writer, obj map[string]any, gobEncoder GobEncoder) (err er
 		}
 		offsets[i] = bucketData.Len()
 		// List size
-		writeUintValue(&bucketData, uint64(len(list)))
+		if err = writeUintValue(bucketData, uint64(len(list))); err != nil {
+			return
+		}
+		if e.FrontCoding || e.SortedKeys && !e.HashOrder {
+			slices.SortFunc(list, func(a, b bucketKV) int {
+				return strings.Compare(a.K, b.K)
+			})
+		} else if e.HashOrder {
+			slices.SortFunc(list, func(a, b bucketKV) int {
+				return cmp.Or(cmp.Compare(a.H, b.H), strings.Compare(a.K, b.K))
+			})
+		}
 		// List data
-		for _, bucket := range list {
-			writeBinaryValue(&bucketData, []byte(bucket.K))
-			var valueData bytes.Buffer
-			WriteValue(&valueData, bucket.V, gobEncoder)
+		var prev string
+		for j, bucket := range list {
+			for _, other := range list[:j] {
+				if other.H == bucket.H && other.K == bucket.K {
+					return &EncodeError{Path: []string{bucket.K}, Err: ErrDuplicateKey}
+				}
+			}
+			if e.FrontCoding {
+				prefix := commonPrefixLen(prev, bucket.K)
+				if err = writeUintValue(bucketData, uint64(prefix)); err == nil {
+					err = writeStringValue(bucketData, bucket.K[prefix:])
+				}
+				prev = bucket.K
+			} else if e.HashOrder {
+				if err = writeFixedUint(bucketData, bucket.H, 8); err == nil {
+					err = writeStringValue(bucketData, bucket.K)
+				}
+			} else {
+				err = writeStringValue(bucketData, bucket.K)
+			}
+			if err != nil {
+				return
+			}
+			valueData.Reset()
+			if err = e.WriteValue(valueData, bucket.V); err != nil {
+				return EncodeErrorAt(err, bucket.K)
+			}
 			// Used to skip value
-			writeUintValue(&bucketData, uint64(valueData.Len()))
-			io.Copy(&bucketData, &valueData)
+			if err = writeUintValue(bucketData, uint64(valueData.Len())); err != nil {
+				return
+			}
+			if err = copyBuffers(bucketData, valueData); err != nil {
+				return
+			}
 		}
 	}
 
@@ -919,15 +2119,10 @@ func WriteObject(w io.Writer, obj map[string]any, gobEncoder GobEncoder) (err er
 			break // The last real offset
 		}
 	}
-	var offsetSize = fixedUintSize(uint64(maxOffset))
 	// offsetSize must be large enough to hold the max offset plus the size of offset section.
-	for offsetSize < fixedUintSize(uint64(maxOffset+bucketCount*int(offsetSize))) {
-		offsetSize *= 2
-		if offsetSize > 8 {
-			err = fmt.Errorf("invalid offset size %v", offsetSize)
-			return
-		}
-	}
+	offsetSize := minOffsetSize(func(size byte) uint64 {
+		return uint64(maxOffset) + uint64(bucketCount)*uint64(size)
+	})
 
 	// Fix offsets
 	delta := bucketCount * int(offsetSize)
@@ -940,19 +2135,87 @@ func WriteObject(w io.Writer, obj map[string]any, gobEncoder GobEncoder) (err er
 		}
 	}
 
-	var header bytes.Buffer
-	header.WriteByte(byte(newTypeMarker(typeObject, offsetSize)))
-	writeUintValue(&header, uint64(bucketCount))
+	header := getBuffer()
+	defer putBuffer(header)
+	objectType := typeObject
+	if e.FrontCoding {
+		objectType = typePrefixObject
+	} else if e.HashOrder {
+		objectType = typeHashedObject
+	} else if e.SortedKeys {
+		objectType = typeSortedObject
+	}
+	if err = writeTypeMarker(header, objectType, offsetSize); err != nil {
+		return
+	}
+	if shift > 0 {
+		err = writeByte(header, pow2BucketsMark|(64-shift))
+	} else {
+		err = writeUintValue(header, uint64(bucketCount))
+	}
+	if err != nil {
+		return
+	}
 	for _, offset := range offsets {
-		writeFixedUint(&header, uint64(offset), offsetSize)
+		if err = writeFixedUint(header, uint64(offset), offsetSize); err != nil {
+			return
+		}
 	}
+	return copyBuffers(w, header, bucketData)
+}
 
-	if _, err = io.Copy(w, &header); err == nil {
-		_, err = io.Copy(w, &bucketData)
+// bucketCount returns the bucket count of a hash table of no less than n
+// buckets, and the shift of [bucketIndex].
+func (e *Encoder) bucketCount(n int) (count int, shift byte) {
+	switch {
+	case e.Pow2Buckets:
+		k := bits.Len(uint(max(n, 1) - 1))
+		if e.MaxBuckets > 0 && 1<<k > e.MaxBuckets {
+			k = bits.Len(uint(e.MaxBuckets)) - 1
+		}
+		return 1 << k, byte(64 - k)
+	case e.PrimeTable:
+		count = tablePrime(n)
+	default:
+		count = nearestPrime(n)
+	}
+	if e.MaxBuckets > 0 {
+		count = min(count, e.MaxBuckets)
 	}
 	return
 }
 
+// readBucketCount reads the bucket count of a hash table from r, and the
+// shift of [bucketIndex].
+func readBucketCount(r ByteReadSeeker) (count uint64, shift byte, err error) {
+	b0, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	if b0 <= math.MaxInt8 {
+		return uint64(b0), 0, nil
+	}
+	if k := b0 &^ pow2BucketsMark; k < 64 {
+		return 1 << k, 64 - k, nil
+	}
+	count, err = readFixedUint(r, -b0) // size = -b0
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// commonPrefixLen returns the length of the common prefix of a and b.
+func commonPrefixLen[A, B ~string | ~[]byte](a A, b B) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
 // ErrNotFound is returned when no value is associated with a key
 // when indexing an map[string]any.
 var ErrNotFound = errors.New("not found")
@@ -963,12 +2226,45 @@ type Object struct {
 	pos         int64
 	bucketCount uint64
 	offsetSize  byte
+	keySize     int    // the size of every key of a [typeFixedKeyObject], whose entries are buckets of themselves
+	intKeys     bool   // keys are integers stored as fixed size keys, see [Encoder.IntKeys]
+	prefixed    bool   // chains are sorted and keys are front-coded, see [Encoder.FrontCoding]
+	sorted      bool   // chains are sorted, see [Encoder.SortedKeys]
+	hashed      bool   // chains are sorted by hash, see [Encoder.HashOrder]
+	shift       byte   // the shift of [bucketIndex], see [Encoder.Pow2Buckets]
+	keyBuf      []byte // buffer to compare keys in Seek, or the previous key of front-coded chains
+	limit       depthLimit
+	dir         *directory // see [Object.LoadDirectory]
 }
 
-// Value reads and returns the content of obj.
-func (obj *Object) Value() (v map[string]any, err error) {
-	v = make(map[string]any)
-	for i := range obj.bucketCount {
+// bucket returns the bucket of hash in the hash table of obj.
+func (obj *Object) bucket(hash uint64) uint64 {
+	return bucketIndex(hash, obj.bucketCount, obj.shift)
+}
+
+// forEach calls fn for every entry of obj. When fn is called, the underlying
+// reader is positioned at the start of the value.
+// On success, the underlying reader is positioned at the end of obj.
+func (obj *Object) forEach(fn func(key string) error) (err error) {
+	return obj.forEachFrom(Cursor{}, func(_ Cursor, key string) error {
+		return fn(key)
+	})
+}
+
+// Cursor is the position of an entry in an [Object].
+type Cursor struct {
+	Bucket uint64 // The index of the bucket.
+	Entry  uint64 // The index of the entry in the chain of the bucket.
+}
+
+// forEachFrom is like forEach, but starts from the entry at cursor from.
+func (obj *Object) forEachFrom(from Cursor, fn func(cursor Cursor, key string) error) (err error) {
+	if obj.keySize > 0 {
+		return obj.forEachFixedKey(from, fn)
+	}
+	// The end of obj, initialized to the end of offset section.
+	end := obj.pos + int64(obj.bucketCount)*int64(obj.offsetSize)
+	for i := from.Bucket; i < obj.bucketCount; i++ {
 		offsetPos := obj.pos + int64(i)*int64(obj.offsetSize)
 		if _, err = obj.r.Seek(offsetPos, io.SeekStart); err != nil {
 			return
@@ -978,7 +2274,7 @@ func (obj *Object) Value() (v map[string]any, err error) {
 		if err != nil {
 			return
 		}
-		if offset > math.MaxInt {
+		if offset > math.MaxInt64-uint64(obj.pos) {
 			err = fmt.Errorf("invalid offset %v", offset)
 			return
 		}
@@ -994,20 +2290,173 @@ func (obj *Object) Value() (v map[string]any, err error) {
 		if err != nil {
 			return
 		}
-		for range listLen {
-			var key string
-			if key, err = readStringValue(obj.r); err != nil {
+		obj.keyBuf = obj.keyBuf[:0]
+		for j := range listLen {
+			if i == from.Bucket && j < from.Entry {
+				// Skip entries before from.
+				if _, err = obj.readKey(); err != nil {
+					return
+				}
+				var valueSize uint64
+				if valueSize, err = readUintValue(obj.r); err != nil {
+					return
+				}
+				if _, err = obj.r.Seek(int64(valueSize), io.SeekCurrent); err != nil {
+					return
+				}
+				continue
+			}
+			var key []byte
+			if key, err = obj.readKey(); err != nil {
 				return
 			}
 			// Read value size
-			if _, err = readUintValue(obj.r); err != nil {
+			var valueSize uint64
+			if valueSize, err = readUintValue(obj.r); err != nil {
+				return
+			}
+			var valuePos int64
+			if valuePos, err = obj.r.Seek(0, io.SeekCurrent); err != nil {
+				return
+			}
+			if err = fn(Cursor{i, j}, string(key)); err != nil {
+				return
+			}
+			// Reading nested arrays and objects moves r to anywhere.
+			if end, err = obj.r.Seek(valuePos+int64(valueSize), io.SeekStart); err != nil {
 				return
 			}
-			var value any
-			if value, err = ReadValue(obj.r, true); err != nil {
+		}
+	}
+	_, err = obj.r.Seek(end, io.SeekStart)
+	return
+}
+
+// Value reads and returns the content of obj.
+// On success, the underlying reader is positioned at the end of obj.
+func (obj *Object) Value() (v map[string]any, err error) {
+	v = make(map[string]any)
+	err = obj.forEach(func(key string) (err error) {
+		v[key], err = readValue(obj.r, true, obj.limit)
+		return
+	})
+	return
+}
+
+// errStop stops iterations.
+var errStop = errors.New("stop")
+
+// Keys returns at most limit keys of obj starting from the entry at cursor
+// from, and the cursor of the entry after them. If there are no more entries,
+// more is false.
+func (obj *Object) Keys(from Cursor, limit int) (keys []string, next Cursor, more bool, err error) {
+	err = obj.forEachFrom(from, func(cursor Cursor, key string) error {
+		if len(keys) == limit {
+			next, more = cursor, true
+			return errStop
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return
+}
+
+// StringMap reads and returns the content of obj, whose values are all strings.
+func (obj *Object) StringMap() (m map[string]string, err error) {
+	m = make(map[string]string)
+	err = obj.forEach(func(key string) (err error) {
+		tb, err := obj.r.ReadByte()
+		if err != nil {
+			return
+		}
+		if t := typeMarker(tb).Type(); t != typeString {
+			return fmt.Errorf("failed to read string value of %q: %w", key, &TypeError{t})
+		}
+		m[key], err = readStringValue(obj.r)
+		return
+	})
+	return
+}
+
+// Int64Map reads and returns the content of obj, whose values are all integers
+// can be represented by int64.
+func (obj *Object) Int64Map() (m map[string]int64, err error) {
+	m = make(map[string]int64)
+	err = obj.forEach(func(key string) (err error) {
+		tb, err := obj.r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch t := typeMarker(tb).Type(); t {
+		case typeInt:
+			m[key], err = readIntValue(obj.r)
+		case typeUint:
+			var n uint64
+			if n, err = readUintValue(obj.r); err != nil {
 				return
 			}
-			v[key] = value
+			if n > math.MaxInt64 {
+				return fmt.Errorf("failed to read int64 value of %q: overflow %v", key, n)
+			}
+			m[key] = int64(n)
+		default:
+			return fmt.Errorf("failed to read int64 value of %q: %w", key, &TypeError{t})
+		}
+		return
+	})
+	return
+}
+
+// skip seeks to the end of obj.
+func (obj *Object) skip() (err error) {
+	if obj.keySize > 0 {
+		return obj.skipFixedKey()
+	}
+	// Buckets are stored in order, the last non-empty one ends the object.
+	end := obj.pos + int64(obj.bucketCount)*int64(obj.offsetSize)
+	var empty = true
+	for i := obj.bucketCount; i > 0; i-- {
+		offsetPos := obj.pos + int64(i-1)*int64(obj.offsetSize)
+		if _, err = obj.r.Seek(offsetPos, io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(obj.r, obj.offsetSize); err != nil {
+			return
+		}
+		if offset > math.MaxInt64-uint64(obj.pos) {
+			err = fmt.Errorf("invalid offset %v", offset)
+			return
+		}
+		if offset != 0 {
+			end = obj.pos + int64(offset)
+			empty = false
+			break
+		}
+	}
+	if _, err = obj.r.Seek(end, io.SeekStart); err != nil || empty {
+		return
+	}
+	listLen, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	for range listLen {
+		if err = obj.skipKey(); err != nil {
+			return
+		}
+		var valueSize uint64
+		if valueSize, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if valueSize > math.MaxInt64 {
+			return fmt.Errorf("invalid value size %v", valueSize)
+		}
+		if _, err = obj.r.Seek(int64(valueSize), io.SeekCurrent); err != nil {
+			return
 		}
 	}
 	return
@@ -1017,8 +2466,40 @@ func (obj *Object) Value() (v map[string]any, err error) {
 // if no value is associated with key.
 // See [Array.Index] for the meaning of recursive.
 func (obj *Object) Index(key string, recursive bool) (v any, err error) {
-	hash := stringHash(key)
-	i := hash % obj.bucketCount
+	if err = obj.Seek(key); err != nil {
+		return
+	}
+	return readValue(obj.r, recursive, obj.limit)
+}
+
+// IndexHash is like [Object.Index] but uses hash as the hash of key,
+// which must be HashKey(0, key).
+func (obj *Object) IndexHash(hash uint64, key string, recursive bool) (v any, err error) {
+	if err = obj.SeekHash(hash, key); err != nil {
+		return
+	}
+	return readValue(obj.r, recursive, obj.limit)
+}
+
+// Seek positions the underlying reader at the start of the value associated
+// with key. The returned error is [ErrNotFound] if no value is associated with key.
+func (obj *Object) Seek(key string) (err error) {
+	return obj.SeekHash(stringHash(key), key)
+}
+
+// SeekHash is like [Object.Seek] but uses hash as the hash of key,
+// which must be HashKey(0, key).
+func (obj *Object) SeekHash(hash uint64, key string) (err error) {
+	if obj.bucketCount == 0 {
+		return ErrNotFound
+	}
+	if obj.keySize > 0 {
+		return obj.seekFixedKey(key)
+	}
+	if obj.dir != nil {
+		return obj.seekDirectory(hash, key)
+	}
+	i := obj.bucket(hash)
 	offsetPos := obj.pos + int64(i)*int64(obj.offsetSize)
 	if _, err = obj.r.Seek(offsetPos, io.SeekStart); err != nil {
 		return
@@ -1027,7 +2508,7 @@ func (obj *Object) Index(key string, recursive bool) (v any, err error) {
 	if err != nil {
 		return
 	}
-	if offset > math.MaxInt {
+	if offset > math.MaxInt64-uint64(obj.pos) {
 		err = fmt.Errorf("invalid offset %v", offset)
 		return
 	}
@@ -1043,35 +2524,302 @@ func (obj *Object) Index(key string, recursive bool) (v any, err error) {
 	if err != nil {
 		return
 	}
+	if obj.prefixed {
+		return obj.seekSorted(key, listLen)
+	}
 	for range listLen {
-		var bucketKey string
-		if bucketKey, err = readStringValue(obj.r); err != nil {
-			return
+		if obj.hashed {
+			var entryHash uint64
+			if entryHash, err = readFixedUint(obj.r, 8); err != nil {
+				return
+			} else if entryHash > hash {
+				return ErrNotFound
+			} else if entryHash < hash {
+				if err = obj.skipEntry(); err != nil {
+					return
+				}
+				continue
+			}
 		}
-		if key == bucketKey { // FOUND!
-			// Read value size
-			if _, err = readUintValue(obj.r); err != nil {
+		var found bool
+		if obj.sorted {
+			var cmp int
+			if cmp, err = obj.compareKey(key); err != nil {
 				return
+			} else if cmp > 0 {
+				return ErrNotFound
+			}
+			found = cmp == 0
+		} else if found, err = obj.matchKey(key); err != nil {
+			return
+		}
+		// Read value size
+		var valueSize uint64
+		if valueSize, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if found {
+			return
+		}
+		// Skip value
+		if _, err = obj.r.Seek(int64(valueSize), io.SeekCurrent); err != nil {
+			return
+		}
+	}
+	return ErrNotFound
+}
+
+// ContainsKeys reports whether obj contains all the keys, or any of them if
+// matchAny is true, without reading values. Keys are probed in the order of
+// their buckets, so that the underlying reader moves forward mostly.
+func (obj *Object) ContainsKeys(keys []string, matchAny bool) (found bool, err error) {
+	type probe struct {
+		key    string
+		hash   uint64
+		bucket uint64
+	}
+	probes := make([]probe, len(keys))
+	for i, key := range keys {
+		hash := stringHash(key)
+		var bucket uint64
+		if obj.bucketCount > 0 && obj.keySize == 0 {
+			bucket = obj.bucket(hash)
+		}
+		probes[i] = probe{key, hash, bucket}
+	}
+	slices.SortFunc(probes, func(a, b probe) int {
+		return cmp.Or(cmp.Compare(a.bucket, b.bucket), cmp.Compare(a.key, b.key))
+	})
+	for _, p := range probes {
+		if err = obj.SeekHash(p.hash, p.key); err == ErrNotFound {
+			if !matchAny {
+				return false, nil
 			}
-			return ReadValue(obj.r, recursive)
+			continue
+		} else if err != nil {
+			return
+		} else if matchAny {
+			return true, nil
 		}
+	}
+	return !matchAny, nil
+}
 
+// matchKey reads a key from the underlying reader and reports whether it equals key.
+// Keys of different length are skipped without being read.
+func (obj *Object) matchKey(key string) (match bool, err error) {
+	length, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	if err = obj.limit.checkKey(length); err != nil {
+		return
+	}
+	if length != uint64(len(key)) {
+		if length > math.MaxInt64 {
+			err = fmt.Errorf("failed to read key: invalid length %v", length)
+			return
+		}
+		_, err = obj.r.Seek(int64(length), io.SeekCurrent)
+		return
+	}
+	obj.keyBuf = slices.Grow(obj.keyBuf[:0], len(key))[:len(key)]
+	if _, err = io.ReadFull(obj.r, obj.keyBuf); err != nil {
+		return
+	}
+	match = string(obj.keyBuf) == key
+	return
+}
+
+// compareKey reads a key from the underlying reader and compares it with key.
+func (obj *Object) compareKey(key string) (cmp int, err error) {
+	length, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	if err = obj.limit.checkKey(length); err != nil {
+		return
+	}
+	if length > math.MaxInt32 {
+		err = fmt.Errorf("failed to read key: invalid length %v", length)
+		return
+	}
+	obj.keyBuf = slices.Grow(obj.keyBuf[:0], int(length))[:length]
+	if _, err = io.ReadFull(obj.r, obj.keyBuf); err != nil {
+		return
+	}
+	return strings.Compare(string(obj.keyBuf), key), nil
+}
+
+// seekSorted is like [Object.SeekHash], but seeks in a front-coded chain of
+// listLen entries sorted by key. Keys are compared without reconstructing
+// them, and the scan stops at the first key greater than key.
+func (obj *Object) seekSorted(key string, listLen uint64) (err error) {
+	// The length of the common prefix of key and the current key.
+	var matched uint64
+	for range listLen {
+		var prefix, length uint64
+		if prefix, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if length, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if length > math.MaxInt64 {
+			err = fmt.Errorf("failed to read key: invalid length %v", length)
+			return
+		}
+		if err = obj.limit.checkKey(length); err != nil {
+			return
+		}
+		var found bool
+		switch {
+		case prefix < matched:
+			// The current key differs from the previous key before
+			// where the previous key differs from key, so it is greater.
+			return ErrNotFound
+		case prefix > matched:
+			// The current key is less than key as the previous key is.
+			if _, err = obj.r.Seek(int64(length), io.SeekCurrent); err != nil {
+				return
+			}
+		default:
+			obj.keyBuf = slices.Grow(obj.keyBuf[:0], int(length))[:length]
+			if _, err = io.ReadFull(obj.r, obj.keyBuf); err != nil {
+				return
+			}
+			rest := key[matched:]
+			n := commonPrefixLen(obj.keyBuf, rest)
+			matched += uint64(n)
+			if n == len(obj.keyBuf) {
+				found = n == len(rest)
+			} else if n == len(rest) || obj.keyBuf[n] > rest[n] {
+				return ErrNotFound
+			}
+		}
 		// Read value size
 		var valueSize uint64
 		if valueSize, err = readUintValue(obj.r); err != nil {
 			return
 		}
+		if found {
+			return
+		}
 		// Skip value
 		if _, err = obj.r.Seek(int64(valueSize), io.SeekCurrent); err != nil {
 			return
 		}
 	}
-	return nil, ErrNotFound
+	return ErrNotFound
 }
 
-// readObjectValue reads a map[string]any from r after the type mark.
-func readObjectValue(r ByteReadSeeker, offsetSize byte) (obj *Object, err error) {
-	bucketCount, err := readUintValue(r)
+// readKey reads a key of a chain into obj.keyBuf and returns it.
+// For front-coded chains, obj.keyBuf must hold the previous key of the
+// chain, or be empty for the first key.
+func (obj *Object) readKey() (key []byte, err error) {
+	if obj.hashed {
+		if _, err = obj.r.Seek(8, io.SeekCurrent); err != nil {
+			return
+		}
+	}
+	var prefix uint64
+	if obj.prefixed {
+		if prefix, err = readUintValue(obj.r); err != nil {
+			return
+		}
+		if prefix > uint64(len(obj.keyBuf)) {
+			err = fmt.Errorf("failed to read key: invalid prefix length %v", prefix)
+			return
+		}
+	}
+	length, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt32 {
+		err = fmt.Errorf("failed to read key: invalid length %v", length)
+		return
+	}
+	if err = obj.limit.checkKey(prefix + length); err != nil {
+		return
+	}
+	obj.keyBuf = slices.Grow(obj.keyBuf[:prefix], int(length))[:prefix+length]
+	if _, err = io.ReadFull(obj.r, obj.keyBuf[prefix:]); err != nil {
+		return
+	}
+	return obj.keyBuf, nil
+}
+
+// skipEntry skips the key and value of an entry of a chain after the hash.
+func (obj *Object) skipEntry() (err error) {
+	length, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt64 {
+		return fmt.Errorf("failed to read key: invalid length %v", length)
+	}
+	if err = obj.limit.checkKey(length); err != nil {
+		return
+	}
+	if _, err = obj.r.Seek(int64(length), io.SeekCurrent); err != nil {
+		return
+	}
+	valueSize, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	if valueSize > math.MaxInt64 {
+		return fmt.Errorf("invalid value size %v", valueSize)
+	}
+	_, err = obj.r.Seek(int64(valueSize), io.SeekCurrent)
+	return
+}
+
+// skipKey skips a key of a chain without reading it.
+func (obj *Object) skipKey() (err error) {
+	if obj.hashed {
+		if _, err = obj.r.Seek(8, io.SeekCurrent); err != nil {
+			return
+		}
+	}
+	if obj.prefixed {
+		if _, err = readUintValue(obj.r); err != nil {
+			return
+		}
+	}
+	length, err := readUintValue(obj.r)
+	if err != nil {
+		return
+	}
+	if length > math.MaxInt64 {
+		return fmt.Errorf("failed to read key: invalid length %v", length)
+	}
+	if err = obj.limit.checkKey(length); err != nil {
+		return
+	}
+	_, err = obj.r.Seek(int64(length), io.SeekCurrent)
+	return
+}
+
+// isObject reports whether t is one of the types of map[string]any.
+func isObject(t typ) bool {
+	switch t {
+	case typeObject, typePrefixObject, typeFixedKeyObject, typeIntKeyObject, typeSortedObject, typeHashedObject:
+		return true
+	}
+	return false
+}
+
+// readObjectValue reads a map[string]any of type t from r after the type mark.
+func readObjectValue(r ByteReadSeeker, t typ, offsetSize byte) (obj *Object, err error) {
+	var bucketCount uint64
+	var shift byte
+	if t == typeFixedKeyObject || t == typeIntKeyObject {
+		bucketCount, err = readUintValue(r)
+	} else {
+		bucketCount, shift, err = readBucketCount(r)
+	}
 	if err != nil {
 		return
 	}
@@ -1084,20 +2832,33 @@ func readObjectValue(r ByteReadSeeker, offsetSize byte) (obj *Object, err error)
 		pos:         pos,
 		bucketCount: bucketCount,
 		offsetSize:  offsetSize,
+		prefixed:    t == typePrefixObject,
+		sorted:      t == typeSortedObject,
+		hashed:      t == typeHashedObject,
+		shift:       shift,
+		limit:       depthLimit{depth: 1, max: DefaultMaxDepth},
+	}
+	if t == typeFixedKeyObject || t == typeIntKeyObject {
+		obj.intKeys = t == typeIntKeyObject
+		if err = readFixedKeyObjectValue(r, obj); err != nil {
+			return
+		}
+		if obj.pos, err = r.Seek(0, io.SeekCurrent); err != nil {
+			return
+		}
 	}
 	return
 }
 
 // ReadObject reads a map[string]any from r.
 func ReadObject(r ByteReadSeeker) (obj *Object, err error) {
-	tb, err := r.ReadByte()
+	tm, t, err := readTypeMarker(r)
 	if err != nil {
 		return
 	}
-	tm := typeMarker(tb)
-	if t := tm.Type(); t != typeObject {
-		err = fmt.Errorf("failed to read object: invalid type %v", t)
+	if !isObject(t) {
+		err = fmt.Errorf("failed to read object: %w", &TypeError{t})
 		return
 	}
-	return readObjectValue(r, tm.OffsetSize())
+	return readObjectValue(r, t, tm.OffsetSize())
 }
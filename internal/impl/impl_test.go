@@ -1,10 +1,17 @@
 package impl
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -125,6 +132,8 @@ func TestWriteInt(t *testing.T) {
 	}{
 		{"0", 0, []byte{byte(typeInt), 0}, false},
 		{"-129", -129, []byte{byte(typeInt), 0xFE, 0x01, 0x01}, false},
+		{"-1<<32", -1 << 32, []byte{byte(typeInt), 0xFB, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, false},
+		{"MinInt64", math.MinInt64, []byte{byte(typeInt), 0xF8, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -149,6 +158,8 @@ func TestReadInt(t *testing.T) {
 	}{
 		{"0", []byte{byte(typeInt), 0}, 0, false},
 		{"-129", []byte{byte(typeInt), 0xFE, 0x01, 0x01}, -129, false},
+		{"-1<<32", []byte{byte(typeInt), 0xFB, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, -1 << 32, false},
+		{"MinInt64", []byte{byte(typeInt), 0xF8, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, math.MinInt64, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -332,6 +343,90 @@ func TestReadWriteArray(t *testing.T) {
 	}
 }
 
+func TestReadWriteFixedArray(t *testing.T) {
+	ary := []any{"abc", "def", "ghi", "jkl"}
+
+	var buf bytes.Buffer
+	err := WriteArray(&buf, ary, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm := typeMarker(buf.Bytes()[0]); tm.Type() != typeFixedArray {
+		t.Fatal(tm.Type())
+	}
+	// type mark + length + stride + 4 * (type mark + length + 3 bytes)
+	if l := buf.Len(); l != 1+1+1+4*5 {
+		t.Fatal(l)
+	}
+
+	readAry, err := ReadArray(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := readAry.Len(); l != len(ary) {
+		t.Fatal(l)
+	}
+	if v, err := readAry.Index(2, true); err != nil {
+		t.Fatal(err)
+	} else if v != "ghi" {
+		t.Fatal(v)
+	}
+	var boundsErr *BoundsError
+	if _, err := readAry.Index(4, true); !errors.As(err, &boundsErr) {
+		t.Fatal(err)
+	}
+	read, err := readAry.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ary, read) {
+		t.Fatal(read)
+	}
+}
+
+func TestAppendFrom(t *testing.T) {
+	ary := []any{int64(1), "abc", []any{true}, map[string]any{"1": "123"}}
+	var want, buf bytes.Buffer
+	if err := WriteArray(&want, ary, nil); err != nil {
+		t.Fatal(err)
+	}
+	e := &Encoder{Gob: NewGobEncoder()}
+	if err := e.AppendFrom(&buf, slices.Values(ary)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Fatal(buf.Bytes())
+	}
+
+	// Generated.
+	buf.Reset()
+	seq := func(yield func(any) bool) {
+		for i := range 1000 {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	if err := e.AppendFrom(&buf, seq); err != nil {
+		t.Fatal(err)
+	}
+	readAry, err := ReadArray(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := readAry.Len(); l != 1000 {
+		t.Fatal(l)
+	}
+	if v, err := readAry.Index(999, true); err != nil || v != int64(999) {
+		t.Fatal(v, err)
+	}
+
+	var encodeErr *EncodeError
+	if err := e.AppendFrom(&buf, slices.Values([]any{1, func() {}})); !errors.As(err, &encodeErr) || !slices.Equal(encodeErr.Path, []string{"1"}) {
+		t.Fatal(err)
+	}
+}
+
 func TestReadWriteObject(t *testing.T) {
 	gobEncoder := NewGobEncoder()
 	obj := map[string]any{
@@ -371,6 +466,337 @@ func TestReadWriteObject(t *testing.T) {
 	}
 }
 
+func Test_genBuckets(t *testing.T) {
+	entries := make([]bucketKV, 1000)
+	for i := range entries {
+		key := strconv.Itoa(i)
+		entries[i] = bucketKV{stringHash(key), key, i}
+	}
+	for _, shift := range []byte{0, 64 - 9} {
+		bucketCount := 509
+		if shift != 0 {
+			bucketCount = 512
+		}
+		buckets, _ := genBuckets(entries, bucketCount, shift)
+		// The same as appending every entry to its bucket.
+		want := make([][]bucketKV, bucketCount)
+		for _, entry := range entries {
+			i := bucketIndex(entry.H, uint64(bucketCount), shift)
+			want[i] = append(want[i], entry)
+		}
+		if !reflect.DeepEqual(buckets, want) {
+			t.Fatal(shift)
+		}
+	}
+}
+
+func TestWriteLargeObject(t *testing.T) {
+	obj := make(map[string]any, parallelThreshold*2)
+	for i := range parallelThreshold * 2 {
+		obj[strconv.Itoa(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := (&Encoder{}).WriteObject(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+	o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, parallelThreshold, parallelThreshold*2 - 1} {
+		if v, err := o.Index(strconv.Itoa(i), true); err != nil || v != int64(i) {
+			t.Fatal(i, v, err)
+		}
+	}
+}
+
+func Test_minOffsetSize(t *testing.T) {
+	for _, test := range []struct {
+		count, data uint64
+		want        byte
+	}{
+		{0, 0, 1},
+		{10, 245, 1},
+		{10, 246, 2},
+		{1000, 70000, 3},
+		{1 << 30, 1 << 40, 6},
+	} {
+		size := minOffsetSize(func(size byte) uint64 {
+			return test.data + test.count*uint64(size)
+		})
+		if size != test.want {
+			t.Fatal(test, size)
+		}
+	}
+	if size := minOffsetSize(func(size byte) uint64 { return math.MaxUint64 }); size != 8 {
+		t.Fatal(size)
+	}
+
+	// Not rounded up to a power of 2.
+	ary := make([]any, 11)
+	for i := range ary {
+		ary[i] = make([]byte, 7000+i)
+	}
+	var buf bytes.Buffer
+	if err := (&Encoder{}).WriteArray(&buf, ary); err != nil {
+		t.Fatal(err)
+	}
+	if size := typeMarker(buf.Bytes()[0]).OffsetSize(); size != 3 {
+		t.Fatal(size)
+	}
+	if v, err := ReadValue(bytes.NewReader(buf.Bytes()), true); err != nil || !reflect.DeepEqual(v, ary) {
+		t.Fatal(err)
+	}
+}
+
+// sparseReader reads size bytes, which are zeros except the parts, so that
+// huge values can be tested without the memory of them.
+type sparseReader struct {
+	parts map[int64][]byte // parts by their positions
+	size  int64
+	pos   int64
+}
+
+func (r *sparseReader) ReadByte() (b byte, err error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	for pos, part := range r.parts {
+		if r.pos >= pos && r.pos < pos+int64(len(part)) {
+			b = part[r.pos-pos]
+		}
+	}
+	r.pos++
+	return
+}
+
+func (r *sparseReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if p[n], err = r.ReadByte(); err != nil {
+			if n > 0 {
+				err = nil
+			}
+			return
+		}
+		n++
+	}
+	return
+}
+
+func (r *sparseReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func TestLargeContainers(t *testing.T) {
+	const bigSize = 5 << 30 // The size of the binary making containers larger than 4 GiB.
+	var big bytes.Buffer
+	if err := writeTypeMarker(&big, typeBinary, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeUintValue(&big, bigSize); err != nil {
+		t.Fatal(err)
+	}
+	var small bytes.Buffer
+	if err := (&Encoder{}).WriteValue(&small, "small"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An array of the big binary and a small string.
+	var head bytes.Buffer
+	if err := writeTypeMarker(&head, typeArray, 5); err != nil {
+		t.Fatal(err)
+	}
+	smallOffset := uint64(2*5+big.Len()) + bigSize
+	for _, n := range []uint64{2, 2 * 5, smallOffset} {
+		if err := writeFixedUint(&head, n, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tableStart := int64(1 + 5)
+	head.Write(big.Bytes())
+	r := &sparseReader{
+		parts: map[int64][]byte{0: head.Bytes(), tableStart + int64(smallOffset): small.Bytes()},
+		size:  tableStart + int64(smallOffset) + int64(small.Len()),
+	}
+	array, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := array.Index(1, true); err != nil || v != "small" {
+		t.Fatal(v, err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := SkipValue(r); err != nil || r.pos != r.size {
+		t.Fatal(r.pos, err)
+	}
+
+	// An object of 2 buckets, whose second chain is after the big binary.
+	var keys [2]string
+	for i := 0; keys[0] == "" || keys[1] == ""; i++ {
+		key := strconv.Itoa(i)
+		keys[bucketIndex(stringHash(key), 2, 0)] = key
+	}
+	var chain0, chain1 bytes.Buffer
+	for _, chain := range []struct {
+		buf   *bytes.Buffer
+		key   string
+		value *bytes.Buffer
+		size  uint64
+	}{
+		{&chain0, keys[0], &big, uint64(big.Len()) + bigSize},
+		{&chain1, keys[1], &small, uint64(small.Len())},
+	} {
+		if err := writeUintValue(chain.buf, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeStringValue(chain.buf, chain.key); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeUintValue(chain.buf, chain.size); err != nil {
+			t.Fatal(err)
+		}
+		chain.buf.Write(chain.value.Bytes())
+	}
+	head.Reset()
+	if err := writeTypeMarker(&head, typeObject, 5); err != nil {
+		t.Fatal(err)
+	}
+	head.WriteByte(2) // bucket count
+	chain1Offset := uint64(2*5+chain0.Len()) + bigSize
+	for _, n := range []uint64{2 * 5, chain1Offset} {
+		if err := writeFixedUint(&head, n, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tableStart = int64(head.Len() - 2*5)
+	head.Write(chain0.Bytes())
+	r = &sparseReader{
+		parts: map[int64][]byte{0: head.Bytes(), tableStart + int64(chain1Offset): chain1.Bytes()},
+		size:  tableStart + int64(chain1Offset) + int64(chain1.Len()),
+	}
+	obj, err := ReadObject(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := obj.Index(keys[1], true); err != nil || v != "small" {
+		t.Fatal(v, err)
+	}
+	if err := obj.Seek(keys[0]); err != nil {
+		t.Fatal(err)
+	} else if _, typ, err := readTypeMarker(r); err != nil || typ != typeBinary {
+		t.Fatal(typ, err)
+	}
+	stats, err := obj.BucketStats()
+	if err != nil || stats.Entries != 2 || stats.MaxChainSize <= bigSize {
+		t.Fatal(stats, err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := SkipValue(r); err != nil || r.pos != r.size {
+		t.Fatal(r.pos, err)
+	}
+}
+
+func TestSkipValue(t *testing.T) {
+	values := []any{
+		nil,
+		int64(-129),
+		uint64(0xFFFF + 1),
+		true,
+		"abc",
+		1.625,
+		[]byte{1, 2, 3},
+		[]any{},
+		[]any{"a", "b"},
+		[]any{int64(1), "2", []any{map[string]any{"k": "v"}}},
+		map[string]any{},
+		map[string]any{"1": "123", "2": []any{int64(1), "abc"}, "3": map[string]any{"4": nil}},
+	}
+	var buf bytes.Buffer
+	var ends []int64
+	for _, v := range values {
+		if err := WriteValue(&buf, v, nil); err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, int64(buf.Len()))
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	for i, end := range ends {
+		if err := SkipValue(r); err != nil {
+			t.Fatal(i, err)
+		}
+		if pos, _ := r.Seek(0, io.SeekCurrent); pos != end {
+			t.Fatalf("SkipValue(%v) = %v, want %v", values[i], pos, end)
+		}
+	}
+
+	// ReadValue ends at the same position.
+	r = bytes.NewReader(buf.Bytes())
+	for i, end := range ends {
+		if _, err := ReadValue(r, true); err != nil {
+			t.Fatal(i, err)
+		}
+		if pos, _ := r.Seek(0, io.SeekCurrent); pos != end {
+			t.Fatalf("ReadValue(%v) ends at %v, want %v", values[i], pos, end)
+		}
+	}
+}
+
+func TestSkipValueInvalidSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteValue(&buf, map[string]any{"a": nil}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Replace the value size 1 after key "a" with math.MaxUint64.
+	data := buf.Bytes()
+	i := bytes.IndexByte(data, 'a') + 1
+	if data[i] != 1 {
+		t.Fatal(data)
+	}
+	data = slices.Concat(data[:i], []byte{0xF8, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, data[i+1:])
+	if err := SkipValue(bytes.NewReader(data)); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func TestObjectValueNested(t *testing.T) {
+	// Many keys make some nested values in the same bucket list,
+	// and the last bucket of the nested objects is empty.
+	obj := make(map[string]any)
+	for i := range 100 {
+		obj[strconv.Itoa(i)] = map[string]any{"Name": "Joe", "Age": int64(i), "Addr": "abc street"}
+	}
+	var buf bytes.Buffer
+	if err := WriteObject(&buf, obj, nil); err != nil {
+		t.Fatal(err)
+	}
+	readObj, err := ReadObject(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := readObj.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(obj, read) {
+		t.Fatal(read)
+	}
+}
+
 func TestByteReadSeeker(t *testing.T) {
 	data := make([]byte, 100)
 	for i := range data {
@@ -404,6 +830,78 @@ func TestByteReadSeeker(t *testing.T) {
 
 }
 
+func TestByteReadSeekerSeekCurrent(t *testing.T) {
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	br, err := NewBufByteReadSeeker(bytes.NewReader(data), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := br.ReadByte(); err != nil {
+		t.Fatal(err)
+	} else if b != 0 {
+		t.Fatal(b)
+	}
+	// Seeks to the current position with io.SeekStart
+	// after the buffer is filled.
+	if _, err := br.Seek(1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	var p = make([]byte, 20)
+	if _, err := io.ReadFull(br, p); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, data[1:21]) {
+		t.Fatal(p)
+	}
+}
+
+func TestAdaptiveBufByteReadSeeker(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	brs, err := NewAdaptiveBufByteReadSeeker(bytes.NewReader(data), 64, 32, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := brs.(*bufByteReadSeeker)
+	// Scanning grows the buffer.
+	p := make([]byte, 3000)
+	for i := range p {
+		if p[i], err = br.ReadByte(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(p, data[:3000]) || br.buf.Size() != 1024 {
+		t.Fatal(br.buf.Size())
+	}
+	// Point lookups shrink the buffer.
+	for i := range 10 {
+		pos := int64((9 - i) * 1000)
+		if _, err := br.Seek(pos, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := br.ReadByte(); err != nil || b != data[pos] {
+			t.Fatal(b, err)
+		}
+	}
+	if br.buf.Size() != 32 {
+		t.Fatal(br.buf.Size())
+	}
+	// Skips buffered data.
+	if _, err := br.Seek(20, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := br.Seek(0, io.SeekCurrent); err != nil || pos != 20 || br.bufOffset != 0 {
+		t.Fatal(pos, br.bufOffset, err)
+	}
+	if _, err := io.ReadFull(br, p); err != nil || !bytes.Equal(p, data[20:3020]) {
+		t.Fatal(err)
+	}
+}
+
 func TestByteReadSeeker2(t *testing.T) {
 	var buf bytes.Buffer
 	err := WriteArray(&buf, []any{1, 2, 3}, nil)
@@ -454,3 +952,499 @@ func TestByteReadSeeker3(t *testing.T) {
 		t.Fatal(v)
 	}
 }
+
+func TestStringHash(t *testing.T) {
+	for _, s := range []string{"", "a", "abc", "hashive", strings.Repeat("x", 1000)} {
+		h := fnv.New64a()
+		io.WriteString(h, s)
+		if got, want := stringHash(s), h.Sum64(); got != want {
+			t.Fatalf("%q: %v != %v", s, got, want)
+		}
+	}
+}
+
+var uintValueTests = []uint64{
+	0, 1, 127, 128, 255, 256, 0xFFFF, 0x1_0000, 0xFF_FFFF, 0x100_0000,
+	0xFFFF_FFFF, 0x1_0000_0000, 0xFF_FFFF_FFFF, 1 << 48, 1 << 56, math.MaxUint64,
+}
+
+func TestUintValue(t *testing.T) {
+	for _, n := range uintValueTests {
+		p := appendUintValue(nil, n)
+		// All the writers produce the same encoding.
+		var buf bytes.Buffer
+		if err := writeUintValue(&buf, n); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(buf.Bytes(), p) {
+			t.Fatalf("%v: %v != %v", n, buf.Bytes(), p)
+		}
+		var bw bytes.Buffer
+		w := bufio.NewWriter(&bw)
+		if err := writeUintValue(w, n); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		if !bytes.Equal(bw.Bytes(), p) {
+			t.Fatalf("%v: %v != %v", n, bw.Bytes(), p)
+		}
+		var ww bytes.Buffer
+		if err := writeUintValue(struct{ io.Writer }{&ww}, n); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(ww.Bytes(), p) {
+			t.Fatalf("%v: %v != %v", n, ww.Bytes(), p)
+		}
+
+		got, err := readUintValue(bytes.NewReader(p))
+		if err != nil {
+			t.Fatal(err)
+		} else if got != n {
+			t.Fatalf("%v != %v", got, n)
+		}
+		if _, err := readUintValue(bytes.NewReader(p[:len(p)-1])); len(p) > 1 && err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteUintValue(b *testing.B) {
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		for _, n := range uintValueTests {
+			writeUintValue(&buf, n)
+		}
+	}
+}
+
+func BenchmarkReadUintValue(b *testing.B) {
+	var buf bytes.Buffer
+	for _, n := range uintValueTests {
+		writeUintValue(&buf, n)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	for b.Loop() {
+		r.Seek(0, io.SeekStart)
+		for range uintValueTests {
+			if _, err := readUintValue(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestReadValueDepth(t *testing.T) {
+	var v any = "leaf"
+	for i := range 10 {
+		if i%2 == 0 {
+			v = []any{v}
+		} else {
+			v = map[string]any{"k": v}
+		}
+	}
+	var buf bytes.Buffer
+	if err := WriteValue(&buf, v, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if read, err := ReadValueDepth(bytes.NewReader(buf.Bytes()), true, 10, 0); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(read, v) {
+		t.Fatal(read)
+	}
+	var depthErr *DepthError
+	if _, err := ReadValueDepth(bytes.NewReader(buf.Bytes()), true, 9, 0); !errors.As(err, &depthErr) {
+		t.Fatal(err)
+	} else if depthErr.MaxDepth != 9 {
+		t.Fatal(depthErr.MaxDepth)
+	}
+	// Containers read lazily are limited too.
+	array, obj, err := ReadContainer(bytes.NewReader(buf.Bytes()), 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if array != nil {
+		t.Fatal(array)
+	}
+	if _, err := obj.Index("k", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obj.Index("k", true); !errors.As(err, &depthErr) {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadContainer(bytes.NewReader(buf.Bytes()), 0, 0); !errors.As(err, &depthErr) {
+		t.Fatal(err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("prefix")
+	value := map[string]any{"a": []any{"x", int64(1)}, "b": map[string]any{}}
+	if err := WriteValue(&buf, value, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	r.Seek(6, io.SeekStart)
+	var paths []string
+	err := Walk(r, DefaultMaxDepth, func(path []string, t Type, offset, size int64) error {
+		paths = append(paths, strings.Join(path, "/"))
+		if len(path) == 0 && (offset != 6 || size != int64(buf.Len()-6)) {
+			return fmt.Errorf("root at %v of %v", offset, size)
+		}
+		if t == TypeObject && len(path) == 1 {
+			return ErrSkipChildren
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := r.Seek(0, io.SeekCurrent); pos != int64(buf.Len()) {
+		t.Fatal(pos)
+	}
+	slices.Sort(paths)
+	if want := []string{"", "a", "a/0", "a/1", "b"}; !slices.Equal(paths, want) {
+		t.Fatal(paths)
+	}
+}
+
+func TestRef(t *testing.T) {
+	var buf bytes.Buffer
+	target := []any{"a", int64(1)}
+	if err := WriteValue(&buf, target, nil); err != nil {
+		t.Fatal(err)
+	}
+	start := buf.Len()
+	if err := WriteValue(&buf, []any{Ref(0), Ref(0), "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	r.Seek(int64(start), io.SeekStart)
+	if v, err := ReadValue(r, true); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []any{target, target, "b"}) {
+		t.Fatal(v)
+	}
+	r.Seek(int64(start), io.SeekStart)
+	if err := SkipValue(r); err != nil {
+		t.Fatal(err)
+	} else if r.Len() != 0 {
+		t.Fatal(r.Len())
+	}
+
+	// Refs must point backward.
+	buf.Reset()
+	WriteRef(&buf, Ref(0))
+	if _, err := ReadValue(bytes.NewReader(buf.Bytes()), true); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func TestFrontCoding(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 3000 {
+		obj[fmt.Sprintf("AC:%02X:%X", i%7, i)] = i
+	}
+	obj[""] = "empty"
+	var plain, prefixed bytes.Buffer
+	if err := WriteObject(&plain, obj, nil); err != nil {
+		t.Fatal(err)
+	}
+	encoder := &Encoder{FrontCoding: true}
+	if err := encoder.WriteValue(&prefixed, []any{obj, "end"}); err != nil {
+		t.Fatal(err)
+	}
+	if prefixed.Len() >= plain.Len() {
+		t.Fatal(prefixed.Len(), plain.Len())
+	}
+
+	r := bytes.NewReader(prefixed.Bytes())
+	ary, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ary.Index(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := v.(*Object)
+	for key, value := range obj {
+		if v, err := o.Index(key, true); err != nil {
+			t.Fatal(key, err)
+		} else if !reflect.DeepEqual(v, int64OrString(value)) {
+			t.Fatal(key, v)
+		}
+	}
+	for i := range 3000 {
+		for _, key := range []string{fmt.Sprintf("AC:%02X:%X0", i%7, i), fmt.Sprintf("AC:%02X:%X", i%7+1, i), fmt.Sprintf("AC:%02X:", i%7), "B"} {
+			if _, ok := obj[key]; ok {
+				continue
+			}
+			if _, err := o.Index(key, false); err != ErrNotFound {
+				t.Fatal(key, err)
+			}
+		}
+	}
+	if all, err := o.Value(); err != nil {
+		t.Fatal(err)
+	} else if len(all) != len(obj) || all[""] != "empty" || all["AC:01:1"] != int64(1) {
+		t.Fatal(len(all))
+	}
+	if end, err := ary.Index(1, true); err != nil {
+		t.Fatal(err)
+	} else if end != "end" {
+		t.Fatal(end)
+	}
+	r.Seek(0, io.SeekStart)
+	if err := SkipValue(r); err != nil {
+		t.Fatal(err)
+	} else if r.Len() != 0 {
+		t.Fatal(r.Len())
+	}
+}
+
+func TestSortedChains(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 3000 {
+		obj[fmt.Sprint(i)] = i
+	}
+	obj[""] = "empty"
+	for _, encoder := range []*Encoder{{SortedKeys: true}, {HashOrder: true}} {
+		var buf bytes.Buffer
+		if err := encoder.WriteValue(&buf, []any{obj, "end"}); err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(buf.Bytes())
+		ary, err := ReadArray(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := ary.Index(0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := v.(*Object)
+		for key, value := range obj {
+			if v, err := o.Index(key, true); err != nil {
+				t.Fatal(key, err)
+			} else if !reflect.DeepEqual(v, int64OrString(value)) {
+				t.Fatal(key, v)
+			}
+		}
+		for i := range 3000 {
+			key := fmt.Sprint(i + 3000)
+			if _, err := o.Index(key, false); err != ErrNotFound {
+				t.Fatal(key, err)
+			}
+		}
+		if all, err := o.Value(); err != nil {
+			t.Fatal(err)
+		} else if len(all) != len(obj) || all[""] != "empty" || all["1"] != int64(1) {
+			t.Fatal(len(all))
+		}
+		if keys, _, more, err := o.Keys(Cursor{}, len(obj)); err != nil || more || len(keys) != len(obj) {
+			t.Fatal(len(keys), more, err)
+		}
+		if end, err := ary.Index(1, true); err != nil {
+			t.Fatal(err)
+		} else if end != "end" {
+			t.Fatal(end)
+		}
+	}
+}
+
+func TestPow2Buckets(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 1000 {
+		obj[fmt.Sprint(i)] = i
+	}
+	for _, encoder := range []*Encoder{
+		{Pow2Buckets: true},
+		{Pow2Buckets: true, SortedKeys: true},
+		{Pow2Buckets: true, HashOrder: true},
+		{Pow2Buckets: true, FrontCoding: true},
+	} {
+		var buf bytes.Buffer
+		if err := encoder.WriteValue(&buf, []any{obj, map[string]any{}, "end"}); err != nil {
+			t.Fatal(err)
+		}
+		ary, err := ReadArray(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := ary.Index(0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := v.(*Object)
+		if o.shift == 0 || o.bucketCount&(o.bucketCount-1) != 0 || o.bucketCount < 1000*4/3 {
+			t.Fatal(o.bucketCount, o.shift)
+		}
+		for key, value := range obj {
+			if v, err := o.Index(key, true); err != nil || v != int64OrString(value) {
+				t.Fatal(key, v, err)
+			}
+		}
+		if _, err := o.Index("1000", false); err != ErrNotFound {
+			t.Fatal(err)
+		}
+		if found, err := o.ContainsKeys([]string{"1", "999", "500"}, false); err != nil || !found {
+			t.Fatal(found, err)
+		}
+		if all, err := o.Value(); err != nil || len(all) != len(obj) {
+			t.Fatal(len(all), err)
+		}
+		if loaded, err := o.LoadDirectory(0); err != nil || loaded == encoder.FrontCoding {
+			t.Fatal(loaded, err)
+		}
+		if v, err := o.Index("999", true); err != nil || v != int64(999) {
+			t.Fatal(v, err)
+		}
+		if empty, err := ary.Index(1, true); err != nil || !reflect.DeepEqual(empty, map[string]any{}) {
+			t.Fatal(empty, err)
+		}
+		if end, err := ary.Index(2, true); err != nil || end != "end" {
+			t.Fatal(end, err)
+		}
+	}
+}
+
+// int64OrString returns v as it is read.
+func int64OrString(v any) any {
+	if n, ok := v.(int); ok {
+		return int64(n)
+	}
+	return v
+}
+
+func TestOversizedLength(t *testing.T) {
+	// Lengths larger than the data must fail without allocating them.
+	const length = 1 << 30 // below math.MaxInt32 of 32-bit platforms
+	var buf bytes.Buffer
+	for _, tp := range []typ{typeString, typeBinary} {
+		buf.Reset()
+		buf.WriteByte(byte(tp))
+		writeUintValue(&buf, length)
+		buf.WriteString("short")
+		if _, err := ReadValue(&byteReadSeeker{ReadSeeker: bytes.NewReader(buf.Bytes())}, true); err != io.ErrUnexpectedEOF {
+			t.Fatal(tp, err)
+		}
+		if _, err := appendBinary(nil, &byteReadSeeker{ReadSeeker: bytes.NewReader(buf.Bytes())}, tp); err != io.ErrUnexpectedEOF {
+			t.Fatal(tp, err)
+		}
+	}
+	buf.Reset()
+	buf.WriteByte(byte(newTypeMarker(typeFixedArray, 8)))
+	writeFixedUint(&buf, length, 8)
+	writeFixedUint(&buf, 1, 8)
+	buf.WriteByte(byte(typeNull))
+	if _, err := ReadValue(&byteReadSeeker{ReadSeeker: bytes.NewReader(buf.Bytes())}, true); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	errInjected := errors.New("injected")
+	defer func() { writeHook = nil }()
+	value := map[string]any{
+		"ints":      []any{1, -1, uint(1000), 1.5, true, nil},
+		"strs":      []any{"a", "bc", []byte("def")},
+		"obj":       map[string]any{"x": "y", "xy": map[string]any{}, "z": []any{}},
+		"fixed":     map[string]any{"aa": 1, "bb": 2},
+		"-1":        map[string]any{"1": "one", "2": "two"},
+		"ref":       Ref(1),
+		"intervals": []Interval{{Start: 0, End: 10, Value: "a"}, {Start: 5, End: 6, Value: 1}},
+		"gob":       struct{ X int }{1},
+	}
+	for _, e := range []*Encoder{
+		{},
+		{FrontCoding: true},
+		{HashOrder: true},
+		{SortedKeys: true},
+		{FixedKeys: true, IntKeys: true},
+		{Legacy: true},
+	} {
+		e.Gob = NewGobEncoder()
+		// Count the writes, then fail each of them in turn.
+		var writes int
+		writeHook = func() error {
+			writes++
+			return nil
+		}
+		if err := e.WriteValue(&bytes.Buffer{}, value); err != nil {
+			t.Fatal(err)
+		}
+		for i := range writes {
+			n := 0
+			writeHook = func() error {
+				if n++; n == i+1 {
+					return errInjected
+				}
+				return nil
+			}
+			if err := e.WriteValue(&bytes.Buffer{}, value); !errors.Is(err, errInjected) {
+				t.Fatalf("%+v: write %v: %v", *e, i, err)
+			}
+		}
+	}
+}
+
+func TestMaxBuckets(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 100 {
+		obj[fmt.Sprint(i)] = i
+	}
+	for _, test := range []struct {
+		encoder *Encoder
+		buckets uint64
+	}{
+		{&Encoder{MaxBuckets: 1}, 1},
+		{&Encoder{MaxBuckets: 7, PrimeTable: true}, 7},
+		{&Encoder{MaxBuckets: 1000}, uint64(nearestPrime(100 * 4 / 3))},
+		{&Encoder{MaxBuckets: 7, Pow2Buckets: true}, 4},
+		{&Encoder{MaxBuckets: 1, Pow2Buckets: true, HashOrder: true}, 1},
+	} {
+		var buf bytes.Buffer
+		if err := test.encoder.WriteObject(&buf, obj); err != nil {
+			t.Fatal(err)
+		}
+		o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.bucketCount != test.buckets {
+			t.Fatal(test.buckets, o.bucketCount)
+		}
+		for key, value := range obj {
+			if v, err := o.Index(key, true); err != nil || v != int64OrString(value) {
+				t.Fatal(key, v, err)
+			}
+		}
+		if _, err := o.Index("100", false); err != ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWriteValueInt8(t *testing.T) {
+	for _, test := range []struct {
+		v, want any
+	}{
+		{int8(-1), int64(-1)},
+		{int8(-128), int64(-128)},
+		{int8(127), int64(127)},
+		{uint8(0), uint64(0)},
+		{uint8(255), uint64(255)},
+	} {
+		var buf bytes.Buffer
+		if err := WriteValue(&buf, test.v, nil); err != nil {
+			t.Fatal(err)
+		}
+		v, err := ReadValue(bytes.NewReader(buf.Bytes()), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.want {
+			t.Fatalf("%T(%v): got %T(%v), want %T(%v)", test.v, test.v, v, v, test.want, test.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package impl
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Interval is a value associated with the integers in [Start, End].
+type Interval struct {
+	Start, End int64
+	Value      any
+}
+
+// A [typeIntervals] is a []Interval sorted by start, which answers stabbing
+// queries. The layout is: type mark, number of intervals, the table of
+// intervals and then the values. Each entry of the table is start, end,
+// the max end of the intervals before and including it, all of which are
+// 8-byte integers, followed by the offset of the value. The table ends with
+// the offset of the end of the container. Offsets are relative to the start
+// of the table.
+
+// intervalEntrySize returns the size of an entry of the interval table.
+func intervalEntrySize(offsetSize byte) int64 {
+	return 3*8 + int64(offsetSize)
+}
+
+// WriteIntervals writes intervals to w as a [typeIntervals].
+func (e *Encoder) WriteIntervals(w io.Writer, intervals []Interval) (err error) {
+	for _, interval := range intervals {
+		if interval.Start > interval.End {
+			return &EncodeError{Err: fmt.Errorf("invalid interval [%v, %v]", interval.Start, interval.End)}
+		}
+	}
+	sorted := slices.Clone(intervals)
+	slices.SortStableFunc(sorted, func(a, b Interval) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	data := getBuffer()
+	defer putBuffer(data)
+	offsets := make([]int, len(sorted)+1)
+	for i, interval := range sorted {
+		offsets[i] = data.Len()
+		if err = e.WriteValue(data, interval.Value); err != nil {
+			// Indices of intervals are in the sorted order, as they are read.
+			return EncodeErrorAt(err, strconv.Itoa(i))
+		}
+	}
+	offsets[len(sorted)] = data.Len()
+
+	tableSize := func(size byte) int {
+		return len(sorted)*int(intervalEntrySize(size)) + int(size)
+	}
+	offsetSize := minOffsetSize(func(size byte) uint64 {
+		return uint64(tableSize(size) + data.Len())
+	})
+	delta := tableSize(offsetSize)
+
+	header := getBuffer()
+	defer putBuffer(header)
+	if err = writeTypeMarker(header, typeIntervals, offsetSize); err != nil {
+		return
+	}
+	if err = writeUintValue(header, uint64(len(sorted))); err != nil {
+		return
+	}
+	maxEnd := int64(math.MinInt64)
+	for i, interval := range sorted {
+		maxEnd = max(maxEnd, interval.End)
+		for _, n := range [...]uint64{uint64(interval.Start), uint64(interval.End), uint64(maxEnd)} {
+			if err = writeFixedUint(header, n, 8); err != nil {
+				return
+			}
+		}
+		if err = writeFixedUint(header, uint64(offsets[i]+delta), offsetSize); err != nil {
+			return
+		}
+	}
+	if err = writeFixedUint(header, uint64(offsets[len(sorted)]+delta), offsetSize); err != nil {
+		return
+	}
+	return copyBuffers(w, header, data)
+}
+
+// Intervals is a descriptor of []Interval read from a stream.
+type Intervals struct {
+	r          ByteReadSeeker
+	pos        int64 // the position of the table
+	length     uint64
+	offsetSize byte
+	limit      depthLimit
+}
+
+// readIntervalsValue reads the descriptor of a [typeIntervals] from r after the type mark.
+func readIntervalsValue(r ByteReadSeeker, offsetSize byte) (intervals *Intervals, err error) {
+	length, err := readUintValue(r)
+	if err != nil {
+		return
+	}
+	if offsetSize < 1 || offsetSize > 8 || length > math.MaxInt64/uint64(intervalEntrySize(offsetSize)) {
+		err = fmt.Errorf("invalid intervals of length %v, offset size %v", length, offsetSize)
+		return
+	}
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	intervals = &Intervals{
+		r:          r,
+		pos:        pos,
+		length:     length,
+		offsetSize: offsetSize,
+		limit:      depthLimit{depth: 1, max: DefaultMaxDepth},
+	}
+	return
+}
+
+// Len returns the number of intervals.
+func (intervals *Intervals) Len() int {
+	return int(intervals.length)
+}
+
+// entry reads the i-th entry of the table. Only the offset is read if i is
+// the number of intervals.
+func (intervals *Intervals) entry(i uint64) (start, end, maxEnd int64, pos int64, err error) {
+	entryPos := intervals.pos + int64(i)*intervalEntrySize(intervals.offsetSize)
+	if _, err = intervals.r.Seek(entryPos, io.SeekStart); err != nil {
+		return
+	}
+	if i < intervals.length {
+		var n uint64
+		if n, err = readFixedUint(intervals.r, 8); err != nil {
+			return
+		}
+		start = int64(n)
+		if n, err = readFixedUint(intervals.r, 8); err != nil {
+			return
+		}
+		end = int64(n)
+		if n, err = readFixedUint(intervals.r, 8); err != nil {
+			return
+		}
+		maxEnd = int64(n)
+	}
+	offset, err := readFixedUint(intervals.r, intervals.offsetSize)
+	if err != nil {
+		return
+	}
+	if offset > math.MaxInt64-uint64(intervals.pos) {
+		err = fmt.Errorf("invalid offset %v", offset)
+		return
+	}
+	pos = intervals.pos + int64(offset)
+	return
+}
+
+// interval reads the i-th interval.
+func (intervals *Intervals) interval(start, end int64, pos int64, recursive bool) (interval Interval, err error) {
+	if _, err = intervals.r.Seek(pos, io.SeekStart); err != nil {
+		return
+	}
+	v, err := readValue(intervals.r, recursive, intervals.limit)
+	if err != nil {
+		return
+	}
+	return Interval{start, end, v}, nil
+}
+
+// Value reads and returns all the intervals sorted by start.
+func (intervals *Intervals) Value() (v []Interval, err error) {
+	v = make([]Interval, 0, min(intervals.length, maxPrealloc))
+	for i := range intervals.length {
+		start, end, _, pos, err := intervals.entry(i)
+		if err != nil {
+			return nil, err
+		}
+		interval, err := intervals.interval(start, end, pos, true)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, interval)
+	}
+	return v, intervals.skip()
+}
+
+// Stab returns the intervals containing point sorted by start, and their
+// indices in all the intervals. See [Array.Index] for the meaning of recursive.
+func (intervals *Intervals) Stab(point int64, recursive bool) (indices []int, v []Interval, err error) {
+	// Binary search the number of intervals starting <= point.
+	lo, hi := uint64(0), intervals.length
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		var start int64
+		if start, _, _, _, err = intervals.entry(mid); err != nil {
+			return
+		}
+		if start <= point {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	// Scan backward until no interval before can reach point.
+	for i := lo; i > 0; i-- {
+		start, end, maxEnd, pos, err := intervals.entry(i - 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if maxEnd < point {
+			break
+		}
+		if end < point {
+			continue
+		}
+		interval, err := intervals.interval(start, end, pos, recursive)
+		if err != nil {
+			return nil, nil, err
+		}
+		indices = append(indices, int(i-1))
+		v = append(v, interval)
+	}
+	slices.Reverse(indices)
+	slices.Reverse(v)
+	return
+}
+
+// skip seeks to the end of intervals.
+func (intervals *Intervals) skip() (err error) {
+	_, _, _, end, err := intervals.entry(intervals.length)
+	if err != nil {
+		return
+	}
+	_, err = intervals.r.Seek(end, io.SeekStart)
+	return
+}
@@ -0,0 +1,87 @@
+package impl
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestIntervals(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	var intervals []Interval
+	for i := range 300 {
+		start := rnd.Int64N(1000) - 500
+		intervals = append(intervals, Interval{start, start + rnd.Int64N(50), int64(i)})
+	}
+	intervals = append(intervals, Interval{math.MinInt64, math.MinInt64 + 1, "min"}, Interval{math.MaxInt64, math.MaxInt64, "max"})
+	var buf bytes.Buffer
+	if err := (&Encoder{}).WriteValue(&buf, []any{intervals, "end"}); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	ary, err := ReadArray(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ary.Index(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := v.(*Intervals)
+	if container.Len() != len(intervals) {
+		t.Fatal(container.Len())
+	}
+	for _, point := range []int64{math.MinInt64, math.MinInt64 + 2, -600, -500, -1, 0, 1, 200, 499, 548, 549, 600, math.MaxInt64} {
+		var want []Interval
+		for _, interval := range intervals {
+			if interval.Start <= point && point <= interval.End {
+				want = append(want, interval)
+			}
+		}
+		_, got, err := container.Stab(point, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatal(point, got, want)
+		}
+		for i := range got {
+			if i > 0 && got[i-1].Start > got[i].Start {
+				t.Fatal(point, got)
+			}
+		}
+	}
+	if all, err := container.Value(); err != nil {
+		t.Fatal(err)
+	} else if len(all) != len(intervals) {
+		t.Fatal(len(all))
+	}
+	if v, err := ary.Index(1, true); err != nil || v != "end" {
+		t.Fatal(v, err)
+	}
+	r.Reset(buf.Bytes())
+	if v, err := ReadValue(r, true); err != nil {
+		t.Fatal(err)
+	} else if all := v.([]any)[0].([]Interval); len(all) != len(intervals) {
+		t.Fatal(len(all))
+	}
+	if err := (&Encoder{}).WriteValue(&buf, []Interval{{2, 1, nil}}); err == nil {
+		t.Fatal("invalid interval")
+	}
+}
+
+func TestEmptyIntervals(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&Encoder{}).WriteValue(&buf, []Interval{}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := ReadValue(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []Interval{}) {
+		t.Fatal(v)
+	}
+}
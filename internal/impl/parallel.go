@@ -0,0 +1,32 @@
+package impl
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelThreshold is the number of items below which [parallelize] runs
+// in the calling goroutine, because starting goroutines costs more.
+const parallelThreshold = 1 << 16
+
+// parallelize calls fn with the consecutive ranges [lo, hi) covering [0, n)
+// in multiple goroutines if n is large enough, and returns after all of
+// them return. The ranges are disjoint, so fn can write the items in its
+// range without locks.
+func parallelize(n int, fn func(lo, hi int)) {
+	workers := min(runtime.GOMAXPROCS(0), n/parallelThreshold)
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+	size := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += size {
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			fn(lo, hi)
+		}(lo, min(lo+size, n))
+	}
+	wg.Wait()
+}
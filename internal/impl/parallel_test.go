@@ -0,0 +1,27 @@
+package impl
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func Test_parallelize(t *testing.T) {
+	for _, n := range []int{0, 1, parallelThreshold, parallelThreshold*4 + 1} {
+		counts := make([]int32, n)
+		var calls atomic.Int32
+		parallelize(n, func(lo, hi int) {
+			calls.Add(1)
+			for i := lo; i < hi; i++ {
+				counts[i]++
+			}
+		})
+		for i, count := range counts {
+			if count != 1 {
+				t.Fatal(n, i, count)
+			}
+		}
+		if calls.Load() < 1 {
+			t.Fatal(n)
+		}
+	}
+}
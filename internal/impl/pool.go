@@ -0,0 +1,55 @@
+package impl
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledSize is the max capacity of buffers put back into pools.
+// Larger buffers are left to GC to avoid pinning memory.
+const maxPooledSize = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+// The buffer should be returned with putBuffer after use.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool.
+// buf must not be used after calling this function.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+var bytesPool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// getBytes returns a byte slice of length n from the pool.
+// The slice should be returned with putBytes after use.
+func getBytes(n int) *[]byte {
+	p := bytesPool.Get().(*[]byte)
+	if cap(*p) < n {
+		*p = make([]byte, n)
+	}
+	*p = (*p)[:n]
+	return p
+}
+
+// putBytes returns p to the pool.
+// p must not be used after calling this function.
+func putBytes(p *[]byte) {
+	if cap(*p) > maxPooledSize {
+		return
+	}
+	bytesPool.Put(p)
+}
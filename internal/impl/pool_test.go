@@ -0,0 +1,72 @@
+package impl
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestPooledWrite(t *testing.T) {
+	obj := map[string]any{
+		"a": "abc",
+		"b": []any{int64(1), "two", map[string]any{"c": 3.0}},
+		"d": map[string]any{"e": []byte{1, 2, 3}},
+	}
+	var want bytes.Buffer
+	if err := WriteObject(&want, obj, NewGobEncoder()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing concurrently with pooled buffers produces the same result.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				var buf bytes.Buffer
+				if err := WriteObject(&buf, obj, NewGobEncoder()); err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+					t.Error("mismatched output")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGetBytes(t *testing.T) {
+	p := getBytes(10)
+	if len(*p) != 10 {
+		t.Fatal(len(*p))
+	}
+	putBytes(p)
+	p = getBytes(3)
+	if len(*p) != 3 {
+		t.Fatal(len(*p))
+	}
+	putBytes(p)
+}
+
+func BenchmarkStringHash(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		stringHash("hashive")
+	}
+}
+
+func BenchmarkReadFixedUint(b *testing.B) {
+	b.ReportAllocs()
+	r := bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	for b.Loop() {
+		r.Seek(0, io.SeekStart)
+		if _, err := readFixedUint(r, 8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package impl
 import (
 	"math"
 	"math/big"
+	"slices"
+	"sync"
 )
 
 func isPrimeMillerRabin(n int) bool {
@@ -32,3 +34,25 @@ func nearestPrime(n int) (prime int) {
 	}
 	panic("can't find the nearest prime") // should not happen.
 }
+
+// primeTable returns the table of primes nearest to the numbers growing by
+// 1/4 from 2 to [math.MaxInt32], which is computed once.
+var primeTable = sync.OnceValue(func() (table []int) {
+	for n := 2; n < math.MaxInt32; n += min(max(n/4, 1), math.MaxInt32-n) {
+		if p := nearestPrime(n); len(table) == 0 || p > table[len(table)-1] {
+			table = append(table, p)
+		}
+	}
+	return
+})
+
+// tablePrime returns the smallest prime of [primeTable] no less than n,
+// or the prime nearest to n if n is greater than the table.
+// Panics if n < 0.
+func tablePrime(n int) int {
+	table := primeTable()
+	if i, _ := slices.BinarySearch(table, n); i < len(table) {
+		return table[i]
+	}
+	return nearestPrime(n)
+}
@@ -25,3 +25,21 @@ func Test_nearestPrime(t *testing.T) {
 		})
 	}
 }
+
+func Test_tablePrime(t *testing.T) {
+	table := primeTable()
+	for i, p := range table {
+		if !isPrimeMillerRabin(p) || i > 0 && p <= table[i-1] {
+			t.Fatal(i, p)
+		}
+	}
+	for _, n := range []int{0, 1, 8, 100, 1000, 12345, 1 << 20, math.MaxInt32} {
+		p := tablePrime(n)
+		if p < n && p != nearestPrime(n) || !isPrimeMillerRabin(p) {
+			t.Fatal(n, p)
+		}
+		if n >= 8 && p-n > n/4+2 {
+			t.Fatal(n, p)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package impl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// File signatures of databases. The last byte is the format version.
+const (
+	// FileSignature is the signature of the files without a header.
+	FileSignature = "hashive\x00"
+	// FileSignatureHeader is the signature of the files with a header,
+	// which is an object stores the metadata of the database.
+	// The header is followed by the root value.
+	FileSignatureHeader = "hashive\x01"
+	// FileSignatureVersion2 is the signature of the files with a header,
+	// which may store the layouts added after version 1.
+	FileSignatureVersion2 = "hashive\x02"
+	// FileSignatureDirty is written in place of the signature before the
+	// rest of the database, and replaced with the real signature after all
+	// of it is written, so that the database is not opened if the writing
+	// is interrupted, such as by a crash.
+	FileSignatureDirty = "hashive\xff"
+)
+
+// ErrPartiallyWritten is returned when reading the signature of a database
+// whose writing was interrupted, see [FileSignatureDirty].
+var ErrPartiallyWritten = errors.New("partially written database")
+
+// ReadSignature reads the file signature from r and returns the format
+// version.
+func ReadSignature(r io.Reader) (version int, err error) {
+	signature := make([]byte, len(FileSignature))
+	if _, err = io.ReadFull(r, signature); err != nil {
+		return
+	}
+	switch sig := string(signature); sig {
+	case FileSignature, FileSignatureHeader, FileSignatureVersion2:
+		return int(sig[len(sig)-1]), nil
+	case FileSignatureDirty:
+		return 0, ErrPartiallyWritten
+	default:
+		return 0, fmt.Errorf("invalid signature %v", sig)
+	}
+}
+
+// ReadHeader reads the file signature and the header from r, and returns
+// the format version and the header, which is nil for version 0. r is
+// positioned at the value after the header on success.
+func ReadHeader(r ByteReadSeeker) (version int, header map[string]any, err error) {
+	if version, err = ReadSignature(r); err != nil || version == 0 {
+		return
+	}
+	v, err := ReadValue(r, true)
+	if err != nil {
+		return
+	}
+	header, ok := v.(map[string]any)
+	if !ok {
+		err = fmt.Errorf("invalid header %v", v)
+	}
+	return
+}
@@ -0,0 +1,32 @@
+package impl
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(FileSignatureVersion2)
+	if err := (&Encoder{}).WriteObject(&buf, map[string]any{"length": uint64(1)}); err != nil {
+		t.Fatal(err)
+	}
+	version, header, err := ReadHeader(NewSliceReader(buf.Bytes()))
+	if err != nil || version != 2 || !reflect.DeepEqual(header, map[string]any{"length": uint64(1)}) {
+		t.Fatal(version, header, err)
+	}
+	version, header, err = ReadHeader(NewSliceReader([]byte(FileSignature + "\x00")))
+	if err != nil || version != 0 || header != nil {
+		t.Fatal(version, header, err)
+	}
+	if _, _, err = ReadHeader(NewSliceReader([]byte(FileSignatureDirty))); err != ErrPartiallyWritten {
+		t.Fatal(err)
+	}
+	// Unknown and truncated signatures, and headers which are not objects.
+	for _, data := range []string{"hashive\x03", "hashive", FileSignatureHeader + "\x00"} {
+		if _, _, err = ReadHeader(NewSliceReader([]byte(data))); err == nil {
+			t.Fatalf("%q read", data)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package impl
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// SliceReader is a [ByteReadSeeker] reading from a byte slice.
+// Unlike [bytes.Reader], it can return views of the slice without copying.
+type SliceReader struct {
+	data []byte
+	pos  int64
+}
+
+// NewSliceReader returns a SliceReader reading from data.
+func NewSliceReader(data []byte) *SliceReader {
+	return &SliceReader{data: data}
+}
+
+func (r *SliceReader) Read(p []byte) (n int, err error) {
+	if r.pos >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n = copy(p, r.data[r.pos:])
+	r.pos += int64(n)
+	return
+}
+
+func (r *SliceReader) ReadByte() (b byte, err error) {
+	if r.pos >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	b = r.data[r.pos]
+	r.pos++
+	return
+}
+
+func (r *SliceReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = int64(len(r.data)) + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = abs
+	return abs, nil
+}
+
+// View returns the next n bytes of the underlying slice without copying.
+func (r *SliceReader) View(n int) (p []byte, err error) {
+	if r.pos > int64(len(r.data)) || int64(n) > int64(len(r.data))-r.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p = r.data[r.pos : r.pos+int64(n) : r.pos+int64(n)]
+	r.pos += int64(n)
+	return
+}
+
+// ReadBinaryView is like [ReadBinary], but the returned slice is a view
+// of the underlying slice if r is a [*SliceReader]. Otherwise, it is a copy.
+func ReadBinaryView(r ByteReadSeeker) (p []byte, err error) {
+	sr, ok := r.(*SliceReader)
+	if !ok {
+		return ReadBinary(r)
+	}
+	length, inflated, err := readBinaryLength(r, typeBinary)
+	if err != nil || inflated != nil {
+		return inflated, err
+	}
+	if length > math.MaxInt {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	return sr.View(int(length))
+}
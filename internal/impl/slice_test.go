@@ -0,0 +1,39 @@
+package impl
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSliceReader(t *testing.T) {
+	var buf bytes.Buffer
+	WriteBinary(&buf, []byte("hello"))
+	WriteString(&buf, "world")
+	r := NewSliceReader(buf.Bytes())
+
+	p, err := ReadBinaryView(r)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal(string(p))
+	}
+	if _, err = ReadBinaryView(r); err == nil {
+		t.Fatal("should fail")
+	}
+	if _, err = r.Seek(-1, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.View(2); err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+	if b, err := r.ReadByte(); err != nil || b != 'd' {
+		t.Fatal(b, err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Fatal(err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("should fail")
+	}
+}
@@ -0,0 +1,134 @@
+package impl
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Type is the type of an encoded value.
+type Type = typ
+
+// Types of encoded values.
+const (
+	TypeNull           = typeNull
+	TypeInt            = typeInt
+	TypeUint           = typeUint
+	TypeBool           = typeBool
+	TypeString         = typeString
+	TypeFloat          = typeFloat
+	TypeBinary         = typeBinary
+	TypeGob            = typeGob
+	TypeArray          = typeArray
+	TypeObject         = typeObject
+	TypeFixedArray     = typeFixedArray
+	TypeRef            = typeRef
+	TypePrefixObject   = typePrefixObject
+	TypeFixedKeyObject = typeFixedKeyObject
+	TypeIntKeyObject   = typeIntKeyObject
+	TypeIntervals      = typeIntervals
+	TypeSortedObject   = typeSortedObject
+	TypeHashedObject   = typeHashedObject
+	TypeBlob           = typeBlob
+	TypeChunked        = typeChunked
+	TypeChunkedArray   = typeChunkedArray
+	TypeCompressed     = typeCompressed
+)
+
+// ReadType reads the type mark of the value at the current position of r,
+// and returns the type of the value.
+func ReadType(r io.ByteReader) (t Type, err error) {
+	_, t, err = readTypeMarker(r)
+	return
+}
+
+// WalkFunc is called by [Walk] for every value with its path relative to
+// the starting value, type, offset in the underlying reader and encoded size.
+type WalkFunc func(path []string, t Type, offset, size int64) error
+
+// ErrSkipChildren can be returned by a [WalkFunc] to skip the children of
+// an array or object.
+var ErrSkipChildren = errors.New("skip children")
+
+// Walk walks the value at the current position of r and all the values in it
+// in depth-first order, calling fn for every value before its children.
+// The nesting depth is limited by maxDepth, see [ReadValueDepth].
+// On success, r is positioned at the end of the value.
+func Walk(r ByteReadSeeker, maxDepth int, fn WalkFunc) error {
+	return walkValue(r, nil, rootLimit(maxDepth, 0), fn)
+}
+
+// walkValue walks a value in a container of parent limit.
+func walkValue(r ByteReadSeeker, path []string, parent depthLimit, fn WalkFunc) (err error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if err = skipValue(r, parent); err != nil {
+		return
+	}
+	end, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return
+	}
+	mt, t, err := readTypeMarker(r)
+	if err != nil {
+		return
+	}
+	if err = fn(path, t, start, end-start); err == ErrSkipChildren {
+		err = nil
+	} else if err == nil {
+		err = walkChildren(r, path, parent, mt, t, fn)
+	}
+	if err != nil {
+		return
+	}
+	_, err = r.Seek(end, io.SeekStart)
+	return
+}
+
+// walkChildren walks the children of a container of type t after its type mark mt.
+// It does nothing if t is not a container.
+func walkChildren(r ByteReadSeeker, path []string, parent depthLimit, mt typeMarker, t typ, fn WalkFunc) (err error) {
+	childPath := func(elem string) []string {
+		return append(path[:len(path):len(path)], elem)
+	}
+	switch t {
+	case typeArray, typeFixedArray, typeChunkedArray:
+		var array *Array
+		if array, err = readArrayOfType(r, t, mt.OffsetSize()); err != nil {
+			return
+		}
+		if array.limit, err = parent.child(); err != nil {
+			return
+		}
+		for i := range array.length {
+			if err = array.seekElem(i); err != nil {
+				return
+			}
+			if err = walkValue(r, childPath(strconv.Itoa(i)), array.limit, fn); err != nil {
+				return
+			}
+		}
+	case typeObject, typePrefixObject, typeFixedKeyObject, typeIntKeyObject, typeSortedObject, typeHashedObject:
+		var obj *Object
+		if obj, err = readObjectValue(r, t, mt.OffsetSize()); err != nil {
+			return
+		}
+		if obj.limit, err = parent.child(); err != nil {
+			return
+		}
+		err = obj.forEach(func(key string) error {
+			return walkValue(r, childPath(key), obj.limit, fn)
+		})
+	}
+	return
+}
+
+// ForEach calls fn with the key of every entry of obj, without reading values.
+func (obj *Object) ForEach(fn func(key string) error) error {
+	return obj.forEach(fn)
+}
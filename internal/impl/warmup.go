@@ -0,0 +1,92 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+)
+
+// warmupChunkSize is the number of bytes read at a time by warmup, after
+// which the context is checked.
+const warmupChunkSize = 64 << 10
+
+// warmupBucketBatch is the number of bucket heads read by
+// [Object.Warmup] between the checks of the context.
+const warmupBucketBatch = 1024
+
+// Touch reads n bytes of r from pos and discards them, so that they are
+// cached by the underlying storage. ctx is checked every chunk read.
+func Touch(ctx context.Context, r ByteReadSeeker, pos, n int64) (err error) {
+	if _, err = r.Seek(pos, io.SeekStart); err != nil {
+		return
+	}
+	for n > 0 {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var read int64
+		if read, err = io.CopyN(io.Discard, r, min(n, warmupChunkSize)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		n -= read
+	}
+	return
+}
+
+// Warmup reads the offset table of obj, and the head of every chain of it
+// if heads is true, so that the first lookups of obj don't wait for the
+// underlying storage. The keys of a [typeFixedKeyObject] are read with
+// the offset table, and it has no chains.
+func (obj *Object) Warmup(ctx context.Context, heads bool) (err error) {
+	if obj.keySize > 0 {
+		return Touch(ctx, obj.r, obj.pos, int64(obj.bucketCount)*int64(obj.keySize)+int64(obj.bucketCount+1)*int64(obj.offsetSize))
+	}
+	if err = Touch(ctx, obj.r, obj.pos, int64(obj.bucketCount)*int64(obj.offsetSize)); err != nil || !heads {
+		return
+	}
+	for i := range obj.bucketCount {
+		if i%warmupBucketBatch == 0 {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+		}
+		if _, err = obj.r.Seek(obj.pos+int64(i)*int64(obj.offsetSize), io.SeekStart); err != nil {
+			return
+		}
+		var offset uint64
+		if offset, err = readFixedUint(obj.r, obj.offsetSize); err != nil {
+			return
+		}
+		if offset > math.MaxInt64-uint64(obj.pos) {
+			return fmt.Errorf("invalid offset %v", offset)
+		}
+		if offset == 0 {
+			continue // Not exists
+		}
+		if _, err = obj.r.Seek(obj.pos+int64(offset), io.SeekStart); err != nil {
+			return
+		}
+		if _, err = readUintValue(obj.r); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Warmup reads the offset table of array, or the table of chunks of a
+// [typeChunkedArray], so that the first lookups of array don't wait for
+// the underlying storage. A [typeFixedArray] has no offset table.
+func (array *Array) Warmup(ctx context.Context) (err error) {
+	switch {
+	case array.stride != 0:
+		return
+	case array.chunkSize != 0:
+		return Touch(ctx, array.r, array.pos, array.data-array.pos)
+	default:
+		return Touch(ctx, array.r, array.pos, int64(array.length)*int64(array.offsetSize))
+	}
+}
@@ -0,0 +1,76 @@
+package impl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestWarmup(t *testing.T) {
+	obj := make(map[string]any)
+	ary := make([]any, 0, 3000)
+	for i := range 3000 {
+		obj[fmt.Sprint(i)] = i
+		ary = append(ary, fmt.Sprint(i))
+	}
+	ctx := context.Background()
+	for _, encoder := range []*Encoder{{}, {HashOrder: true}, {FixedKeys: true}, {Pow2Buckets: true}} {
+		var buf bytes.Buffer
+		if err := encoder.WriteObject(&buf, obj); err != nil {
+			t.Fatal(err)
+		}
+		o, err := ReadObject(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter := &seekCounter{ByteReadSeeker: o.r}
+		o.r = counter
+		if err := o.Warmup(ctx, false); err != nil || counter.seeks != 1 {
+			t.Fatal(counter.seeks, err)
+		}
+		counter.seeks = 0
+		if err := o.Warmup(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+		if o.keySize > 0 && counter.seeks != 1 || o.keySize == 0 && counter.seeks <= int(o.bucketCount) {
+			t.Fatal(counter.seeks)
+		}
+		if v, err := o.Index("42", true); err != nil || v != int64(42) {
+			t.Fatal(v, err)
+		}
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := o.Warmup(canceled, true); err != context.Canceled {
+			t.Fatal(err)
+		}
+
+		// Truncated.
+		o, err = ReadObject(bytes.NewReader(buf.Bytes()[:o.pos+1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Warmup(ctx, false); err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+	}
+
+	for _, encoder := range []*Encoder{{}, {ArrayChunkSize: 100}} {
+		var buf bytes.Buffer
+		if err := encoder.WriteArray(&buf, ary); err != nil {
+			t.Fatal(err)
+		}
+		a, err := ReadArray(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Warmup(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := a.Index(2999, true); err != nil || v != "2999" {
+			t.Fatal(v, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package hashive
+
+import (
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Interval is a value associated with the integers in [Start, End].
+// A []Interval is written as an interval container, which answers
+// [Hashive.QueryInterval] without reading the intervals not containing
+// the point. [Hashive.Query] returns it as a []Interval sorted by Start.
+type Interval = impl.Interval
+
+// QueryInterval queries the intervals containing point in the interval
+// container mapped by the path, and returns them sorted by Start.
+// [ErrNotFound] will be returned if the path does not map to an interval container.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryInterval(point int64, path ...string) (intervals []Interval, err error) {
+	v, err := h.query(path, false)
+	if err != nil {
+		return
+	}
+	container, ok := v.(*impl.Intervals)
+	if !ok {
+		err = ErrNotFound
+		return
+	}
+	indices, intervals, err := container.Stab(point, true)
+	if err != nil {
+		return
+	}
+	for i, index := range indices {
+		if intervals[i].Value, err = h.result(append(path[:len(path):len(path)], strconv.Itoa(index)), intervals[i].Value); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryInterval(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"ranges": []hashive.Interval{{Start: 10, End: 19, Value: "a"}, {Start: 0, End: 100, Value: "b"}, {Start: 15, End: 15, Value: "c"}},
+		"plain":  "x",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intervals, err := h.QueryInterval(15, "ranges"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(intervals, []hashive.Interval{{Start: 0, End: 100, Value: "b"}, {Start: 10, End: 19, Value: "a"}, {Start: 15, End: 15, Value: "c"}}) {
+		t.Fatal(intervals)
+	}
+	if intervals, err := h.QueryInterval(101, "ranges"); err != nil || len(intervals) != 0 {
+		t.Fatal(intervals, err)
+	}
+	if _, err := h.QueryInterval(1, "plain"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("ranges"); err != nil {
+		t.Fatal(err)
+	} else if intervals := v.([]hashive.Interval); len(intervals) != 3 || intervals[0].Value != "b" {
+		t.Fatal(v)
+	}
+}
@@ -0,0 +1,271 @@
+package hashive
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeError is returned by [Hashive.QueryInto] when a value can't be
+// stored into the destination.
+type DecodeError struct {
+	Path   []string     // The path to the value.
+	Kind   Kind         // The kind of the value.
+	Schema *Schema      // The schema of the value, if any.
+	Type   reflect.Type // The type of the destination.
+	Err    error        // The underlying error, if any.
+}
+
+func (err *DecodeError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "can't decode %v value at /%v into %v", err.Kind, strings.Join(err.Path, "/"), err.Type)
+	if err.Schema != nil {
+		fmt.Fprintf(&b, " (schema: %v)", err.Schema.Kind)
+	}
+	if err.Err != nil {
+		fmt.Fprintf(&b, ": %v", err.Err)
+	}
+	return b.String()
+}
+
+func (err *DecodeError) Unwrap() error {
+	return err.Err
+}
+
+// QueryInto queries a value mapped by the path and stores it into the value
+// pointed to by dst, which must be a non-nil pointer.
+//   - Numbers are converted to any numeric type if no overflow occurs.
+//   - Arrays are stored into slices and arrays.
+//   - Objects are stored into maps with string keys, or structs.
+//     Struct fields are matched by the "hashive" tag or the field name.
+//   - Gob encoded values are decoded with encoding/gob.
+//   - Values are stored into interface types as is.
+//
+// If the database has a schema, the value is validated against the schema
+// before stored, and the schema is reported in the returned [*DecodeError].
+//
+// [ErrNotFound] will be returned if the path does not map to any value.
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryInto(dst any, path ...string) (err error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("invalid destination %T", dst)
+	}
+	if h.schema == nil {
+		// Fast paths of homogeneous objects.
+		switch m := dst.(type) {
+		case *map[string]string:
+			*m, err = h.QueryStringMap(path...)
+			return
+		case *map[string]int64:
+			*m, err = h.QueryInt64Map(path...)
+			return
+		}
+	}
+	value, err := h.query(path, true)
+	if err != nil {
+		return
+	}
+	schema := h.schema.lookup(path)
+	if err = schema.validate(path, value); err != nil {
+		return
+	}
+	return h.decodeInto(rv.Elem(), value, path, schema)
+}
+
+// decodeInto stores v into dst.
+func (h *Hashive) decodeInto(dst reflect.Value, v any, path []string, schema *Schema) (err error) {
+	fail := func(err error) error {
+		return &DecodeError{Path: path, Kind: kindOf(v), Schema: schema, Type: dst.Type(), Err: err}
+	}
+	if gob, ok := v.(GobValue); ok {
+		if err = h.decodeGob(gob, dst.Addr().Interface()); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+	if v == nil {
+		dst.SetZero()
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Interface:
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(dst.Type()) {
+			return fail(nil)
+		}
+		dst.Set(rv)
+		return nil
+	case reflect.Pointer:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return h.decodeInto(dst.Elem(), v, path, schema)
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fail(nil)
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch value := v.(type) {
+		case int64:
+			n = value
+		case uint64:
+			if value > math.MaxInt64 {
+				return fail(errOverflow)
+			}
+			n = int64(value)
+		case float64:
+			if value != math.Trunc(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+				return fail(errOverflow)
+			}
+			n = int64(value)
+		default:
+			return fail(nil)
+		}
+		if dst.OverflowInt(n) {
+			return fail(errOverflow)
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch value := v.(type) {
+		case int64:
+			if value < 0 {
+				return fail(errOverflow)
+			}
+			n = uint64(value)
+		case uint64:
+			n = value
+		case float64:
+			if value != math.Trunc(value) || value < 0 || value >= math.MaxUint64 {
+				return fail(errOverflow)
+			}
+			n = uint64(value)
+		default:
+			return fail(nil)
+		}
+		if dst.OverflowUint(n) {
+			return fail(errOverflow)
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch value := v.(type) {
+		case int64:
+			f = float64(value)
+		case uint64:
+			f = float64(value)
+		case float64:
+			f = value
+		default:
+			return fail(nil)
+		}
+		if dst.OverflowFloat(f) {
+			return fail(errOverflow)
+		}
+		dst.SetFloat(f)
+		return nil
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return fail(nil)
+		}
+		dst.SetString(s)
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch value := v.(type) {
+			case []byte:
+				dst.SetBytes(value)
+				return nil
+			case string:
+				dst.SetBytes([]byte(value))
+				return nil
+			}
+		}
+		ary, ok := v.([]any)
+		if !ok {
+			return fail(nil)
+		}
+		dst.Set(reflect.MakeSlice(dst.Type(), len(ary), len(ary)))
+		return h.decodeElems(dst, ary, path, schema)
+	case reflect.Array:
+		ary, ok := v.([]any)
+		if !ok {
+			return fail(nil)
+		}
+		if len(ary) != dst.Len() {
+			return fail(fmt.Errorf("array length %v", len(ary)))
+		}
+		return h.decodeElems(dst, ary, path, schema)
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return fail(nil)
+		}
+		m := reflect.MakeMapWithSize(dst.Type(), len(obj))
+		for key, value := range obj {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err = h.decodeInto(elem, value, append(path[:len(path):len(path)], key), schema.field(key)); err != nil {
+				return
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(m)
+		return nil
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fail(nil)
+		}
+		t := dst.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			key := field.Name
+			if tag, ok := field.Tag.Lookup("hashive"); ok {
+				if tag == "-" {
+					continue
+				}
+				key = tag
+			}
+			value, ok := obj[key]
+			if !ok {
+				continue
+			}
+			if err = h.decodeInto(dst.Field(i), value, append(path[:len(path):len(path)], key), schema.field(key)); err != nil {
+				return
+			}
+		}
+		return nil
+	default:
+		return fail(nil)
+	}
+}
+
+// decodeElems stores the elements of ary into the slice or array dst.
+func (h *Hashive) decodeElems(dst reflect.Value, ary []any, path []string, schema *Schema) (err error) {
+	var elemSchema *Schema
+	if schema != nil {
+		elemSchema = schema.Elem
+	}
+	for i, elem := range ary {
+		if err = h.decodeInto(dst.Index(i), elem, append(path[:len(path):len(path)], strconv.Itoa(i)), elemSchema); err != nil {
+			return
+		}
+	}
+	return
+}
+
+var errOverflow = errors.New("overflow")
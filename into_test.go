@@ -0,0 +1,106 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryInto(t *testing.T) {
+	type Owner struct {
+		Name    string
+		Age     int
+		Address *string `hashive:"Addr"`
+	}
+	type DB struct {
+		Key1   uint8
+		Key2   string
+		Owners []Owner
+		Desc   Description `hashive:"Description"`
+		Ratio  float32
+		Tags   [2]string
+		Extra  map[string]any
+	}
+
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"Key1": 123,
+		"Key2": "456",
+		"Owners": []any{
+			map[string]any{"Name": "John", "Age": 28},
+			map[string]any{"Name": "Joe", "Age": 29, "Addr": "abc street"},
+		},
+		"Description": Description{"description here"},
+		"Ratio":       0.5,
+		"Tags":        []any{"a", "b"},
+		"Extra":       map[string]any{"k": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var db DB
+	if err := h.QueryInto(&db); err != nil {
+		t.Fatal(err)
+	}
+	addr := "abc street"
+	want := DB{
+		Key1:   123,
+		Key2:   "456",
+		Owners: []Owner{{"John", 28, nil}, {"Joe", 29, &addr}},
+		Desc:   Description{"description here"},
+		Ratio:  0.5,
+		Tags:   [2]string{"a", "b"},
+		Extra:  map[string]any{"k": true},
+	}
+	if !reflect.DeepEqual(db, want) {
+		t.Fatalf("QueryInto() = %#v, want %#v", db, want)
+	}
+
+	var age int8
+	if err := h.QueryInto(&age, "Owners", "1", "Age"); err != nil {
+		t.Fatal(err)
+	} else if age != 29 {
+		t.Fatal(age)
+	}
+
+	var decodeErr *hashive.DecodeError
+	var name int
+	if err := h.QueryInto(&name, "Owners", "1", "Name"); !errors.As(err, &decodeErr) {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(decodeErr.Path, []string{"Owners", "1", "Name"}) || decodeErr.Kind != hashive.KindString {
+		t.Fatal(decodeErr)
+	}
+
+	if err := h.QueryInto(&name, "not exists"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryIntoSchema(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"Owners": []any{map[string]any{"Name": "John", "Age": 28}},
+	}, hashive.WithSchema(dbSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var age bool
+	var decodeErr *hashive.DecodeError
+	if err := h.QueryInto(&age, "Owners", "0", "Age"); !errors.As(err, &decodeErr) {
+		t.Fatal(err)
+	} else if decodeErr.Schema == nil || decodeErr.Schema.Kind != hashive.KindNumber {
+		t.Fatal(decodeErr)
+	}
+}
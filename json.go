@@ -0,0 +1,115 @@
+package hashive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// jsonDecoder decodes JSON values token by token, recording the provenance
+// and key order of values, and applying the policy of duplicate keys.
+// See [WithJSONSource], [WithKeyOrder] and [WithDuplicateKeys].
+type jsonDecoder struct {
+	dec        *json.Decoder
+	data       bytes.Buffer // the input read by dec
+	name       string       // the name of the input, or "" if provenance is not recorded
+	ordered    bool         // whether to decode objects as [OrderedObject]
+	duplicates DuplicateKeyPolicy
+	// The offset in data and its line and column.
+	offset    int64
+	line, col int
+}
+
+// decodeJSON decodes the next JSON value from r like [json.Decoder.Decode],
+// according to the JSON options.
+func decodeJSON(r io.Reader, options *writeOptions) (v any, err error) {
+	d := &jsonDecoder{
+		name:       options.jsonSource,
+		ordered:    options.keyOrder,
+		duplicates: options.duplicateKeys,
+		line:       1,
+		col:        1,
+	}
+	d.dec = json.NewDecoder(io.TeeReader(r, &d.data))
+	return d.decode(nil)
+}
+
+// decode decodes the next value at path.
+func (d *jsonDecoder) decode(path []string) (v any, err error) {
+	start := d.dec.InputOffset()
+	tok, err := d.dec.Token()
+	if err != nil {
+		return
+	}
+	var source string
+	if d.name != "" {
+		source = d.location(start)
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj OrderedObject
+		for d.dec.More() {
+			var key json.Token
+			if key, err = d.dec.Token(); err != nil {
+				return
+			}
+			var value any
+			if value, err = d.decode(append(path, key.(string))); err != nil {
+				return
+			}
+			obj = append(obj, Entry{key.(string), value})
+		}
+		if obj, err = obj.dedupKeys(path, d.duplicates); err != nil {
+			return
+		}
+		if d.ordered {
+			v = obj
+		} else {
+			v, _, _ = obj.unwrap()
+		}
+	case json.Delim('['):
+		ary := make([]any, 0)
+		for d.dec.More() {
+			var elem any
+			if elem, err = d.decode(append(path, strconv.Itoa(len(ary)))); err != nil {
+				return
+			}
+			ary = append(ary, elem)
+		}
+		v = ary
+	default:
+		v = tok
+	}
+	if _, ok := tok.(json.Delim); ok {
+		// The closing delimiter.
+		if _, err = d.dec.Token(); err != nil {
+			return
+		}
+	}
+	if d.name != "" {
+		v = ValueWithProvenance{v, source}
+	}
+	return
+}
+
+// location returns the location of the token after offset, skipping
+// white spaces and separators.
+func (d *jsonDecoder) location(offset int64) string {
+	data := d.data.Bytes()
+	for ; offset < int64(len(data)); offset++ {
+		if c := data[offset]; c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != ',' && c != ':' {
+			break
+		}
+	}
+	for ; d.offset < offset; d.offset++ {
+		if data[d.offset] == '\n' {
+			d.line++
+			d.col = 1
+		} else {
+			d.col++
+		}
+	}
+	return fmt.Sprintf("%v:%v:%v", d.name, d.line, d.col)
+}
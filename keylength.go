@@ -0,0 +1,78 @@
+package hashive
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// KeyLengthError is returned when the length of a key of an object exceeds
+// the limit. See [WithMaxKeyLength] and [WithReadMaxKeyLength].
+type KeyLengthError = impl.KeyLengthError
+
+// WithMaxKeyLength fails [Write] with an [*EncodeError] wrapping a
+// [*KeyLengthError] if a key of the objects to write is longer than n bytes,
+// so that databases read with [WithReadMaxKeyLength] are never written.
+// If n <= 0, the length of keys is not limited.
+func WithMaxKeyLength(n int) WriteOption {
+	return func(o *writeOptions) {
+		o.maxKeyLength = max(n, 0)
+	}
+}
+
+// WithReadMaxKeyLength limits the length of keys read from objects to n
+// bytes. Lookups and other reads reaching a longer key, which is likely
+// malicious, fail with a [*KeyLengthError] instead of reading the key.
+// Keys shorter than n bytes are read as usual, so queries on other keys of
+// the object may still succeed.
+// If n <= 0, the length of keys is not limited.
+func WithReadMaxKeyLength(n int) Option {
+	return func(o *options) {
+		o.maxKeyLength = max(n, 0)
+	}
+}
+
+// checkKeyLength returns an [*EncodeError] if a key of the objects in v at
+// path is longer than maxLength.
+func checkKeyLength(path []string, v any, maxLength int) (err error) {
+	check := func(key string, elem any) error {
+		if len(key) > maxLength {
+			return &EncodeError{
+				Path: append(slices.Clone(path), key),
+				Err:  &KeyLengthError{Length: uint64(len(key)), MaxKeyLength: maxLength},
+			}
+		}
+		return checkKeyLength(append(path, key), elem, maxLength)
+	}
+	switch value := v.(type) {
+	case OrderedObject:
+		for _, entry := range value {
+			if err = check(entry.Key, entry.Value); err != nil {
+				return
+			}
+		}
+	case sideValue:
+		inner, _, _ := value.unwrap()
+		return checkKeyLength(path, inner, maxLength)
+	case []any:
+		for i, elem := range value {
+			if err = checkKeyLength(append(path, strconv.Itoa(i)), elem, maxLength); err != nil {
+				return
+			}
+		}
+	case map[string]any:
+		for key, elem := range value {
+			if err = check(key, elem); err != nil {
+				return
+			}
+		}
+	case []Interval:
+		for i, interval := range value {
+			if err = checkKeyLength(append(path, strconv.Itoa(i)), interval.Value, maxLength); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
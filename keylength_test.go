@@ -0,0 +1,70 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWithMaxKeyLength(t *testing.T) {
+	long := strings.Repeat("k", 100)
+	value := map[string]any{"a": map[string]any{"short": 1, long: 2}}
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, value, hashive.WithMaxKeyLength(10))
+	var encodeErr *hashive.EncodeError
+	var lengthErr *hashive.KeyLengthError
+	if !errors.As(err, &encodeErr) || !reflect.DeepEqual(encodeErr.Path, []string{"a", long}) ||
+		!errors.As(err, &lengthErr) || lengthErr.Length != 100 || lengthErr.MaxKeyLength != 10 {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := hashive.Write(&buf, value, hashive.WithMaxKeyLength(100)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithReadMaxKeyLength(t *testing.T) {
+	long := strings.Repeat("k", 100)
+	value := map[string]any{"obj": map[string]any{long: 1}, "list": []any{map[string]any{long: 2}}, "ok": 3}
+	for _, test := range []struct {
+		name string
+		opts []hashive.WriteOption
+	}{
+		{"default", nil},
+		{"frontCoding", []hashive.WriteOption{hashive.WithFrontCoding()}},
+		{"sortedKeys", []hashive.WriteOption{hashive.WithSortedKeys()}},
+		{"hashOrder", []hashive.WriteOption{hashive.WithHashOrder()}},
+		{"fixedKeys", []hashive.WriteOption{hashive.WithFixedKeys()}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := hashive.Write(&buf, value, test.opts...); err != nil {
+				t.Fatal(err)
+			}
+			h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithReadMaxKeyLength(10))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err := h.Query("ok"); err != nil || v != int64(3) {
+				t.Fatal(v, err)
+			}
+			var lengthErr *hashive.KeyLengthError
+			for _, path := range [][]string{{"obj", long}, {"list", "0", long}, {"obj"}, {}} {
+				if _, err := h.Query(path...); !errors.As(err, &lengthErr) || lengthErr.Length != 100 {
+					t.Fatal(path, err)
+				}
+			}
+			// Unlimited.
+			if h, err = hashive.New(bytes.NewReader(buf.Bytes()), -1); err != nil {
+				t.Fatal(err)
+			}
+			if v, err := h.Query("obj", long); err != nil || v != int64(1) {
+				t.Fatal(v, err)
+			}
+		})
+	}
+}
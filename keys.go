@@ -0,0 +1,258 @@
+package hashive
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// KeysPage returns at most limit keys of the object, or indices of the array,
+// mapped by the path, starting from cursor, and the cursor of the next page.
+// Empty cursor starts from the beginning, and empty next means there are no
+// more keys. Cursors are opaque strings returned by previous calls on the same
+// database, and pages are stable: paging through a database visits every key
+// exactly once, in the same order every time. Only keys of the page are read,
+// so huge objects can be browsed lazily.
+// [ErrNotFound] will be returned if the path does not map to an array or object.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) KeysPage(cursor string, limit int, path ...string) (keys []string, next string, err error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("invalid limit %v", limit)
+	}
+	container, err := h.container(path)
+	if err != nil {
+		return
+	}
+	switch c := container.(type) {
+	case *impl.Object:
+		var from impl.Cursor
+		if cursor != "" {
+			bucket, entry, ok := strings.Cut(cursor, ".")
+			if !ok {
+				return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+			}
+			if from.Bucket, err = strconv.ParseUint(bucket, 10, 64); err != nil {
+				return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+			}
+			if from.Entry, err = strconv.ParseUint(entry, 10, 64); err != nil {
+				return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+			}
+		}
+		var nextCursor impl.Cursor
+		var more bool
+		if keys, nextCursor, more, err = c.Keys(from, limit); err != nil {
+			return
+		}
+		if more {
+			next = strconv.FormatUint(nextCursor.Bucket, 10) + "." + strconv.FormatUint(nextCursor.Entry, 10)
+		}
+		return
+	case *impl.Array:
+		var from int
+		if cursor != "" {
+			if from, err = strconv.Atoi(cursor); err != nil || from < 0 || from > c.Len() {
+				return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+			}
+		}
+		end := from + min(limit, c.Len()-from)
+		for i := from; i < end; i++ {
+			keys = append(keys, strconv.Itoa(i))
+		}
+		if end < c.Len() {
+			next = strconv.Itoa(end)
+		}
+		return
+	}
+	return nil, "", ErrNotFound
+}
+
+// IntEntry is an entry of an object whose key is an integer.
+type IntEntry struct {
+	Key   int64
+	Value any
+}
+
+// QueryRangeInt queries the entries of the object mapped by the path whose
+// keys are integers in canonical decimal form in [lo, hi], in the order of
+// keys. Objects written with [WithIntKeys] are binary searched, the others
+// are scanned entirely. Entries hidden by [WithACL] are skipped.
+// [ErrNotFound] will be returned if the path does not map to an object.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryRangeInt(lo, hi int64, path ...string) (entries []IntEntry, err error) {
+	obj, err := h.queryObjectValue(path)
+	if err != nil {
+		return
+	}
+	keys, values, err := obj.IntRange(lo, hi, true)
+	if err != nil {
+		return
+	}
+	entries = make([]IntEntry, 0, len(keys))
+	for i, key := range keys {
+		keyPath := append(path[:len(path):len(path)], strconv.FormatInt(key, 10))
+		if h.checkACL(keyPath) != nil {
+			continue
+		}
+		entry := IntEntry{Key: key}
+		if entry.Value, err = h.result(keyPath, h.hideACL(keyPath, values[i])); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// QueryFloorInt queries the entry of the object mapped by the path whose key
+// is the largest integer in canonical decimal form <= x, such as the start of
+// the IP range containing x. See [Hashive.QueryRangeInt] for the performance.
+// Entries hidden by [WithACL] are skipped.
+// [ErrNotFound] will be returned if the path does not map to an object or
+// there is no such key.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryFloorInt(x int64, path ...string) (key int64, v any, err error) {
+	obj, err := h.queryObjectValue(path)
+	if err != nil {
+		return
+	}
+	for {
+		if key, v, err = obj.FloorInt(x, true); err != nil {
+			return
+		}
+		keyPath := append(path[:len(path):len(path)], strconv.FormatInt(key, 10))
+		if h.checkACL(keyPath) == nil {
+			v, err = h.result(keyPath, h.hideACL(keyPath, v))
+			return
+		}
+		// Hidden, try the next smaller key.
+		if key == math.MinInt64 {
+			return 0, nil, ErrNotFound
+		}
+		x = key - 1
+	}
+}
+
+// QueryLongestPrefix queries the entry of the object mapped by the path whose
+// key is the longest prefix of s, which is the natural lookup of datasets
+// such as MAC address vendors and phone number prefixes.
+// An entry of empty key matches any s.
+// The object is looked up once for every prefix of s from the longest, or
+// only once if it is written with [WithFixedKeys].
+// Entries hidden by [WithACL] are skipped.
+// [ErrNotFound] will be returned if the path does not map to an object or
+// there is no such key.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryLongestPrefix(s string, path ...string) (key string, v any, err error) {
+	obj, err := h.queryObjectValue(path)
+	if err != nil {
+		return
+	}
+	for {
+		if key, v, err = obj.LongestPrefix(s, true); err != nil {
+			return
+		}
+		keyPath := append(path[:len(path):len(path)], key)
+		if h.checkACL(keyPath) == nil {
+			v, err = h.result(keyPath, h.hideACL(keyPath, v))
+			return
+		}
+		// Hidden, try the next shorter prefix.
+		if key == "" {
+			return "", nil, ErrNotFound
+		}
+		s = key[:len(key)-1]
+	}
+}
+
+// ContainsAll reports whether the root object contains all the keys.
+// Keys are probed in the order of their hash buckets without reading values,
+// which is much faster than querying them one by one for many keys.
+// Keys hidden by [WithACL] are absent.
+// [ErrNotFound] will be returned if the root value is not an object.
+func (h *Hashive) ContainsAll(keys ...string) (bool, error) {
+	return h.containsKeys(keys, false)
+}
+
+// ContainsAny is like [Hashive.ContainsAll], but reports whether the root
+// object contains any of the keys.
+func (h *Hashive) ContainsAny(keys ...string) (bool, error) {
+	return h.containsKeys(keys, true)
+}
+
+func (h *Hashive) containsKeys(keys []string, matchAny bool) (bool, error) {
+	if h.obj == nil {
+		return false, ErrNotFound
+	}
+	if denied := h.denied(); len(denied) > 0 {
+		hidden := make(map[string]bool)
+		for _, path := range denied {
+			if len(path) == 0 {
+				return false, ErrNotFound
+			} else if len(path) == 1 {
+				hidden[path[0]] = true
+			}
+		}
+		visible := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if !hidden[key] {
+				visible = append(visible, key)
+			} else if !matchAny {
+				return false, nil
+			}
+		}
+		keys = visible
+	}
+	return h.obj.ContainsKeys(keys, matchAny)
+}
+
+// SortedEntries returns an iterator over the entries of the object mapped by
+// the path in the order of keys, for generating deterministic artifacts.
+// Keys are read and sorted first, and then values are read one by one as
+// the iteration goes. Keys of objects written with [WithFixedKeys] are
+// stored in order already. Keys hidden by [WithACL] are absent.
+// The iteration stops at the first error, which is returned by err after the
+// iteration. [ErrNotFound] is returned if the path does not map to an object.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) SortedEntries(path ...string) (seq iter.Seq2[string, any], err func() error) {
+	var errIter error
+	seq = func(yield func(string, any) bool) {
+		var obj *impl.Object
+		if obj, errIter = h.queryObjectValue(path); errIter != nil {
+			return
+		}
+		var keys []string
+		if errIter = obj.ForEach(func(key string) error {
+			keys = append(keys, key)
+			return nil
+		}); errIter != nil {
+			return
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			keyPath := append(path[:len(path):len(path)], key)
+			if h.checkACL(keyPath) != nil {
+				continue
+			}
+			var v any
+			if v, errIter = obj.Index(key, true); errIter != nil {
+				return
+			}
+			if v, errIter = h.result(keyPath, h.hideACL(keyPath, v)); errIter != nil {
+				return
+			}
+			if !yield(key, v) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return errIter }
+}
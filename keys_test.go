@@ -0,0 +1,239 @@
+package hashive_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestKeysPage(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 100 {
+		obj["key"+strconv.Itoa(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"obj": obj, "ary": make([]any, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []string
+	var cursor string
+	for {
+		keys, next, err := h.KeysPage(cursor, 7, "obj")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) > 7 || next != "" && len(keys) != 7 {
+			t.Fatal(keys)
+		}
+		all = append(all, keys...)
+		if next == "" {
+			break
+		}
+		// Pages are stable.
+		if again, _, err := h.KeysPage(cursor, 7, "obj"); err != nil {
+			t.Fatal(err)
+		} else if !slices.Equal(again, keys) {
+			t.Fatal(again, keys)
+		}
+		cursor = next
+	}
+	if len(all) != len(obj) {
+		t.Fatal(len(all))
+	}
+	slices.Sort(all)
+	for i := 1; i < len(all); i++ {
+		if all[i] == all[i-1] {
+			t.Fatal("duplicated", all[i])
+		}
+	}
+
+	keys, next, err := h.KeysPage("", 4, "ary")
+	if err != nil {
+		t.Fatal(err)
+	} else if !slices.Equal(keys, []string{"0", "1", "2", "3"}) || next != "4" {
+		t.Fatal(keys, next)
+	}
+	if keys, next, err = h.KeysPage("8", 4, "ary"); err != nil {
+		t.Fatal(err)
+	} else if !slices.Equal(keys, []string{"8", "9"}) || next != "" {
+		t.Fatal(keys, next)
+	}
+	if keys, next, err = h.KeysPage("1", math.MaxInt, "ary"); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 9 || keys[0] != "1" || next != "" {
+		t.Fatal(keys, next)
+	}
+	if keys, next, err = h.KeysPage("10", 4, "ary"); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 0 || next != "" {
+		t.Fatal(keys, next)
+	}
+	if _, _, err := h.KeysPage("11", 4, "ary"); err == nil {
+		t.Fatal("should fail")
+	}
+
+	if _, _, err := h.KeysPage("x", 4, "obj"); err == nil {
+		t.Fatal("should fail")
+	}
+	if _, _, err := h.KeysPage("", 4, "obj", "key1"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryRangeInt(t *testing.T) {
+	ranges := map[string]any{"0": "a", "100": "b", "200": "c", "-100": "d"}
+	for _, opts := range [][]hashive.WriteOption{nil, {hashive.WithIntKeys()}} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, map[string]any{"ranges": ranges}, opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries, err := h.QueryRangeInt(-100, 150, "ranges"); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(entries, []hashive.IntEntry{{-100, "d"}, {0, "a"}, {100, "b"}}) {
+			t.Fatal(entries)
+		}
+		if key, v, err := h.QueryFloorInt(199, "ranges"); err != nil {
+			t.Fatal(err)
+		} else if key != 100 || v != "b" {
+			t.Fatal(key, v)
+		}
+		if _, _, err := h.QueryFloorInt(-101, "ranges"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+		if v, err := h.Query("ranges", "200"); err != nil {
+			t.Fatal(err)
+		} else if v != "c" {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestQueryLongestPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"44": "UK", "4420": "London", "1": "NANP"}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, v, err := h.QueryLongestPrefix("442079460000"); err != nil {
+		t.Fatal(err)
+	} else if key != "4420" || v != "London" {
+		t.Fatal(key, v)
+	}
+	if key, v, err := h.QueryLongestPrefix("441632960000"); err != nil {
+		t.Fatal(err)
+	} else if key != "44" || v != "UK" {
+		t.Fatal(key, v)
+	}
+	if _, _, err := h.QueryLongestPrefix("33"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestContainsAll(t *testing.T) {
+	value := make(map[string]any)
+	for i := range 1000 {
+		value[strconv.Itoa(i)] = map[string]any{"v": i}
+	}
+	for _, opts := range [][]hashive.WriteOption{
+		nil,
+		{hashive.WithFrontCoding()},
+		{hashive.WithFixedKeys()},
+		{hashive.WithHashOrder()},
+		{hashive.WithACL(hashive.ACLEntry{Path: []string{"7"}, Capability: "admin"})},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hidden := len(h.ACL()) > 0
+		for _, test := range []struct {
+			keys     []string
+			all, any bool
+		}{
+			{nil, true, false},
+			{[]string{"1", "999", "500"}, true, true},
+			{[]string{"1", "1000", "500"}, false, true},
+			{[]string{"-1", "1000"}, false, false},
+			{[]string{"7"}, !hidden, !hidden},
+			{[]string{"7", "8"}, !hidden, true},
+		} {
+			if all, err := h.ContainsAll(test.keys...); err != nil || all != test.all {
+				t.Fatal(test.keys, all, err)
+			}
+			if any, err := h.ContainsAny(test.keys...); err != nil || any != test.any {
+				t.Fatal(test.keys, any, err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, []any{1}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.ContainsAll("0"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestSortedEntries(t *testing.T) {
+	obj := map[string]any{}
+	for i := range 100 {
+		obj[strconv.Itoa(i)] = i
+	}
+	for _, opts := range [][]hashive.WriteOption{nil, {hashive.WithIntKeys()}, {hashive.WithFixedKeys()}} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, map[string]any{"obj": obj}, append(opts, hashive.WithACL(hashive.ACLEntry{Path: []string{"obj", "5"}, Capability: "admin"}))...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, errFn := h.SortedEntries("obj")
+		var keys []string
+		for key, v := range seq {
+			if v != int64(obj[key].(int)) {
+				t.Fatal(key, v)
+			}
+			keys = append(keys, key)
+		}
+		if err := errFn(); err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 99 || !slices.IsSorted(keys) || slices.Contains(keys, "5") {
+			t.Fatal(keys)
+		}
+		seq, errFn = h.SortedEntries("missing")
+		for range seq {
+			t.Fatal("unexpected entry")
+		}
+		if err := errFn(); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Package lite is a reader-only subset of package hashive for small devices,
+such as firmware built with TinyGo querying databases flashed into ROM.
+Databases are read from byte slices without copying, and neither the
+writers nor the reflection-heavy parts of package hashive are linked.
+Building with TinyGo or the hashive_nogob tag also leaves out encoding/gob.
+
+Values are returned as [hashive.Hashive.Query] does, except that gob
+encoded values are always returned as [GobValue]. Schemas, side tables,
+such as metadata and key order, and checksums are ignored. Values hidden
+by the ACL of the database are hidden unless the capabilities are provided
+to [Open], see [hashive.WithACL].
+*/
+package lite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Keys of the header used by the reader.
+const (
+	headerLength = "length"
+	headerRefs   = "refs"
+	headerACL    = "acl"
+)
+
+// GobValue is a gob encoded value.
+type GobValue = impl.GobValue
+
+// Interval is an element of interval containers, see [hashive.Interval].
+type Interval = impl.Interval
+
+// ErrNotFound is returned when querying a path which does not map to any
+// value. It is the same as [hashive.ErrNotFound].
+var ErrNotFound = impl.ErrNotFound
+
+// DB is a read-only database in memory. It is not safe for concurrent use.
+type DB struct {
+	r       *impl.SliceReader
+	rootPos int64
+	ary     *impl.Array
+	obj     *impl.Object
+	denied  [][]string // the paths hidden by the ACL
+}
+
+// Open opens the database data with the capabilities to access the values
+// hidden by its ACL. data must not be modified while the database is in
+// use.
+func Open(data []byte, capabilities ...string) (db *DB, err error) {
+	r := impl.NewSliceReader(data)
+	_, header, err := impl.ReadHeader(r)
+	if err != nil {
+		return
+	}
+	rootPos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	acl, err := impl.ACLFromValue(header[headerACL])
+	if err != nil {
+		return
+	}
+	db = &DB{r: r, denied: impl.Denied(acl, capabilities)}
+	length, ok := header[headerLength].(uint64)
+	if ok && length > uint64(int64(len(data))-rootPos) {
+		return nil, errors.New("truncated database")
+	}
+	if refs, ok := header[headerRefs]; ok {
+		// Referenced values are stored before the root value.
+		if n, ok := refs.(uint64); !ok || n > length {
+			return nil, fmt.Errorf("invalid refs %v", refs)
+		}
+		rootPos += int64(refs.(uint64))
+		if _, err = r.Seek(rootPos, io.SeekStart); err != nil {
+			return
+		}
+	}
+	db.rootPos = rootPos
+	if db.ary, db.obj, err = impl.ReadContainer(r, impl.DefaultMaxDepth, 0); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Query queries a value mapped by the path, see [hashive.Hashive.Query].
+// [ErrNotFound] will be returned if the path does not map to any value.
+func (db *DB) Query(path ...string) (v any, err error) {
+	if impl.IsDenied(db.denied, path) {
+		return nil, ErrNotFound
+	}
+	if len(path) == 0 {
+		if _, err = db.r.Seek(db.rootPos, io.SeekStart); err != nil {
+			return
+		}
+		v, err = impl.ReadValue(db.r, true)
+	} else if db.obj != nil {
+		v, err = queryObject(path, db.obj)
+	} else if db.ary != nil {
+		v, err = queryArray(path, db.ary)
+	} else {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return
+	}
+	return impl.HideDenied(db.denied, path, v), nil
+}
+
+func queryObject(path []string, obj *impl.Object) (v any, err error) {
+	if v, err = obj.Index(path[0], len(path) == 1); err != nil || len(path) == 1 {
+		return
+	}
+	return queryContainer(path[1:], v)
+}
+
+func queryArray(path []string, ary *impl.Array) (v any, err error) {
+	index, err := strconv.ParseUint(path[0], 0, 64)
+	if err != nil {
+		return
+	}
+	if index > math.MaxInt {
+		return nil, fmt.Errorf("invalid index %v", index)
+	}
+	if v, err = ary.Index(int(index), len(path) == 1); err != nil || len(path) == 1 {
+		return
+	}
+	return queryContainer(path[1:], v)
+}
+
+// queryContainer queries the value mapped by the path in the container c.
+func queryContainer(path []string, c any) (v any, err error) {
+	switch c := c.(type) {
+	case *impl.Object:
+		return queryObject(path, c)
+	case *impl.Array:
+		return queryArray(path, c)
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,105 @@
+package lite_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/lite"
+)
+
+type point struct{ X, Y int }
+
+func TestQuery(t *testing.T) {
+	value := map[string]any{
+		"s":    "str",
+		"long": strings.Repeat("text ", 100),
+		"b":    []byte{1, 2},
+		"u":    uint64(1) << 63,
+		"a":    []any{1, nil, map[string]any{"k": 1.5}, "secret"},
+		"o":    map[string]any{"x": map[string]any{"y": true}, "z": map[string]any{"y": true}},
+		"p":    point{1, 2},
+		"i":    []hashive.Interval{{Start: 1, End: 2, Value: "x"}},
+	}
+	acl := hashive.WithACL(
+		hashive.ACLEntry{Path: []string{"o", "x"}, Capability: "admin"},
+		hashive.ACLEntry{Path: []string{"a", "3"}, Capability: "admin"},
+	)
+	paths := [][]string{
+		nil, {"s"}, {"long"}, {"b"}, {"u"}, {"a"}, {"a", "0"}, {"a", "1"}, {"a", "2"}, {"a", "2", "k"}, {"a", "3"},
+		{"o"}, {"o", "x"}, {"o", "x", "y"}, {"o", "z", "y"}, {"p"}, {"i"},
+		{"x"}, {"a", "4"}, {"a", "x"}, {"s", "x"},
+	}
+	for _, opts := range [][]hashive.WriteOption{
+		nil,
+		{acl},
+		{hashive.WithDedup(), hashive.WithCompression(func([]byte) bool { return true })},
+		{hashive.WithFixedKeys(), hashive.WithChunkedArrays(2), acl},
+		{hashive.WithSortedKeys(), hashive.WithFrontCoding()},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, opts...); err != nil {
+			t.Fatal(err)
+		}
+		for _, capabilities := range [][]string{nil, {"admin"}} {
+			h, err := hashive.NewBytes(buf.Bytes(), hashive.WithCapabilities(capabilities...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			db, err := lite.Open(buf.Bytes(), capabilities...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range paths {
+				want, wantErr := h.Query(path...)
+				got, err := db.Query(path...)
+				if (err == nil) != (wantErr == nil) || err == lite.ErrNotFound != (wantErr == hashive.ErrNotFound) || !reflect.DeepEqual(got, want) {
+					t.Fatal(path, capabilities, got, err, want, wantErr)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for _, data := range [][]byte{nil, []byte("hashive"), []byte("invalid\x00"), data[:len(data)-1]} {
+		if _, err := lite.Open(data); err == nil {
+			t.Fatalf("%q", data)
+		}
+	}
+	dirty := bytes.Clone(data)
+	dirty[len("hashive")] = 0xff
+	if _, err := lite.Open(dirty); err == nil {
+		t.Fatal("dirty")
+	}
+}
+
+// TestBuild32Bit builds the package for the 32-bit platforms of small
+// devices, where int overflows are compile errors.
+func TestBuild32Bit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building for other platforms is slow")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("building needs the go command")
+	}
+	for _, target := range []struct{ goarch, tags string }{
+		{"386", ""},
+		{"arm", "hashive_nogob"},
+	} {
+		cmd := exec.Command("go", "build", "-tags", target.tags, ".")
+		cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+target.goarch, "CGO_ENABLED=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("GOARCH=%v -tags %q: %v\n%s", target.goarch, target.tags, err, out)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package hashive
+
+import "syscall"
+
+// adviseWillNeed advises the kernel that the mapped data will be read soon.
+func adviseWillNeed(data []byte) error {
+	return syscall.Madvise(data, syscall.MADV_WILLNEED)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package hashive
+
+// adviseWillNeed does nothing, because madvise is not available on this
+// platform.
+func adviseWillNeed(data []byte) error {
+	return nil
+}
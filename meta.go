@@ -0,0 +1,32 @@
+package hashive
+
+// ValueWithMeta is a value with metadata, such as the time it expires.
+// [Write] stores Value in place of it, and Meta in a table beside the
+// value, which is returned by [Hashive.QueryWithMeta].
+type ValueWithMeta struct {
+	Value any
+	Meta  map[string]any
+}
+
+// QueryWithMeta is like [Hashive.Query], but also returns the metadata of
+// the value written with [ValueWithMeta], or nil if there is none.
+func (h *Hashive) QueryWithMeta(path ...string) (v any, meta map[string]any, err error) {
+	if v, err = h.Query(path...); err != nil {
+		return
+	}
+	m, err := h.sideTableValue(headerMeta, path)
+	if err != nil {
+		return
+	}
+	meta, _ = m.(map[string]any)
+	return
+}
+
+func (v ValueWithMeta) unwrap() (any, string, any) {
+	return v.Value, headerMeta, v.Meta
+}
+
+func (v ValueWithMeta) rewrap(value any) sideValue {
+	v.Value = value
+	return v
+}
@@ -0,0 +1,55 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryWithMeta(t *testing.T) {
+	value := map[string]any{
+		"rates": map[string]any{
+			"USD": hashive.ValueWithMeta{Value: 1.0, Meta: map[string]any{"expires": 1700000000}},
+			"EUR": 0.9,
+		},
+		"list": []any{hashive.ValueWithMeta{Value: "a", Meta: map[string]any{"source": "x"}}},
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithDedup()); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, meta, err := h.QueryWithMeta("rates", "USD"); err != nil {
+		t.Fatal(err)
+	} else if v != 1.0 || !reflect.DeepEqual(meta, map[string]any{"expires": int64(1700000000)}) {
+		t.Fatal(v, meta)
+	}
+	if v, meta, err := h.QueryWithMeta("rates", "EUR"); err != nil || v != 0.9 || meta != nil {
+		t.Fatal(v, meta, err)
+	}
+	if v, err := h.Query("list", "0"); err != nil || v != "a" {
+		t.Fatal(v, err)
+	}
+	if _, _, err := h.QueryWithMeta("rates", "GBP"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+
+	// Metadata is kept by Compact.
+	var compacted bytes.Buffer
+	if err := hashive.Compact(bytes.NewReader(buf.Bytes()), &compacted, hashive.WithSortedKeys()); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = hashive.New(bytes.NewReader(compacted.Bytes()), -1); err != nil {
+		t.Fatal(err)
+	}
+	if v, meta, err := h.QueryWithMeta("list", "0"); err != nil {
+		t.Fatal(err)
+	} else if v != "a" || !reflect.DeepEqual(meta, map[string]any{"source": "x"}) {
+		t.Fatal(v, meta)
+	}
+}
@@ -0,0 +1,172 @@
+package hashive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Format versions of databases, stored in the last byte of the file signature.
+const (
+	// Version0 is the original format, which has no header and stores
+	// only null, numbers, bool, strings, binaries, gob encoded values,
+	// arrays and objects. The root value must be an array or object.
+	Version0 = 0
+	// Version1 stores a header before the root value. It is written by [Write]
+	// unless any layout of [Version2] is needed.
+	Version1 = 1
+	// Version2 adds blobs, chunked binaries, chunked arrays, compressed
+	// binaries and objects of power-of-two bucket counts to [Version1].
+	// It is written by [Write] if any option or value needs them, see
+	// [WithBlobWriter], [WithChunkedBinary], [WithChunkedArrays],
+	// [WithCompression] and [WithPow2Buckets].
+	Version2 = 2
+	// CurrentVersion is the latest version, which can be read by this
+	// package.
+	CurrentVersion = Version2
+)
+
+// ReadVersion reads the file signature from r and returns the format version.
+func ReadVersion(r io.Reader) (version int, err error) {
+	return impl.ReadSignature(r)
+}
+
+// WithVersion writes the database in format version, at most, so that it
+// can be read by the readers of that version, such as [Version0] for the
+// releases before the header was added. Writing fails if the value or other
+// options need a later version.
+// [Version0] is written with the layouts of that version, and supports none of the options stored in the header, such as [WithSchema],
+// [WithACL], [WithChecksums] and [WithIndexFile], or changing the layouts
+// of objects, such as [WithDedup] and [WithSortedKeys]. The root value must
+// be an array or object, and must not contain gob encoded values, intervals,
+// blobs, metadata or provenance.
+func WithVersion(version int) WriteOption {
+	return func(o *writeOptions) {
+		o.maxVersion = version
+		o.legacy = version == Version0
+	}
+}
+
+// Migrate reads the database from r and writes it to w in format version
+// targetVersion, so that it can be read by the readers of that version.
+// Migrating to [Version2] is the same as [Compact], and opts are applied
+// the same way, so the database is written in [Version1] if it doesn't need
+// [Version2]. Migrating to older versions is like that with [WithVersion],
+// and fails if opts or the values of the database, such as blobs, need a
+// later version.
+// Migrating to [Version0] drops the schema, ACL and gob type information, so
+// the values hidden by the ACL are no longer hidden, and fails if the
+// database stores gob encoded values, which can't be converted.
+func Migrate(r io.ReadSeeker, w io.Writer, targetVersion int, opts ...WriteOption) (err error) {
+	switch targetVersion {
+	case Version2:
+		return Compact(r, w, opts...)
+	case Version1:
+		return Compact(r, w, append(opts[:len(opts):len(opts)], WithVersion(Version1))...)
+	case Version0:
+		value, err := version0Value(r)
+		if err != nil {
+			return err
+		}
+		return Write(w, value, append(opts[:len(opts):len(opts)], WithVersion(Version0))...)
+	}
+	return fmt.Errorf("unsupported version %v", targetVersion)
+}
+
+// MigrateFile is like [Migrate] but reads from and writes to files
+// with [WriteFile].
+// dst must be different from src, and it will be overwritten if exists.
+func MigrateFile(src, dst string, targetVersion int, opts ...WriteOption) (err error) {
+	switch targetVersion {
+	case Version2:
+		return CompactFile(src, dst, opts...)
+	case Version1:
+		return CompactFile(src, dst, append(opts[:len(opts):len(opts)], WithVersion(Version1))...)
+	case Version0:
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		value, err := version0Value(f)
+		if err != nil {
+			return err
+		}
+		return WriteFile(dst, value, append(opts[:len(opts):len(opts)], WithVersion(Version0))...)
+	}
+	return fmt.Errorf("unsupported version %v", targetVersion)
+}
+
+// version0Value reads the database from r, and returns its value to write
+// in [Version0].
+func version0Value(r io.ReadSeeker) (value any, err error) {
+	h, err := New(r, -1, ignoreACL)
+	if err != nil {
+		return
+	}
+	if value, err = h.Query(); err != nil {
+		return
+	}
+	if contains(value, isGob) {
+		err = errors.New("can't migrate gob encoded values to version 0")
+	}
+	return
+}
+
+// encodeVersion0 encodes value in [Version0], see [WithVersion].
+// tables are the side values extracted from value.
+func encodeVersion0(value any, options *writeOptions, tables map[string]map[string]any) (signature string, headerData, payload *bytes.Buffer, err error) {
+	if version := options.version(); version > Version1 {
+		err = fmt.Errorf("the database needs version %v", version)
+		return
+	}
+	if options.schema != nil || len(options.acl) > 0 || options.checksums > 0 || options.indexFile || len(options.gobTypes) > 0 {
+		err = errors.New("header options are not supported by version 0")
+		return
+	}
+	if options.dedup || options.frontCoding || options.fixedKeys || options.intKeys || options.sortedKeys || options.hashOrder {
+		err = errors.New("object layout options are not supported by version 0")
+		return
+	}
+	switch value.(type) {
+	case []any, map[string]any, hashedEntries:
+	default:
+		err = errors.New("root value of version 0 must be an array or object")
+		return
+	}
+	if contains(value, isGob) {
+		err = errors.New("gob encoded values are not supported by version 0")
+		return
+	}
+	if contains(value, func(v any) bool { _, ok := v.([]Interval); return ok }) {
+		err = errors.New("intervals are not supported by version 0")
+		return
+	}
+	if contains(value, isBlob) {
+		err = errors.New("blobs are not supported by version 0")
+		return
+	}
+	if len(tables) > 0 {
+		err = errors.New("metadata and provenance are not supported by version 0")
+		return
+	}
+	payload = new(bytes.Buffer)
+	encoder := &impl.Encoder{Legacy: true, PrimeTable: options.primeTable, MaxBuckets: options.maxBuckets}
+	// Version0 encodes all the gob values with a shared encoder.
+	encoder.Gob = func(v any) (impl.GobValue, error) {
+		return nil, errors.New("gob encoded values are not supported by version 0")
+	}
+	if entries, ok := value.(hashedEntries); ok {
+		err = encoder.WriteEntries(payload, entries)
+	} else {
+		err = encoder.WriteValue(payload, value)
+	}
+	if err != nil {
+		return
+	}
+	return impl.FileSignature, new(bytes.Buffer), payload, nil
+}
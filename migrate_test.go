@@ -0,0 +1,176 @@
+package hashive_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestMigrate(t *testing.T) {
+	value := map[string]any{"a": []any{1, 2, 3}, "b": "x"}
+	var v1 bytes.Buffer
+	if err := hashive.Write(&v1, value); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(v1.Bytes())); err != nil || version != hashive.Version1 {
+		t.Fatal(version, err)
+	}
+	var v0 bytes.Buffer
+	if err := hashive.Migrate(bytes.NewReader(v1.Bytes()), &v0, hashive.Version0); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(v0.Bytes())); err != nil || version != hashive.Version0 {
+		t.Fatal(version, err)
+	}
+	h, err := hashive.New(bytes.NewReader(v0.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump strings.Builder
+	if err := h.Dump(&dump); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(dump.String(), "fixedArray") {
+		t.Fatal(dump.String())
+	}
+	want := map[string]any{"a": []any{int64(1), int64(2), int64(3)}, "b": "x"}
+	if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatal(v, err)
+	}
+
+	var back bytes.Buffer
+	if err := hashive.Migrate(bytes.NewReader(v0.Bytes()), &back, hashive.Version1); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(back.Bytes())); err != nil || version != hashive.Version1 {
+		t.Fatal(version, err)
+	}
+	if h, err = hashive.New(bytes.NewReader(back.Bytes()), -1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatal(v, err)
+	}
+
+	type Point struct{ X, Y int }
+	var gob bytes.Buffer
+	if err := hashive.Write(&gob, []any{Point{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hashive.Migrate(bytes.NewReader(gob.Bytes()), &bytes.Buffer{}, hashive.Version0); err == nil {
+		t.Fatal("gob values migrated to version 0")
+	}
+	if err := hashive.Migrate(bytes.NewReader(v1.Bytes()), &bytes.Buffer{}, hashive.CurrentVersion+1); err == nil {
+		t.Fatal("unsupported version")
+	}
+}
+
+func TestMigrateVersion2(t *testing.T) {
+	value := map[string]any{"a": bytes.Repeat([]byte("a"), 1000), "b": hashive.Blob{Offset: 1, Size: 2}}
+	var v2 bytes.Buffer
+	if err := hashive.Write(&v2, value, hashive.WithCompression(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(v2.Bytes())); err != nil || version != hashive.Version2 {
+		t.Fatal(version, err)
+	}
+	// Blobs need version 2.
+	if err := hashive.Migrate(bytes.NewReader(v2.Bytes()), &bytes.Buffer{}, hashive.Version1); err == nil {
+		t.Fatal("blobs migrated to version 1")
+	}
+	if err := hashive.Migrate(bytes.NewReader(v2.Bytes()), &bytes.Buffer{}, hashive.Version0); err == nil {
+		t.Fatal("blobs migrated to version 0")
+	}
+
+	delete(value, "b")
+	v2.Reset()
+	if err := hashive.Write(&v2, value, hashive.WithCompression(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := hashive.Migrate(bytes.NewReader(v2.Bytes()), &bytes.Buffer{}, hashive.Version1, hashive.WithCompression(nil)); err == nil {
+		t.Fatal("compression migrated to version 1")
+	}
+	var v1 bytes.Buffer
+	if err := hashive.Migrate(bytes.NewReader(v2.Bytes()), &v1, hashive.Version1); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(v1.Bytes())); err != nil || version != hashive.Version1 {
+		t.Fatal(version, err)
+	}
+	h, err := hashive.NewBytes(v1.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("a"); err != nil || !bytes.Equal(v.([]byte), value["a"].([]byte)) {
+		t.Fatal(v, err)
+	}
+}
+
+func TestWithVersion(t *testing.T) {
+	value := map[string]any{"a": []any{1, 2, 3}, "b": "x"}
+	var v0 bytes.Buffer
+	if err := hashive.Write(&v0, value, hashive.WithVersion(hashive.Version0)); err != nil {
+		t.Fatal(err)
+	}
+	var migrated bytes.Buffer
+	if err := hashive.Migrate(bytes.NewReader(v0.Bytes()), &migrated, hashive.Version0); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(migrated.Bytes(), v0.Bytes()) {
+		t.Fatal("not the same as migrated")
+	}
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, value, hashive.WithVersion(hashive.Version0)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filename); err != nil || !bytes.Equal(data, v0.Bytes()) {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(v0.Bytes())); err != nil || version != hashive.Version0 {
+		t.Fatal(version, err)
+	}
+	h, err := hashive.NewBytes(v0.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"a": []any{int64(1), int64(2), int64(3)}, "b": "x"}
+	if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatal(v, err)
+	}
+
+	var v1 bytes.Buffer
+	if err := hashive.Write(&v1, value, hashive.WithVersion(hashive.Version1)); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := hashive.ReadVersion(bytes.NewReader(v1.Bytes())); err != nil || version != hashive.Version1 {
+		t.Fatal(version, err)
+	}
+
+	type Point struct{ X, Y int }
+	for name, write := range map[string]func(w io.Writer) error{
+		"scalar": func(w io.Writer) error { return hashive.Write(w, 1, hashive.WithVersion(hashive.Version0)) },
+		"gob": func(w io.Writer) error {
+			return hashive.Write(w, []any{Point{1, 2}}, hashive.WithVersion(hashive.Version0))
+		},
+		"schema": func(w io.Writer) error {
+			return hashive.Write(w, value, hashive.WithSchema(&hashive.Schema{}), hashive.WithVersion(hashive.Version0))
+		},
+		"dedup": func(w io.Writer) error {
+			return hashive.Write(w, value, hashive.WithDedup(), hashive.WithVersion(hashive.Version0))
+		},
+		"compression": func(w io.Writer) error {
+			return hashive.Write(w, value, hashive.WithCompression(nil), hashive.WithVersion(hashive.Version1))
+		},
+		"unsupported": func(w io.Writer) error { return hashive.Write(w, value, hashive.WithVersion(hashive.CurrentVersion+1)) },
+	} {
+		err := write(&bytes.Buffer{})
+		t.Log(name, err)
+		if err == nil {
+			t.Fatal(name, "written")
+		}
+	}
+}
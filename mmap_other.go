@@ -0,0 +1,47 @@
+//go:build !unix
+
+package hashive
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// OpenMmap opens the Hashive database denoted by filename into memory.
+// Memory mapping is not supported on this platform, so the entire file is
+// read into memory instead, and [Hashive.QueryBinaryView] returns views of it.
+// The returned close function does nothing.
+func OpenMmap(filename string, opts ...Option) (h *Hashive, close func() error, err error) {
+	data, info, unmap, err := mapFile(filename)
+	if err != nil {
+		return
+	}
+	opts, unmapIndex := withIndexFile(filename, info, opts)
+	if h, err = NewBytes(data, opts...); err != nil {
+		unmapIndex()
+		return
+	}
+	close = func() error {
+		return errors.Join(unmapIndex(), unmap())
+	}
+	return
+}
+
+// mapFile reads the file denoted by filename into memory, because memory
+// mapping is not supported on this platform. unmap does nothing.
+func mapFile(filename string) (data []byte, info os.FileInfo, unmap func() error, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if info, err = f.Stat(); err != nil {
+		return
+	}
+	if data, err = io.ReadAll(f); err != nil {
+		return
+	}
+	unmap = func() error { return nil }
+	return
+}
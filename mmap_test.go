@@ -0,0 +1,76 @@
+package hashive_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestOpenMmap(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	blob := bytes.Repeat([]byte{1, 2, 3}, 100)
+	err := hashive.WriteFile(filename, map[string]any{
+		"name": "mkch",
+		"blob": blob,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, close, err := hashive.OpenMmap(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close()
+	if v, err := h.Query("name"); err != nil {
+		t.Fatal(err)
+	} else if v != "mkch" {
+		t.Fatal(v)
+	}
+	p, err := h.QueryBinaryView("blob")
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, blob) {
+		t.Fatal(p)
+	}
+	if _, err := h.QueryBinaryView("name"); err == nil {
+		t.Fatal("should fail")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.hashive")
+	if err := os.WriteFile(empty, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := hashive.OpenMmap(empty); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func TestNewBytes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"blob": []byte("abc")}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	h, err := hashive.NewBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := h.QueryBinaryView("blob")
+	if err != nil {
+		t.Fatal(err)
+	} else if string(p) != "abc" {
+		t.Fatal(string(p))
+	}
+	// p is a view of data.
+	if i := bytes.Index(data, []byte("abc")); &data[i] != &p[0] {
+		t.Fatal("not a view")
+	}
+	// Views can't be appended to overwrite data.
+	if cap(p) != len(p) {
+		t.Fatal(cap(p))
+	}
+}
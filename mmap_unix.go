@@ -0,0 +1,59 @@
+//go:build unix
+
+package hashive
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// OpenMmap opens the Hashive database denoted by filename by mapping the file
+// into memory. Values are read from the memory directly without buffering,
+// and [Hashive.QueryBinaryView] returns views of the mapped memory.
+// The returned close function unmaps the file, after which h and all the
+// views must not be used.
+// See [New] for the meaning of opts.
+func OpenMmap(filename string, opts ...Option) (h *Hashive, close func() error, err error) {
+	data, info, unmap, err := mapFile(filename)
+	if err != nil {
+		return
+	}
+	opts, unmapIndex := withIndexFile(filename, info, opts)
+	if h, err = NewBytes(data, opts...); err != nil {
+		unmapIndex()
+		unmap()
+		return
+	}
+	h.mapped = data
+	close = func() error {
+		return errors.Join(unmapIndex(), unmap())
+	}
+	return
+}
+
+// mapFile maps the file denoted by filename into memory read-only.
+// unmap unmaps the file.
+func mapFile(filename string) (data []byte, info os.FileInfo, unmap func() error, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close() // The mapping is kept after closing.
+	if info, err = f.Stat(); err != nil {
+		return
+	}
+	size := info.Size()
+	if size == 0 {
+		// Can't map an empty file, which is not a valid database anyway.
+		return nil, info, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, nil, syscall.EFBIG
+	}
+	if data, err = syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED); err != nil {
+		return
+	}
+	unmap = func() error { return syscall.Munmap(data) }
+	return
+}
@@ -0,0 +1,112 @@
+package hashive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// MultiReader presents several parts as one logical database file, such as
+// a database split into chunks by a file size limit. It implements
+// [io.ReaderAt] and [io.ReadSeeker], so it can be passed to [New] and
+// [NewSection].
+type MultiReader struct {
+	parts   []io.ReaderAt
+	offsets []int64 // the offsets of parts, followed by the total size
+	pos     int64   // the position of Read and Seek
+}
+
+// NewMultiReader creates a MultiReader reading parts in order.
+// The size of every part must be known: a part must have a Size() int64
+// method, such as [*io.SectionReader] and [*bytes.Reader], or a
+// Stat() (os.FileInfo, error) method, such as [*os.File].
+func NewMultiReader(parts ...io.ReaderAt) (r *MultiReader, err error) {
+	offsets := make([]int64, 1, len(parts)+1)
+	for i, part := range parts {
+		var size int64
+		switch p := part.(type) {
+		case interface{ Size() int64 }:
+			size = p.Size()
+		case interface{ Stat() (os.FileInfo, error) }:
+			var info os.FileInfo
+			if info, err = p.Stat(); err != nil {
+				return
+			}
+			size = info.Size()
+		default:
+			return nil, fmt.Errorf("unknown size of part %v", i)
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("invalid size of part %v: %v", i, size)
+		}
+		offsets = append(offsets, offsets[i]+size)
+	}
+	return &MultiReader{parts: parts, offsets: offsets}, nil
+}
+
+// Size returns the total size of all the parts.
+func (r *MultiReader) Size() int64 {
+	return r.offsets[len(r.offsets)-1]
+}
+
+// ReadAt implements [io.ReaderAt].
+func (r *MultiReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	// The last part starting at or before off.
+	i := sort.Search(len(r.parts), func(i int) bool { return r.offsets[i+1] > off })
+	for ; n < len(p) && i < len(r.parts); i++ {
+		partOff := off + int64(n) - r.offsets[i]
+		want := min(int64(len(p)-n), r.offsets[i+1]-r.offsets[i]-partOff)
+		if want <= 0 {
+			continue
+		}
+		var m int
+		m, err = r.parts[i].ReadAt(p[n:n+int(want)], partOff)
+		n += m
+		if err == io.EOF && int64(m) == want {
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+// Read implements [io.Reader].
+func (r *MultiReader) Read(p []byte) (n int, err error) {
+	if r.pos >= r.Size() {
+		return 0, io.EOF
+	}
+	n, err = r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return
+}
+
+// Seek implements [io.Seeker].
+func (r *MultiReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.Size()
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,66 @@
+package hashive_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestMultiReader(t *testing.T) {
+	value := map[string]any{"name": "mkch", "list": []any{"a", "bb", "ccc"}}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Split into an empty part, a file and byte slices.
+	filename := filepath.Join(t.TempDir(), "part")
+	if err := os.WriteFile(filename, data[3:10], 0666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := hashive.NewMultiReader(bytes.NewReader(data[:3]), bytes.NewReader(nil), f, bytes.NewReader(data[10:20]), bytes.NewReader(data[20:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Fatal(r.Size())
+	}
+	all, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(all, data) {
+		t.Fatal(all)
+	}
+	p := make([]byte, 10)
+	if n, err := r.ReadAt(p, int64(len(data)-5)); n != 5 || err != io.EOF || !bytes.Equal(p[:n], data[len(data)-5:]) {
+		t.Fatal(n, err)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(r, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"name": "mkch", "list": []any{"a", "bb", "ccc"}}
+	if v, err := h.Query(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, want) {
+		t.Fatal(v)
+	}
+
+	if _, err := hashive.NewMultiReader(struct{ io.ReaderAt }{bytes.NewReader(data)}); err == nil {
+		t.Fatal("unknown size")
+	}
+}
@@ -0,0 +1,39 @@
+package hashive
+
+// MultiValue is the values of a key that appears more than once in the
+// source data, such as the records of a DNS name. [Write] stores it as an
+// array, and records that the array holds multiple values of the key in a
+// table beside it, so that [Hashive.QueryAllValues] can tell it from an
+// array value. See [DuplicateMultiValue] for collecting the values of
+// repeated keys into MultiValue.
+type MultiValue []any
+
+func (v MultiValue) unwrap() (any, string, any) {
+	return []any(v), headerMulti, true
+}
+
+func (v MultiValue) rewrap(value any) sideValue {
+	values, _ := value.([]any)
+	return MultiValue(values)
+}
+
+// QueryAllValues queries every value of the key mapped by the path.
+// If the value was written as a [MultiValue], its values are returned,
+// otherwise the value itself is returned as the only one.
+// [ErrNotFound] will be returned if the path does not map to any value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) QueryAllValues(path ...string) (values []any, err error) {
+	v, err := h.Query(path...)
+	if err != nil {
+		return
+	}
+	multi, err := h.sideTableValue(headerMulti, path)
+	if err != nil {
+		return
+	}
+	if values, ok := v.([]any); ok && multi == true {
+		return values, nil
+	}
+	return []any{v}, nil
+}
@@ -0,0 +1,57 @@
+package hashive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestQueryAllValues(t *testing.T) {
+	const input = `{"example.com":{"A":"1.1.1.1","A":"2.2.2.2","MX":"mx"},"list":[1,2]}`
+	var buf bytes.Buffer
+	if err := hashive.WriteJSONString(&buf, input, hashive.WithDuplicateKeys(hashive.DuplicateMultiValue)); err != nil {
+		t.Fatal(err)
+	}
+	var compacted bytes.Buffer
+	if err := hashive.Compact(bytes.NewReader(buf.Bytes()), &compacted); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{buf.Bytes(), compacted.Bytes()} {
+		h, err := hashive.New(bytes.NewReader(data), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range []struct {
+			path   []string
+			values []any
+		}{
+			{[]string{"example.com", "A"}, []any{"1.1.1.1", "2.2.2.2"}},
+			{[]string{"example.com", "MX"}, []any{"mx"}},
+			{[]string{"list"}, []any{[]any{1.0, 2.0}}},
+		} {
+			if values, err := h.QueryAllValues(test.path...); err != nil || !reflect.DeepEqual(values, test.values) {
+				t.Fatal(test.path, values, err)
+			}
+		}
+		if v, err := h.Query("example.com", "A", "1"); err != nil || v != "2.2.2.2" {
+			t.Fatal(v, err)
+		}
+		if _, err := h.QueryAllValues("missing"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+
+	buf.Reset()
+	if err := hashive.Write(&buf, map[string]any{"k": hashive.MultiValue{1, "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, err := h.QueryAllValues("k"); err != nil || !reflect.DeepEqual(values, []any{int64(1), "a"}) {
+		t.Fatal(values, err)
+	}
+}
@@ -0,0 +1,48 @@
+package hashive
+
+import (
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// IsNull reports whether the value mapped by the path is null.
+// Unlike comparing the result of [Hashive.Query] with nil, it does not read
+// arrays and objects.
+// [ErrNotFound] will be returned if the path does not map to any value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) IsNull(path ...string) (null bool, err error) {
+	v, err := h.query(path, false)
+	return err == nil && v == nil, err
+}
+
+// IsEmptyObject reports whether the value mapped by the path is an object
+// without any entries. It is false for null and empty arrays.
+// [ErrNotFound] will be returned if the path does not map to any value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) IsEmptyObject(path ...string) (empty bool, err error) {
+	v, err := h.query(path, false)
+	if err != nil {
+		return
+	}
+	obj, ok := v.(*impl.Object)
+	if !ok {
+		return
+	}
+	keys, _, _, err := obj.Keys(impl.Cursor{}, 1)
+	return err == nil && len(keys) == 0, err
+}
+
+// IsEmptyArray reports whether the value mapped by the path is an array
+// without any elements. It is false for null and empty objects.
+// [ErrNotFound] will be returned if the path does not map to any value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) IsEmptyArray(path ...string) (empty bool, err error) {
+	v, err := h.query(path, false)
+	if err != nil {
+		return
+	}
+	ary, ok := v.(*impl.Array)
+	return ok && ary.Len() == 0, nil
+}
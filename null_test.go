@@ -0,0 +1,87 @@
+package hashive_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestIsNull(t *testing.T) {
+	const input = `{"object":{},"array":[],"null":null,"nested":{"a":[{},[],null]}}`
+	for _, opts := range [][]hashive.WriteOption{
+		nil,
+		{hashive.WithFrontCoding()},
+		{hashive.WithFixedKeys()},
+		{hashive.WithSortedKeys()},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.WriteJSONString(&buf, input, opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range []struct {
+			path                     []string
+			null, emptyObj, emptyAry bool
+			value                    any
+		}{
+			{[]string{"object"}, false, true, false, map[string]any{}},
+			{[]string{"array"}, false, false, true, []any{}},
+			{[]string{"null"}, true, false, false, nil},
+			{[]string{"nested"}, false, false, false, map[string]any{"a": []any{map[string]any{}, []any{}, nil}}},
+			{[]string{"nested", "a", "0"}, false, true, false, map[string]any{}},
+			{[]string{"nested", "a", "1"}, false, false, true, []any{}},
+			{[]string{"nested", "a", "2"}, true, false, false, nil},
+		} {
+			if v, err := h.Query(test.path...); err != nil || !reflect.DeepEqual(v, test.value) {
+				t.Fatal(test.path, v, err)
+			}
+			if null, err := h.IsNull(test.path...); err != nil || null != test.null {
+				t.Fatal(test.path, null, err)
+			}
+			if empty, err := h.IsEmptyObject(test.path...); err != nil || empty != test.emptyObj {
+				t.Fatal(test.path, empty, err)
+			}
+			if empty, err := h.IsEmptyArray(test.path...); err != nil || empty != test.emptyAry {
+				t.Fatal(test.path, empty, err)
+			}
+		}
+		if _, err := h.IsNull("missing"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+		if _, err := h.IsEmptyObject("missing"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := h.WriteCanonicalJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, out.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		if got := compacted.String(); got != `{"array":[],"nested":{"a":[{},[],null]},"null":null,"object":{}}` {
+			t.Fatal(got)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"m": map[string]any(nil), "s": []any(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty, err := h.IsEmptyObject("m"); err != nil || !empty {
+		t.Fatal(empty, err)
+	}
+	if empty, err := h.IsEmptyArray("s"); err != nil || !empty {
+		t.Fatal(empty, err)
+	}
+}
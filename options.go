@@ -0,0 +1,505 @@
+package hashive
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// WriteOption configures how values are written by [Write] and its variants.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	schema      *Schema
+	dedup       bool
+	frontCoding bool
+	fixedKeys   bool
+	intKeys     bool
+	sortedKeys  bool
+	hashOrder   bool
+	chunkSize   int               // see [WithChunkedBinary]
+	chunkOver   int               // the threshold of [WithChunkedBinary]
+	gobTypes    map[string]uint64 // fingerprints of encoded gob values, see [Compact]
+	transform   TransformFunc
+	acl         []ACLEntry
+	jsonSource  string // the name of JSON input, see [WithJSONSource]
+	keyOrder    bool   // see [WithKeyOrder]
+	// see [WithDuplicateKeys]
+	duplicateKeys DuplicateKeyPolicy
+	blobs         *blobWriter // see [WithBlobWriter]
+	utf8          UTF8Policy  // see [WithUTF8]
+	maxKeyLength  int         // see [WithMaxKeyLength]
+	checksums     int         // the block size of [WithChecksums]
+	// the chunk size of [WithChunkedArrays]
+	arrayChunkSize int
+	primeTable     bool // see [WithPrimeTable]
+	pow2Buckets    bool // see [WithPow2Buckets]
+	indexFile      bool // see [WithIndexFile]
+	maxBuckets     int  // see [WithMaxBuckets]
+	// decides whether to compress a []byte value, see [WithCompression]
+	compress func(p []byte) bool
+	// the max format version to write, see [WithVersion], or 0 for any
+	maxVersion int
+	legacy     bool // writes [Version0], see [WithVersion]
+}
+
+func newWriteOptions(opts []WriteOption) *writeOptions {
+	var options writeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &options
+}
+
+// version returns the format version of the databases written with o,
+// which is [Version2] if any layout added after [Version1] may be written.
+func (o *writeOptions) version() int {
+	if o.blobs != nil || o.chunkSize > 0 || o.arrayChunkSize > 0 || o.pow2Buckets || o.compress != nil {
+		return Version2
+	}
+	return Version1
+}
+
+// WithSchema validates the value against schema before writing it,
+// and stores schema in the database. See [Hashive.Schema].
+func WithSchema(schema *Schema) WriteOption {
+	return func(o *writeOptions) {
+		o.schema = schema
+	}
+}
+
+// WithDedup stores identical arrays and objects only once, and references
+// them where they occur, which shrinks databases with repeated blocks, such
+// as configurations. Reading referenced values costs an extra seek.
+// Databases written with this option can't be read by versions without
+// this option.
+func WithDedup() WriteOption {
+	return func(o *writeOptions) {
+		o.dedup = true
+	}
+}
+
+// WithFrontCoding sorts the entries of objects in each hash bucket by key,
+// and stores every key as the length of the prefix shared with the previous
+// one followed by the rest of the key. It shrinks objects whose keys share
+// long prefixes, such as MAC address prefixes, and lookups skip most of the
+// keys in a bucket without reading them.
+// Databases written with this option can't be read by versions without
+// this option.
+func WithFrontCoding() WriteOption {
+	return func(o *writeOptions) {
+		o.frontCoding = true
+	}
+}
+
+// WithFixedKeys stores objects whose keys are all of the same size, such as
+// MAC addresses, country codes or hashes, with keys sorted and packed without
+// lengths. Keys are binary searched instead of scanning hash bucket chains.
+// Other objects are not affected.
+// Databases written with this option can't be read by versions without
+// this option.
+func WithFixedKeys() WriteOption {
+	return func(o *writeOptions) {
+		o.fixedKeys = true
+	}
+}
+
+// WithIntKeys stores objects whose keys are all integers in canonical decimal
+// form, such as "-1" and "42" but not "042", with keys sorted numerically.
+// Keys are binary searched instead of hashed, and
+// [Hashive.QueryRangeInt] and [Hashive.QueryFloorInt] query such objects
+// without scanning all the keys.
+// Other objects are not affected. It takes precedence over [WithFixedKeys].
+// Databases written with this option can't be read by versions without
+// this option.
+func WithIntKeys() WriteOption {
+	return func(o *writeOptions) {
+		o.intKeys = true
+	}
+}
+
+// WithSortedKeys sorts the entries of objects in each hash bucket by key,
+// so that lookups of missing keys stop at the first greater key, and the
+// same value is always written as the same bytes with the same options,
+// except for gob encoded values containing maps.
+// [WithFrontCoding] sorts the entries as well.
+// Databases written with this option can't be read by versions without
+// this option.
+func WithSortedKeys() WriteOption {
+	return func(o *writeOptions) {
+		o.sortedKeys = true
+	}
+}
+
+// WithHashOrder sorts the entries of objects in each hash bucket by the
+// hashes of keys, which are stored with the keys. Lookups compare the
+// hashes instead of the keys, and stop at the first greater hash, which
+// costs about half as much for missing keys in long chains.
+// It takes precedence over [WithSortedKeys], and the same value is always
+// written as the same bytes as well.
+// Databases written with this option can't be read by versions without
+// this option.
+func WithHashOrder() WriteOption {
+	return func(o *writeOptions) {
+		o.hashOrder = true
+	}
+}
+
+// WithPrimeTable chooses the bucket counts of the hash tables of objects
+// from a precomputed table of primes, each about 5/4 of the previous one,
+// instead of searching for the prime nearest to the number of keys of
+// every object, which speeds up writing many objects at the cost of up to
+// 1/4 more buckets. Databases written with this option can be read by
+// versions without this option.
+func WithPrimeTable() WriteOption {
+	return func(o *writeOptions) {
+		o.primeTable = true
+	}
+}
+
+// WithPow2Buckets makes the bucket counts of the hash tables of objects
+// powers of two, whose buckets are chosen by multiply-shift hashing instead
+// of the modulo of a prime, so that lookups take no 64-bit division, which
+// is slow on some low-end CPUs. Objects may take up to twice as many
+// buckets. It takes precedence over [WithPrimeTable].
+// Bucket counts of powers of two need readers of [Version2], the format of
+// databases written with this option.
+func WithPow2Buckets() WriteOption {
+	return func(o *writeOptions) {
+		o.pow2Buckets = true
+	}
+}
+
+// WithMaxBuckets limits the bucket counts of the hash tables of objects to
+// n, rounded down to a power of two with [WithPow2Buckets], so that keys
+// collide as in the worst cases of hashing, and the chains of large objects
+// are long. With n = 1, every lookup scans all the entries of the object.
+// It is meant for testing the latency of queries in the worst cases, see
+// also [github.com/mkch/hashive/testkit.SetKeyHash] for collisions of
+// the whole hashes.
+// It is ignored if n <= 0.
+func WithMaxBuckets(n int) WriteOption {
+	return func(o *writeOptions) {
+		o.maxBuckets = max(n, 0)
+	}
+}
+
+// WithChunkedBinary splits []byte values longer than threshold bytes into
+// chunks of chunkSize bytes with a table of chunk offsets, so that any range
+// of them can be read by [Hashive.QueryReaderAt] without reading the bytes
+// before the range, such as firmware images served by HTTP range requests.
+// It is ignored if chunkSize <= 0.
+// The database is written in format [Version2] even if no value is long
+// enough to be split.
+func WithChunkedBinary(threshold, chunkSize int) WriteOption {
+	return func(o *writeOptions) {
+		o.chunkOver, o.chunkSize = threshold, chunkSize
+	}
+}
+
+// WithChunkedArrays splits arrays of more than chunkSize elements into
+// chunks of chunkSize elements with a table of chunk offsets, so that the
+// arrays of unknown length, such as those written by [WriteArraySeq], are
+// encoded a chunk at a time. Elements are still read by index without
+// reading the elements before them.
+// It is ignored if chunkSize <= 0.
+// Chunked arrays were added in [Version2], so the database is written in
+// that format.
+func WithChunkedArrays(chunkSize int) WriteOption {
+	return func(o *writeOptions) {
+		o.arrayChunkSize = chunkSize
+	}
+}
+
+// TransformFunc is called by [Write] with the path and value of every value
+// to be written. It returns the value to write in place of v, or false to
+// drop the value, which removes the entry from its object or the element
+// from its array. A dropped root value is written as null.
+// path must not be retained after the call.
+type TransformFunc func(path []string, v any) (any, bool)
+
+// WithTransform calls fn for every value to write, before the values in it,
+// so that values can be redacted, normalized or converted while writing.
+// The values in the value returned by fn are then transformed.
+// The input value is not modified.
+func WithTransform(fn TransformFunc) WriteOption {
+	return func(o *writeOptions) {
+		o.transform = fn
+	}
+}
+
+// transform transforms v at path recursively with fn.
+func transform(path []string, v any, fn TransformFunc) (any, bool) {
+	v, ok := fn(path, v)
+	if !ok {
+		return nil, false
+	}
+	switch value := v.(type) {
+	case []any:
+		ary := make([]any, 0, len(value))
+		for i, elem := range value {
+			if elem, ok := transform(append(path, strconv.Itoa(i)), elem, fn); ok {
+				ary = append(ary, elem)
+			}
+		}
+		return ary, true
+	case map[string]any:
+		obj := make(map[string]any, len(value))
+		for key, elem := range value {
+			if elem, ok := transform(append(path, key), elem, fn); ok {
+				obj[key] = elem
+			}
+		}
+		return obj, true
+	case []Interval:
+		intervals := make([]Interval, 0, len(value))
+		for i, interval := range value {
+			if elem, ok := transform(append(path, strconv.Itoa(i)), interval.Value, fn); ok {
+				interval.Value = elem
+				intervals = append(intervals, interval)
+			}
+		}
+		return intervals, true
+	}
+	return v, true
+}
+
+// Option configures how a database is read by [New] and its variants.
+type Option func(*options)
+
+type options struct {
+	decodeGob bool
+	maxDepth  int
+	inMemory  int64 // the max size of files read into memory by [Open]
+	budget    int64 // the memory budget, see [WithMemoryBudget]
+	charged   int64 // the memory charged against budget, see [chargeBudget]
+	transform ReadTransformFunc
+	blobs     io.ReaderAt // the blob file, see [WithBlobReader]
+	// whether strings are returned as []byte, see [WithStringBytes]
+	stringBytes bool
+	utf8        UTF8Policy // see [WithReadUTF8]
+	// the max length of keys, see [WithReadMaxKeyLength]
+	maxKeyLength int
+	directory    bool // see [WithRootDirectory]
+	// the directory of the root object from the index file, see [WithIndexFile]
+	index *rootIndex
+	// the range of read buffer size, see [WithAdaptiveBuffer]
+	minBuffer, maxBuffer int
+	// capabilities to access the values hidden by ACL, see [WithCapabilities]
+	capabilities []string
+	ignoreACL    bool // see [ignoreACL]
+	// the max entries and TTL of cached results, see [WithResultCache]
+	cacheEntries int
+	cacheTTL     time.Duration
+}
+
+func newOptions(opts []Option) *options {
+	var options = options{maxDepth: DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &options
+}
+
+// DefaultMaxDepth is the default max nesting depth of arrays and objects.
+// See [WithMaxDepth].
+const DefaultMaxDepth = impl.DefaultMaxDepth
+
+// DepthError is returned when the nesting depth of arrays and objects
+// exceeds the limit. See [WithMaxDepth].
+type DepthError = impl.DepthError
+
+// WithMaxDepth limits the nesting depth of arrays and objects to read.
+// Reading values nested deeper, which are likely malicious, fails with a
+// [*DepthError] instead of exhausting the stack.
+// The root value is at depth 1. If n <= 0, [DefaultMaxDepth] is used.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		if n <= 0 {
+			n = DefaultMaxDepth
+		}
+		o.maxDepth = n
+	}
+}
+
+// WithGobDecoding makes [Hashive.Query] decode gob encoded values of the types
+// registered with [RegisterGobTypes] before written, instead of returning them
+// as opaque gob values. Structs are converted to map[string]any of exported fields,
+// and other values are converted as [Write] stores them, so the entire content
+// of the database can be exported as JSON.
+func WithGobDecoding() Option {
+	return func(o *options) {
+		o.decodeGob = true
+	}
+}
+
+// WithStringBytes makes [Hashive.Query] return string values, including
+// those in arrays and objects, as []byte, which saves the conversion and copy
+// of strings for pipelines writing the bytes elsewhere, such as to network
+// connections. Other queries are not affected.
+//
+// If the database is created by [NewBytes] or [OpenMmap], the returned bytes
+// are views of the underlying memory, like those returned by
+// [Hashive.QueryBinaryView]. They are only valid until the memory is released
+// (the close function of [OpenMmap] is called), and must not be modified,
+// retained or shared as strings(by unsafe.String, for example) beyond that.
+// Otherwise, the returned bytes are copies owned by the caller.
+func WithStringBytes() Option {
+	return func(o *options) {
+		o.stringBytes = true
+	}
+}
+
+// WithInMemory makes [Open] read the entire file into memory and close it
+// if its size is not greater than threshold, so that queries of small
+// databases, such as reference tables, do no disk I/O.
+// The close function returned by [Open] does nothing in that case.
+// It is ignored by other functions.
+func WithInMemory(threshold int64) Option {
+	return func(o *options) {
+		o.inMemory = threshold
+	}
+}
+
+// WithMemoryBudget bounds the memory used by a database to about n bytes,
+// excluding the values returned by queries. The memory is charged against n
+// in order: the file read into memory by [WithInMemory] if it fits the
+// budget, or the read buffer otherwise, which is shrunk to fit the budget
+// or disabled if the budget is too small to hold a useful buffer, then the
+// directory loaded by [WithRootDirectory] if it fits the rest, and the
+// results cached by [WithResultCache] are evicted to fit what is left.
+// Every snapshot, see [Hashive.Snapshot], has a budget of its own.
+// If n <= 0, the memory is not bounded.
+func WithMemoryBudget(n int64) Option {
+	return func(o *options) {
+		o.budget = max(n, 0)
+	}
+}
+
+// WithRootDirectory loads the positions and key hashes of all the entries
+// of the root object into memory when the database is opened, about 16 bytes
+// per entry, so that most lookups in the root object read the data once
+// instead of reading the offset table and scanning the hash chain.
+// The directory is not loaded if the root is not an object, or is written
+// with [WithFrontCoding], [WithFixedKeys] or [WithIntKeys] in effect.
+// See also [WithMemoryBudget].
+func WithRootDirectory() Option {
+	return func(o *options) {
+		o.directory = true
+	}
+}
+
+// WithAdaptiveBuffer makes the read buffer of [New] and its variants adapt
+// to how the database is read, between minSize and maxSize bytes, starting
+// from the readBufferSize argument. Scans reading many values in a row,
+// such as [Hashive.Query] of large objects and exports, grow the buffer to
+// read the data in fewer and larger reads, and point lookups shrink it to
+// read little more than what they need. Skipping forward within the buffer,
+// such as over values in a hash chain, does not read the data again.
+// It is ignored if maxSize <= minSize or the buffer is disabled.
+// maxSize is bounded by [WithMemoryBudget].
+func WithAdaptiveBuffer(minSize, maxSize int) Option {
+	return func(o *options) {
+		o.minBuffer, o.maxBuffer = minSize, maxSize
+	}
+}
+
+// minBufferSize is the smallest read buffer worth allocating.
+const minBufferSize = 16
+
+// chargeBudget charges n bytes of memory used by the database against the
+// memory budget, see [WithMemoryBudget].
+func chargeBudget(n int64) Option {
+	return func(o *options) {
+		o.charged += n
+	}
+}
+
+// available returns the memory budget not charged yet. bounded is false if
+// the memory is not bounded.
+func (o *options) available() (n int64, bounded bool) {
+	if o.budget <= 0 {
+		return 0, false
+	}
+	return max(o.budget-o.charged, 0), true
+}
+
+// bufferSize returns the size of read buffer within the memory budget.
+// A negative readBufferSize means the default size.
+func (o *options) bufferSize(readBufferSize int) int {
+	if readBufferSize < 0 {
+		readBufferSize = defaultBufferSize
+	}
+	if n, bounded := o.available(); bounded && int64(readBufferSize) > n {
+		if n < minBufferSize {
+			return 0
+		}
+		return int(n)
+	}
+	return readBufferSize
+}
+
+// newReader returns the buffered reader of r with readBufferSize, see [New],
+// and the max size of its buffer.
+func (o *options) newReader(r io.ReadSeeker, readBufferSize int) (_ impl.ByteReadSeeker, maxSize int, err error) {
+	size := o.bufferSize(readBufferSize)
+	if size == 0 || o.maxBuffer <= o.minBuffer {
+		reader, err := impl.NewBufByteReadSeeker(r, size)
+		return reader, size, err
+	}
+	maxSize = o.bufferSize(o.maxBuffer)
+	reader, err := impl.NewAdaptiveBufByteReadSeeker(r, size, max(o.minBuffer, minBufferSize), maxSize)
+	return reader, max(size, maxSize), err
+}
+
+// inMemoryThreshold returns the max size of files read into memory by
+// [Open] within the memory budget.
+func (o *options) inMemoryThreshold() int64 {
+	if n, bounded := o.available(); bounded {
+		return min(o.inMemory, n)
+	}
+	return o.inMemory
+}
+
+// ReadTransformFunc is called with the path and value of every value read
+// by queries, and returns the value to return in place of v.
+// path must not be retained after the call.
+type ReadTransformFunc func(path []string, v any) (any, error)
+
+// WithReadTransform calls fn for every value returned by [Hashive.Query] and
+// the other queries returning values of type any, after the values in it,
+// so that values can be decrypted or converted in one place.
+// Gob encoded values are passed to fn after decoded by [WithGobDecoding].
+// The error returned by fn is returned by the query.
+func WithReadTransform(fn ReadTransformFunc) Option {
+	return func(o *options) {
+		o.transform = fn
+	}
+}
+
+// readTransform transforms v at path recursively with fn.
+func readTransform(path []string, v any, fn ReadTransformFunc) (_ any, err error) {
+	switch value := v.(type) {
+	case []any:
+		for i, elem := range value {
+			if value[i], err = readTransform(append(path, strconv.Itoa(i)), elem, fn); err != nil {
+				return
+			}
+		}
+	case map[string]any:
+		for key, elem := range value {
+			if value[key], err = readTransform(append(path, key), elem, fn); err != nil {
+				return
+			}
+		}
+	case []Interval:
+		for i, interval := range value {
+			if value[i].Value, err = readTransform(append(path, strconv.Itoa(i)), interval.Value, fn); err != nil {
+				return
+			}
+		}
+	}
+	return fn(path, v)
+}
@@ -0,0 +1,127 @@
+package hashive
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+)
+
+// Entry is an entry of an [OrderedObject].
+type Entry struct {
+	Key   string
+	Value any
+}
+
+// OrderedObject is an object whose key order matters, such as a config
+// file. [Write] stores it as an object, and its key order in a table
+// beside the object, which is returned by [Hashive.OrderedKeys].
+// If a key appears more than once, the last value wins by default, and the
+// key stays at its first position. See [WithDuplicateKeys].
+// See [WithKeyOrder] for recording the key order of JSON objects.
+type OrderedObject []Entry
+
+func (obj OrderedObject) unwrap() (any, string, any) {
+	m := make(map[string]any, len(obj))
+	keys := make([]any, 0, len(obj))
+	for _, entry := range obj {
+		if _, ok := m[entry.Key]; !ok {
+			keys = append(keys, entry.Key)
+		}
+		m[entry.Key] = entry.Value
+	}
+	return m, headerOrder, keys
+}
+
+func (obj OrderedObject) rewrap(v any) sideValue {
+	m, _ := v.(map[string]any)
+	_, _, keys := obj.unwrap()
+	return newOrderedObject(m, keys)
+}
+
+// newOrderedObject returns the entries of m in the order of keys, which is
+// a []any of strings. The keys of m not in keys follow in sorted order.
+func newOrderedObject(m map[string]any, keys any) OrderedObject {
+	obj := make(OrderedObject, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	list, _ := keys.([]any)
+	for _, k := range list {
+		key, ok := k.(string)
+		if !ok || seen[key] {
+			continue
+		}
+		if value, ok := m[key]; ok {
+			obj = append(obj, Entry{key, value})
+			seen[key] = true
+		}
+	}
+	rest := make([]string, 0, len(m)-len(obj))
+	for key := range m {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	slices.Sort(rest)
+	for _, key := range rest {
+		obj = append(obj, Entry{key, m[key]})
+	}
+	return obj
+}
+
+// MarshalJSON encodes obj as a JSON object with keys in order.
+func (obj OrderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range obj {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// WithKeyOrder makes [WriteJSON] and its variants record the key order of
+// JSON objects, as if they were [OrderedObject]. See [Hashive.OrderedKeys].
+// It is ignored by other functions.
+func WithKeyOrder() WriteOption {
+	return func(o *writeOptions) {
+		o.keyOrder = true
+	}
+}
+
+// OrderedKeys returns the keys of the object mapped by the path in the
+// order written with [OrderedObject], or nil if the order is not recorded.
+// [ErrNotFound] will be returned if the path does not map to an object.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) OrderedKeys(path ...string) (keys []string, err error) {
+	if _, err = h.queryObjectValue(path); err != nil {
+		return
+	}
+	v, err := h.sideTableValue(headerOrder, path)
+	if err != nil {
+		return
+	}
+	list, _ := v.([]any)
+	for _, k := range list {
+		key, ok := k.(string)
+		if !ok {
+			continue
+		}
+		if h.checkACL(append(path[:len(path):len(path)], key)) == nil {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package hashive_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestOrderedKeys(t *testing.T) {
+	const input = `{"z":1,"a":{"y":true,"b":null,"x":[{"2":2,"1":1}]},"m":"s"}`
+	var buf bytes.Buffer
+	if err := hashive.WriteJSONString(&buf, input, hashive.WithKeyOrder(), hashive.WithJSONSource("in.json")); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := hashive.Compact(bytes.NewReader(buf.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{buf.Bytes(), out.Bytes()} {
+		h, err := hashive.New(bytes.NewReader(data), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range []struct {
+			path []string
+			keys []string
+		}{
+			{nil, []string{"z", "a", "m"}},
+			{[]string{"a"}, []string{"y", "b", "x"}},
+			{[]string{"a", "x", "0"}, []string{"2", "1"}},
+		} {
+			if keys, err := h.OrderedKeys(test.path...); err != nil || !reflect.DeepEqual(keys, test.keys) {
+				t.Fatal(test.path, keys, err)
+			}
+		}
+		if _, err := h.OrderedKeys("m"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+		if source, err := h.Provenance("a", "b"); err != nil || source != "in.json:1:26" {
+			t.Fatal(source, err)
+		}
+		if v, err := h.Query("a", "y"); err != nil || v != true {
+			t.Fatal(v, err)
+		}
+	}
+
+	obj := hashive.OrderedObject{{"b", 1}, {"a", hashive.OrderedObject{{"d", "x"}, {"c", "y"}}}, {"b", 2}}
+	if j, err := json.Marshal(obj); err != nil || string(j) != `{"b":1,"a":{"d":"x","c":"y"},"b":2}` {
+		t.Fatal(string(j), err)
+	}
+	buf.Reset()
+	if err := hashive.Write(&buf, obj, hashive.WithSortedKeys()); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := h.OrderedKeys(); err != nil || !reflect.DeepEqual(keys, []string{"b", "a"}) {
+		t.Fatal(keys, err)
+	}
+	if v, err := h.Query("b"); err != nil || v != int64(2) {
+		t.Fatal(v, err)
+	}
+	if keys, err := h.OrderedKeys("a"); err != nil || !reflect.DeepEqual(keys, []string{"d", "c"}) {
+		t.Fatal(keys, err)
+	}
+
+	buf.Reset()
+	if err := hashive.WriteJSONString(&buf, input); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = hashive.New(bytes.NewReader(buf.Bytes()), -1); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := h.OrderedKeys(); err != nil || keys != nil {
+		t.Fatal(keys, err)
+	}
+}
@@ -0,0 +1,44 @@
+package hashive
+
+// ValueWithProvenance is a value with the location in the source it comes
+// from, such as "data.csv:12". [Write] stores Value in place of it, and
+// Source in a table beside the value, which is returned by
+// [Hashive.Provenance]. See [WithJSONSource] for recording the provenance
+// of JSON values.
+type ValueWithProvenance struct {
+	Value  any
+	Source string
+}
+
+func (v ValueWithProvenance) unwrap() (any, string, any) {
+	return v.Value, headerProvenance, v.Source
+}
+
+func (v ValueWithProvenance) rewrap(value any) sideValue {
+	v.Value = value
+	return v
+}
+
+// Provenance returns the source location of the value mapped by the path
+// written with [ValueWithProvenance], or "" if there is none.
+// [ErrNotFound] will be returned if the path does not map to any value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Provenance(path ...string) (source string, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	v, err := h.sideTableValue(headerProvenance, path)
+	source, _ = v.(string)
+	return
+}
+
+// WithJSONSource makes [WriteJSON] and its variants record the provenance of
+// every value as "name:line:column" of the JSON input, where line and
+// column start from 1, and column counts bytes.
+// See [Hashive.Provenance]. It is ignored by other functions.
+func WithJSONSource(name string) WriteOption {
+	return func(o *writeOptions) {
+		o.jsonSource = name
+	}
+}
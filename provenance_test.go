@@ -0,0 +1,69 @@
+package hashive_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestProvenance(t *testing.T) {
+	const input = `{
+  "name": "a",
+  "list": [1,
+    {"k": true}]
+}`
+	var buf bytes.Buffer
+	if err := hashive.WriteJSONString(&buf, input, hashive.WithJSONSource("a.json")); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := hashive.Compact(bytes.NewReader(buf.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{buf.Bytes(), out.Bytes()} {
+		h, err := hashive.New(bytes.NewReader(data), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query("list", "1", "k"); err != nil || v != true {
+			t.Fatal(v, err)
+		}
+		for _, test := range []struct {
+			path   []string
+			source string
+		}{
+			{nil, "a.json:1:1"},
+			{[]string{"name"}, "a.json:2:11"},
+			{[]string{"list"}, "a.json:3:11"},
+			{[]string{"list", "0"}, "a.json:3:12"},
+			{[]string{"list", "1"}, "a.json:4:5"},
+			{[]string{"list", "1", "k"}, "a.json:4:11"},
+		} {
+			if source, err := h.Provenance(test.path...); err != nil || source != test.source {
+				t.Fatal(test.path, source, err)
+			}
+		}
+		if _, err := h.Provenance("missing"); err != hashive.ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+
+	buf.Reset()
+	if err := hashive.Write(&buf, map[string]any{
+		"a": hashive.ValueWithProvenance{Value: 1, Source: "data.csv:2"},
+		"b": 2,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source, err := h.Provenance("a"); err != nil || source != "data.csv:2" {
+		t.Fatal(source, err)
+	}
+	if source, err := h.Provenance("b"); err != nil || source != "" {
+		t.Fatal(source, err)
+	}
+}
@@ -0,0 +1,80 @@
+package hashive
+
+import (
+	"errors"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Querier is the interface to query databases, which is implemented by
+// [*Hashive] and [*Chained], and by the clients of databases served over
+// HTTP by package remote, so that the code querying databases can be
+// written against it, and tested with mocks or the databases created by
+// [NewMemory].
+type Querier interface {
+	Query(path ...string) (any, error)
+	QueryInto(dst any, path ...string) error
+	QueryGob(v any, path ...string) error
+	Exists(path ...string) (bool, error)
+}
+
+var (
+	_ Querier = (*Hashive)(nil)
+	_ Querier = (*Chained)(nil)
+)
+
+// Exists reports whether the path maps to any value, which is not read.
+// Values hidden by [WithACL] and the elements out of the bounds of arrays
+// don't exist.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Exists(path ...string) (bool, error) {
+	err := h.seekValue(path)
+	var boundsErr *impl.BoundsError
+	if err == ErrNotFound || errors.As(err, &boundsErr) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Kind returns the kind of the value mapped by the path, which is not read
+// except the referenced values of [WithDedup], so that browsers of databases
+// can tell arrays and objects from the other values cheaply.
+// [ErrNotFound] will be returned if the path does not map to any value, see
+// [Hashive.Exists].
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Kind(path ...string) (kind Kind, err error) {
+	if err = h.seekValue(path); err != nil {
+		var boundsErr *impl.BoundsError
+		if errors.As(err, &boundsErr) {
+			err = ErrNotFound
+		}
+		return
+	}
+	t, err := impl.ReadType(h.r)
+	if err != nil {
+		return
+	}
+	if t != impl.TypeRef {
+		return kindOfType(t), nil
+	}
+	v, err := h.query(path, false)
+	if err != nil {
+		return
+	}
+	switch v := v.(type) {
+	case *impl.Array:
+		return KindArray, nil
+	case *impl.Object:
+		return KindObject, nil
+	case *impl.Intervals:
+		return KindIntervals, nil
+	case GobValue:
+		return KindGob, nil
+	case impl.Blob:
+		return KindBinary, nil
+	default:
+		return kindOf(v), nil
+	}
+}
@@ -0,0 +1,78 @@
+package hashive_test
+
+import (
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestExists(t *testing.T) {
+	h, err := hashive.NewMemory(map[string]any{
+		"a":      []any{1, nil},
+		"secret": map[string]any{"x": 1},
+		"s":      "str",
+	}, hashive.WithACL(hashive.ACLEntry{Path: []string{"secret"}, Capability: "admin"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var q hashive.Querier = h
+	for _, test := range []struct {
+		path []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"a"}, true},
+		{[]string{"a", "1"}, true},
+		{[]string{"a", "2"}, false},
+		{[]string{"s", "x"}, false},
+		{[]string{"secret"}, false},
+		{[]string{"secret", "x"}, false},
+		{[]string{"missing"}, false},
+	} {
+		if exists, err := q.Exists(test.path...); err != nil || exists != test.want {
+			t.Fatal(test.path, exists, err)
+		}
+	}
+	if _, err := q.Exists("a", "x"); err == nil {
+		t.Fatal("invalid index")
+	}
+}
+
+func TestKind(t *testing.T) {
+	h, err := hashive.NewMemory(map[string]any{
+		"a":      []any{1, nil, "s", []byte("b")},
+		"o":      map[string]any{"f": 1.5, "b": true},
+		"u":      uint64(1) << 63,
+		"dup1":   map[string]any{"x": 1},
+		"dup2":   map[string]any{"x": 1},
+		"secret": "x",
+	}, hashive.WithDedup(), hashive.WithACL(hashive.ACLEntry{Path: []string{"secret"}, Capability: "admin"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path []string
+		want hashive.Kind
+	}{
+		{nil, hashive.KindObject},
+		{[]string{"a"}, hashive.KindArray},
+		{[]string{"a", "0"}, hashive.KindInt},
+		{[]string{"a", "1"}, hashive.KindNull},
+		{[]string{"a", "2"}, hashive.KindString},
+		{[]string{"a", "3"}, hashive.KindBinary},
+		{[]string{"o"}, hashive.KindObject},
+		{[]string{"o", "f"}, hashive.KindFloat},
+		{[]string{"o", "b"}, hashive.KindBool},
+		{[]string{"u"}, hashive.KindUint},
+		{[]string{"dup2"}, hashive.KindObject},
+	} {
+		if kind, err := h.Kind(test.path...); err != nil || kind != test.want {
+			t.Fatal(test.path, kind, err)
+		}
+	}
+	for _, path := range [][]string{{"missing"}, {"a", "4"}, {"secret"}, {"o", "f", "x"}} {
+		if _, err := h.Kind(path...); err != hashive.ErrNotFound {
+			t.Fatal(path, err)
+		}
+	}
+}
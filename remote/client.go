@@ -0,0 +1,108 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mkch/hashive"
+)
+
+// Client queries a database served by [Handler]. It is safe for concurrent
+// use.
+//
+// Values are decoded by the client, so [Client.QueryInto] doesn't validate
+// them against the schema of the database, and [Client.QueryGob] doesn't
+// check the definitions of gob types, see [hashive.GobTypeError].
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+var _ hashive.Querier = (*Client)(nil)
+
+// NewClient returns the client querying the database served at url by
+// [Handler]. Requests are sent by client, or [http.DefaultClient] if it is
+// nil.
+func NewClient(url string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{strings.TrimSuffix(url, "/"), client}
+}
+
+// get sends a request to the endpoint with the path, and returns the body
+// of the response.
+func (c *Client) get(endpoint string, path []string) (body []byte, err error) {
+	query := url.Values{"path": path}
+	resp, err := c.client.Get(c.url + endpoint + "?" + query.Encode())
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, hashive.ErrNotFound
+	default:
+		return nil, fmt.Errorf("remote query: %v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// value returns the value mapped by the path as a database.
+func (c *Client) value(path []string) (h *hashive.Hashive, err error) {
+	body, err := c.get("/query", path)
+	if err != nil {
+		return
+	}
+	return hashive.NewBytes(body)
+}
+
+// Query is [hashive.Hashive.Query] of the remote database.
+func (c *Client) Query(path ...string) (v any, err error) {
+	h, err := c.value(path)
+	if err != nil {
+		return
+	}
+	return h.Query()
+}
+
+// QueryInto is [hashive.Hashive.QueryInto] of the remote database.
+func (c *Client) QueryInto(dst any, path ...string) (err error) {
+	h, err := c.value(path)
+	if err != nil {
+		return
+	}
+	return h.QueryInto(dst)
+}
+
+// QueryGob is [hashive.Hashive.QueryGob] of the remote database.
+func (c *Client) QueryGob(v any, path ...string) (err error) {
+	h, err := c.value(path)
+	if err != nil {
+		return
+	}
+	return h.QueryGob(v)
+}
+
+// Exists is [hashive.Hashive.Exists] of the remote database.
+func (c *Client) Exists(path ...string) (exists bool, err error) {
+	body, err := c.get("/exists", path)
+	if err != nil {
+		return
+	}
+	switch string(body) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, errors.New("remote query: invalid response " + string(body))
+}
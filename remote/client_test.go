@@ -0,0 +1,99 @@
+package remote_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/remote"
+)
+
+type point struct{ X, Y int }
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	h, err := hashive.NewMemory(map[string]any{
+		"s":   "str",
+		"b":   []byte{1, 2},
+		"u":   uint64(1) << 63,
+		"a":   []any{1, nil, map[string]any{"k": 1.5}},
+		"":    "empty",
+		"a/b": true,
+		"p":   point{1, 2},
+		"i":   []hashive.Interval{{Start: 1, End: 2, Value: "x"}},
+		"m":   map[string]any{"x": int64(1), "y": int64(2)},
+		"secret": map[string]any{
+			"x": 1,
+		},
+	}, hashive.WithACL(hashive.ACLEntry{Path: []string{"secret"}, Capability: "admin"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(remote.NewHandler(h))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := newTestServer(t)
+	var c hashive.Querier = remote.NewClient(server.URL+"/", server.Client())
+
+	for _, test := range []struct {
+		path []string
+		want any
+	}{
+		{[]string{"s"}, "str"},
+		{[]string{"b"}, []byte{1, 2}},
+		{[]string{"u"}, uint64(1) << 63},
+		{[]string{"a", "0"}, int64(1)},
+		{[]string{"a", "1"}, nil},
+		{[]string{"a", "2"}, map[string]any{"k": 1.5}},
+		{[]string{""}, "empty"},
+		{[]string{"a/b"}, true},
+		{[]string{"i"}, []hashive.Interval{{Start: 1, End: 2, Value: "x"}}},
+	} {
+		if v, err := c.Query(test.path...); err != nil || !reflect.DeepEqual(v, test.want) {
+			t.Fatal(test.path, v, err)
+		}
+	}
+	if v, err := c.Query(); err != nil || len(v.(map[string]any)) != 9 {
+		t.Fatal(v, err)
+	}
+	for _, path := range [][]string{{"x"}, {"secret"}, {"s", "x"}} {
+		if _, err := c.Query(path...); err != hashive.ErrNotFound {
+			t.Fatal(path, err)
+		}
+	}
+	for _, path := range [][]string{{"a", "3"}, {"a", "x"}} {
+		if _, err := c.Query(path...); err == nil || err == hashive.ErrNotFound {
+			t.Fatal(path, err)
+		}
+	}
+
+	var m map[string]int
+	if err := c.QueryInto(&m, "m"); err != nil || !reflect.DeepEqual(m, map[string]int{"x": 1, "y": 2}) {
+		t.Fatal(m, err)
+	}
+	var p point
+	if err := c.QueryGob(&p, "p"); err != nil || p != (point{1, 2}) {
+		t.Fatal(p, err)
+	}
+	if err := c.QueryGob(&p, "s"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"a", "2", "k"}, true},
+		{[]string{"a", "3"}, false},
+		{[]string{"secret"}, false},
+	} {
+		if exists, err := c.Exists(test.path...); err != nil || exists != test.want {
+			t.Fatal(test.path, exists, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Package remote serves queries of Hashive databases over HTTP, so that
+several processes on a host can share one open, cached database instead of
+opening it in every process.
+
+[Handler] serves a database, and [Client] queries it, implementing
+[hashive.Querier]. Values are sent as standalone Hashive databases, so they
+are received with exactly the types returned by [hashive.Hashive.Query].
+
+The protocol is two GET endpoints under the URL of the handler, both taking
+the path of the value as repeated "path" query parameters:
+
+	/query   the value mapped by the path as a database written by hashive.Write
+	/exists  "true" or "false", see hashive.Hashive.Exists
+
+Status 404 means [hashive.ErrNotFound], and the bodies of the other errors
+are the messages of them.
+*/
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mkch/hashive"
+)
+
+// Handler is the [http.Handler] serving the queries of a database. Queries
+// are served concurrently by snapshots of the database, see
+// [hashive.Hashive.Snapshot], which are reused by later queries. If the
+// database can't be snapshotted, queries are served one at a time, because
+// databases are not safe for concurrent use.
+type Handler struct {
+	h         *hashive.Hashive
+	snapshots sync.Pool  // of *hashive.Hashive, the idle snapshots of h
+	serial    bool       // whether h can't be snapshotted
+	mu        sync.Mutex // guards h if serial
+}
+
+// NewHandler returns the handler serving the queries of h. h must not be
+// used by others while the handler is in use, unless it can be snapshotted.
+func NewHandler(h *hashive.Hashive) *Handler {
+	handler := &Handler{h: h}
+	if snapshot, err := h.Snapshot(); err != nil {
+		handler.serial = true
+	} else {
+		handler.snapshots.Put(snapshot)
+	}
+	return handler
+}
+
+// acquire returns the database to query, which must be released by
+// calling release after the query.
+func (handler *Handler) acquire() (db *hashive.Hashive, release func(), err error) {
+	if handler.serial {
+		handler.mu.Lock()
+		return handler.h, handler.mu.Unlock, nil
+	}
+	db, ok := handler.snapshots.Get().(*hashive.Hashive)
+	if !ok {
+		if db, err = handler.h.Snapshot(); err != nil {
+			return
+		}
+	}
+	return db, func() { handler.snapshots.Put(db) }, nil
+}
+
+// ServeHTTP implements [http.Handler].
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.Query()["path"]
+	var body []byte
+	var err error
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/query"):
+		body, err = handler.query(path)
+	case strings.HasSuffix(r.URL.Path, "/exists"):
+		body, err = handler.exists(path)
+	default:
+		http.Error(w, "unknown endpoint "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, hashive.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// query returns the value mapped by the path as a database.
+func (handler *Handler) query(path []string) (body []byte, err error) {
+	db, release, err := handler.acquire()
+	if err != nil {
+		return
+	}
+	v, err := db.Query(path...)
+	release()
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err = hashive.Write(&buf, v); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+// exists returns whether the path maps to any value as text.
+func (handler *Handler) exists(path []string) (body []byte, err error) {
+	db, release, err := handler.acquire()
+	if err != nil {
+		return
+	}
+	exists, err := db.Exists(path...)
+	release()
+	if err != nil {
+		return
+	}
+	if exists {
+		return []byte("true"), nil
+	}
+	return []byte("false"), nil
+}
@@ -0,0 +1,85 @@
+package remote_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/remote"
+)
+
+func TestHandler(t *testing.T) {
+	server := newTestServer(t)
+	for _, test := range []struct {
+		method, url string
+		status      int
+		body        string
+	}{
+		{http.MethodGet, "/exists?path=a&path=1", http.StatusOK, "true"},
+		{http.MethodGet, "/exists?path=secret", http.StatusOK, "false"},
+		{http.MethodGet, "/query?path=x", http.StatusNotFound, ""},
+		{http.MethodGet, "/unknown", http.StatusBadRequest, ""},
+		{http.MethodPost, "/query", http.StatusMethodNotAllowed, ""},
+	} {
+		req, err := http.NewRequest(test.method, server.URL+test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.status || test.body != "" && string(body) != test.body {
+			t.Fatal(test.method, test.url, resp.Status, string(body))
+		}
+	}
+}
+
+func TestHandlerConcurrent(t *testing.T) {
+	value := make([]any, 100)
+	for i := range value {
+		value[i] = strconv.Itoa(i)
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+	snapshotted, err := hashive.New(bytes.NewReader(buf.Bytes()), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not an io.ReaderAt, so it can't be snapshotted.
+	serial, err := hashive.New(struct{ io.ReadSeeker }{bytes.NewReader(buf.Bytes())}, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []*hashive.Hashive{snapshotted, serial} {
+		server := httptest.NewServer(remote.NewHandler(h))
+		c := remote.NewClient(server.URL+"/", server.Client())
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range value {
+					if v, err := c.Query(strconv.Itoa(i)); err != nil || v != value[i] {
+						t.Error(i, v, err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		server.Close()
+	}
+}
@@ -0,0 +1,267 @@
+package hashive
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kind is the kind of values described by a [Schema].
+type Kind int
+
+const (
+	KindAny       Kind = iota // Any value
+	KindNull                  // null
+	KindInt                   // Signed integer
+	KindUint                  // Unsigned integer
+	KindFloat                 // Floating-point number
+	KindNumber                // Any of KindInt, KindUint and KindFloat
+	KindBool                  // bool
+	KindString                // string
+	KindBinary                // []byte
+	KindGob                   // gob encoded value
+	KindArray                 // []any
+	KindObject                // map[string]any
+	KindIntervals             // []Interval
+)
+
+var kindNames = [...]string{
+	KindAny:       "any",
+	KindNull:      "null",
+	KindInt:       "int",
+	KindUint:      "uint",
+	KindFloat:     "float",
+	KindNumber:    "number",
+	KindBool:      "bool",
+	KindString:    "string",
+	KindBinary:    "binary",
+	KindGob:       "gob",
+	KindArray:     "array",
+	KindObject:    "object",
+	KindIntervals: "intervals",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// kindOf returns the kind of v as it is stored by [Write].
+func kindOf(v any) Kind {
+	switch v.(type) {
+	case nil:
+		return KindNull
+	case int8, int16, int32, int64, int:
+		return KindInt
+	case uint8, uint16, uint32, uint64, uint:
+		return KindUint
+	case float32, float64:
+		return KindFloat
+	case bool:
+		return KindBool
+	case string:
+		return KindString
+	case []byte:
+		return KindBinary
+	case []any:
+		return KindArray
+	case map[string]any:
+		return KindObject
+	case []Interval:
+		return KindIntervals
+	default:
+		return KindGob
+	}
+}
+
+// match returns whether value of kind k matches the schema kind.
+func (k Kind) match(kind Kind) bool {
+	switch k {
+	case KindAny:
+		return true
+	case KindNumber:
+		return kind == KindInt || kind == KindUint || kind == KindFloat
+	default:
+		return k == kind
+	}
+}
+
+// Schema describes the structure of a value.
+// A nil *Schema matches any value.
+type Schema struct {
+	Kind Kind
+	// Nullable reports whether null is allowed in addition to Kind.
+	Nullable bool
+	// Elem is the schema of the elements of an array.
+	Elem *Schema
+	// Fields is the schema of the values of known keys of an object.
+	Fields map[string]*Schema
+	// Required is the keys must exist in an object.
+	Required []string
+	// Values is the schema of the values of an object not listed in Fields.
+	Values *Schema
+}
+
+// SchemaError is returned when a value does not match the schema.
+type SchemaError struct {
+	Path []string // The path to the mismatched value.
+	Msg  string
+}
+
+func (err *SchemaError) Error() string {
+	return fmt.Sprintf("schema mismatch at /%v: %v", strings.Join(err.Path, "/"), err.Msg)
+}
+
+// Validate validates v against schema.
+// The returned error is a [*SchemaError] if v does not match.
+func (schema *Schema) Validate(v any) error {
+	return schema.validate(nil, v)
+}
+
+func (schema *Schema) validate(path []string, v any) error {
+	if schema == nil {
+		return nil
+	}
+	kind := kindOf(v)
+	if kind == KindNull && schema.Nullable {
+		return nil
+	}
+	if !schema.Kind.match(kind) {
+		return &SchemaError{slices.Clone(path), fmt.Sprintf("expected %v, got %v", schema.Kind, kind)}
+	}
+	switch value := v.(type) {
+	case []any:
+		for i, elem := range value {
+			if err := schema.Elem.validate(append(path, strconv.Itoa(i)), elem); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, key := range schema.Required {
+			if _, ok := value[key]; !ok {
+				return &SchemaError{slices.Clone(path), fmt.Sprintf("missing required key %q", key)}
+			}
+		}
+		for key, elem := range value {
+			if err := schema.field(key).validate(append(path, key), elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// field returns the schema of the value of key.
+func (schema *Schema) field(key string) *Schema {
+	if schema == nil {
+		return nil
+	}
+	if field, ok := schema.Fields[key]; ok {
+		return field
+	}
+	return schema.Values
+}
+
+// lookup returns the schema of the value mapped by path.
+func (schema *Schema) lookup(path []string) *Schema {
+	for _, key := range path {
+		if schema == nil {
+			return nil
+		}
+		if schema.Kind == KindArray {
+			schema = schema.Elem
+		} else {
+			schema = schema.field(key)
+		}
+	}
+	return schema
+}
+
+// Schema values stored in the header.
+const (
+	schemaKind     = "kind"
+	schemaNullable = "nullable"
+	schemaElem     = "elem"
+	schemaFields   = "fields"
+	schemaRequired = "required"
+	schemaValues   = "values"
+)
+
+// value converts schema to a value can be written by [Write].
+func (schema *Schema) value() any {
+	if schema == nil {
+		return nil
+	}
+	v := map[string]any{schemaKind: uint64(schema.Kind)}
+	if schema.Nullable {
+		v[schemaNullable] = true
+	}
+	if schema.Elem != nil {
+		v[schemaElem] = schema.Elem.value()
+	}
+	if len(schema.Fields) > 0 {
+		fields := make(map[string]any, len(schema.Fields))
+		for k, field := range schema.Fields {
+			fields[k] = field.value()
+		}
+		v[schemaFields] = fields
+	}
+	if len(schema.Required) > 0 {
+		required := make([]any, len(schema.Required))
+		for i, k := range schema.Required {
+			required[i] = k
+		}
+		v[schemaRequired] = required
+	}
+	if schema.Values != nil {
+		v[schemaValues] = schema.Values.value()
+	}
+	return v
+}
+
+// schemaFromValue converts the value returned by [Schema.value] back to a Schema.
+func schemaFromValue(v any) (schema *Schema, err error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid schema %v", v)
+	}
+	kind, ok := m[schemaKind].(uint64)
+	if !ok || kind >= uint64(len(kindNames)) {
+		return nil, fmt.Errorf("invalid schema kind %v", m[schemaKind])
+	}
+	schema = &Schema{Kind: Kind(kind)}
+	if nullable, ok := m[schemaNullable].(bool); ok {
+		schema.Nullable = nullable
+	}
+	if schema.Elem, err = schemaFromValue(m[schemaElem]); err != nil {
+		return
+	}
+	if fields, ok := m[schemaFields].(map[string]any); ok {
+		schema.Fields = make(map[string]*Schema, len(fields))
+		for _, k := range slices.Sorted(maps.Keys(fields)) {
+			if schema.Fields[k], err = schemaFromValue(fields[k]); err != nil {
+				return
+			}
+		}
+	}
+	if required, ok := m[schemaRequired].([]any); ok {
+		for _, k := range required {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid required key %v", k)
+			}
+			schema.Required = append(schema.Required, key)
+		}
+	}
+	if schema.Values, err = schemaFromValue(m[schemaValues]); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+var ownerSchema = &hashive.Schema{
+	Kind: hashive.KindObject,
+	Fields: map[string]*hashive.Schema{
+		"Name": {Kind: hashive.KindString},
+		"Age":  {Kind: hashive.KindNumber},
+		"Addr": {Kind: hashive.KindString, Nullable: true},
+	},
+	Required: []string{"Name"},
+}
+
+var dbSchema = &hashive.Schema{
+	Kind: hashive.KindObject,
+	Fields: map[string]*hashive.Schema{
+		"Owners": {Kind: hashive.KindArray, Elem: ownerSchema},
+	},
+	Values: &hashive.Schema{Kind: hashive.KindString},
+}
+
+func TestSchema(t *testing.T) {
+	value := map[string]any{
+		"Owners": []any{
+			map[string]any{"Name": "John", "Age": 28},
+			map[string]any{"Name": "Joe", "Age": 29.5, "Addr": nil},
+		},
+		"Key": "value",
+	}
+
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithSchema(dbSchema)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema := h.Schema(); !reflect.DeepEqual(schema, dbSchema) {
+		t.Fatal(schema)
+	}
+
+	var schemaErr *hashive.SchemaError
+	value["Key"] = 1
+	if err := hashive.Write(&buf, value, hashive.WithSchema(dbSchema)); !errors.As(err, &schemaErr) {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(schemaErr.Path, []string{"Key"}) {
+		t.Fatal(schemaErr)
+	}
+	value["Key"] = "value"
+	value["Owners"] = []any{map[string]any{"Age": 1}}
+	if err := hashive.Write(&buf, value, hashive.WithSchema(dbSchema)); !errors.As(err, &schemaErr) {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(schemaErr.Path, []string{"Owners", "0"}) {
+		t.Fatal(schemaErr)
+	}
+}
@@ -0,0 +1,154 @@
+package hashive
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// WriteSeq is like [Write] but writes the object of the key/value pairs
+// yielded by seq, so that the pairs can be streamed from their source
+// without building the object. Every value is encoded as soon as it is
+// yielded, so only the encoded values are kept in memory.
+// n is the hint of the number of pairs, or 0 if unknown.
+//
+// If a key is yielded more than once, the value kept is decided by
+// [WithDuplicateKeys]. With [WithTransform], [WithSchema], [WithDedup],
+// [DuplicateCollect] or [DuplicateMultiValue], which need the whole
+// object, the pairs are collected before written.
+func WriteSeq(w io.Writer, seq iter.Seq2[string, any], n int, opts ...WriteOption) (err error) {
+	options := newWriteOptions(opts)
+	if !options.needWhole() && options.duplicateKeys != DuplicateCollect && options.duplicateKeys != DuplicateMultiValue {
+		return Write(w, &pairSeq{seq, n}, opts...)
+	}
+	obj := make(OrderedObject, 0, max(n, 0))
+	for key, value := range seq {
+		obj = append(obj, Entry{key, value})
+	}
+	if obj, err = obj.dedupKeys(nil, options.duplicateKeys); err != nil {
+		return
+	}
+	value, _, _ := obj.unwrap()
+	return Write(w, value, opts...)
+}
+
+// pairSeq is the value written by [WriteSeq].
+type pairSeq struct {
+	seq iter.Seq2[string, any]
+	n   int
+}
+
+// encode prepares and encodes every value yielded by seq with encoder, and
+// returns the object of the encoded [impl.Raw] values. The side values are
+// extracted into tables.
+func (seq *pairSeq) encode(encoder *impl.Encoder, options *writeOptions, tables map[string]map[string]any) (_ any, err error) {
+	obj := make(map[string]any, max(seq.n, 0))
+	for key, value := range seq.seq {
+		// The side values are added to tables only if value is kept.
+		sideValues := make(map[string]map[string]any)
+		var prepared any
+		if prepared, err = options.prepare(nil, map[string]any{key: value}, sideValues); err != nil {
+			return
+		}
+		// The key may be replaced by WithUTF8.
+		for key, value = range prepared.(map[string]any) {
+		}
+		if _, exists := obj[key]; exists {
+			switch options.duplicateKeys {
+			case DuplicateKeepFirst:
+				continue
+			case DuplicateError:
+				return nil, &DuplicateKeyError{Key: key}
+			}
+			dropSideValues(tables, key)
+		}
+		if options.blobs != nil {
+			if value, err = options.blobs.extract(value); err != nil {
+				return
+			}
+		}
+		for name, table := range sideValues {
+			if tables[name] == nil {
+				tables[name] = make(map[string]any)
+			}
+			maps.Copy(tables[name], table)
+		}
+		var buf bytes.Buffer
+		if err = encoder.WriteValue(&buf, value); err != nil {
+			return nil, impl.EncodeErrorAt(err, key)
+		}
+		obj[key] = impl.Raw(buf.Bytes())
+	}
+	return obj, nil
+}
+
+// dropSideValues deletes the side values of the value of key in the root
+// object from tables.
+func dropSideValues(tables map[string]map[string]any, key string) {
+	prefix := pathKey([]string{key})
+	for _, table := range tables {
+		for path := range table {
+			if strings.HasPrefix(path, prefix) {
+				delete(table, path)
+			}
+		}
+	}
+}
+
+// WriteArraySeq is like [Write] but writes the array of the elements
+// yielded by seq, whose length is not known until seq ends, such as the
+// rows of a database cursor. Every element is encoded as soon as it is
+// yielded, so only the encoded elements are kept in memory.
+//
+// With [WithTransform], [WithSchema] or [WithDedup], which need the whole
+// array, the elements are collected before written.
+func WriteArraySeq(w io.Writer, seq iter.Seq[any], opts ...WriteOption) error {
+	if newWriteOptions(opts).needWhole() {
+		return Write(w, slices.Collect(seq), opts...)
+	}
+	return Write(w, &elemSeq{seq}, opts...)
+}
+
+// elemSeq is the value written by [WriteArraySeq].
+type elemSeq struct {
+	seq iter.Seq[any]
+}
+
+// write prepares and writes every element yielded by seq to w with encoder.
+// The side values are extracted into tables.
+func (seq *elemSeq) write(w io.Writer, encoder *impl.Encoder, options *writeOptions, tables map[string]map[string]any) (err error) {
+	var errPrepare error
+	i := 0
+	prepared := func(yield func(any) bool) {
+		for elem := range seq.seq {
+			if elem, errPrepare = options.prepare([]string{strconv.Itoa(i)}, elem, tables); errPrepare != nil {
+				return
+			}
+			if options.blobs != nil {
+				if elem, errPrepare = options.blobs.extract(elem); errPrepare != nil {
+					return
+				}
+			}
+			if !yield(elem) {
+				return
+			}
+			i++
+		}
+	}
+	if err = encoder.AppendFrom(w, prepared); err != nil {
+		return
+	}
+	return errPrepare
+}
+
+// needWhole returns whether the options need the whole value before it is
+// written, so that the values of sequences are collected first.
+func (o *writeOptions) needWhole() bool {
+	return o.transform != nil || o.schema != nil || o.dedup || o.legacy
+}
@@ -0,0 +1,154 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+// pairs returns the sequence of key/value pairs of kv, which alternates
+// keys and values.
+func pairs(kv ...any) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for i := 0; i < len(kv); i += 2 {
+			if !yield(kv[i].(string), kv[i+1]) {
+				return
+			}
+		}
+	}
+}
+
+func TestWriteSeq(t *testing.T) {
+	value := map[string]any{"nested": map[string]any{"a": []any{1, "x"}}, "bytes": []byte("bytes")}
+	for i := range 100 {
+		value["key"+strconv.Itoa(i)] = "value" + strconv.Itoa(i)
+	}
+	// canonical returns the canonical JSON of the database written by write.
+	canonical := func(write func(w io.Writer) error) []byte {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.NewBytes(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var json bytes.Buffer
+		if err := h.WriteCanonicalJSON(&json); err != nil {
+			t.Fatal(err)
+		}
+		return json.Bytes()
+	}
+	want := canonical(func(w io.Writer) error { return hashive.Write(w, value) })
+	if got := canonical(func(w io.Writer) error { return hashive.WriteSeq(w, maps.All(value), len(value)) }); !bytes.Equal(got, want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	var buf bytes.Buffer
+
+	// Duplicate keys and side values.
+	seq := pairs(
+		"a", hashive.ValueWithMeta{Value: 1, Meta: map[string]any{"m": 1}},
+		"b", 2,
+		"a", map[string]any{"c": hashive.ValueWithMeta{Value: 3, Meta: map[string]any{"c": 3}}},
+	)
+	buf.Reset()
+	if err := hashive.WriteSeq(&buf, seq, 0); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.NewBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("a", "c"); err != nil || v != int64(3) {
+		t.Fatal(v, err)
+	}
+	if _, meta, err := h.QueryWithMeta("a"); err != nil || meta != nil {
+		t.Fatal(meta, err)
+	}
+	if _, meta, err := h.QueryWithMeta("a", "c"); err != nil || meta["c"] != int64(3) {
+		t.Fatal(meta, err)
+	}
+
+	buf.Reset()
+	if err := hashive.WriteSeq(&buf, seq, 0, hashive.WithDuplicateKeys(hashive.DuplicateKeepFirst)); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = hashive.NewBytes(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("a"); err != nil || v != int64(1) {
+		t.Fatal(v, err)
+	}
+	if _, meta, err := h.QueryWithMeta("a"); err != nil || meta["m"] != int64(1) {
+		t.Fatal(meta, err)
+	}
+
+	var dupErr *hashive.DuplicateKeyError
+	if err := hashive.WriteSeq(&buf, seq, 0, hashive.WithDuplicateKeys(hashive.DuplicateError)); !errors.As(err, &dupErr) || dupErr.Key != "a" {
+		t.Fatal(err)
+	}
+
+	// Collected.
+	buf.Reset()
+	if err := hashive.WriteSeq(&buf, seq, 0, hashive.WithDuplicateKeys(hashive.DuplicateCollect), hashive.WithDedup()); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = hashive.NewBytes(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("a", "1", "c"); err != nil || v != int64(3) {
+		t.Fatal(v, err)
+	}
+	if v, err := h.Query("b"); err != nil || v != int64(2) {
+		t.Fatal(v, err)
+	}
+
+	// Encoding error.
+	var encodeErr *hashive.EncodeError
+	if err := hashive.WriteSeq(&buf, pairs("f", func() {}), 0); !errors.As(err, &encodeErr) || len(encodeErr.Path) != 1 || encodeErr.Path[0] != "f" {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteArraySeq(t *testing.T) {
+	rows := func(yield func(any) bool) {
+		for i := range 100 {
+			row := map[string]any{"id": i, "name": "row" + strconv.Itoa(i)}
+			if i == 42 {
+				row["meta"] = hashive.ValueWithMeta{Value: i, Meta: map[string]any{"m": 1}}
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	}
+	for _, opts := range [][]hashive.WriteOption{nil, {hashive.WithDedup()}} {
+		var buf bytes.Buffer
+		if err := hashive.WriteArraySeq(&buf, rows, opts...); err != nil {
+			t.Fatal(err)
+		}
+		h, err := hashive.NewBytes(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query("99", "name"); err != nil || v != "row99" {
+			t.Fatal(v, err)
+		}
+		if _, meta, err := h.QueryWithMeta("42", "meta"); err != nil || meta["m"] != int64(1) {
+			t.Fatal(meta, err)
+		}
+	}
+
+	var utf8Err *hashive.InvalidUTF8Error
+	if err := hashive.WriteArraySeq(io.Discard, slices.Values([]any{"a", "\xff"}), hashive.WithUTF8(hashive.UTF8Reject)); !errors.As(err, &utf8Err) || !slices.Equal(utf8Err.Path, []string{"1"}) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package hashive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// sharedFiles is the registry of the files opened by [OpenShared], keyed by
+// absolute filenames.
+var sharedFiles = struct {
+	sync.Mutex
+	files map[string]*sharedFile
+}{files: make(map[string]*sharedFile)}
+
+// sharedFile is a file mapped into memory by [OpenShared].
+type sharedFile struct {
+	data  []byte
+	info  os.FileInfo
+	index *rootIndex // see [WithIndexFile]
+	unmap func() error
+	refs  int // the number of the databases not closed
+}
+
+// OpenShared is like [OpenMmap], but the file is mapped once per process
+// and shared by all the databases opened by OpenShared until all of them
+// are closed, so that many components opening the same database don't hold
+// redundant mappings. Every returned Hashive has its own read position,
+// so it can be used concurrently with the others, and opts only apply to it.
+// If the file is replaced, such as by renaming another one over it, the
+// databases opened afterwards map the new file, and the ones opened before
+// keep reading the old one.
+//
+// The returned close function releases h, and unmaps the file when all the
+// databases sharing it are closed. Calling it more than once does nothing.
+func OpenShared(filename string, opts ...Option) (h *Hashive, close func() error, err error) {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		return
+	}
+	sharedFiles.Lock()
+	defer sharedFiles.Unlock()
+	file := sharedFiles.files[key]
+	if file == nil || !os.SameFile(file.info, info) || file.info.ModTime() != info.ModTime() || file.info.Size() != info.Size() {
+		file = &sharedFile{}
+		if file.data, file.info, file.unmap, err = mapFile(key); err != nil {
+			return
+		}
+		var unmapIndex func() error
+		file.index, unmapIndex = openIndexFile(key, file.info)
+		unmapData := file.unmap
+		file.unmap = func() error {
+			return errors.Join(unmapIndex(), unmapData())
+		}
+	}
+	if file.index != nil {
+		opts = append(opts[:len(opts):len(opts)], func(o *options) { o.index = file.index })
+	}
+	if h, err = NewBytes(file.data, opts...); err != nil {
+		if file.refs == 0 {
+			file.unmap()
+		}
+		return
+	}
+	h.mapped = file.data
+	file.refs++
+	sharedFiles.files[key] = file
+	var once sync.Once
+	close = func() (err error) {
+		once.Do(func() {
+			sharedFiles.Lock()
+			defer sharedFiles.Unlock()
+			if file.refs--; file.refs > 0 {
+				return
+			}
+			if sharedFiles.files[key] == file {
+				delete(sharedFiles.files, key)
+			}
+			err = file.unmap()
+		})
+		return
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package hashive_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestOpenShared(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.hashive")
+	blob := bytes.Repeat([]byte{1, 2, 3}, 100)
+	if err := hashive.WriteFile(filename, map[string]any{"name": "old", "blob": blob}); err != nil {
+		t.Fatal(err)
+	}
+	h1, close1, err := hashive.OpenShared(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close1()
+	h2, close2, err := hashive.OpenShared(filepath.Join(dir, ".", "test.hashive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The mapping is shared.
+	p1, err := h1.QueryBinaryView("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := h2.QueryBinaryView("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &p1[0] != &p2[0] || !bytes.Equal(p1, blob) {
+		t.Fatal("not shared")
+	}
+	if err := close2(); err != nil {
+		t.Fatal(err)
+	}
+	if err := close2(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h1.Query("name"); err != nil || v != "old" {
+		t.Fatal(v, err)
+	}
+
+	// Replaced.
+	newFile := filepath.Join(dir, "new.hashive")
+	if err := hashive.WriteFile(newFile, map[string]any{"name": "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(newFile, filename); err != nil {
+		t.Fatal(err)
+	}
+	h3, close3, err := hashive.OpenShared(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close3()
+	if v, err := h3.Query("name"); err != nil || v != "new" {
+		t.Fatal(v, err)
+	}
+	if v, err := h1.Query("name"); err != nil || v != "old" {
+		t.Fatal(v, err)
+	}
+
+	if _, _, err := hashive.OpenShared(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,221 @@
+package hashive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Side tables are objects stored after the root value in this order,
+// which map the paths of values to data about them, such as metadata.
+// Their sizes are stored in the header with their names as keys.
+var sideTables = [...]string{headerMeta, headerProvenance, headerOrder, headerMulti}
+
+// pathKey encodes path as a key of side tables.
+func pathKey(path []string) string {
+	var b []byte
+	for _, key := range path {
+		b = binary.AppendUvarint(b, uint64(len(key)))
+		b = append(b, key...)
+	}
+	return string(b)
+}
+
+// parsePathKey decodes a key encoded by [pathKey].
+func parsePathKey(s string) (path []string, err error) {
+	b := []byte(s)
+	for len(b) > 0 {
+		n, size := binary.Uvarint(b)
+		if size <= 0 || n > uint64(len(b)-size) {
+			return nil, fmt.Errorf("invalid path key %q", s)
+		}
+		path = append(path, string(b[size:size+int(n)]))
+		b = b[size+int(n):]
+	}
+	return
+}
+
+// writeSideTables encodes the non-empty tables to buf, and records their
+// sizes in header.
+func writeSideTables(buf *bytes.Buffer, tables map[string]map[string]any, encoder *impl.Encoder, header map[string]any) (err error) {
+	for _, name := range sideTables {
+		if len(tables[name]) == 0 {
+			continue
+		}
+		start := buf.Len()
+		if err = encoder.WriteObject(buf, tables[name]); err != nil {
+			return
+		}
+		header[name] = uint64(buf.Len() - start)
+	}
+	return
+}
+
+// readSideTables reads the side tables recorded in header, which starts
+// at pos.
+func readSideTables(reader impl.ByteReadSeeker, pos int64, header map[string]any, maxDepth int) (tables map[string]*impl.Object, err error) {
+	for _, name := range sideTables {
+		v, ok := header[name]
+		if !ok {
+			continue
+		}
+		size, ok := v.(uint64)
+		if !ok || size > uint64(math.MaxInt64-pos) {
+			return nil, fmt.Errorf("invalid size of %v %v", name, v)
+		}
+		if _, err = reader.Seek(pos, io.SeekStart); err != nil {
+			return
+		}
+		var obj *impl.Object
+		if _, obj, err = impl.ReadContainer(reader, maxDepth, 0); err != nil {
+			return
+		} else if obj == nil {
+			return nil, fmt.Errorf("invalid %v", name)
+		}
+		if tables == nil {
+			tables = make(map[string]*impl.Object)
+		}
+		tables[name] = obj
+		pos += int64(size)
+	}
+	return
+}
+
+// sideTableValue queries the value of the side table name mapped by the
+// path, or returns nil if there is none.
+func (h *Hashive) sideTableValue(name string, path []string) (v any, err error) {
+	table := h.tables[name]
+	if table == nil {
+		return
+	}
+	if v, err = table.Index(pathKey(path), true); err == ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+// sideValue is a value with data stored in a side table, such as [ValueWithMeta].
+type sideValue interface {
+	// unwrap returns the value, the name of the side table and the data.
+	unwrap() (v any, table string, data any)
+	// rewrap returns a copy of the side value with v as the value.
+	rewrap(v any) sideValue
+}
+
+// wrapSideValue returns v with data of the side table as a [sideValue].
+func wrapSideValue(table string, v, data any) any {
+	switch table {
+	case headerMeta:
+		meta, _ := data.(map[string]any)
+		return ValueWithMeta{v, meta}
+	case headerProvenance:
+		source, _ := data.(string)
+		return ValueWithProvenance{v, source}
+	case headerOrder:
+		if obj, ok := v.(map[string]any); ok {
+			return newOrderedObject(obj, data)
+		}
+	case headerMulti:
+		if values, ok := v.([]any); ok {
+			return MultiValue(values)
+		}
+	}
+	return v
+}
+
+// isSideValue returns whether v is a [sideValue].
+func isSideValue(v any) bool {
+	_, ok := v.(sideValue)
+	return ok
+}
+
+// extractSideValues replaces every [sideValue] in v at path with its value,
+// and stores its data in tables.
+func extractSideValues(path []string, v any, tables map[string]map[string]any) any {
+	switch value := v.(type) {
+	case sideValue:
+		v, name, data := value.unwrap()
+		if tables[name] == nil {
+			tables[name] = make(map[string]any)
+		}
+		tables[name][pathKey(path)] = data
+		return extractSideValues(path, v, tables)
+	case []any:
+		ary := make([]any, len(value))
+		for i, elem := range value {
+			ary[i] = extractSideValues(append(path, strconv.Itoa(i)), elem, tables)
+		}
+		return ary
+	case map[string]any:
+		obj := make(map[string]any, len(value))
+		for key, elem := range value {
+			obj[key] = extractSideValues(append(path, key), elem, tables)
+		}
+		return obj
+	case []Interval:
+		intervals := slices.Clone(value)
+		for i := range intervals {
+			intervals[i].Value = extractSideValues(append(path, strconv.Itoa(i)), intervals[i].Value, tables)
+		}
+		return intervals
+	}
+	return v
+}
+
+// attachSideValues wraps the values in v mapped by the path, which is read
+// recursively, with their data in side tables as [sideValue], so that they
+// can be written again.
+func (h *Hashive) attachSideValues(path []string, v any) (any, error) {
+	for _, name := range sideTables {
+		table := h.tables[name]
+		if table == nil {
+			continue
+		}
+		entries, err := table.Value()
+		if err != nil {
+			return nil, err
+		}
+		for key, data := range entries {
+			p, err := parsePathKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if len(p) >= len(path) && slices.Equal(p[:len(path)], path) {
+				v = wrapAt(v, p[len(path):], name, data)
+			}
+		}
+	}
+	return v, nil
+}
+
+// wrapAt wraps the value in v mapped by the path with data of the side table.
+func wrapAt(v any, path []string, table string, data any) any {
+	if value, ok := v.(sideValue); ok {
+		inner, _, _ := value.unwrap()
+		return value.rewrap(wrapAt(inner, path, table, data))
+	}
+	if len(path) == 0 {
+		return wrapSideValue(table, v, data)
+	}
+	switch value := v.(type) {
+	case []any:
+		if i, err := strconv.Atoi(path[0]); err == nil && i >= 0 && i < len(value) {
+			value[i] = wrapAt(value[i], path[1:], table, data)
+		}
+	case map[string]any:
+		if elem, ok := value[path[0]]; ok {
+			value[path[0]] = wrapAt(elem, path[1:], table, data)
+		}
+	case []Interval:
+		if i, err := strconv.Atoi(path[0]); err == nil && i >= 0 && i < len(value) {
+			value[i].Value = wrapAt(value[i].Value, path[1:], table, data)
+		}
+	}
+	return v
+}
@@ -0,0 +1,26 @@
+package hashive
+
+import "errors"
+
+// Snapshot returns a read-only handle of the database pinned to the data
+// read by h, such as the open file rather than the file name, so that a
+// request handler making multiple queries sees a consistent dataset even if
+// the file is replaced by renaming another one over it in the meantime.
+// The snapshot has its own read position and buffer, so it can be queried
+// concurrently with h and other snapshots. The data must remain readable,
+// such as the file remaining open, while the snapshot is in use.
+//
+// Only databases created by [NewBytes], [Open] and by [New] and
+// [NewSection] reading from an [io.ReaderAt] of known size can be
+// snapshotted. See [NewMultiReader] for the size.
+func (h *Hashive) Snapshot() (*Hashive, error) {
+	if h.snapshot == nil {
+		return nil, errors.New("can't snapshot database not read from io.ReaderAt")
+	}
+	snapshot, err := h.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	snapshot.cache = h.cache
+	return snapshot, nil
+}
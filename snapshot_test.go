@@ -0,0 +1,71 @@
+package hashive_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.hashive")
+	if err := hashive.WriteFile(filename, map[string]any{"version": 1, "list": []any{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	h, close, err := hashive.Open(filename, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close()
+	snapshot, err := h.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swap the file.
+	tmp := filepath.Join(dir, "tmp.hashive")
+	if err := hashive.WriteFile(tmp, map[string]any{"version": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, h := range []*hashive.Hashive{h, snapshot} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if v, err := h.Query("version"); err != nil || v != int64(1) {
+					t.Error(v, err)
+					return
+				}
+				if v, err := h.Query("list", "1"); err != nil || v != "b" {
+					t.Error(v, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// In memory.
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, "value"); err != nil {
+		t.Fatal(err)
+	}
+	h, err = hashive.NewBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot, err = h.Snapshot(); err != nil {
+		t.Fatal(err)
+	} else if v, err := snapshot.Query(); err != nil || v != "value" {
+		t.Fatal(v, err)
+	}
+}
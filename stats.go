@@ -0,0 +1,352 @@
+package hashive
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Sample returns at most n keys of the object, or indices of the array,
+// mapped by the path, chosen randomly with reservoir sampling.
+// Only keys are read, values are skipped.
+// [ErrNotFound] will be returned if the path does not map to an array or object.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Sample(n int, path ...string) (keys []string, err error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	container, err := h.container(path)
+	if err != nil {
+		return
+	}
+	switch c := container.(type) {
+	case *impl.Object:
+		var seen int
+		err = c.ForEach(func(key string) error {
+			seen++
+			if len(keys) < n {
+				keys = append(keys, key)
+			} else if i := rand.IntN(seen); i < n {
+				keys[i] = key
+			}
+			return nil
+		})
+		return
+	case *impl.Array:
+		// Indices are sampled without reading the array.
+		var indices []int
+		for i := range c.Len() {
+			if len(indices) < n {
+				indices = append(indices, i)
+			} else if j := rand.IntN(i + 1); j < n {
+				indices[j] = i
+			}
+		}
+		keys = make([]string, len(indices))
+		for i, index := range indices {
+			keys[i] = strconv.Itoa(index)
+		}
+		return
+	}
+	return nil, ErrNotFound
+}
+
+// Histogram is the statistics of values returned by [Hashive.Histogram].
+type Histogram struct {
+	Count int          // The number of values, including arrays and objects.
+	Kinds map[Kind]int // The number of values of each kind.
+	// Sizes[i] is the number of values whose encoded size is in [2^i, 2^(i+1)).
+	Sizes []int
+	Size  int64 // The encoded size of the entire value.
+}
+
+// kindOfType returns the kind of values of type t.
+func kindOfType(t impl.Type) Kind {
+	switch t {
+	case impl.TypeNull:
+		return KindNull
+	case impl.TypeInt:
+		return KindInt
+	case impl.TypeUint:
+		return KindUint
+	case impl.TypeFloat:
+		return KindFloat
+	case impl.TypeBool:
+		return KindBool
+	case impl.TypeString:
+		return KindString
+	case impl.TypeBinary, impl.TypeBlob, impl.TypeChunked, impl.TypeCompressed:
+		return KindBinary
+	case impl.TypeGob:
+		return KindGob
+	case impl.TypeArray, impl.TypeFixedArray, impl.TypeChunkedArray:
+		return KindArray
+	case impl.TypeObject, impl.TypePrefixObject, impl.TypeFixedKeyObject, impl.TypeIntKeyObject, impl.TypeSortedObject, impl.TypeHashedObject:
+		return KindObject
+	case impl.TypeIntervals:
+		return KindIntervals
+	default:
+		return KindAny
+	}
+}
+
+// Histogram walks the value mapped by the path and all the values in it,
+// and returns the statistics of their kinds and encoded sizes.
+// Only the type marks and sizes are read, so it is much faster than
+// reading the entire value.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) Histogram(path ...string) (hist *Histogram, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	hist = &Histogram{Kinds: make(map[Kind]int)}
+	err = impl.Walk(h.r, h.options.maxDepth, func(p []string, t impl.Type, offset, size int64) error {
+		if len(p) == 0 {
+			hist.Size = size
+		}
+		hist.Count++
+		hist.Kinds[kindOfType(t)]++
+		i := bits.Len64(uint64(size)) - 1
+		for len(hist.Sizes) <= i {
+			hist.Sizes = append(hist.Sizes, 0)
+		}
+		hist.Sizes[i]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// BucketStats is the statistics of the hash table of an object returned by
+// [Hashive.BucketStats].
+type BucketStats = impl.BucketStats
+
+// BucketStats returns the statistics of the buckets of the object mapped by
+// the path, such as the load factor and the lengths of chains, so that
+// operators can decide when to compact the database, see [Compact].
+// Only the offset table and the heads of entries are read, values are
+// skipped. [ErrNotFound] will be returned if the path does not map to an
+// object.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) BucketStats(path ...string) (stats BucketStats, err error) {
+	container, err := h.container(path)
+	if err != nil {
+		return
+	}
+	obj, ok := container.(*impl.Object)
+	if !ok {
+		return stats, ErrNotFound
+	}
+	return obj.BucketStats()
+}
+
+// LayoutStats is the statistics of the offset tables of arrays and objects
+// returned by [Hashive.LayoutStats], which shows the space overhead of them.
+type LayoutStats struct {
+	Containers int // The number of arrays and objects with offset tables.
+	// OffsetSizes[n] is the number of containers whose offsets are n bytes.
+	OffsetSizes [9]int
+	Offsets     int64 // The total number of offsets.
+	TableSize   int64 // The total size of offsets in bytes.
+	Size        int64 // The encoded size of the entire value.
+}
+
+// LayoutStats walks the value mapped by the path and all the values in it,
+// and returns the statistics of the offset tables of arrays and objects.
+// The size of offsets of every table is the minimal one holding its largest
+// offset, see [Write]. Fixed arrays have no offset tables.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) LayoutStats(path ...string) (stats *LayoutStats, err error) {
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	stats = &LayoutStats{}
+	// Containers are read after walking, which moves the reader.
+	var containers []int64
+	err = impl.Walk(h.r, h.options.maxDepth, func(p []string, t impl.Type, offset, size int64) error {
+		if len(p) == 0 {
+			stats.Size = size
+		}
+		if kind := kindOfType(t); kind == KindArray || kind == KindObject {
+			containers = append(containers, offset)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, offset := range containers {
+		if _, err = h.r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		ary, obj, err := impl.ReadContainer(h.r, h.options.maxDepth, h.options.maxKeyLength)
+		if err != nil {
+			return nil, err
+		}
+		var offsetSize byte
+		var count int64
+		if obj != nil {
+			offsetSize, count = obj.OffsetTable()
+		} else {
+			offsetSize, count = ary.OffsetTable()
+		}
+		if offsetSize == 0 {
+			continue
+		} else if offsetSize > 8 {
+			return nil, fmt.Errorf("invalid offset size %v at %v", offsetSize, offset)
+		}
+		stats.Containers++
+		stats.OffsetSizes[offsetSize]++
+		stats.Offsets += count
+		stats.TableSize += count * int64(offsetSize)
+	}
+	return
+}
+
+// TopKBy is what [Hashive.TopK] ranks values by.
+type TopKBy int
+
+const (
+	TopKBySize        TopKBy = iota // The encoded sizes of values.
+	TopKByChainLength               // The longest chains of objects.
+)
+
+// TopValue is a value returned by [Hashive.TopK].
+type TopValue struct {
+	Path []string // The path of the value.
+	Kind Kind     // The kind of the value.
+	Size int64    // The encoded size of the value in bytes.
+	// The number of entries of the longest chain of an object, see
+	// [BucketStats]. It is only read by [TopKByChainLength].
+	MaxChain int
+}
+
+// TopK walks the value mapped by the path and all the values in it, and
+// returns the k values ranked highest by by, in descending order, so that
+// the data responsible for a bloated database can be found.
+// With [TopKBySize], the values include arrays and objects, whose sizes
+// include the values in them. With [TopKByChainLength], only objects are
+// ranked, whose offset tables and heads of chains are read, see
+// [Hashive.BucketStats]. The value mapped by the path itself is not ranked.
+// The values of the same rank are returned in depth-first order.
+//
+// For the meaning of argument path, see [Hashive.Query].
+func (h *Hashive) TopK(k int, by TopKBy, path ...string) (top []TopValue, err error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	if by != TopKBySize && by != TopKByChainLength {
+		return nil, fmt.Errorf("invalid TopKBy %v", by)
+	}
+	if err = h.seekValue(path); err != nil {
+		return
+	}
+	var values topValues
+	// Objects are read after walking, which moves the reader.
+	type object struct {
+		rankedValue
+		offset int64
+	}
+	var objects []object
+	err = impl.Walk(h.r, h.options.maxDepth, func(p []string, t impl.Type, offset, size int64) error {
+		if len(p) == 0 {
+			return nil
+		}
+		v := rankedValue{TopValue: TopValue{Path: p, Kind: kindOfType(t), Size: size}}
+		switch {
+		case by == TopKBySize:
+			v.rank, v.order = size, values.count
+			values.add(k, v)
+		case v.Kind == KindObject:
+			v.Path, v.order = slices.Clone(p), len(objects)
+			objects = append(objects, object{v, offset})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		if _, err = h.r.Seek(object.offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		_, obj, err := impl.ReadContainer(h.r, h.options.maxDepth, h.options.maxKeyLength)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := obj.BucketStats()
+		if err != nil {
+			return nil, err
+		}
+		v := object.rankedValue
+		v.MaxChain, v.rank = stats.MaxChain, int64(stats.MaxChain)
+		values.add(k, v)
+	}
+	slices.SortFunc(values.values, func(a, b rankedValue) int {
+		return cmp.Or(cmp.Compare(b.rank, a.rank), cmp.Compare(a.order, b.order))
+	})
+	top = make([]TopValue, len(values.values))
+	for i, v := range values.values {
+		top[i] = v.TopValue
+	}
+	return
+}
+
+// rankedValue is a value ranked by [Hashive.TopK].
+type rankedValue struct {
+	TopValue
+	rank  int64
+	order int // the order of the value in depth-first order
+}
+
+// topValues is the k values ranked highest so far by [Hashive.TopK], which
+// is a min-heap, so that the lowest one is replaced first.
+type topValues struct {
+	values []rankedValue
+	count  int // the number of values added
+}
+
+func (t *topValues) Len() int { return len(t.values) }
+
+func (t *topValues) Less(i, j int) bool {
+	if t.values[i].rank != t.values[j].rank {
+		return t.values[i].rank < t.values[j].rank
+	}
+	return t.values[i].order > t.values[j].order
+}
+
+func (t *topValues) Swap(i, j int) { t.values[i], t.values[j] = t.values[j], t.values[i] }
+
+func (t *topValues) Push(x any) { t.values = append(t.values, x.(rankedValue)) }
+
+func (t *topValues) Pop() any {
+	v := t.values[len(t.values)-1]
+	t.values = t.values[:len(t.values)-1]
+	return v
+}
+
+// add adds v to t if it is ranked in the top k. The path of v is cloned.
+func (t *topValues) add(k int, v rankedValue) {
+	t.count++
+	if len(t.values) == k {
+		if v.rank <= t.values[0].rank {
+			return // Ranked lower, or the same but later.
+		}
+		heap.Pop(t)
+	}
+	v.Path = slices.Clone(v.Path)
+	heap.Push(t, v)
+}
@@ -0,0 +1,244 @@
+package hashive_test
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestSample(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 100 {
+		obj[strconv.Itoa(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"obj": obj, "ary": make([]any, 50), "str": ""}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := h.Sample(10, "obj")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 10 {
+		t.Fatal(keys)
+	}
+	slices.Sort(keys)
+	if len(slices.Compact(keys)) != 10 {
+		t.Fatal(keys)
+	}
+	for _, key := range keys {
+		if _, ok := obj[key]; !ok {
+			t.Fatal(key)
+		}
+	}
+
+	if keys, err = h.Sample(100, "ary"); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 50 {
+		t.Fatal(keys)
+	}
+	if keys, err = h.Sample(5); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 3 {
+		t.Fatal(keys)
+	}
+	if _, err = h.Sample(5, "str"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	var buf bytes.Buffer
+	err := hashive.Write(&buf, map[string]any{
+		"a": "abc",
+		"b": []any{1, 2, nil},
+		"c": map[string]any{"d": true, "e": 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := h.Histogram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hist.Count != 9 {
+		t.Fatal(hist.Count)
+	}
+	want := map[hashive.Kind]int{
+		hashive.KindObject: 2,
+		hashive.KindArray:  1,
+		hashive.KindString: 1,
+		hashive.KindInt:    2,
+		hashive.KindNull:   1,
+		hashive.KindBool:   1,
+		hashive.KindFloat:  1,
+	}
+	for k, n := range want {
+		if hist.Kinds[k] != n {
+			t.Fatal(k, hist.Kinds)
+		}
+	}
+	var count int
+	for _, n := range hist.Sizes {
+		count += n
+	}
+	if count != hist.Count {
+		t.Fatal(hist.Sizes)
+	}
+
+	if hist, err = h.Histogram("b"); err != nil {
+		t.Fatal(err)
+	} else if hist.Count != 4 {
+		t.Fatal(hist.Count)
+	}
+	// Queries work after walking.
+	if v, err := h.Query("c", "e"); err != nil || v != 1.5 {
+		t.Fatal(v, err)
+	}
+}
+
+func TestBucketStats(t *testing.T) {
+	obj := make(map[string]any)
+	for i := range 100 {
+		obj[strconv.Itoa(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"obj": obj, "ary": make([]any, 50)}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := h.BucketStats("obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 100 || stats.Buckets < 100 || stats.MaxChain < 1 || stats.LoadFactor > 1 {
+		t.Fatal(stats)
+	}
+	hist, err := h.Histogram("obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := stats.TableSize + stats.ChainSize; size >= hist.Size || size < hist.Size-4 {
+		t.Fatal(stats, hist.Size)
+	}
+	if stats, err = h.BucketStats(); err != nil || stats.Entries != 2 {
+		t.Fatal(stats, err)
+	}
+	if _, err = h.BucketStats("ary"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestLayoutStats(t *testing.T) {
+	big := make([]any, 11)
+	for i := range big {
+		big[i] = make([]byte, 7000+i)
+	}
+	obj := make(map[string]any)
+	for i := range 10 {
+		obj[strconv.Itoa(i)] = "v" + strconv.Itoa(i)
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"big": big, "obj": obj, "fixed": []any{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := h.LayoutStats("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Containers != 1 || stats.OffsetSizes[3] != 1 || stats.Offsets != 11 || stats.TableSize != 33 {
+		t.Fatal(stats)
+	}
+	if stats, err = h.LayoutStats(); err != nil {
+		t.Fatal(err)
+	}
+	// The fixed array has no offset table.
+	if stats.Containers != 3 || stats.OffsetSizes[3] < 1 || stats.Offsets < 11+10 {
+		t.Fatal(stats)
+	}
+	hist, err := h.Histogram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Size != hist.Size {
+		t.Fatal(stats.Size, hist.Size)
+	}
+	if _, err = h.LayoutStats("missing"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestTopK(t *testing.T) {
+	long := make(map[string]any)
+	for i := range 100 {
+		long[strconv.Itoa(i)] = i
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{
+		"a": map[string]any{
+			"blob":  make([]byte, 5000),
+			"small": "x",
+			"long":  long,
+		},
+		"b":    make([]byte, 3000),
+		"c":    []any{make([]byte, 4000), "y"},
+		"tiny": map[string]any{"k": "v"},
+	}, hashive.WithMaxBuckets(2)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := h.TopK(4, hashive.TopKBySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, v := range top {
+		paths = append(paths, strings.Join(v.Path, "/"))
+	}
+	if !slices.Equal(paths, []string{"a", "a/blob", "c", "c/0"}) {
+		t.Fatal(paths)
+	}
+	if top[1].Kind != hashive.KindBinary || top[1].Size < 5000 || top[0].Size <= top[1].Size {
+		t.Fatal(top)
+	}
+
+	top, err = h.TopK(2, hashive.TopKByChainLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || !slices.Equal(top[0].Path, []string{"a", "long"}) || top[0].MaxChain < 50 || top[0].Kind != hashive.KindObject {
+		t.Fatal(top)
+	}
+	if !slices.Equal(top[1].Path, []string{"a"}) || top[1].MaxChain != 2 {
+		t.Fatal(top)
+	}
+
+	if top, err = h.TopK(10, hashive.TopKByChainLength, "a", "long"); err != nil || len(top) != 0 {
+		t.Fatal(top, err)
+	}
+	if _, err = h.TopK(1, hashive.TopKBySize, "missing"); err != hashive.ErrNotFound {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package hashive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// A stream is a sequence of independent Hashive databases(documents).
+// Every document is written as a frame, see [WriteFrame].
+
+// StreamEncoder writes a stream of Hashive documents.
+type StreamEncoder struct {
+	w    io.Writer
+	opts []WriteOption
+	buf  bytes.Buffer
+}
+
+// NewStreamEncoder returns a StreamEncoder writing to w.
+// Every document is written with opts.
+func NewStreamEncoder(w io.Writer, opts ...WriteOption) *StreamEncoder {
+	return &StreamEncoder{w: w, opts: opts}
+}
+
+// Encode writes value to the stream as a document.
+func (e *StreamEncoder) Encode(value any) (err error) {
+	e.buf.Reset()
+	if err = Write(&e.buf, value, e.opts...); err != nil {
+		return
+	}
+	return writeFrame(e.w, &e.buf)
+}
+
+// ReadFrom reads an encoded fragment from r until EOF and writes it to the
+// stream as a document, so that the fragments can be encoded concurrently,
+// such as by the workers of a pipeline, and assembled by a single goroutine.
+// A fragment starting with the file signature is a document written by
+// [Write], which is written as is. Otherwise it is a value encoded by
+// [EncodeValue] with the options of e, which is written as the root value
+// of a document. The header of documents and the encoding of values are
+// checked before written. n is the number of bytes read from r.
+func (e *StreamEncoder) ReadFrom(r io.Reader) (n int64, err error) {
+	e.buf.Reset()
+	if n, err = e.buf.ReadFrom(r); err != nil {
+		return
+	}
+	data := e.buf.Bytes()
+	if bytes.HasPrefix(data, []byte(impl.FileSignatureHeader)) || bytes.HasPrefix(data, []byte(impl.FileSignatureVersion2)) ||
+		bytes.HasPrefix(data, []byte(impl.FileSignature)) {
+		if _, err = NewBytes(data); err != nil {
+			return n, fmt.Errorf("invalid document: %w", err)
+		}
+		return n, writeFrame(e.w, &e.buf)
+	}
+	reader := bytes.NewReader(data)
+	if err = impl.SkipValue(reader); err == nil {
+		// Values can be skipped beyond the end of data.
+		if end, _ := reader.Seek(0, io.SeekCurrent); end != int64(len(data)) {
+			err = fmt.Errorf("value of %v bytes in fragment of %v bytes", end, len(data))
+		}
+	}
+	if err != nil {
+		return n, fmt.Errorf("invalid value: %w", err)
+	}
+	value := impl.Raw(bytes.Clone(data))
+	opts := append(e.opts[:len(e.opts):len(e.opts)], func(o *writeOptions) {
+		o.transform, o.schema, o.dedup = nil, nil, false
+	})
+	e.buf.Reset()
+	if err = Write(&e.buf, value, opts...); err != nil {
+		return
+	}
+	return n, writeFrame(e.w, &e.buf)
+}
+
+// StreamDecoder reads a stream of Hashive documents.
+type StreamDecoder struct {
+	r    *bufio.Reader
+	opts []Option
+}
+
+// NewStreamDecoder returns a StreamDecoder reading from r.
+// Every document is opened with opts.
+func NewStreamDecoder(r io.Reader, opts ...Option) *StreamDecoder {
+	return &StreamDecoder{r: bufio.NewReader(r), opts: opts}
+}
+
+// Decode reads the next document of the stream into memory.
+// [io.EOF] is returned if there are no more documents.
+func (d *StreamDecoder) Decode() (h *Hashive, err error) {
+	return ReadFrame(d.r, math.MaxInt64, d.opts...)
+}
@@ -0,0 +1,109 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestStream(t *testing.T) {
+	values := []any{
+		map[string]any{"a": "b"},
+		"str",
+		[]any{int64(1), int64(2)},
+		nil,
+	}
+	var buf bytes.Buffer
+	enc := hashive.NewStreamEncoder(&buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := hashive.NewStreamDecoder(bytes.NewReader(buf.Bytes()))
+	for _, want := range values {
+		h, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query(); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, want) {
+			t.Fatal(v)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatal(err)
+	}
+
+	dec = hashive.NewStreamDecoder(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	var err error
+	for err == nil {
+		_, err = dec.Decode()
+	}
+	if !errors.Is(err, hashive.ErrTruncated) {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamEncoderReadFrom(t *testing.T) {
+	values := []any{
+		map[string]any{"a": "b"},
+		[]any{int64(1), "x"},
+		"str",
+	}
+	opts := []hashive.WriteOption{hashive.WithHashOrder()}
+	fragments := make([][]byte, len(values)+1)
+	var wg sync.WaitGroup
+	for i, v := range values {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if fragments[i], err = hashive.EncodeValue(v, opts...); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var doc bytes.Buffer
+	if err := hashive.Write(&doc, map[string]any{"doc": true}); err != nil {
+		t.Fatal(err)
+	}
+	fragments[len(values)] = doc.Bytes()
+	values = append(values, map[string]any{"doc": true})
+
+	var buf bytes.Buffer
+	enc := hashive.NewStreamEncoder(&buf, opts...)
+	for _, fragment := range fragments {
+		if n, err := enc.ReadFrom(bytes.NewReader(fragment)); err != nil || n != int64(len(fragment)) {
+			t.Fatal(n, err)
+		}
+	}
+	dec := hashive.NewStreamDecoder(bytes.NewReader(buf.Bytes()))
+	for _, want := range values {
+		h, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+			t.Fatal(v, err)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatal(err)
+	}
+
+	// Invalid fragments.
+	for _, fragment := range [][]byte{fragments[0][:len(fragments[0])-1], append(fragments[2], 0), doc.Bytes()[:10]} {
+		if _, err := enc.ReadFrom(bytes.NewReader(fragment)); err == nil {
+			t.Fatal(fragment)
+		}
+	}
+}
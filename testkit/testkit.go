@@ -0,0 +1,304 @@
+/*
+Package testkit generates valid and deliberately corrupted Hashive databases,
+which can be used to test how programs handle realistic failure modes, or as
+the seed corpus of fuzz tests.
+*/
+package testkit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// SetKeyHash replaces the hash function of the keys of objects with hash, in
+// both the writers and readers of this process, until restore is called.
+// A constant hash, for example, makes all the keys of an object collide, so
+// that programs can be tested under the worst-case chain lengths; see also
+// [hashive.WithMaxBuckets]. The databases written with hash can only be read
+// with the same hash. [hashive.HashKey] is not affected, so the hashes
+// passed to [hashive.Hashive.QueryHashed] must be computed by hash. The
+// databases written or read while hash is being replaced are inconsistent,
+// so it is meant to be set at the start of a test.
+func SetKeyHash(hash func(key string) uint64) (restore func()) {
+	return impl.SetKeyHash(hash)
+}
+
+// Corruption is the kind of damage done to a database.
+type Corruption int
+
+const (
+	None            Corruption = iota // A valid database.
+	Truncated                         // The database ends in the middle of a value.
+	BadType                           // A value has an unknown type.
+	BadOffset                         // An offset or position points to a wrong place.
+	OversizedLength                   // A length or count is much larger than the database.
+)
+
+var corruptionNames = [...]string{
+	None:            "none",
+	Truncated:       "truncated",
+	BadType:         "badType",
+	BadOffset:       "badOffset",
+	OversizedLength: "oversizedLength",
+}
+
+func (c Corruption) String() string {
+	if c >= 0 && int(c) < len(corruptionNames) {
+		return corruptionNames[c]
+	}
+	return "Corruption(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Case is a generated database.
+type Case struct {
+	Name       string // The name of the case, which describes how it is made.
+	Corruption Corruption
+	Data       []byte
+}
+
+// RandomValue returns a random value which can be written by [hashive.Write],
+// whose arrays and objects are nested at most maxDepth levels.
+func RandomValue(r *rand.Rand, maxDepth int) any {
+	n := 8
+	if maxDepth > 0 {
+		n = 10
+	}
+	switch r.IntN(n) {
+	case 0:
+		return nil
+	case 1:
+		return r.Int64() >> r.IntN(64)
+	case 2:
+		return r.Uint64() >> r.IntN(64)
+	case 3:
+		return r.IntN(2) == 0
+	case 4:
+		return r.NormFloat64()
+	case 5:
+		return randomString(r)
+	case 6:
+		p := make([]byte, r.IntN(16))
+		for i := range p {
+			p[i] = byte(r.Uint32())
+		}
+		return p
+	case 7:
+		return strconv.Itoa(r.IntN(1000))
+	case 8:
+		ary := make([]any, r.IntN(8))
+		for i := range ary {
+			ary[i] = RandomValue(r, maxDepth-1)
+		}
+		return ary
+	default:
+		obj := make(map[string]any)
+		for range r.IntN(16) {
+			obj[randomString(r)] = RandomValue(r, maxDepth-1)
+		}
+		return obj
+	}
+}
+
+// randomString returns a random short string.
+func randomString(r *rand.Rand) string {
+	var b strings.Builder
+	for range r.IntN(12) {
+		b.WriteByte(byte('a' + r.IntN(26)))
+	}
+	return b.String()
+}
+
+// writeOptions are the combinations of write options used by [Valid].
+var writeOptions = []struct {
+	name string
+	opts []hashive.WriteOption
+}{
+	{"default", nil},
+	{"frontCoding", []hashive.WriteOption{hashive.WithFrontCoding()}},
+	{"fixedKeys", []hashive.WriteOption{hashive.WithFixedKeys(), hashive.WithIntKeys()}},
+	{"sortedKeys", []hashive.WriteOption{hashive.WithSortedKeys()}},
+	{"hashOrder", []hashive.WriteOption{hashive.WithHashOrder()}},
+	{"dedup", []hashive.WriteOption{hashive.WithDedup()}},
+}
+
+// Valid returns n valid databases of random values generated from r,
+// written with various options.
+func Valid(r *rand.Rand, n int) (cases []Case, err error) {
+	for i := range n {
+		value := map[string]any{}
+		for range 1 + r.IntN(16) {
+			value[randomString(r)] = RandomValue(r, 3)
+		}
+		if r.IntN(4) == 0 {
+			// Repeated subtrees for dedup.
+			for key, v := range value {
+				value[key+"_copy"] = v
+			}
+		}
+		options := writeOptions[i%len(writeOptions)]
+		var buf bytes.Buffer
+		if err = hashive.Write(&buf, value, options.opts...); err != nil {
+			return
+		}
+		cases = append(cases, Case{fmt.Sprintf("valid/%v/%v", options.name, i), None, buf.Bytes()})
+	}
+	return
+}
+
+// Corrupt returns databases made by damaging every value of the valid
+// database data in every way that applies to its type, including the
+// header and side tables.
+func Corrupt(data []byte) (cases []Case, err error) {
+	version, err := hashive.ReadVersion(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	start := int64(len("hashive\x00"))
+	r := bytes.NewReader(data)
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return
+	}
+	for i := 0; r.Len() > 0; i++ {
+		var prefix string
+		if version == hashive.Version0 {
+			prefix = "root"
+		} else if i == 0 {
+			prefix = "header"
+		} else {
+			prefix = "value" + strconv.Itoa(i)
+		}
+		err = impl.Walk(r, impl.DefaultMaxDepth, func(path []string, t impl.Type, offset, size int64) error {
+			name := prefix
+			if len(path) > 0 {
+				name += "/" + strings.Join(path, "/")
+			}
+			cases = append(cases, corruptValue(data, name, t, offset, size)...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// corruptValue returns the databases made by damaging the value of type t
+// at offset of size in data.
+func corruptValue(data []byte, name string, t impl.Type, offset, size int64) (cases []Case) {
+	add := func(c Corruption, what string, p []byte) {
+		cases = append(cases, Case{name + ":" + what, c, p})
+	}
+	add(Truncated, "truncated", bytes.Clone(data[:offset+size-1]))
+	add(BadType, "type", replace(data, offset, 1, []byte{badTypeMarker}))
+
+	marker := data[offset]
+	s := int64(marker >> 4) // the size in the type marker
+	pos := offset + 1       // after the type marker
+	if marker&0x0F == 0x0F {
+		pos++ // extended type
+	}
+	end := offset + size
+	switch t {
+	case impl.TypeString, impl.TypeBinary, impl.TypeGob:
+		add(OversizedLength, "length", replaceUint(data, pos, math.MaxUint64))
+	case impl.TypeArray:
+		add(OversizedLength, "length", fill(data, pos, s))
+		if pos+2*s <= end {
+			add(BadOffset, "offset", fill(data, pos+s, s))
+		}
+	case impl.TypeFixedArray:
+		add(OversizedLength, "length", fill(data, pos, s))
+		add(BadOffset, "stride", fill(data, pos+s, s))
+	case impl.TypeRef:
+		add(BadOffset, "position", fill(data, pos, s))
+	case impl.TypeObject, impl.TypePrefixObject, impl.TypeSortedObject, impl.TypeHashedObject:
+		count, n := readUint(data[pos:end])
+		add(OversizedLength, "bucketCount", replaceUint(data, pos, math.MaxUint64))
+		for table := pos + n; count > 0 && table+s <= end; table += s {
+			if !allZero(data[table : table+s]) {
+				add(BadOffset, "offset", fill(data, table, s))
+				break
+			}
+		}
+	case impl.TypeFixedKeyObject, impl.TypeIntKeyObject:
+		count, n := readUint(data[pos:end])
+		add(OversizedLength, "count", replaceUint(data, pos, math.MaxUint64))
+		keySize, m := readUint(data[pos+n : end])
+		add(OversizedLength, "keySize", replaceUint(data, pos+n, math.MaxUint64))
+		if table := pos + n + m + int64(count*keySize); table+s <= end {
+			add(BadOffset, "offset", fill(data, table, s))
+		}
+	case impl.TypeIntervals:
+		count, n := readUint(data[pos:end])
+		add(OversizedLength, "length", replaceUint(data, pos, math.MaxUint64))
+		if table := pos + n; count > 0 && table+3*8+s <= end {
+			add(BadOffset, "offset", fill(data, table+3*8, s))
+		}
+	}
+	return
+}
+
+// badTypeMarker is a type marker of an unknown type.
+const badTypeMarker = 0x0E
+
+// replace returns a copy of data whose n bytes at pos are replaced with p.
+func replace(data []byte, pos, n int64, p []byte) []byte {
+	result := make([]byte, 0, int64(len(data))-n+int64(len(p)))
+	result = append(result, data[:pos]...)
+	result = append(result, p...)
+	return append(result, data[pos+n:]...)
+}
+
+// fill returns a copy of data whose n bytes at pos are all 0xFF.
+func fill(data []byte, pos, n int64) []byte {
+	return replace(data, pos, n, bytes.Repeat([]byte{0xFF}, int(n)))
+}
+
+// readUint decodes a variable-length unsigned integer at the start of p,
+// and returns it with its size. See FORMAT.md for the encoding.
+func readUint(p []byte) (v uint64, n int64) {
+	if len(p) == 0 {
+		return 0, 0
+	}
+	if p[0] <= math.MaxInt8 {
+		return uint64(p[0]), 1
+	}
+	size := int64(-p[0])
+	if size > 8 || int64(len(p)) < 1+size {
+		return 0, 1
+	}
+	for i := range size {
+		v |= uint64(p[1+i]) << (8 * i)
+	}
+	return v, 1 + size
+}
+
+// replaceUint returns a copy of data whose variable-length unsigned integer
+// at pos is replaced with v.
+func replaceUint(data []byte, pos int64, v uint64) []byte {
+	_, n := readUint(data[pos:])
+	p := []byte{256 - 8} // 8 bytes
+	for range 8 {
+		p = append(p, byte(v))
+		v >>= 8
+	}
+	return replace(data, pos, n, p)
+}
+
+// allZero returns whether all the bytes of p are zero.
+func allZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
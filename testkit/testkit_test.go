@@ -0,0 +1,101 @@
+package testkit_test
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/mkch/hashive"
+	"github.com/mkch/hashive/testkit"
+)
+
+func TestCorrupt(t *testing.T) {
+	valid, err := testkit.Valid(rand.New(rand.NewPCG(1, 2)), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(valid) != 12 {
+		t.Fatal(len(valid))
+	}
+	corruptions := make(map[testkit.Corruption]int)
+	for _, c := range valid {
+		if c.Corruption != testkit.None {
+			t.Fatal(c.Name, c.Corruption)
+		}
+		h, err := hashive.NewBytes(c.Data)
+		if err != nil {
+			t.Fatal(c.Name, err)
+		}
+		if _, err := h.Query(); err != nil {
+			t.Fatal(c.Name, err)
+		}
+
+		cases, err := testkit.Corrupt(c.Data)
+		if err != nil {
+			t.Fatal(c.Name, err)
+		}
+		for _, corrupted := range cases {
+			corruptions[corrupted.Corruption]++
+			// Must fail without panicking.
+			h, err := hashive.NewBytes(corrupted.Data)
+			if err == nil {
+				_, err = h.Query()
+			}
+			if err == nil && corrupted.Corruption == testkit.Truncated {
+				t.Fatal(corrupted.Name, "expected error")
+			}
+		}
+	}
+	for _, c := range []testkit.Corruption{testkit.Truncated, testkit.BadType, testkit.BadOffset, testkit.OversizedLength} {
+		if corruptions[c] == 0 {
+			t.Fatal("no corruption of", c)
+		}
+	}
+}
+
+func TestSetKeyHash(t *testing.T) {
+	hash := func(string) uint64 { return 1 }
+	restore := testkit.SetKeyHash(hash)
+	defer restore()
+	if hashive.HashKey(0, "a") == hash("a") {
+		t.Fatal("HashKey replaced")
+	}
+	value := make(map[string]any)
+	for i := range 100 {
+		value[strconv.Itoa(i)] = int64(i)
+	}
+	for i, opts := range [][]hashive.WriteOption{
+		nil,
+		{hashive.WithSortedKeys()},
+		{hashive.WithHashOrder()},
+		{hashive.WithFrontCoding()},
+		{hashive.WithPow2Buckets()},
+	} {
+		var buf bytes.Buffer
+		if err := hashive.Write(&buf, value, opts...); err != nil {
+			t.Fatal(err)
+		}
+		for _, readOpts := range [][]hashive.Option{nil, {hashive.WithRootDirectory()}} {
+			h, err := hashive.NewBytes(buf.Bytes(), readOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range value {
+				if v, err := h.Query(key); err != nil || v != want {
+					t.Fatal(i, key, v, err)
+				}
+				if v, err := h.QueryHashed(hash(key), key); err != nil || v != want {
+					t.Fatal(i, key, v, err)
+				}
+			}
+			if _, err := h.Query("100"); err != hashive.ErrNotFound {
+				t.Fatal(i, err)
+			}
+			// All the keys are in the same chain.
+			if stats, err := h.BucketStats(); err != nil || stats.MaxChain != len(value) {
+				t.Fatal(i, stats, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package hashive
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TestVector is a database with its expected content, used to validate
+// readers of the format implemented in other languages. See FORMAT.md for
+// the specification of the format.
+type TestVector struct {
+	Name    string // The name of the vector, which is unique and stable.
+	Version int    // The format version of Data.
+	Data    []byte // The database.
+	// JSON is the root value of Data written by [Hashive.WriteCanonicalJSON].
+	JSON string
+}
+
+// testVectorValue is a value written as a [TestVector].
+type testVectorValue struct {
+	name  string
+	value any
+	opts  []WriteOption
+}
+
+// testVectorValues are the values of the test vectors. Every type and layout
+// of the format must be covered, so new layouts come with new vectors.
+// Names must not change once released, and new vectors are appended.
+var testVectorValues = []testVectorValue{
+	{"null", nil, nil},
+	{"bool", true, nil},
+	{"int", []any{0, 1, -1, 63, -64, 64, 1000, -1000, int64(math.MaxInt64), math.MinInt32, int64(-1 << 32), int64(math.MinInt64)}, nil},
+	{"uint", []any{uint64(0), uint64(127), uint64(128), uint64(math.MaxUint64)}, nil},
+	{"float", []any{0.0, 0.5, -1.25, 1e300, math.SmallestNonzeroFloat64}, nil},
+	{"string", "hello, 世界", nil},
+	{"binary", []byte{0, 1, 2, 0xFF}, nil},
+	{"array", []any{nil, false, "two", []any{}, map[string]any{}}, nil},
+	{"fixedArray", []any{"a", "b", "c"}, nil},
+	{"emptyArray", []any{}, nil},
+	{"emptyObject", map[string]any{}, nil},
+	{"object", map[string]any{"a": 1, "b": "x", "c": map[string]any{"d": nil}}, nil},
+	{"prefixObject", map[string]any{"00:1A:2B": "x", "00:1A:2C": "y", "00:1B": "z", "": 0}, []WriteOption{WithFrontCoding()}},
+	{"sortedObject", map[string]any{"k1": 1, "k2": 2, "k3": 3, "k4": 4, "k5": 5}, []WriteOption{WithSortedKeys()}},
+	{"hashedObject", map[string]any{"k1": 1, "k2": 2, "k3": 3, "k4": 4, "k5": 5}, []WriteOption{WithHashOrder()}},
+	{"fixedKeyObject", map[string]any{"US": 1, "CN": 2, "DE": []any{3}}, []WriteOption{WithFixedKeys()}},
+	{"intKeyObject", map[string]any{"-10": "a", "0": "b", "7": "c", "100000": "d"}, []WriteOption{WithIntKeys()}},
+	{"intervals", []Interval{{Start: 10, End: 20, Value: "a"}, {Start: 0, End: 5, Value: "b"}, {Start: 15, End: 30, Value: []any{1}}}, nil},
+	{"refs", map[string]any{
+		"a": map[string]any{"x": []any{1, 2}},
+		"b": map[string]any{"x": []any{1, 2}},
+	}, []WriteOption{WithDedup(), WithSortedKeys()}},
+	{"header", map[string]any{"name": "n", "tags": []any{"t"}}, []WriteOption{
+		WithSchema(&Schema{Kind: KindObject, Required: []string{"name"}}),
+		WithSortedKeys(),
+	}},
+	{"sideTables", map[string]any{
+		"a": ValueWithMeta{Value: 1, Meta: map[string]any{"m": true}},
+		"b": ValueWithProvenance{Value: 2, Source: "src:1"},
+	}, []WriteOption{WithSortedKeys()}},
+	// Version 2
+	{"blob", map[string]any{"firmware": Blob{Offset: 4096, Size: 1 << 20}}, nil},
+	{"chunkedBinary", []byte("0123456789abcdefghij"), []WriteOption{WithChunkedBinary(8, 8)}},
+	{"chunkedArray", []any{1, "two", 3.5, nil, []any{5}}, []WriteOption{WithChunkedArrays(2)}},
+	{"pow2Buckets", map[string]any{"k1": 1, "k2": 2, "k3": 3, "k4": 4, "k5": 5}, []WriteOption{WithPow2Buckets()}},
+	{"compressed", []byte(strings.Repeat("hashive ", 64)), []WriteOption{WithCompression(func([]byte) bool { return true })}},
+}
+
+// GenerateTestVectors returns the test vectors of the format versions up to
+// [CurrentVersion], which are the same every time. They are stored in testdata/vectors of this
+// module, and can be used to validate readers implemented in other languages:
+// reading Data of every vector should result in JSON.
+func GenerateTestVectors() (vectors []TestVector, err error) {
+	for _, v := range testVectorValues {
+		var buf bytes.Buffer
+		if err = Write(&buf, v.value, v.opts...); err != nil {
+			return nil, fmt.Errorf("test vector %v: %w", v.name, err)
+		}
+		var h *Hashive
+		if h, err = NewBytes(buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("test vector %v: %w", v.name, err)
+		}
+		var j bytes.Buffer
+		if err = h.WriteCanonicalJSON(&j); err != nil {
+			return nil, fmt.Errorf("test vector %v: %w", v.name, err)
+		}
+		var version int
+		if version, err = ReadVersion(bytes.NewReader(buf.Bytes())); err != nil {
+			return nil, fmt.Errorf("test vector %v: %w", v.name, err)
+		}
+		vectors = append(vectors, TestVector{v.name, version, buf.Bytes(), j.String()})
+	}
+	return
+}
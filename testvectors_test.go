@@ -0,0 +1,75 @@
+package hashive_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+var updateVectors = flag.Bool("update", false, "update the test vectors in testdata/vectors")
+
+func TestGenerateTestVectors(t *testing.T) {
+	vectors, err := hashive.GenerateTestVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Vectors must be the same every time.
+	for range 20 {
+		again, err := hashive.GenerateTestVectors()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range again {
+			if !bytes.Equal(v.Data, vectors[i].Data) || v.JSON != vectors[i].JSON {
+				t.Fatalf("test vector %v is not stable", v.Name)
+			}
+		}
+	}
+
+	const dir = "testdata/vectors"
+	if *updateVectors {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := make(map[string]bool)
+	version := hashive.Version1
+	for _, v := range vectors {
+		if v.Name == "blob" {
+			version = hashive.Version2 // The first vector of version 2.
+		}
+		if names[v.Name] {
+			t.Fatalf("duplicate test vector %v", v.Name)
+		}
+		names[v.Name] = true
+		if v.Version != version {
+			t.Fatal(v.Name, v.Version)
+		}
+		if version, err := hashive.ReadVersion(bytes.NewReader(v.Data)); err != nil || version != v.Version {
+			t.Fatal(v.Name, version, err)
+		}
+		dataFile := filepath.Join(dir, v.Name+".hashive")
+		jsonFile := filepath.Join(dir, v.Name+".json")
+		if *updateVectors {
+			if err := os.WriteFile(dataFile, v.Data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(jsonFile, []byte(v.JSON), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		// The released vectors must not change, or readers in other
+		// languages will break.
+		if data, err := os.ReadFile(dataFile); err != nil || !bytes.Equal(data, v.Data) {
+			t.Fatalf("test vector %v changed: %v", v.Name, err)
+		}
+		if data, err := os.ReadFile(jsonFile); err != nil || string(data) != v.JSON {
+			t.Fatalf("test vector %v changed: %v", v.Name, err)
+		}
+	}
+}
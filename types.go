@@ -0,0 +1,75 @@
+package hashive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// TypeUsage is the number of values of a type in a database,
+// see [TypesUsed].
+type TypeUsage struct {
+	Type  string // The name of the type, such as "sortedObject".
+	Code  int    // The type code, see the value types of FORMAT.md.
+	Count int    // The number of values of the type.
+}
+
+// UnknownTypeError is returned by [TypesUsed] if a value of a type unknown
+// to this version is found, which can't be read by this version.
+type UnknownTypeError struct {
+	Code int // The type code.
+}
+
+func (err *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown type %v", err.Code)
+}
+
+// TypesUsed reads the whole database from r, which must be positioned at the
+// start of it, and returns the types of all the values in it, including the
+// header and side tables, in the order of type codes.
+// Operators can check the result against the types supported by the
+// readers deployed before rolling out a database written with new options.
+// An [*UnknownTypeError] is returned if a type unknown to this version is used.
+func TypesUsed(r io.ReadSeeker) (types []TypeUsage, err error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if _, err = ReadVersion(r); err != nil {
+		return
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if _, err = r.Seek(start+int64(len(impl.FileSignature)), io.SeekStart); err != nil {
+		return
+	}
+	br, err := impl.NewBufByteReadSeeker(r, defaultBufferSize)
+	if err != nil {
+		return
+	}
+	counts := make(map[impl.Type]int)
+	for pos := start + int64(len(impl.FileSignature)); pos < end; {
+		err = impl.Walk(br, impl.DefaultMaxDepth, func(path []string, t impl.Type, offset, size int64) error {
+			counts[t]++
+			return nil
+		})
+		if typeErr := (*impl.TypeError)(nil); errors.As(err, &typeErr) && !typeErr.Type().Known() {
+			return nil, &UnknownTypeError{int(typeErr.Type())}
+		} else if err != nil {
+			return
+		}
+		if pos, err = br.Seek(0, io.SeekCurrent); err != nil {
+			return
+		}
+	}
+	for t, count := range counts {
+		types = append(types, TypeUsage{t.String(), int(t), count})
+	}
+	slices.SortFunc(types, func(a, b TypeUsage) int { return a.Code - b.Code })
+	return
+}
@@ -0,0 +1,66 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestTypesUsed(t *testing.T) {
+	// typesOf returns the names of types used by data.
+	typesOf := func(data []byte) (names []string, usage []hashive.TypeUsage) {
+		usage, err := hashive.TypesUsed(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, u := range usage {
+			if u.Count <= 0 || i > 0 && usage[i-1].Code >= u.Code {
+				t.Fatal(usage)
+			}
+			names = append(names, u.Type)
+		}
+		return
+	}
+
+	value := map[string]any{"a": 1, "b": "x", "c": []any{true, nil}}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+	names, usage := typesOf(buf.Bytes())
+	for _, name := range []string{"null", "int", "bool", "string", "object"} {
+		if !slices.Contains(names, name) {
+			t.Fatal(name, usage)
+		}
+	}
+	if slices.Contains(names, "sortedObject") || slices.Contains(names, "chunked") {
+		t.Fatal(usage)
+	}
+
+	buf.Reset()
+	value["d"] = bytes.Repeat([]byte("0123456789"), 10)
+	if err := hashive.Write(&buf, value, hashive.WithSortedKeys(), hashive.WithChunkedBinary(50, 16)); err != nil {
+		t.Fatal(err)
+	}
+	names, usage = typesOf(buf.Bytes())
+	for _, name := range []string{"sortedObject", "chunked"} {
+		if !slices.Contains(names, name) {
+			t.Fatal(name, usage)
+		}
+	}
+
+	// Unknown type.
+	buf.Reset()
+	if err := hashive.Write(&buf, map[string]any{"a": nil}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[len(data)-1] = 0x0E // The null value.
+	var unknownErr *hashive.UnknownTypeError
+	if _, err := hashive.TypesUsed(bytes.NewReader(data)); !errors.As(err, &unknownErr) || unknownErr.Code != 0x0E {
+		t.Fatal(err)
+	}
+}
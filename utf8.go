@@ -0,0 +1,129 @@
+package hashive
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// UTF8Policy decides what to do with the strings which are not valid UTF-8,
+// including the keys of objects, so that the values can always be exported
+// as JSON. See [WithUTF8] and [WithReadUTF8].
+type UTF8Policy int
+
+const (
+	UTF8Keep    UTF8Policy = iota // Keep the strings as they are.
+	UTF8Reject                    // Fail with an [*InvalidUTF8Error].
+	UTF8Replace                   // Replace invalid byte sequences with [utf8.RuneError].
+)
+
+// WithUTF8 applies policy to the strings to write, after [WithTransform].
+// The default is [UTF8Keep]. Replacing the invalid key of a Go map with an
+// existing key fails with a [*DuplicateKeyError], and so does it in an
+// [OrderedObject] if the policy of [WithDuplicateKeys] is [DuplicateError].
+func WithUTF8(policy UTF8Policy) WriteOption {
+	return func(o *writeOptions) {
+		o.utf8 = policy
+	}
+}
+
+// WithReadUTF8 applies policy to the strings returned by [Hashive.Query] and
+// the other queries returning values of type any, before
+// [WithReadTransform]. The default is [UTF8Keep]. Replacing the invalid
+// key of an object with an existing key fails with a [*DuplicateKeyError].
+// Strings returned as []byte by [WithStringBytes] are not affected.
+func WithReadUTF8(policy UTF8Policy) Option {
+	return func(o *options) {
+		o.utf8 = policy
+	}
+}
+
+// InvalidUTF8Error is returned when a string is not valid UTF-8 and the
+// policy is [UTF8Reject].
+type InvalidUTF8Error struct {
+	Path []string // The path to the string.
+	Key  bool     // Whether the string is a key, the last element of Path.
+}
+
+func (err *InvalidUTF8Error) Error() string {
+	what := "string"
+	if err.Key {
+		what = "key"
+	}
+	return fmt.Sprintf("invalid UTF-8 %v at /%v", what, strings.Join(err.Path, "/"))
+}
+
+// checkUTF8 applies policy to the strings in v at path, and returns the
+// result. v is not modified.
+func checkUTF8(path []string, v any, policy UTF8Policy) (_ any, err error) {
+	// fix returns s with policy applied.
+	fix := func(s string, key bool) (string, error) {
+		if utf8.ValidString(s) {
+			return s, nil
+		}
+		if policy == UTF8Reject {
+			if key {
+				return s, &InvalidUTF8Error{append(slices.Clone(path), s), true}
+			}
+			return s, &InvalidUTF8Error{slices.Clone(path), false}
+		}
+		return strings.ToValidUTF8(s, string(utf8.RuneError)), nil
+	}
+	switch value := v.(type) {
+	case string:
+		return fix(value, false)
+	case OrderedObject:
+		obj := make(OrderedObject, len(value))
+		for i, entry := range value {
+			if entry.Key, err = fix(entry.Key, true); err != nil {
+				return
+			}
+			if entry.Value, err = checkUTF8(append(path, entry.Key), entry.Value, policy); err != nil {
+				return
+			}
+			obj[i] = entry
+		}
+		return obj, nil
+	case sideValue:
+		inner, _, _ := value.unwrap()
+		if inner, err = checkUTF8(path, inner, policy); err != nil {
+			return
+		}
+		return value.rewrap(inner), nil
+	case []any:
+		ary := make([]any, len(value))
+		for i, elem := range value {
+			if ary[i], err = checkUTF8(append(path, strconv.Itoa(i)), elem, policy); err != nil {
+				return
+			}
+		}
+		return ary, nil
+	case map[string]any:
+		obj := make(map[string]any, len(value))
+		for key, elem := range value {
+			fixed, err := fix(key, true)
+			if err != nil {
+				return nil, err
+			}
+			_, exists := value[fixed]
+			if _, ok := obj[fixed]; ok || fixed != key && exists {
+				return nil, &DuplicateKeyError{slices.Clone(path), fixed}
+			}
+			if obj[fixed], err = checkUTF8(append(path, fixed), elem, policy); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case []Interval:
+		intervals := slices.Clone(value)
+		for i := range intervals {
+			if intervals[i].Value, err = checkUTF8(append(path, strconv.Itoa(i)), intervals[i].Value, policy); err != nil {
+				return
+			}
+		}
+		return intervals, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,88 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWithUTF8(t *testing.T) {
+	value := map[string]any{
+		"ok":         "héllo",
+		"bad":        "a\xffb",
+		"list":       []any{"x\xc0"},
+		"bad\xfekey": 1,
+		"ordered":    hashive.OrderedObject{{Key: "k\xff", Value: "v"}},
+	}
+	want := map[string]any{
+		"ok":      "héllo",
+		"bad":     "a�b",
+		"list":    []any{"x�"},
+		"bad�key": int64(1),
+		"ordered": map[string]any{"k�": "v"},
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value, hashive.WithUTF8(hashive.UTF8Replace)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query(); err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatal(v, err)
+	}
+	if keys, err := h.OrderedKeys("ordered"); err != nil || !reflect.DeepEqual(keys, []string{"k�"}) {
+		t.Fatal(keys, err)
+	}
+
+	var utf8Err *hashive.InvalidUTF8Error
+	if err := hashive.Write(&buf, []any{"ok", "a\xff"}, hashive.WithUTF8(hashive.UTF8Reject)); !errors.As(err, &utf8Err) ||
+		!reflect.DeepEqual(utf8Err.Path, []string{"1"}) || utf8Err.Key {
+		t.Fatal(err)
+	}
+	if err := hashive.Write(&buf, map[string]any{"a": map[string]any{"\xff": 1}}, hashive.WithUTF8(hashive.UTF8Reject)); !errors.As(err, &utf8Err) ||
+		!reflect.DeepEqual(utf8Err.Path, []string{"a", "\xff"}) || !utf8Err.Key {
+		t.Fatal(err)
+	}
+	var dupErr *hashive.DuplicateKeyError
+	if err := hashive.Write(&buf, map[string]any{"\xff": 1, "�": 2}, hashive.WithUTF8(hashive.UTF8Replace)); !errors.As(err, &dupErr) {
+		t.Fatal(err)
+	}
+	// Kept by default.
+	if v, err := newHashive(t, value).Query("bad"); err != nil || v != "a\xffb" {
+		t.Fatal(v, err)
+	}
+}
+
+func TestWithReadUTF8(t *testing.T) {
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, map[string]any{"bad": "a\xffb", "obj": map[string]any{"k\xff": []any{"\xfe"}}}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithReadUTF8(hashive.UTF8Replace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("obj"); err != nil || !reflect.DeepEqual(v, map[string]any{"k�": []any{"�"}}) {
+		t.Fatal(v, err)
+	}
+	if v, err := h.QueryDefault(nil, "bad"); err != nil || v != "a�b" {
+		t.Fatal(v, err)
+	}
+
+	h, err = hashive.New(bytes.NewReader(buf.Bytes()), -1, hashive.WithReadUTF8(hashive.UTF8Reject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var utf8Err *hashive.InvalidUTF8Error
+	if _, err := h.Query("obj", "k\xff", "0"); !errors.As(err, &utf8Err) || !reflect.DeepEqual(utf8Err.Path, []string{"obj", "k\xff", "0"}) {
+		t.Fatal(err)
+	}
+	if _, err := h.Query(); !errors.As(err, &utf8Err) {
+		t.Fatal(err)
+	}
+}
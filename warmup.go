@@ -0,0 +1,59 @@
+package hashive
+
+import (
+	"context"
+	"io"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// Warmup reads the header of the database and the offset tables of the
+// root value and the side tables, and the heads of all the buckets of them
+// if buckets is true, so that the first queries after opening the database,
+// such as after a deployment, don't pay the penalty of a cold cache.
+// For the databases opened by [OpenMmap], the kernel is also advised to
+// read the whole mapping ahead where supported.
+// It returns ctx.Err() if ctx is done before finished.
+//
+// Warmup moves the read position of h as queries do, so it must not be
+// called concurrently with the queries of h. Warming a snapshot instead,
+// see [Hashive.Snapshot], caches the same storage.
+func (h *Hashive) Warmup(ctx context.Context, buckets bool) (err error) {
+	if h.mapped != nil {
+		if err = adviseWillNeed(h.mapped); err != nil {
+			return
+		}
+	}
+	if _, err = h.r.Seek(int64(len(impl.FileSignature)), io.SeekStart); err != nil {
+		return
+	}
+	if !h.legacy {
+		if err = impl.SkipValue(h.r); err != nil {
+			return
+		}
+	}
+	headerEnd, err := h.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if err = impl.Touch(ctx, h.r, 0, headerEnd); err != nil {
+		return
+	}
+	switch {
+	case h.obj != nil:
+		err = h.obj.Warmup(ctx, buckets)
+	case h.ary != nil:
+		err = h.ary.Warmup(ctx)
+	}
+	if err != nil {
+		return
+	}
+	for _, name := range sideTables {
+		if table := h.tables[name]; table != nil {
+			if err = table.Warmup(ctx, buckets); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package hashive_test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWarmup(t *testing.T) {
+	value := map[string]any{"meta": hashive.ValueWithMeta{Value: 1, Meta: map[string]any{"m": 1}}}
+	for i := range 1000 {
+		value["key"+strconv.Itoa(i)] = i
+	}
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, value); err != nil {
+		t.Fatal(err)
+	}
+	h, close, err := hashive.OpenMmap(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close()
+	for _, buckets := range []bool{false, true} {
+		if err := h.Warmup(context.Background(), buckets); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := h.Query("key999"); err != nil || v != int64(999) {
+			t.Fatal(v, err)
+		}
+		if _, meta, err := h.QueryWithMeta("meta"); err != nil || meta["m"] != int64(1) {
+			t.Fatal(meta, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.Warmup(ctx, true); err != context.Canceled {
+		t.Fatal(err)
+	}
+
+	// Array root of a file.
+	filename = filepath.Join(t.TempDir(), "array.hashive")
+	if err := hashive.WriteFile(filename, []any{1, "a", 2}); err != nil {
+		t.Fatal(err)
+	}
+	h, closeFile, err := hashive.Open(filename, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFile()
+	if err := h.Warmup(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Query("1"); err != nil || v != "a" {
+		t.Fatal(v, err)
+	}
+}
@@ -0,0 +1,144 @@
+package hashive
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/mkch/hashive/internal/impl"
+)
+
+// writeAtChunkSize is the size of sections written concurrently by [WriteAt].
+const writeAtChunkSize = 1 << 20
+
+// WriteAt is like [Write] but writes the database to w at offset off, such as
+// a regular file, and returns the number of bytes written. The values in
+// the root array or object are encoded concurrently, except with [WithDedup],
+// and the sections of the database are written concurrently.
+// The database written is equivalent to the one written by [Write].
+//
+// The signature of the database is written last, over a dirty one written
+// first, so that opening a database whose writing is interrupted, such as
+// by a crash, fails with [ErrPartiallyWritten]. If w has a Sync method,
+// such as [*os.File], it is called before and after the rest of the
+// database is written, so that the writes reach the storage in order.
+func WriteAt(w io.WriterAt, off int64, value any, opts ...WriteOption) (n int64, err error) {
+	data, err := writeAt(w, off, value, newWriteOptions(opts))
+	if err != nil {
+		return
+	}
+	return int64(len(data)), nil
+}
+
+// writeAt is [WriteAt] with options, and returns the database written.
+func writeAt(w io.WriterAt, off int64, value any, options *writeOptions) (data []byte, err error) {
+	signature, headerData, payload, err := encode(value, options, true)
+	if err != nil {
+		return
+	}
+	data = make([]byte, 0, len(signature)+headerData.Len()+payload.Len())
+	data = append(data, signature...)
+	data = append(data, headerData.Bytes()...)
+	data = append(data, payload.Bytes()...)
+	return data, writeDataAt(w, off, data)
+}
+
+// writeDataAt writes the encoded database data to w at offset off, the way
+// [WriteAt] does: the signature of data is written last, over a dirty one.
+func writeDataAt(w io.WriterAt, off int64, data []byte) (err error) {
+	flush := func() error { return nil }
+	if syncer, ok := w.(interface{ Sync() error }); ok {
+		flush = syncer.Sync
+	}
+	n := len(impl.FileSignatureDirty)
+	if _, err = w.WriteAt([]byte(impl.FileSignatureDirty), off); err != nil {
+		return
+	}
+	if err = flush(); err != nil {
+		return
+	}
+	rest := data[n:]
+	var wg sync.WaitGroup
+	errs := make([]error, (len(rest)+writeAtChunkSize-1)/writeAtChunkSize)
+	for i := range errs {
+		chunk := rest[i*writeAtChunkSize : min((i+1)*writeAtChunkSize, len(rest))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = w.WriteAt(chunk, off+int64(n+i*writeAtChunkSize))
+		}()
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			return
+		}
+	}
+	if err = flush(); err != nil {
+		return
+	}
+	_, err = w.WriteAt(data[:n], off)
+	return
+}
+
+// encodeChildren encodes the values in value concurrently, and returns
+// a copy of value whose values are replaced with the encoded [impl.Raw].
+// Values other than arrays and objects are returned as is.
+func encodeChildren(encoder *impl.Encoder, value any) (any, error) {
+	var elems []any
+	var keys []string
+	switch v := value.(type) {
+	case []any:
+		elems = v
+	case map[string]any:
+		keys = make([]string, 0, len(v))
+		elems = make([]any, 0, len(v))
+		for key, elem := range v {
+			keys = append(keys, key)
+			elems = append(elems, elem)
+		}
+	default:
+		return value, nil
+	}
+
+	encoded := make([]any, len(elems))
+	errs := make([]error, len(elems))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(elems)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				var buf bytes.Buffer
+				errs[i] = encoder.WriteValue(&buf, elems[i])
+				encoded[i] = impl.Raw(buf.Bytes())
+			}
+		}()
+	}
+	for i := range elems {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if keys != nil {
+			return nil, impl.EncodeErrorAt(err, keys[i])
+		}
+		return nil, impl.EncodeErrorAt(err, strconv.Itoa(i))
+	}
+
+	if keys == nil {
+		return encoded, nil
+	}
+	obj := make(map[string]any, len(keys))
+	for i, key := range keys {
+		obj[key] = encoded[i]
+	}
+	return obj, nil
+}
@@ -0,0 +1,119 @@
+package hashive_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mkch/hashive"
+)
+
+func TestWriteAt(t *testing.T) {
+	type Point struct{ X, Y int }
+	value := make(map[string]any)
+	for i := range 200 {
+		value[fmt.Sprint(i)] = map[string]any{"p": Point{i, i}, "list": []any{i, fmt.Sprint(i)}}
+	}
+	var buf bytes.Buffer
+	if err := hashive.Write(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	const off = 10
+	n, err := hashive.WriteAt(f, off, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatal(n, buf.Len())
+	}
+	h, err := hashive.NewSection(f, off, n, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 200 {
+		var p Point
+		if err := h.QueryGob(&p, fmt.Sprint(i), "p"); err != nil {
+			t.Fatal(err)
+		} else if p != (Point{i, i}) {
+			t.Fatal(p)
+		}
+		if v, err := h.Query(fmt.Sprint(i), "list"); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, []any{int64(i), fmt.Sprint(i)}) {
+			t.Fatal(v)
+		}
+	}
+}
+
+// failingWriterAt fails all writes after the first n.
+type failingWriterAt struct {
+	f *os.File
+	n int
+}
+
+func (w *failingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if w.n <= 0 {
+		return 0, errors.New("interrupted")
+	}
+	w.n--
+	return w.f.WriteAt(p, off)
+}
+
+func TestWriteAtInterrupted(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.hashive")
+	if err := hashive.WriteFile(filename, map[string]any{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, closeFile, err := hashive.Open(filename, -1); err != nil {
+		t.Fatal(err)
+	} else {
+		closeFile()
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Only the dirty signature is written.
+	if _, err := hashive.WriteAt(&failingWriterAt{f, 1}, 0, map[string]any{"a": 2}); err == nil {
+		t.Fatal("not interrupted")
+	}
+	if _, _, err := hashive.Open(filename, -1); !errors.Is(err, hashive.ErrPartiallyWritten) {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hashive.NewBytes(data); !errors.Is(err, hashive.ErrPartiallyWritten) {
+		t.Fatal(err)
+	}
+	if _, err := hashive.ReadVersion(bytes.NewReader(data)); !errors.Is(err, hashive.ErrPartiallyWritten) {
+		t.Fatal(err)
+	}
+
+	// Written completely.
+	if _, err := hashive.WriteAt(f, 0, map[string]any{"a": 2}); err != nil {
+		t.Fatal(err)
+	}
+	h, closeFile, err := hashive.Open(filename, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFile()
+	if v, err := h.Query("a"); err != nil || v != int64(2) {
+		t.Fatal(v, err)
+	}
+}